@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/plate/cli/internal/client"
+	"github.com/spf13/cobra"
+)
+
+// changesCmd represents the changes command
+var changesCmd = &cobra.Command{
+	Use:   "changes",
+	Short: "Show what the last deployment to an environment shipped",
+	Long: `Show the commits, authors and merged pull requests between the commit
+deployed to an environment before and the one deployed now.
+
+Changes are listed for deployments of a commit, such as those triggered by
+pushes to a branch. Pull requests are recognized by the merge and squash
+commit messages Gitea, GitHub and GitLab write.
+
+Examples:
+  # What went to production with the last deployment
+  plate changes --env production
+
+  # Release notes for one service of a monorepo
+  plate changes --env production --service api --markdown > RELEASE.md`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		env, _ := cmd.Flags().GetString("env")
+		service, _ := cmd.Flags().GetString("service")
+		markdown, _ := cmd.Flags().GetBool("markdown")
+
+		deployable, err := registeredDeployable(service)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		apiClient := client.NewAPIClient()
+		if markdown {
+			notes, err := apiClient.GetChangesMarkdown(deployable.Service.ProjectID, env)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error getting changes: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Print(notes)
+			return
+		}
+
+		changelog, err := apiClient.GetChanges(deployable.Service.ProjectID, env)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error getting changes: %v\n", err)
+			os.Exit(1)
+		}
+		printChangelog(changelog)
+	},
+}
+
+// printChangelog prints the merged pull requests and commits of a
+// changelog, newest first.
+func printChangelog(changelog *client.Changelog) {
+	switch {
+	case changelog.From == "":
+		fmt.Printf("%s in %s: %d commits up to %s, the first commit deployed there\n",
+			changelog.Project, changelog.Environment, len(changelog.Commits), shortSHA(changelog.To))
+	case len(changelog.Commits) == 0:
+		fmt.Printf("%s in %s: %s was redeployed with no new commits\n", changelog.Project, changelog.Environment, shortSHA(changelog.To))
+		return
+	default:
+		fmt.Printf("%s in %s: %d commits from %s to %s\n",
+			changelog.Project, changelog.Environment, len(changelog.Commits), shortSHA(changelog.From), shortSHA(changelog.To))
+	}
+
+	if len(changelog.PullRequests) > 0 {
+		fmt.Println("\nPull requests:")
+		for _, pr := range changelog.PullRequests {
+			fmt.Printf("  %s %s\n", pr.Reference, pr.Title)
+		}
+	}
+
+	fmt.Println("\nCommits:")
+	for _, commit := range changelog.Commits {
+		subject, _, _ := strings.Cut(strings.TrimSpace(commit.Message), "\n")
+		fmt.Printf("  %s %s (%s)\n", shortSHA(commit.SHA), subject, commit.Author)
+	}
+
+	if len(changelog.Authors) > 0 {
+		fmt.Printf("\nAuthors: %s\n", strings.Join(changelog.Authors, ", "))
+	}
+	if changelog.Truncated {
+		fmt.Printf("\nOnly the latest commits are listed; earlier changes since %s are missing.\n", shortSHA(changelog.From))
+	}
+}
+
+// shortSHA abbreviates a commit SHA as Git does.
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+func init() {
+	rootCmd.AddCommand(changesCmd)
+
+	changesCmd.Flags().StringP("env", "e", "production", "Environment whose last deployment to show")
+	changesCmd.Flags().StringP("service", "s", "", "Service of a monorepo to show the changes of")
+	changesCmd.Flags().Bool("markdown", false, "Print Markdown release notes")
+}
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/plate/cli/internal/project"
+	"github.com/spf13/cobra"
+)
+
+// configCmd groups the commands working on .plate/config.yaml
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Validate and migrate your project configuration",
+	Long: `Work with the .plate/config.yaml file written by plate import.
+
+The file carries an apiVersion. Configurations written by older versions of
+plate are migrated automatically when read; use plate config migrate to
+rewrite them in the current format.`,
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate [path]",
+	Short: "Check .plate/config.yaml for errors",
+	Long: `Check .plate/config.yaml against the configuration schema.
+
+Unknown fields, invalid values and missing required fields are reported with
+the line they appear on.
+
+Examples:
+  # Validate the project in the current directory
+  plate config validate
+
+  # Validate another project
+  plate config validate /path/to/my-app`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		projectPath := "."
+		if len(args) > 0 {
+			projectPath = args[0]
+		}
+		configPath := project.ConfigPath(projectPath)
+
+		parsed := parseConfigFile(configPath)
+		if parsed.Migrated() {
+			fmt.Printf("%s uses an older format (%s), run plate config migrate to upgrade it to %s\n",
+				configPath, configVersionName(parsed.Version), project.APIVersion)
+		}
+		fmt.Printf("%s is valid\n", configPath)
+	},
+}
+
+var configMigrateCmd = &cobra.Command{
+	Use:   "migrate [path]",
+	Short: "Upgrade .plate/config.yaml to the current format",
+	Long: `Rewrite .plate/config.yaml in the current configuration format, keeping
+comments and the order of fields.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		projectPath := "."
+		if len(args) > 0 {
+			projectPath = args[0]
+		}
+		configPath := project.ConfigPath(projectPath)
+
+		parsed := parseConfigFile(configPath)
+		if !parsed.Migrated() {
+			fmt.Printf("%s is already at %s\n", configPath, project.APIVersion)
+			return
+		}
+
+		data, err := project.EncodeDocument(parsed.Document)
+		if err == nil {
+			err = os.WriteFile(configPath, data, 0644)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", configPath, err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Migrated %s from %s to %s\n", configPath, configVersionName(parsed.Version), project.APIVersion)
+	},
+}
+
+var configSchemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON Schema of .plate/config.yaml",
+	Long: `Print the JSON Schema of the current configuration format, e.g. for editor
+validation and completion:
+
+  plate config schema > plate-config.schema.json`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Print(project.Schema)
+	},
+}
+
+// parseConfigFile reads and validates a configuration file, exiting with the
+// problems found if it is invalid.
+func parseConfigFile(configPath string) *project.ParsedConfig {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	parsed, err := project.ParseConfig(data)
+	if err != nil {
+		var configErrs project.ConfigErrors
+		if errors.As(err, &configErrs) {
+			for _, configErr := range configErrs {
+				fmt.Fprintf(os.Stderr, "%s:%s\n", configPath, formatConfigError(configErr))
+			}
+		} else {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", configPath, err)
+		}
+		os.Exit(1)
+	}
+	return parsed
+}
+
+// formatConfigError renders an error in the file:line: form editors link to.
+func formatConfigError(err project.ConfigError) string {
+	message := err.Message
+	if err.Path != "" {
+		message = err.Path + ": " + message
+	}
+	if err.Line > 0 {
+		return fmt.Sprintf("%d: %s", err.Line, message)
+	}
+	return " " + message
+}
+
+func configVersionName(version string) string {
+	if version == "" {
+		return "unversioned"
+	}
+	return version
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(configMigrateCmd)
+	configCmd.AddCommand(configSchemaCmd)
+}
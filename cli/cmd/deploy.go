@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/plate/cli/internal/client"
+	"github.com/plate/cli/internal/project"
 	"github.com/spf13/cobra"
 )
 
@@ -17,6 +18,7 @@ var deployCmd = &cobra.Command{
 This command will:
 - Package your application code
 - Deploy to the target environment (development, staging, or production)
+- Release every service of a monorepo as its own project
 - Provide real-time deployment feedback
 - Generate a live URL for your application
 
@@ -28,26 +30,74 @@ Examples:
   plate deploy --env production
   
   # Deploy specific version
-  plate deploy --env staging --version v1.2.0`,
+  plate deploy --env staging --version v1.2.0
+
+  # Deploy a single service of a monorepo
+  plate deploy --service api`,
 	Run: func(cmd *cobra.Command, args []string) {
 		env, _ := cmd.Flags().GetString("env")
 		watch, _ := cmd.Flags().GetBool("watch")
+		version, _ := cmd.Flags().GetString("version")
+		service, _ := cmd.Flags().GetString("service")
+
+		config, err := project.LoadConfig(".")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading project configuration: %v\n", err)
+			os.Exit(1)
+		}
 
+		deployables := config.Deployables()
+		if service != "" {
+			if _, ok := config.Services[service]; !ok {
+				fmt.Fprintf(os.Stderr, "Error: service %q is not defined in %s\n", service, project.ConfigPath("."))
+				os.Exit(1)
+			}
+			for _, deployable := range deployables {
+				if deployable.ProjectName == config.Name+"-"+service {
+					deployables = []project.Deployable{deployable}
+					break
+				}
+			}
+		}
+
+		var stream *client.EventStream
 		client := client.NewAPIClient()
-		
+
+		// Subscribe before deploying, so that no event is missed
+		if watch {
+			projects := make([]string, 0, len(deployables))
+			for _, deployable := range deployables {
+				projects = append(projects, deployable.ProjectName)
+			}
+			stream, err = client.OpenEvents(projects, env)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error watching deployment: %v\n", err)
+				os.Exit(1)
+			}
+			defer stream.Close()
+		}
+
 		fmt.Printf("Deploying to environment: %s\n", env)
-		
-		if err := client.Deploy(env); err != nil {
-			fmt.Fprintf(os.Stderr, "Error deploying project: %v\n", err)
-			os.Exit(1)
+
+		deployments := map[uint]string{}
+		for _, deployable := range deployables {
+			fmt.Printf("Deploying %s...\n", deployable.ProjectName)
+			deployment, err := client.Deploy(deployable.ProjectName, env, version)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error deploying %s: %v\n", deployable.ProjectName, err)
+				os.Exit(1)
+			}
+			deployments[deployment.ID] = deployable.ProjectName
 		}
 
 		if watch {
 			fmt.Println("Watching deployment status...")
-			if err := client.WatchDeployment(env); err != nil {
-				fmt.Fprintf(os.Stderr, "Error watching deployment: %v\n", err)
+			if err := client.WatchDeployments(stream, deployments); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 				os.Exit(1)
 			}
+			fmt.Println("Deployment completed successfully!")
+			return
 		}
 
 		fmt.Println("Deployment initiated successfully!")
@@ -59,4 +109,6 @@ func init() {
 
 	deployCmd.Flags().StringP("env", "e", "development", "Environment to deploy to")
 	deployCmd.Flags().BoolP("watch", "w", false, "Watch deployment progress")
+	deployCmd.Flags().String("version", "", "Version to deploy (default: generated)")
+	deployCmd.Flags().StringP("service", "s", "", "Deploy only this service of a monorepo")
 }
\ No newline at end of file
@@ -0,0 +1,414 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/plate/cli/internal/client"
+	"github.com/plate/cli/internal/project"
+	"github.com/spf13/cobra"
+)
+
+// envCmd groups the commands moving environment variables in and out of
+// .env files
+var envCmd = &cobra.Command{
+	Use:   "env",
+	Short: "Import and export environment variables as .env files",
+	Long: `Import and export the environment variables of your project as .env files.
+
+Variables whose names suggest a credential, such as *_KEY, *_TOKEN,
+*_SECRET or *PASSWORD*, are stored as secrets (see plate secrets) instead
+of plain env_vars. Variables that already exist keep their kind; use
+--secret to turn a plain variable into a secret.
+
+Without --env, variables apply to every environment.`,
+}
+
+var envImportCmd = &cobra.Command{
+	Use:   "import FILE",
+	Short: "Import variables from a .env file",
+	Long: `Import variables from a .env file into the project on the server and into
+.plate/config.yaml. The changes against the current server state are shown
+before anything is applied; secret values are never printed.
+
+Variables in the file are added or updated. Variables missing from the file
+are left alone.
+
+Examples:
+  # Review and import variables for staging
+  plate env import .env --env staging
+
+  # Only show what would change
+  plate env import .env.production --env production --dry-run
+
+  # Override the secret detection
+  plate env import .env --secret SENTRY_DSN --plain CACHE_KEY --yes`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		env, _ := cmd.Flags().GetString("env")
+		service, _ := cmd.Flags().GetString("service")
+		forceSecret, _ := cmd.Flags().GetStringSlice("secret")
+		forcePlain, _ := cmd.Flags().GetStringSlice("plain")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		yes, _ := cmd.Flags().GetBool("yes")
+
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		vars, err := project.ParseDotenv(data)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", args[0], err)
+			os.Exit(1)
+		}
+		if len(vars) == 0 {
+			fmt.Printf("%s has no variables\n", args[0])
+			return
+		}
+
+		deployable, err := registeredDeployable(service)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		apiClient := client.NewAPIClient()
+		state, err := loadEnvState(apiClient, deployable.Service.ProjectID, env)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		classify := func(name string) bool {
+			switch {
+			case contains(forcePlain, name):
+				return false
+			case contains(forceSecret, name), state.secrets[name]:
+				return true
+			case strings.HasPrefix(vars[name], "vault://"):
+				// A reference to an external secret is not a secret itself
+				return false
+			}
+			// Detection only applies to new variables, so importing an
+			// exported file changes nothing
+			if _, ok := state.effective[name]; ok {
+				return false
+			}
+			return project.LooksSecret(name)
+		}
+		changes := state.diff(vars, classify)
+
+		fmt.Printf("Importing %s into %s (%s)\n", args[0], deployable.ProjectName, secretScope(env))
+		if changes.empty() {
+			fmt.Println("Everything is up to date.")
+			return
+		}
+		changes.print()
+
+		if dryRun {
+			return
+		}
+		if !yes && !confirm("Apply these changes?") {
+			fmt.Fprintln(os.Stderr, "Nothing was changed. Run with --yes to apply without confirmation.")
+			os.Exit(1)
+		}
+
+		// Store secrets first, so a variable moved out of the plain ones is
+		// never missing
+		for _, name := range changes.secretNames() {
+			if err := apiClient.SetSecret(deployable.Service.ProjectID, env, name, vars[name]); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		if len(changes.plain) > 0 || len(changes.moved) > 0 {
+			state.project.setEnvVars(env, changes.plain, changes.moved)
+			if _, err := apiClient.UpdateProject(state.project.Project); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			config, err := project.LoadConfig(".")
+			if err == nil {
+				config.SetEnvVars(deployable, env, changes.plain, changes.moved)
+				err = project.SaveConfig(".", config)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error updating %s: %v\n", project.ConfigPath("."), err)
+				os.Exit(1)
+			}
+		}
+
+		fmt.Println("Imported. Redeploy to apply the change.")
+	},
+}
+
+var envExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export variables as a .env file",
+	Long: `Export the variables the project is deployed with as a .env file. With
+--env, the variables overridden for that environment are merged in.
+
+Secret values are never exported: secrets are listed as comments, and
+importing the file again leaves them untouched.
+
+Examples:
+  plate env export --env staging > .env.staging
+  plate env export --output .env`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		env, _ := cmd.Flags().GetString("env")
+		service, _ := cmd.Flags().GetString("service")
+		output, _ := cmd.Flags().GetString("output")
+
+		deployable, err := registeredDeployable(service)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		state, err := loadEnvState(client.NewAPIClient(), deployable.Service.ProjectID, env)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Plain variables shadowed by a secret are not in effect
+		plain := map[string]string{}
+		for name, value := range state.effective {
+			if !state.secrets[name] {
+				plain[name] = value
+			}
+		}
+
+		var b strings.Builder
+		fmt.Fprintf(&b, "# %s (%s), exported by plate env export\n", deployable.ProjectName, secretScope(env))
+		b.WriteString(project.FormatDotenv(plain))
+		if len(state.secrets) > 0 {
+			b.WriteString("\n# Secrets are not exported; set them with plate secrets set\n")
+			for _, name := range sortedKeys(state.secrets) {
+				fmt.Fprintf(&b, "# %s=\n", name)
+			}
+		}
+
+		if output == "" {
+			fmt.Print(b.String())
+			return
+		}
+		if err := os.WriteFile(output, []byte(b.String()), 0600); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Exported %d variables to %s\n", len(plain), output)
+	},
+}
+
+// envState is what the server deploys a project with in one environment,
+// or in every environment.
+type envState struct {
+	project *serverProject
+	// own are the plain variables set at this level, effective the ones in
+	// effect including the base variables an environment inherits
+	own, effective map[string]string
+	// secrets are the names of the secrets in effect; scoped the ones set
+	// at this level
+	secrets, scoped map[string]bool
+}
+
+func loadEnvState(apiClient *client.APIClient, projectID uint, env string) (*envState, error) {
+	registered, err := apiClient.GetProject(projectID)
+	if err != nil {
+		return nil, err
+	}
+	server := &serverProject{Project: registered}
+
+	base, err := server.baseEnvVars()
+	if err != nil {
+		return nil, err
+	}
+
+	state := &envState{project: server, own: base, effective: base, secrets: map[string]bool{}, scoped: map[string]bool{}}
+	if env != "" {
+		state.own = registered.Environments[env].EnvVars
+		state.effective = make(map[string]string, len(base)+len(state.own))
+		for name, value := range base {
+			state.effective[name] = value
+		}
+		for name, value := range state.own {
+			state.effective[name] = value
+		}
+	}
+
+	secrets, err := apiClient.ListSecrets(projectID, env)
+	if err != nil {
+		return nil, err
+	}
+	for _, secret := range secrets {
+		// Without an environment, the list includes secrets of single
+		// environments, which are not in effect everywhere
+		if env == "" && secret.Environment != "" {
+			continue
+		}
+		state.secrets[secret.Name] = true
+		if secret.Environment == env {
+			state.scoped[secret.Name] = true
+		}
+	}
+
+	return state, nil
+}
+
+// envChanges are the changes an import makes.
+type envChanges struct {
+	plain     map[string]string
+	oldValues map[string]string // previous values of changed plain variables
+	// newSecrets and replacedSecrets are the secrets created and
+	// overwritten; moved the plain variables that become secrets
+	newSecrets, replacedSecrets, moved []string
+	// shadowed are plain variables a secret of the same name overrides
+	shadowed  []string
+	unchanged int
+}
+
+// diff compares imported variables with the state, where isSecret decides
+// which variables are stored as secrets.
+func (s *envState) diff(vars map[string]string, isSecret func(string) bool) *envChanges {
+	changes := &envChanges{plain: map[string]string{}, oldValues: map[string]string{}}
+
+	for _, name := range sortedKeys(vars) {
+		value := vars[name]
+		if isSecret(name) {
+			if s.scoped[name] {
+				changes.replacedSecrets = append(changes.replacedSecrets, name)
+			} else {
+				changes.newSecrets = append(changes.newSecrets, name)
+			}
+			if _, ok := s.own[name]; ok {
+				changes.moved = append(changes.moved, name)
+			}
+			continue
+		}
+
+		if s.secrets[name] {
+			changes.shadowed = append(changes.shadowed, name)
+		}
+		old, ok := s.effective[name]
+		switch {
+		case ok && old == value:
+			changes.unchanged++
+		case ok:
+			changes.oldValues[name] = old
+			changes.plain[name] = value
+		default:
+			changes.plain[name] = value
+		}
+	}
+
+	return changes
+}
+
+func (c *envChanges) empty() bool {
+	return len(c.plain) == 0 && len(c.newSecrets) == 0 && len(c.replacedSecrets) == 0
+}
+
+func (c *envChanges) secretNames() []string {
+	return append(append([]string{}, c.newSecrets...), c.replacedSecrets...)
+}
+
+// print shows the changes like a diff, without revealing secret values.
+func (c *envChanges) print() {
+	for _, name := range sortedKeys(c.plain) {
+		if old, ok := c.oldValues[name]; ok {
+			fmt.Printf("  - %s=%s\n", name, old)
+		}
+		fmt.Printf("  + %s=%s\n", name, c.plain[name])
+	}
+	for _, name := range c.moved {
+		fmt.Printf("  - %s (plain variable, moved to secrets)\n", name)
+	}
+	for _, name := range c.newSecrets {
+		fmt.Printf("  + %s (secret)\n", name)
+	}
+	for _, name := range c.replacedSecrets {
+		fmt.Printf("  ~ %s (secret, value replaced)\n", name)
+	}
+	for _, name := range c.shadowed {
+		fmt.Printf("  ! %s is also a secret, which takes precedence\n", name)
+	}
+	if c.unchanged > 0 {
+		fmt.Printf("  %d unchanged\n", c.unchanged)
+	}
+}
+
+// serverProject is a registered project whose base env vars are kept as a
+// JSON object.
+type serverProject struct {
+	*client.Project
+}
+
+func (p *serverProject) baseEnvVars() (map[string]string, error) {
+	envVars := map[string]string{}
+	if p.EnvVars == "" {
+		return envVars, nil
+	}
+	if err := json.Unmarshal([]byte(p.EnvVars), &envVars); err != nil {
+		return nil, fmt.Errorf("project %s has invalid env vars: %w", p.Name, err)
+	}
+	return envVars, nil
+}
+
+// setEnvVars sets and removes env vars in one environment or, with an
+// empty environment, in the base settings.
+func (p *serverProject) setEnvVars(env string, set map[string]string, remove []string) {
+	if env == "" {
+		base, _ := p.baseEnvVars()
+		data, _ := json.Marshal(project.ApplyEnvVars(base, set, remove))
+		p.EnvVars = string(data)
+		return
+	}
+
+	if p.Environments == nil {
+		p.Environments = map[string]project.EnvironmentConfig{}
+	}
+	override := p.Environments[env]
+	override.EnvVars = project.ApplyEnvVars(override.EnvVars, set, remove)
+	p.Environments[env] = override
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func init() {
+	rootCmd.AddCommand(envCmd)
+	envCmd.AddCommand(envImportCmd, envExportCmd)
+
+	envCmd.PersistentFlags().StringP("env", "e", "", "Environment the variables apply to (default: every environment)")
+	envCmd.PersistentFlags().StringP("service", "s", "", "Service of a monorepo the variables belong to")
+
+	envImportCmd.Flags().StringSlice("secret", nil, "Store these variables as secrets")
+	envImportCmd.Flags().StringSlice("plain", nil, "Store these variables as plain env vars")
+	envImportCmd.Flags().Bool("dry-run", false, "Only show the changes")
+	envImportCmd.Flags().BoolP("yes", "y", false, "Apply without asking for confirmation")
+
+	envExportCmd.Flags().StringP("output", "o", "", "File to write (default: standard output)")
+}
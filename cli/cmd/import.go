@@ -1,25 +1,37 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 
+	"github.com/plate/cli/internal/client"
 	"github.com/plate/cli/internal/project"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 // importCmd represents the import command
 var importCmd = &cobra.Command{
-	Use:   "import [path]",
+	Use:   "import [path|git-url]",
 	Short: "Import your project into the Plate platform",
 	Long: `Import your project into the Plate platform for easy deployment.
 
 Plate automatically detects your project type and sets up everything needed:
-- Detects runtime (Node.js, Python, Go, Java, etc.)
-- Configures build and start commands
+- Detects runtime (Node.js, Python, Go, Java, etc.) and framework
+  (Next.js, Express, Django, FastAPI, Flask, Spring Boot, Rails, Laravel, ...)
+- Configures build and start commands and the listen port
+- Finds every service of a monorepo (apps/*, services/*, npm/pnpm
+  workspaces, go.work, Cargo workspaces)
+- Generates a Dockerfile and .dockerignore from templates, which an
+  org-level template directory can override
+- Pins the runtime version from .nvmrc, engines.node, .python-version,
+  go.mod, rust-toolchain.toml, .java-version and similar files
 - Sets up environment variables
 - Prepares deployment configuration
+- Registers the project with the Plate service and records its ID in
+  .plate/config.yaml, optionally pushing the code to a platform repository
 
 After importing, you can deploy to any environment with a single command.
 
@@ -31,7 +43,22 @@ Examples:
   plate import /path/to/my-app
   
   # Import with custom name
-  plate import --name my-awesome-app`,
+  plate import --name my-awesome-app
+
+  # Import a remote repository without a local checkout
+  plate import https://git.example.com/org/app.git --ref main
+
+  # Show which files led to each detection decision
+  plate import --explain
+
+  # Regenerate the Dockerfile and show what changed
+  plate import --regenerate-dockerfile
+
+  # Register the project and push the code to a new platform repository
+  plate import --push
+
+  # Only write the local configuration
+  plate import --skip-register`,
 	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		projectPath := "."
@@ -39,33 +66,232 @@ Examples:
 			projectPath = args[0]
 		}
 
+		// Get flags
+		name, _ := cmd.Flags().GetString("name")
+		env, _ := cmd.Flags().GetString("env")
+		runtime, _ := cmd.Flags().GetString("runtime")
+		explain, _ := cmd.Flags().GetBool("explain")
+		ref, _ := cmd.Flags().GetString("ref")
+		push, _ := cmd.Flags().GetBool("push")
+		skipRegister, _ := cmd.Flags().GetBool("skip-register")
+
+		// A remote repository is cloned to a temporary directory and
+		// registered as the project's source
+		var source *client.Repository
+		cleanup := func() {}
+		if project.IsRemoteURL(projectPath) {
+			if push || skipRegister {
+				fmt.Fprintf(os.Stderr, "Error: --push and --skip-register cannot be used when importing from a remote repository\n")
+				os.Exit(1)
+			}
+
+			fmt.Printf("Cloning %s...\n", projectPath)
+			checkout, err := project.Clone(projectPath, ref, project.GitAuth{
+				Username: viper.GetString("git_username"),
+				Password: viper.GetString("git_token"),
+			})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error cloning repository: %v\n", err)
+				os.Exit(1)
+			}
+			cleanup = func() { os.RemoveAll(checkout.Dir) }
+			defer cleanup()
+
+			// The project deploys pushes to its branch, which a tag is not
+			if checkout.Tag != "" {
+				fmt.Fprintf(os.Stderr, "Error: %s is a tag; pass the branch the project should track with --ref\n", checkout.Tag)
+				cleanup()
+				os.Exit(1)
+			}
+			fmt.Printf("Checked out %s at %.12s\n", checkout.Branch, checkout.Commit)
+
+			source = &client.Repository{Name: project.RepositoryName(projectPath), CloneURL: projectPath, Branch: checkout.Branch}
+			if name == "" {
+				name = project.RepositoryName(projectPath)
+			}
+			projectPath = checkout.Dir
+		} else if ref != "" {
+			fmt.Fprintf(os.Stderr, "Error: --ref is only supported when importing from a Git URL\n")
+			os.Exit(1)
+		}
+
 		// Get absolute path
 		absPath, err := filepath.Abs(projectPath)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error getting absolute path: %v\n", err)
+			cleanup()
 			os.Exit(1)
 		}
 
-		// Get flags
-		name, _ := cmd.Flags().GetString("name")
-		env, _ := cmd.Flags().GetString("env")
-		runtime, _ := cmd.Flags().GetString("runtime")
-
 		// Import project
 		importer := project.NewImporter()
-		if err := importer.Import(absPath, name, env, runtime); err != nil {
+		importer.Explain = explain
+		importer.Single, _ = cmd.Flags().GetBool("single")
+		importer.DefaultVersions = viper.GetStringMapString("runtime_versions")
+		importer.RegenerateDockerfile, _ = cmd.Flags().GetBool("regenerate-dockerfile")
+		importer.Dockerfiles.TemplatesDir = viper.GetString("templates_dir")
+		config, err := importer.Import(absPath, name, env, runtime)
+		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error importing project: %v\n", err)
+			cleanup()
 			os.Exit(1)
 		}
 
+		if source != nil {
+			if err := registerProject(absPath, config, false, source); err != nil {
+				fmt.Fprintf(os.Stderr, "Error registering project: %v\n", err)
+				cleanup()
+				os.Exit(1)
+			}
+			// Generated files only existed in the clone
+			if files, err := project.ChangedFiles(absPath); err != nil {
+				fmt.Printf("Warning: Failed to check for generated files: %v\n", err)
+			} else if len(files) > 0 {
+				fmt.Printf("Warning: These files were generated in the temporary clone and are discarded; to keep them, import a local checkout and commit them:\n")
+				for _, file := range files {
+					fmt.Printf("  %s\n", file)
+				}
+			}
+			fmt.Printf("Successfully imported project from %s (%s)\n", source.CloneURL, source.Branch)
+			return
+		}
+
+		if !skipRegister {
+			if err := registerProject(absPath, config, push, nil); err != nil {
+				fmt.Fprintf(os.Stderr, "Error registering project: %v\n", err)
+				fmt.Fprintf(os.Stderr, "The local configuration was written; rerun plate import once the service is reachable, or pass --skip-register.\n")
+				cleanup()
+				os.Exit(1)
+			}
+		}
+
 		fmt.Printf("Successfully imported project from %s\n", absPath)
 	},
 }
 
+// registerProject registers every deployable of an imported project with
+// the service, optionally creating its Gitea repository and pushing the code,
+// and writes the assigned project IDs back into the local config. A project
+// imported from a remote repository is registered with that repository as
+// its source instead, and has no local config to update.
+func registerProject(projectPath string, config *project.ProjectConfig, push bool, source *client.Repository) error {
+	apiClient := client.NewAPIClient()
+
+	repo := source
+	for _, deployable := range config.Deployables() {
+		envVars, err := json.Marshal(deployable.Service.EnvVars)
+		if err != nil {
+			return err
+		}
+
+		registered, created, err := apiClient.RegisterProject(&client.Project{
+			Name:           deployable.ProjectName,
+			Runtime:        deployable.Service.Runtime,
+			Framework:      deployable.Service.Framework,
+			RuntimeVersion: deployable.Service.RuntimeVersion,
+			BuildContext:   deployable.Service.Path,
+			BuildCmd:       deployable.Service.BuildCmd,
+			StartCmd:       deployable.Service.StartCmd,
+			Port:           deployable.Service.Port,
+			EnvVars:        string(envVars),
+			Replicas:       deployable.Service.Replicas,
+			Size:           deployable.Service.Size,
+			Domain:         deployable.Service.Domain,
+			Resources:      valueOr(deployable.Service.Resources),
+			HealthCheck:    valueOr(deployable.Service.HealthCheck),
+			Autoscaling:    valueOr(deployable.Service.Autoscaling),
+			Environments:   deployable.Service.Environments,
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", deployable.ProjectName, err)
+		}
+
+		config.SetProjectID(deployable, registered.ID)
+		if created {
+			fmt.Printf("Registered project %s (ID %d)\n", registered.Name, registered.ID)
+		} else {
+			fmt.Printf("Updated project %s (ID %d)\n", registered.Name, registered.ID)
+		}
+
+		if !push && source == nil {
+			continue
+		}
+
+		// Services of a monorepo share one repository, named after the
+		// monorepo rather than the service it is created for
+		if repo == nil {
+			repo, err = apiClient.SetRepository(registered.ID, config.Name, "", "")
+		} else {
+			_, err = apiClient.SetRepository(registered.ID, repo.Name, repo.CloneURL, repo.Branch)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", deployable.ProjectName, err)
+		}
+	}
+
+	if source != nil {
+		return nil
+	}
+
+	if err := project.SaveConfig(projectPath, config); err != nil {
+		return fmt.Errorf("failed to write project IDs: %w", err)
+	}
+
+	if repo != nil {
+		auth := project.GitAuth{
+			Username: viper.GetString("git_username"),
+			Password: viper.GetString("git_token"),
+		}
+		if auth.Password == "" {
+			auth.Password = viper.GetString("token")
+		}
+
+		fmt.Printf("Pushing to %s (%s)...\n", repo.CloneURL, repo.Branch)
+		pushed, err := project.Push(projectPath, repo.CloneURL, repo.Branch, project.GeneratedFiles(config), auth)
+		if err != nil {
+			return err
+		}
+		if len(pushed.Committed) > 0 {
+			fmt.Printf("Committed the generated files:\n")
+			for _, file := range pushed.Committed {
+				fmt.Printf("  %s\n", file)
+			}
+		}
+		fmt.Printf("Pushed %s at %.12s to %s (%s)\n", pushed.Branch, pushed.Commit, repo.CloneURL, repo.Branch)
+		if len(pushed.Uncommitted) > 0 {
+			fmt.Printf("Warning: These changes are not committed and were not pushed:\n")
+			for _, file := range pushed.Uncommitted {
+				fmt.Printf("  %s\n", file)
+			}
+		}
+	}
+
+	return nil
+}
+
+// valueOr dereferences an optional setting, returning the zero value if it
+// is not set.
+func valueOr[T any](value *T) T {
+	if value == nil {
+		var zero T
+		return zero
+	}
+	return *value
+}
+
 func init() {
 	rootCmd.AddCommand(importCmd)
 
 	importCmd.Flags().StringP("name", "n", "", "Project name (default: directory name)")
 	importCmd.Flags().StringP("env", "e", "development", "Environment name")
 	importCmd.Flags().StringP("runtime", "r", "", "Runtime type (auto-detect if not specified)")
-}
\ No newline at end of file
+	importCmd.Flags().Bool("explain", false, "Show the evidence behind each detection decision")
+	importCmd.Flags().Bool("single", false, "Import as a single project without scanning for monorepo services")
+	importCmd.Flags().Bool("regenerate-dockerfile", false, "Replace an existing Dockerfile and .dockerignore, showing the diff")
+	importCmd.Flags().String("templates-dir", "", "Directory with org-level Dockerfile templates overriding the defaults")
+	importCmd.Flags().Bool("skip-register", false, "Only write local files without registering the project with the service")
+	importCmd.Flags().String("ref", "", "Branch to import and track when importing from a Git URL (default: the remote's default branch)")
+	importCmd.Flags().Bool("push", false, "Create the project's Git repository on the platform and push the code to it")
+
+	viper.BindPFlag("templates_dir", importCmd.Flags().Lookup("templates-dir"))
+}
@@ -1,17 +1,7 @@
 package cmd
-// rootCmd represents the base command when called without any subcommands
-var rootCmd = &cobra.Command{
-	Use:   "plate",
-	Short: "Developer portal CLI for seamless app deployment",
-	Long: `Plate is a developer-friendly CLI tool that simplifies application deployment.
 
-Focus on your code while Plate handles the deployment complexity:
-- Import your projects with automatic runtime detection
-- Deploy to multiple environments with a single command
-- Monitor your applications across development, staging, and production
-- Get instant feedback on deployment status and application health
-
-No infrastructure knowledge required - just build, deploy, and ship!`,mt"
+import (
+	"fmt"
 	"os"
 
 	"github.com/spf13/cobra"
@@ -23,16 +13,16 @@ var cfgFile string
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "plate",
-	Short: "Plate CLI - Deploy your code to Kubernetes with ease",
-	Long: `Plate is a CLI tool that allows you to import your projects 
-and deploy them to Kubernetes clusters using GitOps workflows.
+	Short: "Developer portal CLI for seamless app deployment",
+	Long: `Plate is a developer-friendly CLI tool that simplifies application deployment.
+
+Focus on your code while Plate handles the deployment complexity:
+- Import your projects with automatic runtime detection
+- Deploy to multiple environments with a single command
+- Monitor your applications across development, staging, and production
+- Get instant feedback on deployment status and application health
 
-Features:
-- Import existing projects
-- Deploy to Kubernetes
-- GitOps integration with ArgoCD
-- Helm chart management
-- Environment management`,
+No infrastructure knowledge required - just build, deploy, and ship!`,
 	Version: "1.0.0",
 }
 
@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/plate/cli/internal/client"
+	"github.com/plate/cli/internal/project"
+	"github.com/spf13/cobra"
+)
+
+// scaleCmd represents the scale command
+var scaleCmd = &cobra.Command{
+	Use:   "scale [app]",
+	Short: "Change the replicas or size of an application",
+	Long: `Change how many instances of an application run in an environment, or
+switch it to a sizing preset that sets its CPU and memory requests and limits.
+
+Sizes are small, medium (the default), large and xlarge. A new size is
+checked against the environment's resource quota, stored with the project
+and rolled out to the running application. Inside a project directory new
+sizes and autoscaler bounds are also recorded in .plate/config.yaml, so the
+next plate import keeps them.
+
+Replicas of an app managed by an autoscaler are not changed manually, since
+the autoscaler would undo it. On a terminal, plate scale offers to raise
+the autoscaler's minimum instead; --adjust-autoscaler does so without asking.
+
+Without an app name, every service of the project in the current directory
+is scaled.
+
+Examples:
+  # Give the production deployment more resources
+  plate scale --env production --size large
+
+  # Run three instances of one service of a monorepo
+  plate scale --env staging --service api --replicas 3
+
+  # Scale an app by name
+  plate scale my-app --env development --replicas 0`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		env, _ := cmd.Flags().GetString("env")
+		size, _ := cmd.Flags().GetString("size")
+		service, _ := cmd.Flags().GetString("service")
+		adjustAutoscaler, _ := cmd.Flags().GetBool("adjust-autoscaler")
+
+		var replicas *int
+		if cmd.Flags().Changed("replicas") {
+			value, _ := cmd.Flags().GetInt("replicas")
+			replicas = &value
+		}
+
+		if replicas == nil && size == "" {
+			fmt.Fprintf(os.Stderr, "Error: --replicas or --size is required\n")
+			os.Exit(1)
+		}
+		if size != "" && !project.ValidSize(size) {
+			fmt.Fprintf(os.Stderr, "Error: unknown size %q, must be one of %s\n", size, strings.Join(project.Sizes, ", "))
+			os.Exit(1)
+		}
+
+		// The local config is optional when the app is named
+		config, err := project.LoadConfig(".")
+		if err != nil && len(args) == 0 {
+			fmt.Fprintf(os.Stderr, "Error loading project configuration: %v\n", err)
+			os.Exit(1)
+		}
+
+		var deployables []project.Deployable
+		if config != nil {
+			for _, deployable := range config.Deployables() {
+				if len(args) > 0 && deployable.ProjectName != args[0] {
+					continue
+				}
+				if service != "" && deployable.Key != service {
+					continue
+				}
+				deployables = append(deployables, deployable)
+			}
+		}
+
+		switch {
+		case len(args) > 0 && len(deployables) == 0:
+			deployables = []project.Deployable{{ProjectName: args[0]}}
+		case len(deployables) == 0:
+			fmt.Fprintf(os.Stderr, "Error: service %q is not defined in %s\n", service, project.ConfigPath("."))
+			os.Exit(1)
+		}
+
+		apiClient := client.NewAPIClient()
+		updated := false
+		for _, deployable := range deployables {
+			req := client.ScaleRequest{Replicas: replicas, Size: size, AdjustAutoscaler: adjustAutoscaler}
+			result, err := apiClient.ScaleApp(deployable.ProjectName, env, req)
+
+			// Offer to move the autoscaler's bounds instead of fighting it
+			var conflict *client.AutoscalerConflictError
+			if errors.As(err, &conflict) && *replicas > 0 && confirm(fmt.Sprintf(
+				"%s: %v.\nRaise the autoscaler's minimum to %d instead?", deployable.ProjectName, err, *replicas)) {
+				req.AdjustAutoscaler = true
+				result, err = apiClient.ScaleApp(deployable.ProjectName, env, req)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error scaling %s: %v\n", deployable.ProjectName, err)
+				if errors.As(err, &conflict) {
+					fmt.Fprintf(os.Stderr, "Pass --adjust-autoscaler to move the autoscaler's minimum to the requested replicas, or change autoscaling in %s.\n", project.ConfigPath("."))
+				}
+				os.Exit(1)
+			}
+
+			if replicas != nil {
+				fmt.Printf("Scaled %s in %s to %d replicas\n", deployable.ProjectName, env, *replicas)
+			}
+			if result.Autoscaler != nil {
+				fmt.Printf("Autoscaler %s now scales %s between %d and %d replicas\n",
+					result.Autoscaler.Name, deployable.ProjectName, result.Autoscaler.MinReplicas, result.Autoscaler.MaxReplicas)
+
+				if deployable.Service != nil {
+					config.SetEnvironmentAutoscaling(deployable, env, result.Autoscaler.MinReplicas, result.Autoscaler.MaxReplicas)
+					updated = true
+				}
+			}
+			if size != "" {
+				fmt.Printf("Resized %s in %s to %s (requests %s CPU, %s memory; limits %s CPU, %s memory)\n",
+					deployable.ProjectName, env, result.Size,
+					result.Resources.Requests.CPU, result.Resources.Requests.Memory,
+					result.Resources.Limits.CPU, result.Resources.Limits.Memory)
+
+				if deployable.Service != nil {
+					config.SetEnvironmentSize(deployable, env, size)
+					updated = true
+				}
+			}
+		}
+
+		if updated {
+			if err := project.SaveConfig(".", config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error updating project configuration: %v\n", err)
+				os.Exit(1)
+			}
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(scaleCmd)
+
+	scaleCmd.Flags().StringP("env", "e", "development", "Environment to scale in")
+	scaleCmd.Flags().Int("replicas", 0, "Number of instances to run")
+	scaleCmd.Flags().String("size", "", "Sizing preset: "+strings.Join(project.Sizes, ", "))
+	scaleCmd.Flags().StringP("service", "s", "", "Scale only this service of a monorepo")
+	scaleCmd.Flags().Bool("adjust-autoscaler", false, "Move the minimum of an autoscaler managing the app to --replicas instead of failing")
+}
+
+// confirm asks a yes/no question on the terminal. Without a terminal the
+// answer is no.
+func confirm(question string) bool {
+	if stat, err := os.Stdin.Stat(); err != nil || stat.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
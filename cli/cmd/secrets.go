@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/plate/cli/internal/client"
+	"github.com/plate/cli/internal/project"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// secretsCmd groups the commands managing project secrets
+var secretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "Manage the secrets of your project",
+	Long: `Manage secrets such as API keys and database passwords.
+
+Secrets are stored encrypted by the Plate service and handed to your
+application as environment variables through a Kubernetes Secret. Unlike
+env_vars in .plate/config.yaml, they never appear in the generated chart or
+in Git. A secret overrides an env var of the same name.
+
+Without --env, a secret applies to every environment; a secret set for one
+environment takes precedence there.`,
+}
+
+var secretsSetCmd = &cobra.Command{
+	Use:   "set NAME=VALUE... | NAME",
+	Short: "Create or replace secrets",
+	Long: `Create or replace secrets.
+
+To keep a value out of your shell history, pass only the name: the value is
+then read from standard input, or prompted for without echo on a terminal.
+
+Examples:
+  # Set a secret for every environment
+  plate secrets set STRIPE_KEY=sk_test_123
+
+  # Set a production-only secret, typing the value at a prompt
+  plate secrets set DATABASE_PASSWORD --env production
+
+  # Read the value from a file
+  plate secrets set TLS_KEY < key.pem`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		env, _ := cmd.Flags().GetString("env")
+		service, _ := cmd.Flags().GetString("service")
+
+		deployable, err := registeredDeployable(service)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		values := map[string]string{}
+		var names []string
+		for _, arg := range args {
+			name, value, ok := strings.Cut(arg, "=")
+			if !ok {
+				if len(args) > 1 {
+					fmt.Fprintf(os.Stderr, "Error: %s has no value; pass a single name to read its value from standard input\n", arg)
+					os.Exit(1)
+				}
+				value, err = readSecretValue(name)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error reading value of %s: %v\n", name, err)
+					os.Exit(1)
+				}
+			}
+			if _, seen := values[name]; !seen {
+				names = append(names, name)
+			}
+			values[name] = value
+		}
+
+		apiClient := client.NewAPIClient()
+		for _, name := range names {
+			if err := apiClient.SetSecret(deployable.Service.ProjectID, env, name, values[name]); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Set %s for %s in %s\n", name, deployable.ProjectName, secretScope(env))
+		}
+		fmt.Println("Redeploy to apply the change.")
+	},
+}
+
+var secretsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List secrets without their values",
+	Long: `List the names of the secrets of your project. Values are never shown.
+
+With --env, the secrets deployed to that environment are listed, including
+the ones set for every environment.
+
+Examples:
+  plate secrets list
+  plate secrets list --env production`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		env, _ := cmd.Flags().GetString("env")
+		service, _ := cmd.Flags().GetString("service")
+
+		deployable, err := registeredDeployable(service)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		secrets, err := client.NewAPIClient().ListSecrets(deployable.Service.ProjectID, env)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if len(secrets) == 0 {
+			fmt.Printf("%s has no secrets in %s\n", deployable.ProjectName, secretScope(env))
+			return
+		}
+
+		fmt.Printf("%-32s %-16s %s\n", "NAME", "ENVIRONMENT", "UPDATED")
+		for _, secret := range secrets {
+			scope := secret.Environment
+			if scope == "" {
+				scope = "(all)"
+			}
+			fmt.Printf("%-32s %-16s %s\n", secret.Name, scope, secret.UpdatedAt)
+		}
+	},
+}
+
+var secretsUnsetCmd = &cobra.Command{
+	Use:   "unset NAME...",
+	Short: "Remove secrets",
+	Long: `Remove secrets. Without --env, the secrets set for every environment are
+removed; with --env, the ones set for that environment.
+
+Examples:
+  plate secrets unset STRIPE_KEY
+  plate secrets unset DATABASE_PASSWORD --env production`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		env, _ := cmd.Flags().GetString("env")
+		service, _ := cmd.Flags().GetString("service")
+
+		deployable, err := registeredDeployable(service)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		apiClient := client.NewAPIClient()
+		for _, name := range args {
+			if err := apiClient.UnsetSecret(deployable.Service.ProjectID, env, name); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Removed %s from %s in %s\n", name, deployable.ProjectName, secretScope(env))
+		}
+		fmt.Println("Redeploy to apply the change.")
+	},
+}
+
+// registeredDeployable returns the deployable of the project in the current
+// directory that a command applies to, which must be registered with the
+// service. A monorepo needs the service to be named.
+func registeredDeployable(service string) (project.Deployable, error) {
+	config, err := project.LoadConfig(".")
+	if err != nil {
+		return project.Deployable{}, fmt.Errorf("loading project configuration: %w", err)
+	}
+
+	deployables := config.Deployables()
+	var selected *project.Deployable
+	for i := range deployables {
+		if deployables[i].Key == service {
+			selected = &deployables[i]
+			break
+		}
+	}
+
+	switch {
+	case selected == nil && service == "":
+		return project.Deployable{}, fmt.Errorf("%s has several services; choose one with --service", config.Name)
+	case selected == nil:
+		return project.Deployable{}, fmt.Errorf("service %q is not defined in %s", service, project.ConfigPath("."))
+	case selected.Service.ProjectID == 0:
+		return project.Deployable{}, fmt.Errorf("%s is not registered with the service; run plate import first", selected.ProjectName)
+	}
+
+	return *selected, nil
+}
+
+// readSecretValue reads a secret value from standard input, prompting for
+// it without echo on a terminal.
+func readSecretValue(name string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		fmt.Printf("Value of %s: ", name)
+		value, err := term.ReadPassword(fd)
+		fmt.Println()
+		return string(value), err
+	}
+
+	value, err := io.ReadAll(bufio.NewReader(os.Stdin))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(string(value), "\n"), "\r"), nil
+}
+
+func secretScope(env string) string {
+	if env == "" {
+		return "every environment"
+	}
+	return env
+}
+
+func init() {
+	rootCmd.AddCommand(secretsCmd)
+	secretsCmd.AddCommand(secretsSetCmd, secretsListCmd, secretsUnsetCmd)
+
+	secretsCmd.PersistentFlags().StringP("env", "e", "", "Environment the secrets apply to (default: every environment)")
+	secretsCmd.PersistentFlags().StringP("service", "s", "", "Service of a monorepo the secrets belong to")
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"sort"
 
 	"github.com/plate/cli/internal/client"
 	"github.com/spf13/cobra"
@@ -10,7 +11,7 @@ import (
 
 // statusCmd represents the status command
 var statusCmd = &cobra.Command{
-	Use:   "status",
+	Use:   "status [app]",
 	Short: "Check your application deployment status",
 	Long: `Check the status of your deployed applications across all environments.
 
@@ -30,12 +31,26 @@ Examples:
   plate status --detailed
   
   # Check specific environment
-  plate status --env production`,
+  plate status --env production
+
+  # Show the replicas and autoscaler of one application
+  plate status my-app`,
+	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		env, _ := cmd.Flags().GetString("env")
 		detailed, _ := cmd.Flags().GetBool("detailed")
 
 		client := client.NewAPIClient()
+
+		if len(args) > 0 {
+			statuses, err := client.GetAppStatus(args[0])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error getting status: %v\n", err)
+				os.Exit(1)
+			}
+			printAppStatus(args[0], env, statuses)
+			return
+		}
 		
 		status, err := client.GetStatus(env, detailed)
 		if err != nil {
@@ -47,6 +62,43 @@ Examples:
 	},
 }
 
+// printAppStatus prints the replicas of an app per namespace, next to what
+// its autoscaler wants and why.
+func printAppStatus(name, env string, statuses map[string]client.AppStatus) {
+	namespaces := make([]string, 0, len(statuses))
+	for namespace := range statuses {
+		if env == "" || namespace == env {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	sort.Strings(namespaces)
+
+	if len(namespaces) == 0 {
+		fmt.Printf("%s is not running in %s\n", name, env)
+		return
+	}
+
+	for _, namespace := range namespaces {
+		status := statuses[namespace]
+		fmt.Printf("%s in %s\n", name, namespace)
+		fmt.Printf("  Replicas: %d desired, %d current, %d updated, %d ready\n",
+			status.DesiredReplicas, status.CurrentReplicas, status.UpdatedReplicas, status.ReadyReplicas)
+
+		if autoscaler := status.Autoscaler; autoscaler != nil {
+			fmt.Printf("  Autoscaler %s: %d-%d replicas, %d current, %d desired\n",
+				autoscaler.Name, autoscaler.MinReplicas, autoscaler.MaxReplicas,
+				autoscaler.CurrentReplicas, autoscaler.DesiredReplicas)
+			for _, metric := range autoscaler.Metrics {
+				current := metric.Current
+				if current == "" {
+					current = "unknown"
+				}
+				fmt.Printf("    %s: %s (target %s)\n", metric.Name, current, metric.Target)
+			}
+		}
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(statusCmd)
 
@@ -47,22 +47,34 @@ func NewAPIClient() *APIClient {
 	}
 }
 
-func (c *APIClient) Deploy(environment string) error {
+// Deployment is a deployment started on the server.
+type Deployment struct {
+	ID      uint   `json:"id"`
+	Version string `json:"version"`
+	Status  string `json:"status"`
+	URL     string `json:"url"`
+}
+
+func (c *APIClient) Deploy(project, environment, version string) (*Deployment, error) {
+	var deployment Deployment
 	resp, err := c.client.R().
 		SetBody(map[string]string{
+			"project":     project,
 			"environment": environment,
+			"version":     version,
 		}).
+		SetResult(&deployment).
 		Post(c.baseURL + "/api/v1/deploy")
 
 	if err != nil {
-		return fmt.Errorf("failed to make deploy request: %w", err)
+		return nil, fmt.Errorf("failed to make deploy request: %w", err)
 	}
 
-	if resp.StatusCode() != 200 {
-		return fmt.Errorf("deploy request failed with status %d: %s", resp.StatusCode(), resp.String())
+	if resp.StatusCode() != 200 && resp.StatusCode() != 201 {
+		return nil, fmt.Errorf("deploy request failed with status %d: %s", resp.StatusCode(), resp.String())
 	}
 
-	return nil
+	return &deployment, nil
 }
 
 func (c *APIClient) GetStatus(environment string, detailed bool) (string, error) {
@@ -92,27 +104,6 @@ func (c *APIClient) GetStatus(environment string, detailed bool) (string, error)
 	return resp.String(), nil
 }
 
-func (c *APIClient) WatchDeployment(environment string) error {
-	// Simple polling implementation
-	for {
-		status, err := c.GetStatus(environment, false)
-		if err != nil {
-			return err
-		}
-
-		fmt.Printf("[%s] %s\n", time.Now().Format("15:04:05"), status)
-
-		// Check if deployment is complete
-		if c.isDeploymentComplete(status) {
-			break
-		}
-
-		time.Sleep(5 * time.Second)
-	}
-
-	return nil
-}
-
 func (c *APIClient) formatDetailedStatus(data []byte) (string, error) {
 	var statuses []DeploymentStatus
 	if err := json.Unmarshal(data, &statuses); err != nil {
@@ -139,8 +130,3 @@ func (c *APIClient) formatDetailedStatus(data []byte) (string, error) {
 
 	return result, nil
 }
-
-func (c *APIClient) isDeploymentComplete(status string) bool {
-	// Simple heuristic - check if status contains "running" or "failed"
-	return fmt.Sprintf("%s", status) != "" // Placeholder logic
-}
\ No newline at end of file
@@ -0,0 +1,64 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Changelog lists what the last deployment of a project in an environment
+// shipped since the commit deployed before it.
+type Changelog struct {
+	DeploymentID uint   `json:"deployment_id"`
+	Project      string `json:"project"`
+	Environment  string `json:"environment"`
+	From         string `json:"from"` // empty if no commit was deployed before
+	To           string `json:"to"`
+	Commits      []struct {
+		SHA       string    `json:"sha"`
+		Message   string    `json:"message"`
+		Author    string    `json:"author"`
+		Timestamp time.Time `json:"timestamp"`
+	} `json:"commits"`
+	Authors      []string `json:"authors"`
+	PullRequests []struct {
+		Number    int    `json:"number"`
+		Reference string `json:"reference"` // #12, or !12 on GitLab
+		Title     string `json:"title"`
+	} `json:"pull_requests"`
+	Truncated bool `json:"truncated"`
+}
+
+// GetChanges returns the changelog of the last successful deployment of a
+// project in an environment.
+func (c *APIClient) GetChanges(projectID uint, environment string) (*Changelog, error) {
+	var changelog Changelog
+	resp, err := c.client.R().
+		SetQueryParam("env", environment).
+		SetResult(&changelog).
+		Get(fmt.Sprintf("%s/api/v1/projects/%d/changes", c.baseURL, projectID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get changes: %w", err)
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return nil, fmt.Errorf("changes request failed with status %d: %s", resp.StatusCode(), resp.String())
+	}
+
+	return &changelog, nil
+}
+
+// GetChangesMarkdown returns the same changelog as GetChanges, rendered as
+// Markdown release notes by the server.
+func (c *APIClient) GetChangesMarkdown(projectID uint, environment string) (string, error) {
+	resp, err := c.client.R().
+		SetQueryParams(map[string]string{"env": environment, "format": "markdown"}).
+		Get(fmt.Sprintf("%s/api/v1/projects/%d/changes", c.baseURL, projectID))
+	if err != nil {
+		return "", fmt.Errorf("failed to get changes: %w", err)
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return "", fmt.Errorf("changes request failed with status %d: %s", resp.StatusCode(), resp.String())
+	}
+
+	return resp.String(), nil
+}
@@ -0,0 +1,248 @@
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event is an event of a deployment or app, as streamed by the server.
+type Event struct {
+	ID           uint64          `json:"id"`
+	Type         string          `json:"type"`
+	Time         time.Time       `json:"time"`
+	Project      string          `json:"project"`
+	Environment  string          `json:"environment"`
+	DeploymentID uint            `json:"deployment_id"`
+	Data         json.RawMessage `json:"data"`
+}
+
+// Event types streamed by the server
+const (
+	EventDeploymentStatus = "deployment.status"
+	EventDeploymentLog    = "deployment.log"
+	EventPodPhase         = "pod.phase"
+	EventAppScaled        = "app.scaled"
+)
+
+// EventStream reads the Server-Sent Events of GET /api/v1/events. It
+// reconnects after the last event received when the connection drops.
+type EventStream struct {
+	client *APIClient
+	query  url.Values
+	lastID uint64
+
+	body   io.ReadCloser
+	reader *bufio.Reader
+}
+
+// maxEventReconnects is how many times in a row an event stream tries to
+// reconnect before giving up.
+const maxEventReconnects = 5
+
+// OpenEvents subscribes to the events of the given projects in an
+// environment. Events published after it returns are not missed.
+func (c *APIClient) OpenEvents(projects []string, environment string) (*EventStream, error) {
+	query := url.Values{}
+	query.Set("project", strings.Join(projects, ","))
+	query.Set("environment", environment)
+
+	stream := &EventStream{client: c, query: query}
+	if err := stream.connect(); err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
+func (s *EventStream) connect() error {
+	query := url.Values{}
+	for key, values := range s.query {
+		query[key] = values
+	}
+	if s.lastID > 0 {
+		query.Set("last_event_id", strconv.FormatUint(s.lastID, 10))
+	}
+
+	req, err := http.NewRequest(http.MethodGet, s.client.baseURL+"/api/v1/events?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if s.client.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.client.token)
+	}
+
+	// Unlike API requests, the stream has no timeout
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to open event stream: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		return fmt.Errorf("event stream request failed with status %d: %s", resp.StatusCode, message)
+	}
+
+	s.body = resp.Body
+	s.reader = bufio.NewReader(resp.Body)
+	return nil
+}
+
+// Next returns the next event, waiting for it.
+func (s *EventStream) Next() (*Event, error) {
+	failures := 0
+	for {
+		event, err := s.read()
+		if err == nil {
+			failures = 0
+			if event == nil {
+				continue
+			}
+			s.lastID = event.ID
+			return event, nil
+		}
+
+		// Reconnect after the last event received
+		s.body.Close()
+		for {
+			failures++
+			if failures > maxEventReconnects {
+				return nil, fmt.Errorf("event stream ended: %w", err)
+			}
+			time.Sleep(time.Duration(failures) * time.Second)
+			if err = s.connect(); err == nil {
+				break
+			}
+		}
+	}
+}
+
+// read reads one message of the stream, which is nil for messages without
+// data such as keep-alive comments.
+func (s *EventStream) read() (*Event, error) {
+	var data []string
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		if value, ok := strings.CutPrefix(line, "data:"); ok {
+			data = append(data, strings.TrimPrefix(value, " "))
+		}
+		// id and event are repeated in the data; comments and retry are
+		// not needed
+	}
+
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var event Event
+	if err := json.Unmarshal([]byte(strings.Join(data, "\n")), &event); err != nil {
+		return nil, fmt.Errorf("invalid event: %w", err)
+	}
+	return &event, nil
+}
+
+// Close ends the stream.
+func (s *EventStream) Close() error {
+	return s.body.Close()
+}
+
+// DeploymentFailedError is returned by WatchDeployments when deployments
+// failed.
+type DeploymentFailedError struct {
+	Failed []string // projects whose deployment failed
+}
+
+func (e *DeploymentFailedError) Error() string {
+	return "deployment failed: " + strings.Join(e.Failed, ", ")
+}
+
+// WatchDeployments prints the progress of deployments from an event stream
+// opened before they were started, until each succeeded or failed.
+func (c *APIClient) WatchDeployments(stream *EventStream, deployments map[uint]string) error {
+	pending := map[uint]bool{}
+	for id := range deployments {
+		pending[id] = true
+	}
+	var failed []string
+
+	for len(pending) > 0 {
+		event, err := stream.Next()
+		if err != nil {
+			return err
+		}
+		timestamp := event.Time.Local().Format("15:04:05")
+
+		switch event.Type {
+		case EventDeploymentStatus:
+			if !pending[event.DeploymentID] {
+				continue
+			}
+			var status struct {
+				Status string `json:"status"`
+				URL    string `json:"url"`
+				Error  string `json:"error"`
+			}
+			json.Unmarshal(event.Data, &status)
+
+			switch status.Status {
+			case "success":
+				delete(pending, event.DeploymentID)
+				if status.URL != "" {
+					fmt.Printf("[%s] %s: deployed to %s\n", timestamp, event.Project, status.URL)
+				} else {
+					fmt.Printf("[%s] %s: deployed\n", timestamp, event.Project)
+				}
+			case "failed":
+				delete(pending, event.DeploymentID)
+				failed = append(failed, event.Project)
+				fmt.Printf("[%s] %s: failed: %s\n", timestamp, event.Project, status.Error)
+			default:
+				fmt.Printf("[%s] %s: %s\n", timestamp, event.Project, status.Status)
+			}
+
+		case EventDeploymentLog:
+			if !pending[event.DeploymentID] {
+				continue
+			}
+			var log struct {
+				Level   string `json:"level"`
+				Message string `json:"message"`
+			}
+			json.Unmarshal(event.Data, &log)
+			if log.Level == "error" {
+				continue // repeated by the failed status
+			}
+			fmt.Printf("[%s] %s: %s\n", timestamp, event.Project, log.Message)
+
+		case EventPodPhase:
+			var pod struct {
+				Pod    string `json:"pod"`
+				Phase  string `json:"phase"`
+				Reason string `json:"reason"`
+			}
+			json.Unmarshal(event.Data, &pod)
+			if pod.Reason != "" {
+				fmt.Printf("[%s] %s: pod %s %s (%s)\n", timestamp, event.Project, pod.Pod, pod.Phase, pod.Reason)
+			} else {
+				fmt.Printf("[%s] %s: pod %s %s\n", timestamp, event.Project, pod.Pod, pod.Phase)
+			}
+		}
+	}
+
+	if len(failed) > 0 {
+		return &DeploymentFailedError{Failed: failed}
+	}
+	return nil
+}
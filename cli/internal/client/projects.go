@@ -0,0 +1,253 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/plate/cli/internal/project"
+)
+
+// Project is a project as registered with the Plate service.
+type Project struct {
+	ID             uint   `json:"id,omitempty"`
+	Name           string `json:"name"`
+	Description    string `json:"description,omitempty"`
+	Repository     string `json:"repository,omitempty"`
+	Runtime        string `json:"runtime"`
+	Framework      string `json:"framework,omitempty"`
+	RuntimeVersion string `json:"runtime_version,omitempty"`
+	BuildContext   string `json:"build_context,omitempty"`
+	BuildCmd       string `json:"build_cmd,omitempty"`
+	StartCmd       string `json:"start_cmd,omitempty"`
+	Port           int    `json:"port,omitempty"`
+	EnvVars        string `json:"env_vars,omitempty"` // JSON object
+	Replicas       int    `json:"replicas,omitempty"`
+	Size           string `json:"size,omitempty"`
+	Domain         string `json:"domain,omitempty"`
+
+	Resources    project.Resources                    `json:"resources"`
+	HealthCheck  project.HealthCheck                  `json:"health_check"`
+	Autoscaling  project.Autoscaling                  `json:"autoscaling"`
+	Environments map[string]project.EnvironmentConfig `json:"environments,omitempty"`
+}
+
+// Repository is the Git repository a project is built from.
+type Repository struct {
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	CloneURL string `json:"clone_url"`
+	Branch   string `json:"branch"`
+}
+
+// RegisterProject creates the project on the server, or updates it if a
+// project with the same name already exists. It reports whether the project
+// was created.
+func (c *APIClient) RegisterProject(project *Project) (*Project, bool, error) {
+	var created Project
+	var conflict struct {
+		Project Project `json:"project"`
+	}
+
+	resp, err := c.client.R().
+		SetBody(project).
+		SetResult(&created).
+		Post(c.baseURL + "/api/v1/projects")
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to register project: %w", err)
+	}
+
+	switch resp.StatusCode() {
+	case http.StatusCreated, http.StatusOK:
+		return &created, true, nil
+	case http.StatusConflict:
+		if err := json.Unmarshal(resp.Body(), &conflict); err != nil || conflict.Project.ID == 0 {
+			return nil, false, fmt.Errorf("project %s already exists but the server did not return it", project.Name)
+		}
+	default:
+		return nil, false, fmt.Errorf("register request failed with status %d: %s", resp.StatusCode(), resp.String())
+	}
+
+	// Keep what the server knows that the local config does not
+	update := *project
+	update.ID = conflict.Project.ID
+	if update.Repository == "" {
+		update.Repository = conflict.Project.Repository
+	}
+	if update.Description == "" {
+		update.Description = conflict.Project.Description
+	}
+
+	updated, err := c.UpdateProject(&update)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return updated, false, nil
+}
+
+// GetProject returns a registered project.
+func (c *APIClient) GetProject(id uint) (*Project, error) {
+	var project Project
+	resp, err := c.client.R().
+		SetResult(&project).
+		Get(fmt.Sprintf("%s/api/v1/projects/%d", c.baseURL, id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project: %w", err)
+	}
+
+	if resp.StatusCode() != http.StatusOK {
+		return nil, fmt.Errorf("project request failed with status %d: %s", resp.StatusCode(), resp.String())
+	}
+
+	return &project, nil
+}
+
+// UpdateProject replaces the settings of a registered project.
+func (c *APIClient) UpdateProject(project *Project) (*Project, error) {
+	var updated Project
+	resp, err := c.client.R().
+		SetBody(project).
+		SetResult(&updated).
+		Put(fmt.Sprintf("%s/api/v1/projects/%d", c.baseURL, project.ID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to update project: %w", err)
+	}
+
+	if resp.StatusCode() != http.StatusOK {
+		return nil, fmt.Errorf("update request failed with status %d: %s", resp.StatusCode(), resp.String())
+	}
+
+	return &updated, nil
+}
+
+// SetRepository records the repository a project is built from. With an
+// empty clone URL the server creates a repository in its Gitea organization,
+// called name or, if that is empty, after the project.
+func (c *APIClient) SetRepository(projectID uint, name, cloneURL, branch string) (*Repository, error) {
+	var repo Repository
+	resp, err := c.client.R().
+		SetBody(map[string]string{
+			"name":      name,
+			"clone_url": cloneURL,
+			"branch":    branch,
+		}).
+		SetResult(&repo).
+		Post(fmt.Sprintf("%s/api/v1/projects/%d/repository", c.baseURL, projectID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to set repository: %w", err)
+	}
+
+	if resp.StatusCode() != http.StatusOK {
+		return nil, fmt.Errorf("repository request failed with status %d: %s", resp.StatusCode(), resp.String())
+	}
+
+	return &repo, nil
+}
+
+// ScaleRequest changes the replicas and/or sizing preset of an app. A nil
+// Replicas or empty Size leaves that setting unchanged.
+type ScaleRequest struct {
+	Replicas *int   `json:"replicas,omitempty"`
+	Size     string `json:"size,omitempty"`
+	// AdjustAutoscaler moves the minimum of an autoscaler managing the app
+	// to Replicas instead of rejecting the scale.
+	AdjustAutoscaler bool `json:"adjust_autoscaler,omitempty"`
+}
+
+// ScaleResult is what the server reports after scaling an app.
+type ScaleResult struct {
+	Replicas   *int              `json:"replicas,omitempty"`
+	Size       string            `json:"size,omitempty"`
+	Resources  project.Resources `json:"resources"`
+	Autoscaler *Autoscaler       `json:"autoscaler,omitempty"`
+}
+
+// Autoscaler is the HorizontalPodAutoscaler managing an app's replicas.
+type Autoscaler struct {
+	Name        string `json:"name"`
+	MinReplicas int    `json:"min_replicas"`
+	MaxReplicas int    `json:"max_replicas"`
+}
+
+// AutoscalerConflictError is returned when a manual scale is rejected
+// because an autoscaler manages the app's replicas.
+type AutoscalerConflictError struct {
+	Message    string
+	Autoscaler Autoscaler
+}
+
+func (e *AutoscalerConflictError) Error() string {
+	return e.Message
+}
+
+// ScaleApp scales an app in one environment.
+func (c *APIClient) ScaleApp(name, environment string, req ScaleRequest) (*ScaleResult, error) {
+	var result ScaleResult
+	resp, err := c.client.R().
+		SetQueryParam("env", environment).
+		SetBody(req).
+		SetResult(&result).
+		Post(fmt.Sprintf("%s/api/v1/apps/%s/scale", c.baseURL, name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to scale %s: %w", name, err)
+	}
+
+	switch resp.StatusCode() {
+	case http.StatusOK:
+		return &result, nil
+	case http.StatusConflict:
+		var conflict struct {
+			Error      string     `json:"error"`
+			Autoscaler Autoscaler `json:"autoscaler"`
+		}
+		if err := json.Unmarshal(resp.Body(), &conflict); err == nil && conflict.Autoscaler.Name != "" {
+			return nil, &AutoscalerConflictError{Message: conflict.Error, Autoscaler: conflict.Autoscaler}
+		}
+	}
+
+	return nil, fmt.Errorf("scale request failed with status %d: %s", resp.StatusCode(), resp.String())
+}
+
+// AppStatus is the state of an app's deployment in one namespace.
+type AppStatus struct {
+	Name              string            `json:"name"`
+	Namespace         string            `json:"namespace"`
+	DesiredReplicas   int               `json:"desired_replicas"`
+	CurrentReplicas   int               `json:"current_replicas"`
+	UpdatedReplicas   int               `json:"updated_replicas"`
+	ReadyReplicas     int               `json:"ready_replicas"`
+	AvailableReplicas int               `json:"available_replicas"`
+	Autoscaler        *AutoscalerStatus `json:"autoscaler,omitempty"`
+}
+
+// AutoscalerStatus is the state of the autoscaler managing an app.
+type AutoscalerStatus struct {
+	Autoscaler
+	CurrentReplicas int `json:"current_replicas"`
+	DesiredReplicas int `json:"desired_replicas"`
+	Metrics         []struct {
+		Name    string `json:"name"`
+		Current string `json:"current"`
+		Target  string `json:"target"`
+	} `json:"metrics"`
+}
+
+// GetAppStatus returns the state of an app in every namespace it runs in.
+func (c *APIClient) GetAppStatus(name string) (map[string]AppStatus, error) {
+	var result struct {
+		Environments map[string]AppStatus `json:"environments"`
+	}
+	resp, err := c.client.R().
+		SetResult(&result).
+		Get(fmt.Sprintf("%s/api/v1/apps/%s/status", c.baseURL, name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status of %s: %w", name, err)
+	}
+
+	if resp.StatusCode() != http.StatusOK {
+		return nil, fmt.Errorf("status request failed with status %d: %s", resp.StatusCode(), resp.String())
+	}
+
+	return result.Environments, nil
+}
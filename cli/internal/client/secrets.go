@@ -0,0 +1,72 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Secret describes a secret of a project. The server never returns values.
+type Secret struct {
+	Name        string `json:"name"`
+	Environment string `json:"environment"` // empty for every environment
+	UpdatedAt   string `json:"updated_at"`
+}
+
+// ListSecrets returns the secrets of a project. With an environment, it
+// returns the secrets deployed there, including the ones for every
+// environment.
+func (c *APIClient) ListSecrets(projectID uint, environment string) ([]Secret, error) {
+	var secrets []Secret
+	req := c.client.R().SetResult(&secrets)
+	if environment != "" {
+		req.SetQueryParam("env", environment)
+	}
+
+	resp, err := req.Get(fmt.Sprintf("%s/api/v1/projects/%d/secrets", c.baseURL, projectID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return nil, fmt.Errorf("secrets request failed with status %d: %s", resp.StatusCode(), resp.String())
+	}
+
+	return secrets, nil
+}
+
+// SetSecret creates or replaces a secret of a project, for one environment
+// or, with an empty environment, for every environment.
+func (c *APIClient) SetSecret(projectID uint, environment, name, value string) error {
+	req := c.client.R().SetBody(map[string]string{"value": value})
+	if environment != "" {
+		req.SetQueryParam("env", environment)
+	}
+
+	resp, err := req.Put(fmt.Sprintf("%s/api/v1/projects/%d/secrets/%s", c.baseURL, projectID, url.PathEscape(name)))
+	if err != nil {
+		return fmt.Errorf("failed to set secret %s: %w", name, err)
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return fmt.Errorf("secret request failed with status %d: %s", resp.StatusCode(), resp.String())
+	}
+
+	return nil
+}
+
+// UnsetSecret removes a secret of a project.
+func (c *APIClient) UnsetSecret(projectID uint, environment, name string) error {
+	req := c.client.R()
+	if environment != "" {
+		req.SetQueryParam("env", environment)
+	}
+
+	resp, err := req.Delete(fmt.Sprintf("%s/api/v1/projects/%d/secrets/%s", c.baseURL, projectID, url.PathEscape(name)))
+	if err != nil {
+		return fmt.Errorf("failed to unset secret %s: %w", name, err)
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return fmt.Errorf("secret request failed with status %d: %s", resp.StatusCode(), resp.String())
+	}
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package project
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectConfig is the content of .plate/config.yaml. A single-service
+// project keeps its build settings inline; a monorepo lists them under
+// Services, keyed by service name.
+type ProjectConfig struct {
+	APIVersion    string `yaml:"apiVersion"`
+	Name          string `yaml:"name"`
+	Environment   string `yaml:"environment"`
+	ServiceConfig `yaml:",inline"`
+	Services      map[string]ServiceConfig `yaml:"services,omitempty"`
+}
+
+// ServiceConfig describes how a single deployable unit is built and run.
+type ServiceConfig struct {
+	// ProjectID is the ID the service assigned when the project was
+	// registered.
+	ProjectID uint `yaml:"project_id,omitempty"`
+	// Path is the build context relative to the repository root.
+	Path           string            `yaml:"path,omitempty"`
+	Runtime        string            `yaml:"runtime,omitempty"`
+	Framework      string            `yaml:"framework,omitempty"`
+	RuntimeVersion string            `yaml:"runtime_version,omitempty"`
+	BuildImage     string            `yaml:"build_image,omitempty"`
+	RunImage       string            `yaml:"run_image,omitempty"`
+	Port           int               `yaml:"port,omitempty"`
+	BuildCmd       string            `yaml:"build_cmd,omitempty"`
+	StartCmd       string            `yaml:"start_cmd,omitempty"`
+	EnvVars        map[string]string `yaml:"env_vars,omitempty"`
+	Replicas       int               `yaml:"replicas,omitempty"`
+	// Size is a sizing preset; Resources overrides single values of it.
+	Size      string     `yaml:"size,omitempty"`
+	Resources *Resources `yaml:"resources,omitempty"`
+	// Domain replaces the default <project>.<environment domain> host name.
+	Domain      string       `yaml:"domain,omitempty"`
+	HealthCheck *HealthCheck `yaml:"health_check,omitempty"`
+	Autoscaling *Autoscaling `yaml:"autoscaling,omitempty"`
+	// Environments overrides the settings above per environment name. In a
+	// monorepo, the top-level block applies to every service.
+	Environments map[string]EnvironmentConfig `yaml:"environments,omitempty"`
+}
+
+// Deployable is a service that is registered and released as its own
+// project on the server.
+type Deployable struct {
+	ProjectName string
+	// Key is the service's key in Services, empty for a single-service
+	// project.
+	Key     string
+	Service *ServiceConfig
+}
+
+// Deployables returns the services to release, in a stable order. Monorepo
+// services are named <project>-<service>.
+func (c *ProjectConfig) Deployables() []Deployable {
+	if len(c.Services) == 0 {
+		return []Deployable{{ProjectName: c.Name, Service: &c.ServiceConfig}}
+	}
+
+	names := make([]string, 0, len(c.Services))
+	for name := range c.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	deployables := make([]Deployable, 0, len(names))
+	for _, name := range names {
+		service := c.Services[name]
+		service.Environments = mergeEnvironments(c.Environments, service.Environments)
+		deployables = append(deployables, Deployable{
+			ProjectName: c.Name + "-" + name,
+			Key:         name,
+			Service:     &service,
+		})
+	}
+	return deployables
+}
+
+// SetProjectID records the registered project ID of a deployable.
+func (c *ProjectConfig) SetProjectID(deployable Deployable, id uint) {
+	if deployable.Key == "" {
+		c.ProjectID = id
+		return
+	}
+	service := c.Services[deployable.Key]
+	service.ProjectID = id
+	c.Services[deployable.Key] = service
+}
+
+// keepProjectIDs copies the registered project IDs of services that are
+// still present from a previously written configuration.
+func (c *ProjectConfig) keepProjectIDs(previous *ProjectConfig) {
+	if previous.Name != c.Name {
+		return
+	}
+	if len(c.Services) == 0 {
+		c.ProjectID = previous.ProjectID
+	}
+	for name, service := range c.Services {
+		if old, ok := previous.Services[name]; ok {
+			service.ProjectID = old.ProjectID
+			c.Services[name] = service
+		}
+	}
+}
+
+// ConfigPath returns the location of the project configuration file.
+func ConfigPath(projectPath string) string {
+	return filepath.Join(projectPath, ".plate", "config.yaml")
+}
+
+// LoadConfig reads .plate/config.yaml from a project directory.
+func LoadConfig(projectPath string) (*ProjectConfig, error) {
+	data, err := os.ReadFile(ConfigPath(projectPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no Plate project found in %s, run plate import first", projectPath)
+		}
+		return nil, err
+	}
+
+	parsed, err := ParseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s:\n%w", ConfigPath(projectPath), err)
+	}
+	return parsed.Config, nil
+}
+
+// SaveConfig writes .plate/config.yaml into a project directory.
+func SaveConfig(projectPath string, config *ProjectConfig) error {
+	if err := os.MkdirAll(filepath.Dir(ConfigPath(projectPath)), 0755); err != nil {
+		return fmt.Errorf("failed to create .plate directory: %w", err)
+	}
+
+	config.APIVersion = APIVersion
+
+	var doc yaml.Node
+	if err := doc.Encode(config); err != nil {
+		return err
+	}
+	data, err := EncodeDocument(&doc)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(ConfigPath(projectPath), data, 0644)
+}
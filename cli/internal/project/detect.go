@@ -0,0 +1,192 @@
+package project
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// Evidence records a single observation that led to a detection decision.
+type Evidence struct {
+	Field  string // services, runtime, framework, version, build, start, port or health
+	Source string // file the observation was made in
+	Reason string
+}
+
+// Detection is the outcome of running the detector chain against a project.
+type Detection struct {
+	Runtime   string
+	Framework string
+	BuildCmd  string
+	StartCmd  string
+	Port      int
+	Evidence  []Evidence
+	// Warnings are problems with the detected settings that need the
+	// user's attention, such as a missing dependency manifest.
+	Warnings []string
+}
+
+func (d *Detection) explain(field, source, format string, args ...interface{}) {
+	d.Evidence = append(d.Evidence, Evidence{
+		Field:  field,
+		Source: source,
+		Reason: fmt.Sprintf(format, args...),
+	})
+}
+
+func (d *Detection) warn(format string, args ...interface{}) {
+	d.Warnings = append(d.Warnings, fmt.Sprintf(format, args...))
+}
+
+// Detector recognises a single runtime ecosystem and the frameworks built on it.
+type Detector interface {
+	Runtime() string
+	Detect(dir string) (*Detection, bool)
+}
+
+// detectors is the prioritized detector chain. Ecosystems that commonly carry
+// a package.json for asset tooling (Rails, Laravel, Django) are checked before
+// Node.js so that the backend runtime wins.
+var detectors = []Detector{
+	goDetector{},
+	rustDetector{},
+	javaDetector{},
+	rubyDetector{},
+	phpDetector{},
+	pythonDetector{},
+	nodeDetector{},
+}
+
+// Detect runs the detector chain against dir. When runtime is set only the
+// matching detector is consulted; the first detector to match wins otherwise.
+func Detect(dir, runtime string) *Detection {
+	var result *Detection
+	for _, detector := range detectors {
+		if runtime != "" && detector.Runtime() != runtime {
+			continue
+		}
+
+		detection, ok := detector.Detect(dir)
+		if !ok {
+			continue
+		}
+
+		if result == nil {
+			result = detection
+			continue
+		}
+
+		result.explain("runtime", "", "%s project files also present but %s takes priority", detection.Runtime, result.Runtime)
+	}
+
+	if result == nil {
+		result = &Detection{Runtime: runtime}
+		if runtime == "" {
+			result.Runtime = "generic"
+			result.explain("runtime", "", "no known project files found")
+		} else {
+			result.explain("runtime", "", "runtime %s requested explicitly", runtime)
+		}
+		result.Port = defaultPort(result.Runtime)
+		result.explain("port", "", "default port for %s", result.Runtime)
+	}
+
+	return result
+}
+
+// defaultPort returns the conventional listen port for a runtime.
+func defaultPort(runtime string) int {
+	ports := map[string]int{
+		"nodejs": 3000,
+		"python": 8000,
+		"go":     8080,
+		"rust":   8080,
+		"java":   8080,
+		"php":    8000,
+		"ruby":   3000,
+	}
+
+	if port, exists := ports[runtime]; exists {
+		return port
+	}
+	return 8080
+}
+
+// portPattern matches the common ways a port is passed on a command line. A
+// host:port pair only counts when bound to a local address, e.g. -b 0.0.0.0:8000.
+var portPattern = regexp.MustCompile(`(?:--port[= ]|-p |PORT=|(?:^|[\s=])(?:localhost|0\.0\.0\.0|127\.0\.0\.1):)(\d{2,5})\b`)
+
+// portFromCommand extracts an explicit port from a start command, if any.
+// Debugger addresses and URLs of other services are not the app's port.
+func portFromCommand(cmd string) (int, bool) {
+	var args []string
+	for _, arg := range strings.Fields(cmd) {
+		if strings.HasPrefix(arg, "--inspect") || strings.Contains(arg, "://") {
+			continue
+		}
+		args = append(args, arg)
+	}
+	match := portPattern.FindStringSubmatch(strings.Join(args, " "))
+	if match == nil {
+		return 0, false
+	}
+
+	port, err := strconv.Atoi(match[1])
+	if err != nil || port <= 0 || port > 65535 {
+		return 0, false
+	}
+	return port, true
+}
+
+func fileExists(dir, name string) bool {
+	_, err := os.Stat(filepath.Join(dir, name))
+	return err == nil
+}
+
+func readFile(dir, name string) (string, bool) {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+func readJSON(dir, name string, v interface{}) bool {
+	content, ok := readFile(dir, name)
+	return ok && jsonUnmarshal(content, v)
+}
+
+func jsonUnmarshal(content string, v interface{}) bool {
+	return json.Unmarshal([]byte(content), v) == nil
+}
+
+func readTOML(dir, name string, v interface{}) bool {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return false
+	}
+	return toml.Unmarshal(data, v) == nil
+}
+
+// firstExisting returns the first of names that exists in dir.
+func firstExisting(dir string, names ...string) (string, bool) {
+	for _, name := range names {
+		if fileExists(dir, name) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// containsDependency reports whether a free-form manifest mentions dep as a
+// dependency name. It is used for formats we do not fully parse.
+func containsDependency(content, dep string) bool {
+	pattern := regexp.MustCompile(`(?im)(^|["'\s:/\[])` + regexp.QuoteMeta(dep) + `($|["'\s=<>~!\[;,:])`)
+	return pattern.MatchString(content)
+}
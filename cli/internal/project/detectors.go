@@ -0,0 +1,610 @@
+package project
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// framework describes a framework recognised through a dependency name.
+type framework struct {
+	dependency string
+	name       string
+	port       int
+}
+
+// Node.js
+
+type packageJSON struct {
+	Name            string            `json:"name"`
+	Main            string            `json:"main"`
+	Scripts         map[string]string `json:"scripts"`
+	Dependencies    map[string]string `json:"dependencies"`
+	DevDependencies map[string]string `json:"devDependencies"`
+	Engines         map[string]string `json:"engines"`
+}
+
+func (p *packageJSON) hasDependency(name string) bool {
+	if _, ok := p.Dependencies[name]; ok {
+		return true
+	}
+	_, ok := p.DevDependencies[name]
+	return ok
+}
+
+// nodeFrameworks is checked in order; meta-frameworks come before the HTTP
+// servers they are frequently bundled with.
+var nodeFrameworks = []framework{
+	{"next", "nextjs", 3000},
+	{"nuxt", "nuxt", 3000},
+	{"@remix-run/serve", "remix", 3000},
+	{"@nestjs/core", "nestjs", 3000},
+	{"fastify", "fastify", 3000},
+	{"koa", "koa", 3000},
+	{"express", "express", 3000},
+}
+
+type nodeDetector struct{}
+
+func (nodeDetector) Runtime() string { return "nodejs" }
+
+func (nodeDetector) Detect(dir string) (*Detection, bool) {
+	var pkg packageJSON
+	if !fileExists(dir, "package.json") {
+		return nil, false
+	}
+	d := &Detection{Runtime: "nodejs"}
+	d.explain("runtime", "package.json", "package.json found")
+	if !readJSON(dir, "package.json", &pkg) {
+		d.explain("runtime", "package.json", "package.json could not be parsed; using defaults")
+	}
+
+	install, run := "npm install", "npm run"
+	switch {
+	case fileExists(dir, "pnpm-lock.yaml"):
+		install, run = "pnpm install --frozen-lockfile", "pnpm run"
+		d.explain("build", "pnpm-lock.yaml", "pnpm lockfile found")
+	case fileExists(dir, "yarn.lock"):
+		install, run = "yarn install --frozen-lockfile", "yarn run"
+		d.explain("build", "yarn.lock", "yarn lockfile found")
+	case fileExists(dir, "package-lock.json"):
+		install = "npm ci"
+		d.explain("build", "package-lock.json", "npm lockfile found, using npm ci")
+	}
+
+	d.Port = defaultPort("nodejs")
+	for _, fw := range nodeFrameworks {
+		if pkg.hasDependency(fw.dependency) {
+			d.Framework = fw.name
+			d.Port = fw.port
+			d.explain("framework", "package.json", "dependency %q found", fw.dependency)
+			break
+		}
+	}
+
+	d.BuildCmd = install
+	if _, ok := pkg.Scripts["build"]; ok {
+		d.BuildCmd = fmt.Sprintf("%s && %s build", install, run)
+		d.explain("build", "package.json", "scripts.build defined")
+	}
+
+	if script, ok := pkg.Scripts["start"]; ok {
+		d.StartCmd = run + " start"
+		d.explain("start", "package.json", "scripts.start defined: %s", script)
+		if port, ok := portFromCommand(script); ok {
+			d.Port = port
+			d.explain("port", "package.json", "scripts.start sets port %d", port)
+			return d, true
+		}
+	} else if d.Framework == "nextjs" {
+		d.StartCmd = "npx next start"
+		d.explain("start", "package.json", "no start script, using next start")
+	} else if pkg.Main != "" {
+		d.StartCmd = "node " + pkg.Main
+		d.explain("start", "package.json", "main entry point is %s", pkg.Main)
+	} else if entry, ok := firstExisting(dir, "server.js", "index.js", "app.js", "main.js"); ok {
+		d.StartCmd = "node " + entry
+		d.explain("start", entry, "entry point found")
+	} else {
+		d.StartCmd = "npm start"
+		d.explain("start", "", "no start script or entry point, defaulting to npm start")
+	}
+
+	if d.Framework != "" {
+		d.explain("port", "", "default port for %s", d.Framework)
+	} else {
+		d.explain("port", "", "default port for nodejs")
+	}
+	return d, true
+}
+
+// Python
+
+type pyprojectTOML struct {
+	Project struct {
+		Name         string   `toml:"name"`
+		Dependencies []string `toml:"dependencies"`
+	} `toml:"project"`
+	Tool struct {
+		Poetry *struct {
+			Dependencies map[string]interface{} `toml:"dependencies"`
+		} `toml:"poetry"`
+	} `toml:"tool"`
+}
+
+type pipfileTOML struct {
+	Packages map[string]interface{} `toml:"packages"`
+}
+
+var pythonFrameworks = []framework{
+	{"django", "django", 8000},
+	{"fastapi", "fastapi", 8000},
+	{"flask", "flask", 5000},
+}
+
+// requirementName strips version specifiers, extras and markers from a PEP 508
+// requirement.
+func requirementName(req string) string {
+	req = strings.TrimSpace(req)
+	if i := strings.IndexAny(req, "=<>~!;[ @"); i >= 0 {
+		req = req[:i]
+	}
+	return strings.ToLower(strings.ReplaceAll(req, "_", "-"))
+}
+
+type pythonDetector struct{}
+
+func (pythonDetector) Runtime() string { return "python" }
+
+func (pythonDetector) Detect(dir string) (*Detection, bool) {
+	manifest, ok := firstExisting(dir, "pyproject.toml", "Pipfile", "requirements.txt", "setup.py", "manage.py")
+	if !ok {
+		return nil, false
+	}
+	d := &Detection{Runtime: "python"}
+	d.explain("runtime", manifest, "%s found", manifest)
+
+	deps := map[string]string{}
+	if content, ok := readFile(dir, "requirements.txt"); ok {
+		for _, line := range strings.Split(content, "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "-") {
+				continue
+			}
+			deps[requirementName(line)] = "requirements.txt"
+		}
+		d.BuildCmd = "pip install -r requirements.txt"
+		d.explain("build", "requirements.txt", "requirements.txt found")
+	}
+
+	var pipfile pipfileTOML
+	if readTOML(dir, "Pipfile", &pipfile) {
+		for name := range pipfile.Packages {
+			deps[requirementName(name)] = "Pipfile"
+		}
+		if d.BuildCmd == "" {
+			d.BuildCmd = "pipenv install --system --deploy"
+			d.explain("build", "Pipfile", "Pipfile found")
+		}
+	}
+
+	var pyproject pyprojectTOML
+	if readTOML(dir, "pyproject.toml", &pyproject) {
+		for _, req := range pyproject.Project.Dependencies {
+			deps[requirementName(req)] = "pyproject.toml"
+		}
+		if pyproject.Tool.Poetry != nil {
+			for name := range pyproject.Tool.Poetry.Dependencies {
+				deps[requirementName(name)] = "pyproject.toml"
+			}
+		}
+		if d.BuildCmd == "" {
+			if pyproject.Tool.Poetry != nil {
+				d.BuildCmd = "poetry install --no-root --only main"
+				d.explain("build", "pyproject.toml", "[tool.poetry] section found")
+			} else {
+				d.BuildCmd = "pip install ."
+				d.explain("build", "pyproject.toml", "PEP 621 project found")
+			}
+		}
+	}
+
+	if d.BuildCmd == "" && fileExists(dir, "setup.py") {
+		d.BuildCmd = "pip install ."
+		d.explain("build", "setup.py", "setup.py found")
+	}
+	if d.BuildCmd == "" {
+		d.explain("build", "", "no dependency manifest found")
+		d.warn("no requirements.txt, Pipfile, pyproject.toml or setup.py found, so no dependencies are installed; add one or set build_cmd in .plate/config.yaml")
+	}
+
+	d.Port = defaultPort("python")
+	for _, fw := range pythonFrameworks {
+		if source, ok := deps[fw.dependency]; ok {
+			d.Framework = fw.name
+			d.Port = fw.port
+			d.explain("framework", source, "dependency %q found", fw.dependency)
+			break
+		}
+	}
+	if d.Framework == "" && fileExists(dir, "manage.py") {
+		d.Framework = "django"
+		d.Port = 8000
+		d.explain("framework", "manage.py", "Django management script found")
+	}
+
+	_, gunicorn := deps["gunicorn"]
+	switch d.Framework {
+	case "django":
+		module := djangoProjectModule(dir)
+		if gunicorn && module != "" {
+			d.StartCmd = fmt.Sprintf("gunicorn %s.wsgi:application --bind 0.0.0.0:%d", module, d.Port)
+			d.explain("start", "manage.py", "settings module %s.settings, gunicorn available", module)
+		} else {
+			d.StartCmd = fmt.Sprintf("python manage.py runserver 0.0.0.0:%d", d.Port)
+			d.explain("start", "manage.py", "using Django development server")
+		}
+	case "fastapi":
+		module, app := pythonApp(dir, "FastAPI")
+		d.StartCmd = fmt.Sprintf("uvicorn %s:%s --host 0.0.0.0 --port %d", module, app, d.Port)
+		d.explain("start", moduleFile(module), "ASGI application %s:%s", module, app)
+	case "flask":
+		module, app := pythonApp(dir, "Flask")
+		if gunicorn {
+			d.StartCmd = fmt.Sprintf("gunicorn %s:%s --bind 0.0.0.0:%d", module, app, d.Port)
+			d.explain("start", moduleFile(module), "WSGI application %s:%s, gunicorn available", module, app)
+		} else {
+			d.StartCmd = fmt.Sprintf("flask --app %s:%s run --host 0.0.0.0 --port %d", module, app, d.Port)
+			d.explain("start", moduleFile(module), "WSGI application %s:%s", module, app)
+		}
+	default:
+		entry, ok := firstExisting(dir, "app.py", "main.py", "server.py", "wsgi.py")
+		if !ok {
+			entry = "app.py"
+		}
+		d.StartCmd = "python " + entry
+		d.explain("start", entry, "script entry point")
+	}
+
+	d.explain("port", "", "default port for %s", d.Runtime+frameworkSuffix(d.Framework))
+	return d, true
+}
+
+var djangoSettingsPattern = regexp.MustCompile(`DJANGO_SETTINGS_MODULE['"]\s*,\s*['"]([\w.]+)\.settings`)
+
+// djangoProjectModule reads the settings module out of manage.py.
+func djangoProjectModule(dir string) string {
+	content, ok := readFile(dir, "manage.py")
+	if !ok {
+		return ""
+	}
+	match := djangoSettingsPattern.FindStringSubmatch(content)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+// pythonApp locates the module and variable holding an application object
+// constructed with ctor, e.g. app = FastAPI().
+func pythonApp(dir, ctor string) (string, string) {
+	pattern := regexp.MustCompile(`(?m)^(\w+)\s*=\s*` + ctor + `\(`)
+	for _, candidate := range []string{"main.py", "app.py", "app/main.py", "src/main.py", "api/main.py", "wsgi.py", "asgi.py"} {
+		content, ok := readFile(dir, candidate)
+		if !ok {
+			continue
+		}
+		if match := pattern.FindStringSubmatch(content); match != nil {
+			module := strings.TrimSuffix(strings.ReplaceAll(candidate, "/", "."), ".py")
+			return module, match[1]
+		}
+	}
+	return "main", "app"
+}
+
+func moduleFile(module string) string {
+	return strings.ReplaceAll(module, ".", "/") + ".py"
+}
+
+func frameworkSuffix(framework string) string {
+	if framework == "" {
+		return ""
+	}
+	return "/" + framework
+}
+
+// Go
+
+var goFrameworks = []framework{
+	{"github.com/gin-gonic/gin", "gin", 8080},
+	{"github.com/labstack/echo", "echo", 8080},
+	{"github.com/gofiber/fiber", "fiber", 3000},
+	{"github.com/go-chi/chi", "chi", 8080},
+	{"github.com/gorilla/mux", "gorilla", 8080},
+}
+
+type goDetector struct{}
+
+func (goDetector) Runtime() string { return "go" }
+
+func (goDetector) Detect(dir string) (*Detection, bool) {
+	gomod, ok := readFile(dir, "go.mod")
+	if !ok {
+		return nil, false
+	}
+	d := &Detection{Runtime: "go", Port: defaultPort("go")}
+	d.explain("runtime", "go.mod", "go.mod found")
+
+	for _, fw := range goFrameworks {
+		if strings.Contains(gomod, fw.dependency) {
+			d.Framework = fw.name
+			d.Port = fw.port
+			d.explain("framework", "go.mod", "module %s required", fw.dependency)
+			break
+		}
+	}
+
+	pkg := "."
+	if !fileExists(dir, "main.go") {
+		if entries, err := os.ReadDir(filepath.Join(dir, "cmd")); err == nil {
+			var mains []string
+			for _, entry := range entries {
+				if entry.IsDir() && fileExists(filepath.Join(dir, "cmd", entry.Name()), "main.go") {
+					mains = append(mains, entry.Name())
+				}
+			}
+			sort.Strings(mains)
+			if len(mains) > 0 {
+				pkg = "./cmd/" + mains[0]
+				d.explain("build", filepath.Join("cmd", mains[0], "main.go"), "main package found under cmd/")
+			}
+		}
+	} else {
+		d.explain("build", "main.go", "main package at module root")
+	}
+
+	d.BuildCmd = "go build -o app " + pkg
+	d.StartCmd = "./app"
+	d.explain("start", "", "run the compiled binary")
+	d.explain("port", "", "default port for %s", d.Runtime+frameworkSuffix(d.Framework))
+	return d, true
+}
+
+// Rust
+
+type cargoTOML struct {
+	Package struct {
+		Name string `toml:"name"`
+	} `toml:"package"`
+	Dependencies map[string]interface{} `toml:"dependencies"`
+}
+
+var rustFrameworks = []framework{
+	{"actix-web", "actix", 8080},
+	{"axum", "axum", 3000},
+	{"rocket", "rocket", 8000},
+	{"warp", "warp", 3030},
+}
+
+type rustDetector struct{}
+
+func (rustDetector) Runtime() string { return "rust" }
+
+func (rustDetector) Detect(dir string) (*Detection, bool) {
+	if !fileExists(dir, "Cargo.toml") {
+		return nil, false
+	}
+	d := &Detection{Runtime: "rust", Port: defaultPort("rust")}
+	d.explain("runtime", "Cargo.toml", "Cargo.toml found")
+
+	var cargo cargoTOML
+	readTOML(dir, "Cargo.toml", &cargo)
+
+	for _, fw := range rustFrameworks {
+		if _, ok := cargo.Dependencies[fw.dependency]; ok {
+			d.Framework = fw.name
+			d.Port = fw.port
+			d.explain("framework", "Cargo.toml", "dependency %q found", fw.dependency)
+			break
+		}
+	}
+
+	binary := cargo.Package.Name
+	if binary == "" {
+		binary = "app"
+	} else {
+		d.explain("start", "Cargo.toml", "binary named after package %q", binary)
+	}
+
+	d.BuildCmd = "cargo build --release"
+	d.StartCmd = "./target/release/" + binary
+	d.explain("port", "", "default port for %s", d.Runtime+frameworkSuffix(d.Framework))
+	return d, true
+}
+
+// Java
+
+var javaFrameworks = []framework{
+	{"spring-boot", "spring-boot", 8080},
+	{"io.quarkus", "quarkus", 8080},
+	{"io.micronaut", "micronaut", 8080},
+}
+
+var springPortPattern = regexp.MustCompile(`(?m)^\s*server\.port\s*[=:]\s*(\d+)`)
+
+type javaDetector struct{}
+
+func (javaDetector) Runtime() string { return "java" }
+
+func (javaDetector) Detect(dir string) (*Detection, bool) {
+	manifest, ok := firstExisting(dir, "pom.xml", "build.gradle.kts", "build.gradle")
+	if !ok {
+		return nil, false
+	}
+	d := &Detection{Runtime: "java", Port: defaultPort("java")}
+	d.explain("runtime", manifest, "%s found", manifest)
+
+	content, _ := readFile(dir, manifest)
+	for _, fw := range javaFrameworks {
+		if strings.Contains(content, fw.dependency) {
+			d.Framework = fw.name
+			d.Port = fw.port
+			d.explain("framework", manifest, "%s referenced", fw.dependency)
+			break
+		}
+	}
+
+	if manifest == "pom.xml" {
+		if fileExists(dir, "mvnw") {
+			d.BuildCmd = "./mvnw -B package -DskipTests"
+			d.explain("build", "mvnw", "Maven wrapper found")
+		} else {
+			d.BuildCmd = "mvn -B package -DskipTests"
+			d.explain("build", "pom.xml", "Maven project")
+		}
+	} else {
+		if fileExists(dir, "gradlew") {
+			d.BuildCmd = "./gradlew build -x test"
+			d.explain("build", "gradlew", "Gradle wrapper found")
+		} else {
+			d.BuildCmd = "gradle build -x test"
+			d.explain("build", manifest, "Gradle project")
+		}
+	}
+
+	// Quarkus packages a directory of its own instead of a single jar
+	artifacts := javaOutputDir(d.BuildCmd)
+	if d.Framework == "quarkus" {
+		artifacts += "/quarkus-app"
+		d.StartCmd = fmt.Sprintf("java -jar %s/quarkus-run.jar", artifacts)
+	} else {
+		if artifacts == "build" {
+			artifacts = "build/libs"
+		}
+		d.StartCmd = fmt.Sprintf("java -jar %s/*.jar", artifacts)
+	}
+	d.explain("start", "", "run the packaged jar from %s", artifacts)
+
+	for _, props := range []string{"src/main/resources/application.properties", "src/main/resources/application.yml", "src/main/resources/application.yaml"} {
+		content, ok := readFile(dir, props)
+		if !ok {
+			continue
+		}
+		if match := springPortPattern.FindStringSubmatch(content); match != nil {
+			fmt.Sscanf(match[1], "%d", &d.Port)
+			d.explain("port", props, "server.port is %s", match[1])
+			return d, true
+		}
+	}
+
+	d.explain("port", "", "default port for %s", d.Runtime+frameworkSuffix(d.Framework))
+	return d, true
+}
+
+// Ruby
+
+type rubyDetector struct{}
+
+func (rubyDetector) Runtime() string { return "ruby" }
+
+func (rubyDetector) Detect(dir string) (*Detection, bool) {
+	gemfile, ok := readFile(dir, "Gemfile")
+	if !ok {
+		return nil, false
+	}
+	d := &Detection{Runtime: "ruby", BuildCmd: "bundle install"}
+	d.explain("runtime", "Gemfile", "Gemfile found")
+	d.explain("build", "Gemfile", "install gems with bundler")
+
+	switch {
+	case containsDependency(gemfile, "rails") || fileExists(dir, "config/application.rb"):
+		d.Framework = "rails"
+		d.Port = 3000
+		d.StartCmd = fmt.Sprintf("bundle exec rails server -b 0.0.0.0 -p %d", d.Port)
+		d.explain("framework", "Gemfile", "rails gem found")
+	case containsDependency(gemfile, "sinatra"):
+		d.Framework = "sinatra"
+		d.Port = 4567
+		d.explain("framework", "Gemfile", "sinatra gem found")
+		if fileExists(dir, "config.ru") {
+			d.StartCmd = fmt.Sprintf("bundle exec rackup -o 0.0.0.0 -p %d", d.Port)
+		} else {
+			d.StartCmd = "bundle exec ruby app.rb -o 0.0.0.0"
+		}
+	case fileExists(dir, "config.ru"):
+		d.Framework = "rack"
+		d.Port = 9292
+		d.StartCmd = fmt.Sprintf("bundle exec rackup -o 0.0.0.0 -p %d", d.Port)
+		d.explain("framework", "config.ru", "rackup file found")
+	default:
+		d.Port = defaultPort("ruby")
+		d.StartCmd = "bundle exec ruby app.rb"
+	}
+
+	d.explain("start", "", "%s", d.StartCmd)
+	d.explain("port", "", "default port for %s", d.Runtime+frameworkSuffix(d.Framework))
+	return d, true
+}
+
+// PHP
+
+type composerJSON struct {
+	Require map[string]string `json:"require"`
+}
+
+var phpFrameworks = []framework{
+	{"laravel/framework", "laravel", 8000},
+	{"symfony/framework-bundle", "symfony", 8000},
+	{"slim/slim", "slim", 8000},
+}
+
+type phpDetector struct{}
+
+func (phpDetector) Runtime() string { return "php" }
+
+func (phpDetector) Detect(dir string) (*Detection, bool) {
+	manifest, ok := firstExisting(dir, "composer.json", "artisan")
+	if !ok {
+		return nil, false
+	}
+	d := &Detection{Runtime: "php", Port: defaultPort("php")}
+	d.explain("runtime", manifest, "%s found", manifest)
+
+	var composer composerJSON
+	if readJSON(dir, "composer.json", &composer) {
+		d.BuildCmd = "composer install --no-dev --optimize-autoloader"
+		d.explain("build", "composer.json", "install dependencies with composer")
+	} else {
+		d.explain("build", "", "no composer.json found")
+		d.warn("no composer.json found, so no dependencies are installed; the vendor directory must be committed")
+	}
+
+	for _, fw := range phpFrameworks {
+		if _, ok := composer.Require[fw.dependency]; ok {
+			d.Framework = fw.name
+			d.Port = fw.port
+			d.explain("framework", "composer.json", "package %q required", fw.dependency)
+			break
+		}
+	}
+	if d.Framework == "" && fileExists(dir, "artisan") {
+		d.Framework = "laravel"
+		d.explain("framework", "artisan", "Laravel artisan script found")
+	}
+
+	switch d.Framework {
+	case "laravel":
+		d.StartCmd = fmt.Sprintf("php artisan serve --host=0.0.0.0 --port=%d", d.Port)
+	case "symfony", "slim":
+		d.StartCmd = fmt.Sprintf("php -S 0.0.0.0:%d -t public", d.Port)
+	default:
+		d.StartCmd = fmt.Sprintf("php -S 0.0.0.0:%d", d.Port)
+	}
+
+	d.explain("start", "", "%s", d.StartCmd)
+	d.explain("port", "", "default port for %s", d.Runtime+frameworkSuffix(d.Framework))
+	return d, true
+}
@@ -0,0 +1,143 @@
+package project
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates
+var embeddedTemplates embed.FS
+
+// DockerfileGenerator renders Dockerfiles and .dockerignore files from
+// templates. Templates in TemplatesDir take precedence over the embedded
+// defaults and use the same layout:
+//
+//	dockerfile/<runtime>-<framework>.tmpl
+//	dockerfile/<runtime>.tmpl
+//	dockerignore.tmpl
+type DockerfileGenerator struct {
+	TemplatesDir string
+}
+
+// dockerfileData is passed to the templates. Install and Build split the
+// configured build command into the dependency install step, which can be
+// cached separately, and the remaining build steps.
+type dockerfileData struct {
+	*ServiceConfig
+	Install string
+	Build   string
+	// OutputDir is the directory the build tool of a Java service writes
+	// to
+	OutputDir string
+}
+
+var templateFuncs = template.FuncMap{
+	"hasPrefix":   strings.HasPrefix,
+	"execForm":    execForm,
+	"artifactDir": artifactDir,
+}
+
+// Dockerfile renders the Dockerfile for a service.
+func (g *DockerfileGenerator) Dockerfile(config *ServiceConfig) ([]byte, error) {
+	candidates := []string{path.Join("dockerfile", config.Runtime+".tmpl")}
+	if config.Framework != "" {
+		candidates = append([]string{path.Join("dockerfile", config.Runtime+"-"+config.Framework+".tmpl")}, candidates...)
+	}
+	candidates = append(candidates, path.Join("dockerfile", "generic.tmpl"))
+
+	install, build, _ := strings.Cut(config.BuildCmd, " && ")
+	data := dockerfileData{
+		ServiceConfig: config,
+		Install:       install,
+		Build:         build,
+	}
+	if config.Runtime == "java" {
+		data.OutputDir = javaOutputDir(config.BuildCmd)
+	}
+	return g.render(candidates, data)
+}
+
+// Dockerignore renders the .dockerignore for a service.
+func (g *DockerfileGenerator) Dockerignore(config *ServiceConfig) ([]byte, error) {
+	return g.render([]string{"dockerignore.tmpl"}, dockerfileData{ServiceConfig: config})
+}
+
+// render executes the first template found among candidates, looking in the
+// org template directory before the embedded defaults.
+func (g *DockerfileGenerator) render(candidates []string, data dockerfileData) ([]byte, error) {
+	name, content, err := g.lookup(candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %s: %w", name, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render template %s: %w", name, err)
+	}
+
+	return []byte(strings.TrimSpace(buf.String()) + "\n"), nil
+}
+
+func (g *DockerfileGenerator) lookup(candidates []string) (string, string, error) {
+	for _, candidate := range candidates {
+		if g.TemplatesDir != "" {
+			file := filepath.Join(g.TemplatesDir, filepath.FromSlash(candidate))
+			if content, err := os.ReadFile(file); err == nil {
+				return file, string(content), nil
+			} else if !os.IsNotExist(err) {
+				return "", "", fmt.Errorf("failed to read template %s: %w", file, err)
+			}
+		}
+
+		if content, err := embeddedTemplates.ReadFile(path.Join("templates", candidate)); err == nil {
+			return candidate, string(content), nil
+		}
+	}
+
+	return "", "", fmt.Errorf("no template found among %s", strings.Join(candidates, ", "))
+}
+
+// execForm renders a command as a JSON exec-form array, wrapping it in a
+// shell when it relies on shell features.
+func execForm(cmd string) string {
+	var args []string
+	if strings.ContainsAny(cmd, "&|;<>$*`'\"") {
+		args = []string{"sh", "-c", cmd}
+	} else {
+		args = strings.Fields(cmd)
+	}
+
+	data, _ := json.Marshal(args)
+	return string(data)
+}
+
+// javaOutputDir returns the directory a Java build command writes to:
+// build for Gradle and target for Maven.
+func javaOutputDir(buildCmd string) string {
+	if strings.Contains(buildCmd, "gradle") {
+		return "build"
+	}
+	return "target"
+}
+
+// artifactDir returns the directory of the artifact a start command runs,
+// e.g. "target" for "java -jar target/*.jar".
+func artifactDir(cmd string) string {
+	fields := strings.Fields(cmd)
+	if len(fields) == 0 {
+		return "."
+	}
+	return path.Dir(fields[len(fields)-1])
+}
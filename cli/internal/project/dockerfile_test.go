@@ -0,0 +1,85 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// renderDetected detects the service in a directory holding files and
+// renders its Dockerfile.
+func renderDetected(t *testing.T, files map[string]string) (*Detection, string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	detection := Detect(dir, "")
+	config := (&Importer{}).configureService(dir, detection)
+	dockerfile, err := (&DockerfileGenerator{}).Dockerfile(&config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return detection, string(dockerfile)
+}
+
+func TestDockerfileWithoutPythonManifest(t *testing.T) {
+	detection, dockerfile := renderDetected(t, map[string]string{"manage.py": "#!/usr/bin/env python"})
+
+	if detection.Framework != "django" || detection.BuildCmd != "" {
+		t.Errorf("detection = %+v", detection)
+	}
+	if len(detection.Warnings) != 1 || !strings.Contains(detection.Warnings[0], "no dependencies are installed") {
+		t.Errorf("warnings = %q", detection.Warnings)
+	}
+	for _, line := range strings.Split(dockerfile, "\n") {
+		if strings.HasPrefix(line, "RUN") && strings.HasSuffix(strings.TrimSpace(line), "pip") {
+			t.Errorf("RUN without a command:\n%s", dockerfile)
+		}
+	}
+}
+
+func TestDockerfileQuarkusOutputDir(t *testing.T) {
+	quarkus := `plugins { id("io.quarkus") }`
+	tests := []struct {
+		files map[string]string
+		dir   string
+	}{
+		{map[string]string{"pom.xml": "<artifactId>quarkus</artifactId><groupId>io.quarkus</groupId>"}, "target/quarkus-app"},
+		{map[string]string{"build.gradle.kts": quarkus}, "build/quarkus-app"},
+		{map[string]string{"build.gradle.kts": quarkus, "gradlew": "#!/bin/sh"}, "build/quarkus-app"},
+	}
+	for _, tt := range tests {
+		detection, dockerfile := renderDetected(t, tt.files)
+		if detection.Framework != "quarkus" || detection.StartCmd != "java -jar "+tt.dir+"/quarkus-run.jar" {
+			t.Errorf("%s: detection = %+v", detection.BuildCmd, detection)
+		}
+		if !strings.Contains(dockerfile, "COPY --from=build --chown=app:app /app/"+tt.dir+" ./") {
+			t.Errorf("%s: Dockerfile does not copy %s:\n%s", detection.BuildCmd, tt.dir, dockerfile)
+		}
+	}
+}
+
+func TestDockerfileWithoutComposerJSON(t *testing.T) {
+	detection, dockerfile := renderDetected(t, map[string]string{"artisan": "#!/usr/bin/env php"})
+
+	if detection.Framework != "laravel" || len(detection.Warnings) != 1 {
+		t.Errorf("detection = %+v", detection)
+	}
+	if strings.Contains(dockerfile, "composer") {
+		t.Errorf("Dockerfile uses composer without a composer.json:\n%s", dockerfile)
+	}
+
+	_, dockerfile = renderDetected(t, map[string]string{"artisan": "", "composer.json": `{"require": {"laravel/framework": "^11.0"}}`})
+	if !strings.Contains(dockerfile, "COPY composer.json composer.lock* ./") {
+		t.Errorf("Dockerfile does not install dependencies with composer:\n%s", dockerfile)
+	}
+}
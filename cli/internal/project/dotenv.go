@@ -0,0 +1,151 @@
+package project
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var envVarName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// secretPatterns match variable names that likely hold credentials.
+var secretPatterns = []string{
+	"*_KEY", "*_KEYS", "*_TOKEN", "*_TOKENS", "*_SECRET", "*SECRET_*",
+	"*PASSWORD*", "*PASSWD*", "*_PASS", "*_PWD", "*CREDENTIAL*",
+	"*PRIVATE_KEY*", "*_DSN", "*_AUTH", "API_KEY", "TOKEN", "SECRET",
+}
+
+// publicPatterns match variables that are shipped to browsers by frontend
+// build tools, and are therefore never secret.
+var publicPatterns = []string{"NEXT_PUBLIC_*", "NUXT_PUBLIC_*", "VITE_*", "REACT_APP_*", "PUBLIC_*", "*_PUBLIC_KEY"}
+
+// LooksSecret reports whether the name of a variable suggests it holds a
+// credential, such as STRIPE_API_KEY, GITHUB_TOKEN or DB_PASSWORD.
+func LooksSecret(name string) bool {
+	name = strings.ToUpper(name)
+	for _, pattern := range publicPatterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return false
+		}
+	}
+	for _, pattern := range secretPatterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseDotenv reads variables from a .env file. It accepts comments, blank
+// lines, an optional export prefix, unquoted values with trailing comments,
+// single-quoted literal values and double-quoted values with escapes. Quoted
+// values may span several lines.
+func ParseDotenv(data []byte) (map[string]string, error) {
+	vars := map[string]string{}
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+
+	for i := 0; i < len(lines); i++ {
+		lineNumber := i + 1
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// The export keyword is followed by whitespace, unlike a variable
+		// whose name merely starts with export
+		if rest, ok := strings.CutPrefix(line, "export"); ok && rest != "" && (rest[0] == ' ' || rest[0] == '\t') {
+			line = strings.TrimSpace(rest)
+		}
+
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected NAME=VALUE", lineNumber)
+		}
+		if !envVarName.MatchString(name) {
+			return nil, fmt.Errorf("line %d: invalid variable name %q", lineNumber, name)
+		}
+		value = strings.TrimLeft(value, " \t")
+
+		if value == "" || (value[0] != '"' && value[0] != '\'') {
+			if comment := strings.Index(value, " #"); comment >= 0 {
+				value = value[:comment]
+			}
+			vars[name] = strings.TrimSpace(value)
+			continue
+		}
+
+		// Quoted values continue until the closing quote, on this line or a
+		// later one
+		quote := value[0]
+		text := value[1:]
+		for {
+			end := closingQuote(text, quote)
+			if end >= 0 {
+				rest := strings.TrimSpace(text[end+1:])
+				if rest != "" && !strings.HasPrefix(rest, "#") {
+					return nil, fmt.Errorf("line %d: unexpected text after the closing quote", lineNumber)
+				}
+				text = text[:end]
+				break
+			}
+			i++
+			if i == len(lines) {
+				return nil, fmt.Errorf("line %d: missing closing quote for %s", lineNumber, name)
+			}
+			text += "\n" + lines[i]
+		}
+
+		if quote == '"' {
+			text = unescapeDotenv(text)
+		}
+		vars[name] = text
+	}
+
+	return vars, nil
+}
+
+// closingQuote returns the index of the quote ending a value, skipping
+// escaped quotes in double-quoted values, or -1 if there is none.
+func closingQuote(text string, quote byte) int {
+	for i := 0; i < len(text); i++ {
+		switch {
+		case quote == '"' && text[i] == '\\':
+			i++
+		case text[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+var dotenvUnescaper = strings.NewReplacer(`\n`, "\n", `\r`, "\r", `\t`, "\t", `\"`, `"`, `\$`, `$`, `\\`, `\`)
+
+var dotenvEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`, "\t", `\t`, `"`, `\"`, `$`, `\$`)
+
+func unescapeDotenv(value string) string {
+	return dotenvUnescaper.Replace(value)
+}
+
+var plainDotenvValue = regexp.MustCompile(`^[A-Za-z0-9_./:@%+,=-]*$`)
+
+// FormatDotenv writes variables as .env lines in name order, quoting values
+// that would not read back unchanged otherwise.
+func FormatDotenv(vars map[string]string) string {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		value := vars[name]
+		if !plainDotenvValue.MatchString(value) {
+			value = `"` + dotenvEscaper.Replace(value) + `"`
+		}
+		fmt.Fprintf(&b, "%s=%s\n", name, value)
+	}
+	return b.String()
+}
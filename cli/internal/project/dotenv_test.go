@@ -0,0 +1,33 @@
+package project
+
+import "testing"
+
+func TestParseDotenvExport(t *testing.T) {
+	data := "export PORT=8080\nexport\tHOST=localhost\nexport   DEBUG = true\nexported=yes\nEXPORTER=prometheus\nexport=1\n"
+	vars, err := ParseDotenv([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"PORT": "8080", "HOST": "localhost", "DEBUG": "true", "exported": "yes", "EXPORTER": "prometheus", "export": "1"}
+	if len(vars) != len(want) {
+		t.Errorf("vars = %v, want %v", vars, want)
+	}
+	for name, value := range want {
+		if got, ok := vars[name]; !ok || got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+
+	if _, err := ParseDotenv([]byte("exportPORT\n")); err == nil {
+		t.Error("a line without = was accepted")
+	}
+}
+
+func TestApplyEnvVars(t *testing.T) {
+	envVars := ApplyEnvVars(nil, map[string]string{"PORT": "8080", "HOST": "localhost"}, nil)
+	envVars = ApplyEnvVars(envVars, map[string]string{"PORT": "9090"}, []string{"HOST", "MISSING"})
+	if len(envVars) != 1 || envVars["PORT"] != "9090" {
+		t.Errorf("env vars = %v", envVars)
+	}
+}
@@ -0,0 +1,333 @@
+package project
+
+import "sort"
+
+// Sizes are the sizing presets the server knows, from smallest to largest.
+// A preset sets a service's requests and limits; explicit resources are
+// applied over it.
+var Sizes = []string{"small", "medium", "large", "xlarge"}
+
+// ValidSize reports whether size names a sizing preset.
+func ValidSize(size string) bool {
+	for _, known := range Sizes {
+		if size == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Resources are the CPU and memory requests and limits of a service, as
+// Kubernetes quantities such as 250m or 512Mi.
+type Resources struct {
+	Requests ResourceList `yaml:"requests,omitempty" json:"requests,omitempty"`
+	Limits   ResourceList `yaml:"limits,omitempty" json:"limits,omitempty"`
+}
+
+type ResourceList struct {
+	CPU    string `yaml:"cpu,omitempty" json:"cpu,omitempty"`
+	Memory string `yaml:"memory,omitempty" json:"memory,omitempty"`
+}
+
+// HealthCheck configures the liveness, readiness and startup probes of a
+// service. Path is the default HTTP path for probes that do not set their
+// own; without any path, probes only check that the port accepts
+// connections.
+type HealthCheck struct {
+	Path      string `yaml:"path,omitempty" json:"path,omitempty"`
+	Liveness  *Probe `yaml:"liveness,omitempty" json:"liveness,omitempty"`
+	Readiness *Probe `yaml:"readiness,omitempty" json:"readiness,omitempty"`
+	Startup   *Probe `yaml:"startup,omitempty" json:"startup,omitempty"`
+}
+
+// Probe configures a single probe. Type is http, tcp, exec or none, and is
+// inferred from Path and Command when empty.
+type Probe struct {
+	Type                string   `yaml:"type,omitempty" json:"type,omitempty"`
+	Path                string   `yaml:"path,omitempty" json:"path,omitempty"`
+	Port                int      `yaml:"port,omitempty" json:"port,omitempty"`
+	Command             []string `yaml:"command,omitempty" json:"command,omitempty"`
+	InitialDelaySeconds int      `yaml:"initial_delay_seconds,omitempty" json:"initial_delay_seconds,omitempty"`
+	PeriodSeconds       int      `yaml:"period_seconds,omitempty" json:"period_seconds,omitempty"`
+	TimeoutSeconds      int      `yaml:"timeout_seconds,omitempty" json:"timeout_seconds,omitempty"`
+	FailureThreshold    int      `yaml:"failure_threshold,omitempty" json:"failure_threshold,omitempty"`
+	SuccessThreshold    int      `yaml:"success_threshold,omitempty" json:"success_threshold,omitempty"`
+}
+
+// Autoscaling scales a service between MinReplicas and MaxReplicas on CPU
+// and memory utilization and custom metrics. Without any target it scales
+// on 80% CPU utilization.
+type Autoscaling struct {
+	Enabled                 *bool    `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+	MinReplicas             int      `yaml:"min_replicas,omitempty" json:"min_replicas,omitempty"`
+	MaxReplicas             int      `yaml:"max_replicas,omitempty" json:"max_replicas,omitempty"`
+	TargetCPUUtilization    int      `yaml:"target_cpu_utilization,omitempty" json:"target_cpu_utilization,omitempty"`
+	TargetMemoryUtilization int      `yaml:"target_memory_utilization,omitempty" json:"target_memory_utilization,omitempty"`
+	Metrics                 []Metric `yaml:"metrics,omitempty" json:"metrics,omitempty"`
+}
+
+// Metric is an autoscaling target on a pods, object or external metric.
+// Pods metrics target an AverageValue; object and external metrics either
+// a Value or an AverageValue.
+type Metric struct {
+	Type         string            `yaml:"type" json:"type"`
+	Name         string            `yaml:"name" json:"name"`
+	Selector     map[string]string `yaml:"selector,omitempty" json:"selector,omitempty"`
+	AverageValue string            `yaml:"average_value,omitempty" json:"average_value,omitempty"`
+	Value        string            `yaml:"value,omitempty" json:"value,omitempty"`
+	Object       *MetricObject     `yaml:"object,omitempty" json:"object,omitempty"`
+}
+
+// MetricObject is the object an object metric describes.
+type MetricObject struct {
+	APIVersion string `yaml:"api_version,omitempty" json:"api_version,omitempty"`
+	Kind       string `yaml:"kind" json:"kind"`
+	Name       string `yaml:"name" json:"name"`
+}
+
+// EnvironmentConfig overrides a service's settings in one environment.
+// Unset fields keep the base value and env vars are merged key by key; the
+// server applies the overrides when it deploys to that environment.
+type EnvironmentConfig struct {
+	Replicas    *int              `yaml:"replicas,omitempty" json:"replicas,omitempty"`
+	Size        string            `yaml:"size,omitempty" json:"size,omitempty"`
+	Resources   *Resources        `yaml:"resources,omitempty" json:"resources,omitempty"`
+	EnvVars     map[string]string `yaml:"env_vars,omitempty" json:"env_vars,omitempty"`
+	Domain      string            `yaml:"domain,omitempty" json:"domain,omitempty"`
+	HealthCheck *HealthCheck      `yaml:"health_check,omitempty" json:"health_check,omitempty"`
+	Autoscaling *Autoscaling      `yaml:"autoscaling,omitempty" json:"autoscaling,omitempty"`
+	// Branch is deployed to the environment whenever it is pushed to
+	Branch string `yaml:"branch,omitempty" json:"branch,omitempty"`
+}
+
+// EnvironmentNames returns the names of the overridden environments in a
+// stable order.
+func (s *ServiceConfig) EnvironmentNames() []string {
+	names := make([]string, 0, len(s.Environments))
+	for name := range s.Environments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetEnvironmentSize records the sizing preset of a deployable in one
+// environment, replacing explicit resources overridden there.
+func (c *ProjectConfig) SetEnvironmentSize(deployable Deployable, environment, size string) {
+	c.updateEnvironment(deployable, environment, func(override *EnvironmentConfig) {
+		override.Size = size
+		override.Resources = nil
+	})
+}
+
+// SetEnvironmentAutoscaling records the autoscaler bounds of a deployable
+// in one environment.
+func (c *ProjectConfig) SetEnvironmentAutoscaling(deployable Deployable, environment string, minReplicas, maxReplicas int) {
+	c.updateEnvironment(deployable, environment, func(override *EnvironmentConfig) {
+		if override.Autoscaling == nil {
+			override.Autoscaling = &Autoscaling{}
+		}
+		override.Autoscaling.MinReplicas = minReplicas
+		override.Autoscaling.MaxReplicas = maxReplicas
+	})
+}
+
+// SetEnvVars sets and removes env vars of a deployable, in one environment
+// or, with an empty environment, in its base settings.
+func (c *ProjectConfig) SetEnvVars(deployable Deployable, environment string, set map[string]string, remove []string) {
+	if environment == "" {
+		c.updateService(deployable, func(service *ServiceConfig) {
+			service.EnvVars = ApplyEnvVars(service.EnvVars, set, remove)
+		})
+		return
+	}
+	c.updateEnvironment(deployable, environment, func(override *EnvironmentConfig) {
+		override.EnvVars = ApplyEnvVars(override.EnvVars, set, remove)
+	})
+}
+
+// ApplyEnvVars sets and removes env vars in envVars, which may be nil, and
+// returns it.
+func ApplyEnvVars(envVars, set map[string]string, remove []string) map[string]string {
+	if envVars == nil {
+		envVars = map[string]string{}
+	}
+	for name, value := range set {
+		envVars[name] = value
+	}
+	for _, name := range remove {
+		delete(envVars, name)
+	}
+	return envVars
+}
+
+// updateEnvironment changes the deployable's own override for environment.
+func (c *ProjectConfig) updateEnvironment(deployable Deployable, environment string, update func(*EnvironmentConfig)) {
+	c.updateService(deployable, func(service *ServiceConfig) {
+		if service.Environments == nil {
+			service.Environments = map[string]EnvironmentConfig{}
+		}
+		override := service.Environments[environment]
+		update(&override)
+		service.Environments[environment] = override
+	})
+}
+
+// updateService changes the deployable's own settings, leaving the ones
+// shared by all services of a monorepo alone.
+func (c *ProjectConfig) updateService(deployable Deployable, update func(*ServiceConfig)) {
+	if deployable.Key == "" {
+		update(&c.ServiceConfig)
+		return
+	}
+	own := c.Services[deployable.Key]
+	update(&own)
+	c.Services[deployable.Key] = own
+}
+
+// mergeEnvironments applies a service's own environment overrides over the
+// ones shared by all services of a monorepo.
+func mergeEnvironments(shared, own map[string]EnvironmentConfig) map[string]EnvironmentConfig {
+	if len(shared) == 0 {
+		return own
+	}
+
+	merged := make(map[string]EnvironmentConfig, len(shared)+len(own))
+	for name, config := range shared {
+		merged[name] = config
+	}
+	for name, config := range own {
+		base, ok := merged[name]
+		if !ok {
+			merged[name] = config
+			continue
+		}
+		merged[name] = base.merge(config)
+	}
+	return merged
+}
+
+// merge returns c with the fields set in override replaced.
+func (c EnvironmentConfig) merge(override EnvironmentConfig) EnvironmentConfig {
+	if override.Replicas != nil {
+		c.Replicas = override.Replicas
+	}
+	if override.Size != "" {
+		c.Size = override.Size
+		c.Resources = nil
+	}
+	if override.Resources != nil {
+		resources := Resources{}
+		if c.Resources != nil {
+			resources = *c.Resources
+		}
+		resources.Requests = resources.Requests.merge(override.Resources.Requests)
+		resources.Limits = resources.Limits.merge(override.Resources.Limits)
+		c.Resources = &resources
+	}
+	if len(override.EnvVars) > 0 {
+		envVars := make(map[string]string, len(c.EnvVars)+len(override.EnvVars))
+		for name, value := range c.EnvVars {
+			envVars[name] = value
+		}
+		for name, value := range override.EnvVars {
+			envVars[name] = value
+		}
+		c.EnvVars = envVars
+	}
+	if override.Domain != "" {
+		c.Domain = override.Domain
+	}
+	if override.Branch != "" {
+		c.Branch = override.Branch
+	}
+	if override.HealthCheck != nil {
+		healthCheck := HealthCheck{}
+		if c.HealthCheck != nil {
+			healthCheck = *c.HealthCheck
+		}
+		if override.HealthCheck.Path != "" {
+			healthCheck.Path = override.HealthCheck.Path
+		}
+		healthCheck.Liveness = healthCheck.Liveness.merge(override.HealthCheck.Liveness)
+		healthCheck.Readiness = healthCheck.Readiness.merge(override.HealthCheck.Readiness)
+		healthCheck.Startup = healthCheck.Startup.merge(override.HealthCheck.Startup)
+		c.HealthCheck = &healthCheck
+	}
+	if override.Autoscaling != nil {
+		autoscaling := Autoscaling{}
+		if c.Autoscaling != nil {
+			autoscaling = *c.Autoscaling
+		}
+		if override.Autoscaling.Enabled != nil {
+			autoscaling.Enabled = override.Autoscaling.Enabled
+		}
+		if override.Autoscaling.MinReplicas != 0 {
+			autoscaling.MinReplicas = override.Autoscaling.MinReplicas
+		}
+		if override.Autoscaling.MaxReplicas != 0 {
+			autoscaling.MaxReplicas = override.Autoscaling.MaxReplicas
+		}
+		if override.Autoscaling.TargetCPUUtilization != 0 {
+			autoscaling.TargetCPUUtilization = override.Autoscaling.TargetCPUUtilization
+		}
+		if override.Autoscaling.TargetMemoryUtilization != 0 {
+			autoscaling.TargetMemoryUtilization = override.Autoscaling.TargetMemoryUtilization
+		}
+		if len(override.Autoscaling.Metrics) > 0 {
+			autoscaling.Metrics = override.Autoscaling.Metrics
+		}
+		c.Autoscaling = &autoscaling
+	}
+	return c
+}
+
+func (l ResourceList) merge(override ResourceList) ResourceList {
+	if override.CPU != "" {
+		l.CPU = override.CPU
+	}
+	if override.Memory != "" {
+		l.Memory = override.Memory
+	}
+	return l
+}
+
+// merge returns p with the fields set in override replaced.
+func (p *Probe) merge(override *Probe) *Probe {
+	if override == nil {
+		return p
+	}
+	if p == nil {
+		return override
+	}
+
+	merged := *p
+	if override.Type != "" {
+		merged.Type = override.Type
+	}
+	if override.Path != "" {
+		merged.Path = override.Path
+	}
+	if override.Port != 0 {
+		merged.Port = override.Port
+	}
+	if len(override.Command) > 0 {
+		merged.Command = override.Command
+	}
+	if override.InitialDelaySeconds != 0 {
+		merged.InitialDelaySeconds = override.InitialDelaySeconds
+	}
+	if override.PeriodSeconds != 0 {
+		merged.PeriodSeconds = override.PeriodSeconds
+	}
+	if override.TimeoutSeconds != 0 {
+		merged.TimeoutSeconds = override.TimeoutSeconds
+	}
+	if override.FailureThreshold != 0 {
+		merged.FailureThreshold = override.FailureThreshold
+	}
+	if override.SuccessThreshold != 0 {
+		merged.SuccessThreshold = override.SuccessThreshold
+	}
+	return &merged
+}
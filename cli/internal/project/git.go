@@ -0,0 +1,327 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// plateRemote is the name of the Git remote pointing at the repository the
+// service builds from.
+const plateRemote = "plate"
+
+// GitAuth holds the HTTP credentials used to push to the platform's Git
+// server.
+type GitAuth struct {
+	Username string
+	Password string
+}
+
+// PushResult describes what Push pushed.
+type PushResult struct {
+	// Branch is the local branch that was pushed
+	Branch string
+	// Commit is the hash of the pushed commit
+	Commit string
+	// Committed lists the generated files Push committed first
+	Committed []string
+	// Uncommitted lists the other changes of the worktree, which were not
+	// pushed
+	Uncommitted []string
+}
+
+// Push pushes the current branch of the repository at dir to branch of
+// remoteURL. A directory that is not yet a Git repository is initialized and
+// its files committed first. In an existing repository, the generated files,
+// given relative to dir, are committed if they changed, so the pushed code
+// builds the way the service expects; other changes are left alone.
+func Push(dir, remoteURL, branch string, generated []string, auth GitAuth) (*PushResult, error) {
+	result := &PushResult{}
+	repo, err := git.PlainOpenWithOptions(dir, &git.PlainOpenOptions{DetectDotGit: true})
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		repo, err = initRepository(dir, branch)
+	} else if err == nil {
+		result.Committed, result.Uncommitted, err = commitGenerated(repo, dir, generated)
+		if err != nil {
+			return nil, fmt.Errorf("failed to commit the generated files: %w", err)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open repository: %w", err)
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve HEAD, commit your code first: %w", err)
+	}
+	if !head.Name().IsBranch() {
+		return nil, fmt.Errorf("HEAD is detached, check out a branch to push")
+	}
+	result.Branch = head.Name().Short()
+	result.Commit = head.Hash().String()
+
+	remote, err := repo.Remote(plateRemote)
+	switch {
+	case errors.Is(err, git.ErrRemoteNotFound):
+		_, err = repo.CreateRemote(&gitconfig.RemoteConfig{Name: plateRemote, URLs: []string{remoteURL}})
+	case err == nil && (len(remote.Config().URLs) == 0 || remote.Config().URLs[0] != remoteURL):
+		if err = repo.DeleteRemote(plateRemote); err == nil {
+			_, err = repo.CreateRemote(&gitconfig.RemoteConfig{Name: plateRemote, URLs: []string{remoteURL}})
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure remote %s: %w", plateRemote, err)
+	}
+
+	options := &git.PushOptions{
+		RemoteName: plateRemote,
+		RefSpecs: []gitconfig.RefSpec{
+			gitconfig.RefSpec(fmt.Sprintf("%s:%s", head.Name(), plumbing.NewBranchReferenceName(branch))),
+		},
+	}
+	if auth.Password != "" {
+		options.Auth = &http.BasicAuth{Username: auth.Username, Password: auth.Password}
+	}
+
+	if err := repo.Push(options); err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return nil, fmt.Errorf("failed to push to %s: %w", remoteURL, err)
+	}
+	return result, nil
+}
+
+// commitGenerated commits the generated files that changed, returning them
+// and the other changes of the worktree. It refuses to commit while other
+// changes are staged, as they would be committed along.
+func commitGenerated(repo *git.Repository, dir string, generated []string) (committed, uncommitted []string, err error) {
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return nil, nil, err
+	}
+	status, err := worktree.Status()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	root, err := filepath.Abs(worktree.Filesystem.Root())
+	if err != nil {
+		return nil, nil, err
+	}
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	isGenerated := map[string]bool{}
+	for _, file := range generated {
+		path, err := filepath.Rel(root, filepath.Join(dir, file))
+		if err != nil {
+			return nil, nil, err
+		}
+		isGenerated[filepath.ToSlash(path)] = true
+	}
+
+	for file, fileStatus := range status {
+		if fileStatus.Worktree == git.Unmodified && fileStatus.Staging == git.Unmodified {
+			continue
+		}
+		switch {
+		case isGenerated[file]:
+			committed = append(committed, file)
+		case fileStatus.Staging != git.Unmodified && fileStatus.Staging != git.Untracked:
+			return nil, nil, fmt.Errorf("%s is staged; commit or unstage it so the generated files can be committed on their own", file)
+		default:
+			uncommitted = append(uncommitted, file)
+		}
+	}
+	sort.Strings(committed)
+	sort.Strings(uncommitted)
+	if len(committed) == 0 {
+		return nil, uncommitted, nil
+	}
+
+	for _, file := range committed {
+		if _, err := worktree.Add(file); err != nil {
+			return nil, nil, err
+		}
+	}
+	_, err = worktree.Commit("Add Plate build configuration", &git.CommitOptions{Author: commitSignature(repo)})
+	if err != nil {
+		return nil, nil, err
+	}
+	return committed, uncommitted, nil
+}
+
+// commitSignature returns the user configured for the repository, or Plate
+// if there is none.
+func commitSignature(repo *git.Repository) *object.Signature {
+	signature := &object.Signature{Name: "Plate", Email: "plate@localhost", When: time.Now()}
+	if config, err := repo.ConfigScoped(gitconfig.GlobalScope); err == nil && config.User.Name != "" && config.User.Email != "" {
+		signature.Name, signature.Email = config.User.Name, config.User.Email
+	}
+	return signature
+}
+
+// initRepository creates a repository in dir with a single commit holding
+// all files not excluded by .gitignore.
+func initRepository(dir, branch string) (*git.Repository, error) {
+	repo, err := git.PlainInitWithOptions(dir, &git.PlainInitOptions{
+		InitOptions: git.InitOptions{DefaultBranch: plumbing.NewBranchReferenceName(branch)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	if err := worktree.AddWithOptions(&git.AddOptions{All: true}); err != nil {
+		return nil, err
+	}
+
+	_, err = worktree.Commit("Import into Plate", &git.CommitOptions{
+		Author: &object.Signature{Name: "Plate", Email: "plate@localhost", When: time.Now()},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("Initialized a Git repository in %s\n", dir)
+	return repo, nil
+}
+
+// IsRemoteURL reports whether source names a remote Git repository rather
+// than a local directory.
+func IsRemoteURL(source string) bool {
+	if strings.Contains(source, "://") {
+		return true
+	}
+	// scp-like syntax, e.g. git@example.com:org/app.git
+	user, rest, ok := strings.Cut(source, "@")
+	return ok && !strings.Contains(user, "/") && strings.Contains(rest, ":")
+}
+
+// RepositoryName derives a project name from a repository URL, e.g. "app"
+// for https://git.example.com/org/app.git.
+func RepositoryName(url string) string {
+	url = strings.TrimSuffix(strings.TrimRight(url, "/"), ".git")
+	if i := strings.LastIndexAny(url, "/:"); i >= 0 {
+		url = url[i+1:]
+	}
+	return sanitizeName(url)
+}
+
+// Checkout is a repository cloned by Clone.
+type Checkout struct {
+	// Dir is the temporary directory holding the clone, which the caller
+	// removes
+	Dir string
+	// Branch is the checked out branch, or empty if Tag is set
+	Branch string
+	// Tag is the checked out tag, if ref named one
+	Tag string
+	// Commit is the hash of the checked out commit
+	Commit string
+}
+
+// Clone shallow-clones url into a new temporary directory, checking out ref
+// (a branch or tag) or the remote's default branch when ref is empty.
+func Clone(url, ref string, auth GitAuth) (*Checkout, error) {
+	dir, err := os.MkdirTemp("", "plate-import-")
+	if err != nil {
+		return nil, err
+	}
+
+	options := &git.CloneOptions{
+		URL:          url,
+		Depth:        1,
+		SingleBranch: true,
+		Tags:         git.NoTags,
+	}
+	if auth.Password != "" {
+		options.Auth = &http.BasicAuth{Username: auth.Username, Password: auth.Password}
+	}
+
+	var repo *git.Repository
+	if ref == "" {
+		repo, err = git.PlainClone(dir, false, options)
+	} else {
+		// The ref may name a branch or a tag
+		for _, name := range []plumbing.ReferenceName{plumbing.NewBranchReferenceName(ref), plumbing.NewTagReferenceName(ref)} {
+			options.ReferenceName = name
+			repo, err = git.PlainClone(dir, false, options)
+			if !errors.Is(err, plumbing.ErrReferenceNotFound) && !isNoMatchingRef(err) {
+				break
+			}
+			// Start over from an empty directory
+			os.RemoveAll(dir)
+			if mkErr := os.MkdirAll(dir, 0700); mkErr != nil {
+				return nil, mkErr
+			}
+		}
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		if ref != "" && (errors.Is(err, plumbing.ErrReferenceNotFound) || isNoMatchingRef(err)) {
+			return nil, fmt.Errorf("no branch or tag %s in %s", ref, url)
+		}
+		return nil, fmt.Errorf("failed to clone %s: %w", url, err)
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to resolve HEAD of %s: %w", url, err)
+	}
+
+	checkout := &Checkout{Dir: dir, Commit: head.Hash().String()}
+	// A tag leaves HEAD detached
+	if options.ReferenceName.IsTag() {
+		checkout.Tag = ref
+	} else {
+		checkout.Branch = head.Name().Short()
+	}
+	return checkout, nil
+}
+
+// ChangedFiles lists the files of the repository at dir that were added or
+// changed since its last commit, sorted.
+func ChangedFiles(dir string) ([]string, error) {
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		return nil, err
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	status, err := worktree.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for file, fileStatus := range status {
+		if fileStatus.Worktree != git.Unmodified || fileStatus.Staging != git.Unmodified {
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// isNoMatchingRef reports whether a clone failed because the remote has no
+// reference matching the requested one.
+func isNoMatchingRef(err error) bool {
+	var noMatch git.NoMatchingRefSpecError
+	return errors.As(err, &noMatch)
+}
@@ -0,0 +1,319 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// testRepository is a bare repository with a main branch, a release
+// branch a commit ahead of it, and a v1.0.0 tag on main.
+type testRepository struct {
+	url           string
+	main, release plumbing.Hash
+}
+
+func newTestRepository(t *testing.T) *testRepository {
+	t.Helper()
+	signature := &object.Signature{Name: "Ada", Email: "ada@example.com", When: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)}
+
+	workDir := t.TempDir()
+	work, err := git.PlainInitWithOptions(workDir, &git.PlainInitOptions{
+		InitOptions: git.InitOptions{DefaultBranch: plumbing.NewBranchReferenceName("main")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := work.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commit := func(file, content, message string) plumbing.Hash {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(workDir, file), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := worktree.Add(file); err != nil {
+			t.Fatal(err)
+		}
+		hash, err := worktree.Commit(message, &git.CommitOptions{Author: signature})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+
+	repo := &testRepository{}
+	repo.main = commit("package.json", `{"name": "shop"}`, "Add package.json")
+	if _, err := work.CreateTag("v1.0.0", repo.main, &git.CreateTagOptions{Tagger: signature, Message: "Release 1.0.0"}); err != nil {
+		t.Fatal(err)
+	}
+	err = worktree.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("release"), Create: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.release = commit("server.js", "require('http')", "Add server")
+
+	bareDir := filepath.Join(t.TempDir(), "shop.git")
+	_, err = git.PlainInitWithOptions(bareDir, &git.PlainInitOptions{
+		Bare:        true,
+		InitOptions: git.InitOptions{DefaultBranch: plumbing.NewBranchReferenceName("main")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := work.CreateRemote(&gitconfig.RemoteConfig{Name: "origin", URLs: []string{bareDir}}); err != nil {
+		t.Fatal(err)
+	}
+	err = work.Push(&git.PushOptions{
+		RemoteName: "origin",
+		RefSpecs:   []gitconfig.RefSpec{"refs/heads/*:refs/heads/*", "refs/tags/*:refs/tags/*"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo.url = bareDir
+	return repo
+}
+
+func TestClone(t *testing.T) {
+	repo := newTestRepository(t)
+
+	tests := []struct {
+		ref    string
+		branch string
+		tag    string
+		commit plumbing.Hash
+		files  []string
+	}{
+		{ref: "", branch: "main", commit: repo.main, files: []string{"package.json"}},
+		{ref: "main", branch: "main", commit: repo.main, files: []string{"package.json"}},
+		{ref: "release", branch: "release", commit: repo.release, files: []string{"package.json", "server.js"}},
+		{ref: "v1.0.0", tag: "v1.0.0", commit: repo.main, files: []string{"package.json"}},
+	}
+
+	for _, tt := range tests {
+		checkout, err := Clone(repo.url, tt.ref, GitAuth{})
+		if err != nil {
+			t.Fatalf("Clone(%q): %v", tt.ref, err)
+		}
+		defer os.RemoveAll(checkout.Dir)
+
+		if checkout.Branch != tt.branch || checkout.Tag != tt.tag || checkout.Commit != tt.commit.String() {
+			t.Errorf("Clone(%q) = branch %q, tag %q, commit %s, want branch %q, tag %q, commit %s",
+				tt.ref, checkout.Branch, checkout.Tag, checkout.Commit, tt.branch, tt.tag, tt.commit)
+		}
+		for _, file := range tt.files {
+			if _, err := os.Stat(filepath.Join(checkout.Dir, file)); err != nil {
+				t.Errorf("Clone(%q): %v", tt.ref, err)
+			}
+		}
+		if tt.ref == "" || tt.ref == "main" {
+			if _, err := os.Stat(filepath.Join(checkout.Dir, "server.js")); !os.IsNotExist(err) {
+				t.Errorf("Clone(%q) checked out server.js of the release branch", tt.ref)
+			}
+		}
+	}
+}
+
+func TestCloneMissingRef(t *testing.T) {
+	repo := newTestRepository(t)
+
+	_, err := Clone(repo.url, "develop", GitAuth{})
+	if err == nil || !strings.Contains(err.Error(), "no branch or tag develop") {
+		t.Errorf("error = %v", err)
+	}
+
+	if _, err := Clone(filepath.Join(t.TempDir(), "missing.git"), "", GitAuth{}); err == nil {
+		t.Error("cloning a missing repository succeeded")
+	}
+}
+
+func TestChangedFiles(t *testing.T) {
+	repo := newTestRepository(t)
+	checkout, err := Clone(repo.url, "release", GitAuth{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(checkout.Dir)
+
+	files, err := ChangedFiles(checkout.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Errorf("fresh clone has changes: %v", files)
+	}
+
+	for file, content := range map[string]string{
+		"Dockerfile":         "FROM node:20",
+		".plate/config.yaml": "name: shop",
+		"server.js":          "require('https')",
+	} {
+		path := filepath.Join(checkout.Dir, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err = ChangedFiles(checkout.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{".plate/config.yaml", "Dockerfile", "server.js"}
+	if strings.Join(files, ",") != strings.Join(want, ",") {
+		t.Errorf("ChangedFiles() = %v, want %v", files, want)
+	}
+}
+
+func TestIsRemoteURL(t *testing.T) {
+	tests := map[string]bool{
+		"https://git.example.com/org/app.git": true,
+		"ssh://git@git.example.com/org/app":   true,
+		"file:///srv/git/app.git":             true,
+		"git@git.example.com:org/app.git":     true,
+		"deploy@10.0.0.5:app.git":             true,
+		".":                                   false,
+		"../app":                              false,
+		"/home/ada/app":                       false,
+		"apps/web@2":                          false,
+		"./user@host/app":                     false,
+		"C:\\Users\\ada\\app":                 false,
+	}
+	for source, want := range tests {
+		if got := IsRemoteURL(source); got != want {
+			t.Errorf("IsRemoteURL(%q) = %v, want %v", source, got, want)
+		}
+	}
+}
+
+func TestRepositoryName(t *testing.T) {
+	tests := map[string]string{
+		"https://git.example.com/org/app.git": "app",
+		"https://github.com/org/My_App/":      "my-app",
+		"git@git.example.com:shop.git":        "shop",
+	}
+	for url, want := range tests {
+		if got := RepositoryName(url); got != want {
+			t.Errorf("RepositoryName(%q) = %q, want %q", url, got, want)
+		}
+	}
+}
+
+func TestPushCommitsGeneratedFiles(t *testing.T) {
+	repo := newTestRepository(t)
+	checkout, err := Clone(repo.url, "main", GitAuth{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(checkout.Dir)
+
+	for file, content := range map[string]string{
+		"Dockerfile":         "FROM node:20",
+		".dockerignore":      "node_modules",
+		".plate/config.yaml": "name: shop\nproject_id: 5",
+		"notes.txt":          "not ready",
+	} {
+		path := filepath.Join(checkout.Dir, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	remoteDir := filepath.Join(t.TempDir(), "plate.git")
+	if _, err := git.PlainInit(remoteDir, true); err != nil {
+		t.Fatal(err)
+	}
+	generated := GeneratedFiles(&ProjectConfig{Name: "shop"})
+	result, err := Push(checkout.Dir, remoteDir, "main", generated, GitAuth{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(result.Committed, ",") != ".dockerignore,.plate/config.yaml,Dockerfile" {
+		t.Errorf("committed = %v", result.Committed)
+	}
+	if strings.Join(result.Uncommitted, ",") != "notes.txt" {
+		t.Errorf("uncommitted = %v", result.Uncommitted)
+	}
+	if result.Branch != "main" || result.Commit == repo.main.String() {
+		t.Errorf("pushed %s at %s", result.Branch, result.Commit)
+	}
+
+	remote, err := git.PlainOpen(remoteDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := remote.Reference(plumbing.NewBranchReferenceName("main"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref.Hash().String() != result.Commit {
+		t.Errorf("remote main = %s, want %s", ref.Hash(), result.Commit)
+	}
+	commit, err := remote.CommitObject(ref.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := commit.File(".plate/config.yaml"); err != nil {
+		t.Errorf("pushed commit lacks the configuration: %v", err)
+	}
+	if _, err := commit.File("notes.txt"); err == nil {
+		t.Error("pushed commit holds an uncommitted change")
+	}
+
+	// Pushing again commits nothing new
+	result, err = Push(checkout.Dir, remoteDir, "main", generated, GitAuth{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Committed) != 0 || result.Commit != ref.Hash().String() {
+		t.Errorf("second push = %+v", result)
+	}
+}
+
+func TestPushRefusesStagedChanges(t *testing.T) {
+	repo := newTestRepository(t)
+	checkout, err := Clone(repo.url, "main", GitAuth{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(checkout.Dir)
+
+	for _, file := range []string{"Dockerfile", "package.json"} {
+		if err := os.WriteFile(filepath.Join(checkout.Dir, file), []byte("changed"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	local, err := git.PlainOpen(checkout.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := local.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := worktree.Add("package.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Push(checkout.Dir, filepath.Join(t.TempDir(), "plate.git"), "main", []string{"Dockerfile"}, GitAuth{})
+	if err == nil || !strings.Contains(err.Error(), "package.json is staged") {
+		t.Errorf("error = %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package project
+
+import "strings"
+
+// jvmStartup gives JVM frameworks up to five minutes to start before the
+// liveness probe takes over.
+func jvmStartup() *Probe {
+	return &Probe{PeriodSeconds: 10, FailureThreshold: 30}
+}
+
+// defaultHealthCheck picks probes for a detected framework. Health endpoints
+// are only used when the project evidently exposes them; otherwise nil is
+// returned and the probes check that the port accepts connections, which
+// works for applications that answer 404 on /.
+func defaultHealthCheck(dir string, d *Detection) *HealthCheck {
+	switch d.Framework {
+	case "spring-boot":
+		check := &HealthCheck{Startup: jvmStartup()}
+		if manifest, ok := javaManifest(dir); ok && strings.Contains(manifest, "spring-boot-starter-actuator") {
+			check.Liveness = &Probe{Path: "/actuator/health/liveness"}
+			check.Readiness = &Probe{Path: "/actuator/health/readiness"}
+			d.explain("health", "", "Spring Boot Actuator provides liveness and readiness endpoints")
+		} else {
+			d.explain("health", "", "no Actuator dependency, probing the port with a startup allowance for the JVM")
+		}
+		return check
+	case "quarkus":
+		check := &HealthCheck{Startup: jvmStartup()}
+		if manifest, ok := javaManifest(dir); ok && strings.Contains(manifest, "smallrye-health") {
+			check.Liveness = &Probe{Path: "/q/health/live"}
+			check.Readiness = &Probe{Path: "/q/health/ready"}
+			d.explain("health", "", "SmallRye Health provides liveness and readiness endpoints")
+		} else {
+			d.explain("health", "", "no SmallRye Health dependency, probing the port with a startup allowance for the JVM")
+		}
+		return check
+	case "micronaut":
+		check := &HealthCheck{Startup: jvmStartup()}
+		if manifest, ok := javaManifest(dir); ok && strings.Contains(manifest, "micronaut-management") {
+			check.Liveness = &Probe{Path: "/health/liveness"}
+			check.Readiness = &Probe{Path: "/health/readiness"}
+			d.explain("health", "", "Micronaut management provides liveness and readiness endpoints")
+		} else {
+			d.explain("health", "", "no management dependency, probing the port with a startup allowance for the JVM")
+		}
+		return check
+	case "rails":
+		if routes, ok := readFile(dir, "config/routes.rb"); ok && strings.Contains(routes, "rails/health") {
+			d.explain("health", "config/routes.rb", "Rails health check route mounted at /up")
+			return &HealthCheck{Path: "/up"}
+		}
+	case "laravel":
+		if app, ok := readFile(dir, "bootstrap/app.php"); ok && strings.Contains(app, "health:") {
+			d.explain("health", "bootstrap/app.php", "Laravel health route configured at /up")
+			return &HealthCheck{Path: "/up"}
+		}
+	case "nextjs", "nuxt", "remix":
+		d.explain("health", "", "%s renders the root page, probing /", d.Framework)
+		return &HealthCheck{Path: "/", Startup: &Probe{PeriodSeconds: 5, FailureThreshold: 24}}
+	}
+
+	d.explain("health", "", "no health endpoint detected, probing that port %d accepts connections", d.Port)
+	return nil
+}
+
+// javaManifest returns the build file of a JVM project.
+func javaManifest(dir string) (string, bool) {
+	for _, name := range []string{"pom.xml", "build.gradle.kts", "build.gradle"} {
+		if content, ok := readFile(dir, name); ok {
+			return content, true
+		}
+	}
+	return "", false
+}
@@ -5,226 +5,228 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
-
-	"gopkg.in/yaml.v3"
 )
 
 type Importer struct {
-	// Add any dependencies here
-}
-
-type ProjectConfig struct {
-	Name        string            `yaml:"name"`
-	Runtime     string            `yaml:"runtime"`
-	Environment string            `yaml:"environment"`
-	Port        int               `yaml:"port"`
-	BuildCmd    string            `yaml:"build_cmd,omitempty"`
-	StartCmd    string            `yaml:"start_cmd,omitempty"`
-	EnvVars     map[string]string `yaml:"env_vars,omitempty"`
+	// Explain prints the evidence behind each detection decision.
+	Explain bool
+	// RegenerateDockerfile replaces an existing Dockerfile and .dockerignore,
+	// printing the diff against the current files.
+	RegenerateDockerfile bool
+	// Dockerfiles renders the generated Dockerfile and .dockerignore.
+	Dockerfiles DockerfileGenerator
+	// Single disables scanning for several services in one repository.
+	Single bool
+	// DefaultVersions overrides entries of the built-in runtime version
+	// matrix for projects that do not pin a version.
+	DefaultVersions map[string]string
 }
 
 func NewImporter() *Importer {
 	return &Importer{}
 }
 
-func (i *Importer) Import(projectPath, name, env, runtime string) error {
+// Import detects the project's services and writes .plate/config.yaml and
+// the Dockerfiles. It returns the written configuration.
+func (i *Importer) Import(projectPath, name, env, runtime string) (*ProjectConfig, error) {
 	// Detect project name if not provided
 	if name == "" {
-		name = filepath.Base(projectPath)
-	}
-
-	// Auto-detect runtime if not provided
-	if runtime == "" {
-		detectedRuntime, err := i.detectRuntime(projectPath)
-		if err != nil {
-			return fmt.Errorf("failed to detect runtime: %w", err)
-		}
-		runtime = detectedRuntime
+		name = sanitizeName(filepath.Base(projectPath))
 	}
 
-	// Create project configuration
 	config := ProjectConfig{
 		Name:        name,
-		Runtime:     runtime,
 		Environment: env,
-		Port:        i.getDefaultPort(runtime),
-		EnvVars:     make(map[string]string),
 	}
 
-	// Set runtime-specific commands
-	i.setRuntimeCommands(&config)
+	// Look for several services unless a single runtime was forced
+	var candidates []ServiceCandidate
+	var workspaceEvidence []Evidence
+	if !i.Single && runtime == "" {
+		candidates, workspaceEvidence = DiscoverServices(projectPath)
+	}
+
+	var detection *Detection
+	if len(candidates) > 0 {
+		config.Services = make(map[string]ServiceConfig, len(candidates))
+		for _, candidate := range candidates {
+			service := i.configureService(filepath.Join(projectPath, filepath.FromSlash(candidate.Path)), candidate.Detection)
+			service.Path = candidate.Path
+			config.Services[candidate.Name] = service
+		}
+	} else {
+		// Run the detector chain, restricted to the given runtime if provided
+		detection = Detect(projectPath, runtime)
+		config.ServiceConfig = i.configureService(projectPath, detection)
+	}
+
+	// Keep the IDs of a previous import so the project is updated rather
+	// than registered again
+	if previous, err := LoadConfig(projectPath); err == nil {
+		config.keepProjectIDs(previous)
+	}
 
-	// Create .plate directory
-	plateDir := filepath.Join(projectPath, ".plate")
-	if err := os.MkdirAll(plateDir, 0755); err != nil {
-		return fmt.Errorf("failed to create .plate directory: %w", err)
+	if err := ValidateConfig(&config); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
 	}
 
 	// Write configuration file
-	configPath := filepath.Join(plateDir, "config.yaml")
-	if err := i.writeConfig(configPath, &config); err != nil {
-		return fmt.Errorf("failed to write configuration: %w", err)
+	if err := SaveConfig(projectPath, &config); err != nil {
+		return nil, fmt.Errorf("failed to write configuration: %w", err)
 	}
 
-	// Generate Dockerfile if it doesn't exist
-	dockerfilePath := filepath.Join(projectPath, "Dockerfile")
-	if _, err := os.Stat(dockerfilePath); os.IsNotExist(err) {
-		if err := i.generateDockerfile(dockerfilePath, runtime); err != nil {
-			return fmt.Errorf("failed to generate Dockerfile: %w", err)
+	// Generate a Dockerfile and .dockerignore in each build context
+	for _, deployable := range config.Deployables() {
+		if err := i.generateDockerfiles(filepath.Join(projectPath, filepath.FromSlash(deployable.Service.Path)), deployable.Service); err != nil {
+			return nil, err
 		}
 	}
 
 	fmt.Printf("Project configured successfully:\n")
 	fmt.Printf("  Name: %s\n", config.Name)
-	fmt.Printf("  Runtime: %s\n", config.Runtime)
 	fmt.Printf("  Environment: %s\n", config.Environment)
-	fmt.Printf("  Port: %d\n", config.Port)
+	if len(config.Services) == 0 {
+		printService("  ", &config.ServiceConfig)
+	} else {
+		fmt.Printf("  Services:\n")
+		for _, deployable := range config.Deployables() {
+			fmt.Printf("    %s (%s):\n", deployable.ProjectName, deployable.Service.Path)
+			printService("      ", deployable.Service)
+		}
+	}
 
-	return nil
-}
+	if detection != nil {
+		for _, warning := range detection.Warnings {
+			fmt.Printf("Warning: %s\n", warning)
+		}
+	}
+	for _, candidate := range candidates {
+		for _, warning := range candidate.Detection.Warnings {
+			fmt.Printf("Warning: %s: %s\n", candidate.Name, warning)
+		}
+	}
 
-func (i *Importer) detectRuntime(projectPath string) (string, error) {
-	// Check for various project files to detect runtime
-	checks := map[string]string{
-		"package.json":   "nodejs",
-		"requirements.txt": "python",
-		"Pipfile":        "python",
-		"go.mod":         "go",
-		"Cargo.toml":     "rust",
-		"pom.xml":        "java",
-		"build.gradle":   "java",
-		"composer.json":  "php",
-		"Gemfile":        "ruby",
-	}
-
-	for file, runtime := range checks {
-		if _, err := os.Stat(filepath.Join(projectPath, file)); err == nil {
-			return runtime, nil
+	if i.Explain {
+		if len(workspaceEvidence) > 0 {
+			printExplanation("Workspace", &Detection{Evidence: workspaceEvidence})
+		}
+		if detection != nil {
+			printExplanation("Detection details", detection)
+		}
+		for _, candidate := range candidates {
+			printExplanation("Detection details for "+candidate.Name, candidate.Detection)
 		}
 	}
 
-	return "generic", nil
+	return &config, nil
 }
 
-func (i *Importer) getDefaultPort(runtime string) int {
-	ports := map[string]int{
-		"nodejs": 3000,
-		"python": 8000,
-		"go":     8080,
-		"rust":   8080,
-		"java":   8080,
-		"php":    8000,
-		"ruby":   3000,
+// configureService derives the build settings for one service directory.
+func (i *Importer) configureService(dir string, detection *Detection) ServiceConfig {
+	version := resolveVersion(dir, detection.Runtime, i.DefaultVersions, detection)
+	buildImage, runImage := baseImages(detection.Runtime, version, detection.BuildCmd)
+
+	return ServiceConfig{
+		Runtime:        detection.Runtime,
+		Framework:      detection.Framework,
+		RuntimeVersion: version,
+		BuildImage:     buildImage,
+		RunImage:       runImage,
+		Port:           detection.Port,
+		BuildCmd:       detection.BuildCmd,
+		StartCmd:       detection.StartCmd,
+		EnvVars:        make(map[string]string),
+		HealthCheck:    defaultHealthCheck(dir, detection),
 	}
+}
 
-	if port, exists := ports[runtime]; exists {
-		return port
+// GeneratedFiles returns the files Import writes for a configuration,
+// relative to the project directory.
+func GeneratedFiles(config *ProjectConfig) []string {
+	files := []string{filepath.Join(".plate", "config.yaml")}
+	for _, deployable := range config.Deployables() {
+		dir := filepath.FromSlash(deployable.Service.Path)
+		files = append(files, filepath.Join(dir, "Dockerfile"), filepath.Join(dir, ".dockerignore"))
 	}
-	return 8080
+	return files
 }
 
-func (i *Importer) setRuntimeCommands(config *ProjectConfig) {
-	switch config.Runtime {
-	case "nodejs":
-		config.BuildCmd = "npm install"
-		config.StartCmd = "npm start"
-	case "python":
-		config.BuildCmd = "pip install -r requirements.txt"
-		config.StartCmd = "python app.py"
-	case "go":
-		config.BuildCmd = "go build -o app ."
-		config.StartCmd = "./app"
-	case "rust":
-		config.BuildCmd = "cargo build --release"
-		config.StartCmd = "./target/release/app"
-	case "java":
-		config.BuildCmd = "mvn clean package"
-		config.StartCmd = "java -jar target/*.jar"
-	case "php":
-		config.StartCmd = "php -S 0.0.0.0:8000"
-	case "ruby":
-		config.BuildCmd = "bundle install"
-		config.StartCmd = "bundle exec ruby app.rb"
+// generateDockerfiles writes the Dockerfile and .dockerignore for a service
+// into its build context.
+func (i *Importer) generateDockerfiles(dir string, service *ServiceConfig) error {
+	dockerfile, err := i.Dockerfiles.Dockerfile(service)
+	if err != nil {
+		return fmt.Errorf("failed to generate Dockerfile: %w", err)
+	}
+	if err := i.writeGenerated(filepath.Join(dir, "Dockerfile"), dockerfile, i.RegenerateDockerfile); err != nil {
+		return fmt.Errorf("failed to write Dockerfile: %w", err)
 	}
-}
 
-func (i *Importer) writeConfig(configPath string, config *ProjectConfig) error {
-	data, err := yaml.Marshal(config)
+	dockerignore, err := i.Dockerfiles.Dockerignore(service)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to generate .dockerignore: %w", err)
 	}
+	if err := i.writeGenerated(filepath.Join(dir, ".dockerignore"), dockerignore, i.RegenerateDockerfile); err != nil {
+		return fmt.Errorf("failed to write .dockerignore: %w", err)
+	}
+
+	return nil
+}
 
-	return os.WriteFile(configPath, data, 0644)
+func printService(indent string, service *ServiceConfig) {
+	if service.RuntimeVersion != "" {
+		fmt.Printf("%sRuntime: %s %s\n", indent, service.Runtime, service.RuntimeVersion)
+	} else {
+		fmt.Printf("%sRuntime: %s\n", indent, service.Runtime)
+	}
+	if service.Framework != "" {
+		fmt.Printf("%sFramework: %s\n", indent, service.Framework)
+	}
+	fmt.Printf("%sPort: %d\n", indent, service.Port)
+	if len(service.Environments) > 0 {
+		fmt.Printf("%sEnvironment overrides: %s\n", indent, strings.Join(service.EnvironmentNames(), ", "))
+	}
 }
 
-func (i *Importer) generateDockerfile(dockerfilePath, runtime string) error {
-	var dockerfile string
-
-	switch runtime {
-	case "nodejs":
-		dockerfile = `FROM node:18-alpine
-WORKDIR /app
-COPY package*.json ./
-RUN npm install
-COPY . .
-EXPOSE 3000
-CMD ["npm", "start"]`
-
-	case "python":
-		dockerfile = `FROM python:3.9-slim
-WORKDIR /app
-COPY requirements.txt .
-RUN pip install -r requirements.txt
-COPY . .
-EXPOSE 8000
-CMD ["python", "app.py"]`
-
-	case "go":
-		dockerfile = `FROM golang:1.21-alpine AS builder
-WORKDIR /app
-COPY go.mod go.sum ./
-RUN go mod download
-COPY . .
-RUN go build -o app .
-
-FROM alpine:latest
-RUN apk --no-cache add ca-certificates
-WORKDIR /root/
-COPY --from=builder /app/app .
-EXPOSE 8080
-CMD ["./app"]`
-
-	case "rust":
-		dockerfile = `FROM rust:1.70 AS builder
-WORKDIR /app
-COPY Cargo.toml Cargo.lock ./
-COPY src ./src
-RUN cargo build --release
-
-FROM debian:bookworm-slim
-COPY --from=builder /app/target/release/app /usr/local/bin/app
-EXPOSE 8080
-CMD ["app"]`
-
-	case "java":
-		dockerfile = `FROM openjdk:17-jdk-slim AS builder
-WORKDIR /app
-COPY pom.xml .
-COPY src ./src
-RUN ./mvnw clean package
-
-FROM openjdk:17-jre-slim
-COPY --from=builder /app/target/*.jar app.jar
-EXPOSE 8080
-CMD ["java", "-jar", "app.jar"]`
-
-	default:
-		dockerfile = `FROM alpine:latest
-WORKDIR /app
-COPY . .
-EXPOSE 8080
-CMD ["./start.sh"]`
-	}
-
-	return os.WriteFile(dockerfilePath, []byte(strings.TrimSpace(dockerfile)+"\n"), 0644)
-}
\ No newline at end of file
+// printExplanation lists the evidence behind each detection decision.
+func printExplanation(title string, detection *Detection) {
+	fmt.Printf("\n%s:\n", title)
+	for _, field := range []string{"services", "runtime", "framework", "version", "build", "start", "port", "health"} {
+		for _, evidence := range detection.Evidence {
+			if evidence.Field != field {
+				continue
+			}
+			if evidence.Source != "" {
+				fmt.Printf("  %-11s %s (%s)\n", field+":", evidence.Reason, evidence.Source)
+			} else {
+				fmt.Printf("  %-11s %s\n", field+":", evidence.Reason)
+			}
+		}
+	}
+}
+
+// writeGenerated renders a generated file. Existing files are left alone
+// unless regenerate is set, in which case the diff against the existing file
+// is printed before it is replaced.
+func (i *Importer) writeGenerated(path string, content []byte, regenerate bool) error {
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	name := filepath.Base(path)
+	if err == nil {
+		if !regenerate {
+			return nil
+		}
+
+		diff := UnifiedDiff("a/"+name, "b/"+name, string(existing), string(content))
+		if diff == "" {
+			fmt.Printf("%s is up to date\n", name)
+			return nil
+		}
+		fmt.Printf("Regenerating %s:\n%s\n", name, diff)
+	}
+
+	return os.WriteFile(path, content, 0644)
+}
@@ -0,0 +1,435 @@
+package project
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// APIVersion is the configuration format written by this version of the CLI.
+const APIVersion = "plate/v1"
+
+// Schema is the JSON Schema of the current configuration format.
+//
+//go:embed schema/config.v1.json
+var Schema string
+
+var compiledSchema = jsonschema.MustCompileString("config.v1.json", Schema)
+
+// schemaDocument is Schema decoded, for looking up the definitions the
+// validator reports problems with.
+var schemaDocument = func() map[string]interface{} {
+	var document map[string]interface{}
+	if err := json.Unmarshal([]byte(Schema), &document); err != nil {
+		panic(err)
+	}
+	return document
+}()
+
+// definedProperties returns the properties the subschema at pointer, such
+// as /$defs/service, defines for an object, including those of the
+// subschemas it references or combines with allOf.
+func definedProperties(pointer string) map[string]bool {
+	properties := map[string]bool{}
+	var collect func(pointer string)
+	collect = func(pointer string) {
+		schema, ok := resolvePointer(schemaDocument, pointer).(map[string]interface{})
+		if !ok {
+			return
+		}
+		if defined, ok := schema["properties"].(map[string]interface{}); ok {
+			for name := range defined {
+				properties[name] = true
+			}
+		}
+		if ref, ok := schema["$ref"].(string); ok && strings.HasPrefix(ref, "#") {
+			collect(strings.TrimPrefix(ref, "#"))
+		}
+		if allOf, ok := schema["allOf"].([]interface{}); ok {
+			for i := range allOf {
+				collect(fmt.Sprintf("%s/allOf/%d", pointer, i))
+			}
+		}
+	}
+	collect(pointer)
+	return properties
+}
+
+// resolvePointer returns the value at a JSON pointer in a decoded
+// document, or nil if there is none.
+func resolvePointer(document interface{}, pointer string) interface{} {
+	value := document
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		if token == "" {
+			continue
+		}
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		switch node := value.(type) {
+		case map[string]interface{}:
+			value = node[token]
+		case []interface{}:
+			index, err := strconv.Atoi(token)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil
+			}
+			value = node[index]
+		default:
+			return nil
+		}
+	}
+	return value
+}
+
+// migration upgrades a configuration document from one format version to
+// the next.
+type migration struct {
+	from, to string
+	apply    func(doc *yaml.Node) error
+}
+
+// migrations is applied in order to bring older configurations up to
+// APIVersion.
+var migrations = []migration{
+	// Configurations written before versioning have the same layout as
+	// plate/v1 and only lack the apiVersion field
+	{from: "", to: "plate/v1", apply: func(doc *yaml.Node) error { return nil }},
+}
+
+// ConfigError is a problem found in a configuration file.
+type ConfigError struct {
+	Line    int    // 0 if unknown
+	Path    string // dotted path of the offending field, e.g. services.api.port
+	Message string
+}
+
+func (e ConfigError) Error() string {
+	var b strings.Builder
+	if e.Line > 0 {
+		fmt.Fprintf(&b, "line %d: ", e.Line)
+	}
+	if e.Path != "" {
+		fmt.Fprintf(&b, "%s: ", e.Path)
+	}
+	b.WriteString(e.Message)
+	return b.String()
+}
+
+// ConfigErrors lists every problem found in a configuration file.
+type ConfigErrors []ConfigError
+
+func (e ConfigErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// ParsedConfig is the result of parsing a configuration file.
+type ParsedConfig struct {
+	Config *ProjectConfig
+	// Version is the apiVersion the file was written with, empty for
+	// configurations from before versioning.
+	Version string
+	// Document is the configuration migrated to APIVersion, keeping the
+	// comments and key order of the original file.
+	Document *yaml.Node
+}
+
+// Migrated reports whether the file was written with an older format.
+func (p *ParsedConfig) Migrated() bool {
+	return p.Version != APIVersion
+}
+
+// ParseConfig decodes and validates a configuration file, migrating older
+// formats to APIVersion. Validation problems are returned as ConfigErrors
+// with the line they were found on.
+func ParseConfig(data []byte) (*ParsedConfig, error) {
+	var file yaml.Node
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	if len(file.Content) == 0 || file.Content[0].Kind != yaml.MappingNode {
+		return nil, ConfigErrors{{Line: 1, Message: "configuration must be a mapping"}}
+	}
+	doc := file.Content[0]
+
+	version := ""
+	if node := mappingValue(doc, "apiVersion"); node != nil {
+		version = node.Value
+	}
+	if err := migrate(doc, version); err != nil {
+		return nil, err
+	}
+
+	if errs := validateDocument(doc); len(errs) > 0 {
+		return nil, errs
+	}
+
+	// Strict decoding catches anything the schema does not describe. A
+	// migrated document is re-encoded first.
+	if version != APIVersion {
+		var err error
+		if data, err = EncodeDocument(&file); err != nil {
+			return nil, err
+		}
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var config ProjectConfig
+	if err := decoder.Decode(&config); err != nil {
+		return nil, err
+	}
+
+	return &ParsedConfig{Config: &config, Version: version, Document: &file}, nil
+}
+
+// ValidateConfig checks a configuration against the schema before it is
+// written.
+func ValidateConfig(config *ProjectConfig) error {
+	versioned := *config
+	versioned.APIVersion = APIVersion
+
+	var doc yaml.Node
+	if err := doc.Encode(&versioned); err != nil {
+		return err
+	}
+	if errs := validateDocument(&doc); len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// migrate upgrades doc from version to APIVersion in place.
+func migrate(doc *yaml.Node, version string) error {
+	for _, m := range migrations {
+		if m.from != version {
+			continue
+		}
+		if err := m.apply(doc); err != nil {
+			return fmt.Errorf("failed to migrate configuration from %s to %s: %w", versionName(m.from), m.to, err)
+		}
+		setMappingValue(doc, "apiVersion", m.to)
+		version = m.to
+	}
+
+	if version != APIVersion {
+		return fmt.Errorf("unsupported apiVersion %q, this version of plate supports %s", version, APIVersion)
+	}
+	return nil
+}
+
+func versionName(version string) string {
+	if version == "" {
+		return "unversioned"
+	}
+	return version
+}
+
+// validateDocument checks doc against the schema, attributing each problem
+// to the line of the offending field.
+func validateDocument(doc *yaml.Node) ConfigErrors {
+	var value interface{}
+	if err := doc.Decode(&value); err != nil {
+		return ConfigErrors{{Line: doc.Line, Message: err.Error()}}
+	}
+
+	// The validator expects values as produced by encoding/json
+	data, err := json.Marshal(value)
+	if err != nil {
+		return ConfigErrors{{Line: doc.Line, Message: err.Error()}}
+	}
+	var instance interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&instance); err != nil {
+		return ConfigErrors{{Line: doc.Line, Message: err.Error()}}
+	}
+
+	err = compiledSchema.Validate(instance)
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		return nil
+	}
+
+	var errs ConfigErrors
+	seen := map[string]bool{}
+	for _, leaf := range leafErrors(validationErr) {
+		// Properties only count as evaluated when the subschema defining
+		// them passes, so a field with an invalid value nested anywhere
+		// would also be reported as unknown. It is only unknown if the
+		// schema rejecting it does not define it for that object.
+		if isDefinedProperty(leaf) {
+			continue
+		}
+		configErr := schemaError(doc, leaf)
+		if key := configErr.Error(); !seen[key] {
+			seen[key] = true
+			errs = append(errs, configErr)
+		}
+	}
+
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Line < errs[j].Line })
+	return errs
+}
+
+// isDefinedProperty reports whether err rejects a property as unevaluated
+// that the schema defines for the object holding it.
+func isDefinedProperty(err *jsonschema.ValidationError) bool {
+	_, location, ok := strings.Cut(err.AbsoluteKeywordLocation, "#")
+	if !ok || !strings.HasSuffix(location, "/unevaluatedProperties") {
+		return false
+	}
+	field := err.InstanceLocation[strings.LastIndex(err.InstanceLocation, "/")+1:]
+	field = strings.NewReplacer("~1", "/", "~0", "~").Replace(field)
+	return definedProperties(strings.TrimSuffix(location, "/unevaluatedProperties"))[field]
+}
+
+// leafErrors returns the problems a validation error is made of. The
+// alternatives of an anyOf are kept together, as only one of them has to
+// be fixed.
+func leafErrors(err *jsonschema.ValidationError) []*jsonschema.ValidationError {
+	if len(err.Causes) == 0 || strings.HasSuffix(err.KeywordLocation, "/anyOf") {
+		return []*jsonschema.ValidationError{err}
+	}
+	var leaves []*jsonschema.ValidationError
+	for _, cause := range err.Causes {
+		leaves = append(leaves, leafErrors(cause)...)
+	}
+	return leaves
+}
+
+// schemaError turns a schema violation into a ConfigError with a message
+// that reads well for YAML authors.
+func schemaError(doc *yaml.Node, err *jsonschema.ValidationError) ConfigError {
+	var path []string
+	for _, token := range strings.Split(strings.TrimPrefix(err.InstanceLocation, "/"), "/") {
+		if token != "" {
+			path = append(path, strings.NewReplacer("~1", "/", "~0", "~").Replace(token))
+		}
+	}
+
+	keyword := err.KeywordLocation[strings.LastIndex(err.KeywordLocation, "/")+1:]
+	message := err.Message
+	switch keyword {
+	case "unevaluatedProperties":
+		message = "unknown field"
+	case "additionalProperties":
+		message = strings.Replace(message, "additionalProperties", "unknown field", 1)
+		message = strings.TrimSuffix(message, " not allowed")
+	case "propertyNames":
+		message = "invalid name: " + message
+	case "not":
+		message = "must be a path inside the repository"
+	case "required":
+		// A single missing field is reported as the problem itself
+		missing := missingProperties(err)
+		if len(missing) == 1 {
+			path, message = append(path, missing[0]), "is required"
+		} else {
+			message = "missing required fields " + strings.Join(missing, ", ")
+		}
+	case "anyOf":
+		var missing []string
+		for _, cause := range err.Causes {
+			missing = append(missing, missingProperties(cause)...)
+		}
+		message = "needs one of " + strings.Join(missing, ", ")
+	}
+
+	line := doc.Line
+	node := doc
+	for _, key := range path {
+		key, value := mappingEntry(node, key)
+		if key == nil {
+			break
+		}
+		// Problems with a name point at the key, others at the value
+		if keyword == "unevaluatedProperties" || keyword == "propertyNames" {
+			line = key.Line
+		} else {
+			line = value.Line
+		}
+		node = value
+	}
+
+	return ConfigError{Line: line, Path: strings.Join(path, "."), Message: message}
+}
+
+// missingProperties returns the properties a required violation lists.
+func missingProperties(err *jsonschema.ValidationError) []string {
+	_, list, ok := strings.Cut(err.Message, "missing properties:")
+	if !ok {
+		return nil
+	}
+	var properties []string
+	for _, property := range strings.Split(list, ",") {
+		properties = append(properties, strings.Trim(strings.TrimSpace(property), "'"))
+	}
+	return properties
+}
+
+// mappingEntry returns the key and value nodes of a mapping entry. For a
+// sequence, key is an index and both nodes are the item.
+func mappingEntry(node *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if node.Kind == yaml.SequenceNode {
+		index, err := strconv.Atoi(key)
+		if err != nil || index < 0 || index >= len(node.Content) {
+			return nil, nil
+		}
+		return node.Content[index], node.Content[index]
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i], node.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	_, value := mappingEntry(node, key)
+	return value
+}
+
+// setMappingValue sets a scalar entry, adding it as the first key if it is
+// missing.
+func setMappingValue(node *yaml.Node, key, value string) {
+	if existing := mappingValue(node, key); existing != nil {
+		existing.Kind, existing.Tag, existing.Value = yaml.ScalarNode, "!!str", value
+		return
+	}
+	keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}
+	// Keep a comment at the top of the file above the new first key
+	if len(node.Content) > 0 {
+		keyNode.HeadComment, node.Content[0].HeadComment = node.Content[0].HeadComment, ""
+	}
+	node.Content = append([]*yaml.Node{
+		keyNode,
+		{Kind: yaml.ScalarNode, Tag: "!!str", Value: value},
+	}, node.Content...)
+}
+
+// EncodeDocument renders a YAML document with the indentation used for
+// generated configuration files.
+func EncodeDocument(doc *yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,116 @@
+package project
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParseConfigUnknownFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   []string
+	}{
+		{
+			name: "project fields of a service",
+			config: `apiVersion: plate/v1
+name: shop
+services:
+  api:
+    apiVersion: plate/v1
+    name: api
+    port: 8080
+`,
+			want: []string{"line 5: services.api.apiVersion: unknown field", "line 6: services.api.name: unknown field"},
+		},
+		{
+			name: "invalid value of a service field",
+			config: `apiVersion: plate/v1
+name: shop
+services:
+  api:
+    port: http
+`,
+			want: []string{"line 5: services.api.port: expected integer, but got string"},
+		},
+		{
+			name: "invalid value of a project field",
+			config: `apiVersion: plate/v1
+name: shop
+replicas: -1
+portt: 8080
+`,
+			want: []string{"line 3: replicas: must be >= 0 but found -1", "line 4: portt: unknown field"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseConfig([]byte(tt.config))
+			var errs ConfigErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("error = %v, want ConfigErrors", err)
+			}
+			if got := err.Error(); got != strings.Join(tt.want, "\n") {
+				t.Errorf("errors:\n%s\nwant:\n%s", got, strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+// The fixtures in testdata/config are shared with the service, which
+// validates projects against the same schema. The first line of each lists
+// the fields that must be rejected.
+func TestParseConfigFixtures(t *testing.T) {
+	paths, err := filepath.Glob("testdata/config/*.yaml")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no fixtures: %v", err)
+	}
+
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := fixtureProblems(t, data)
+
+			var got []string
+			_, err = ParseConfig(data)
+			var errs ConfigErrors
+			if errors.As(err, &errs) {
+				for _, e := range errs {
+					got = append(got, e.Path)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(got)
+			if strings.Join(got, ", ") != strings.Join(want, ", ") {
+				t.Errorf("problems with %s, want %s\n%v", strings.Join(got, ", "), strings.Join(want, ", "), err)
+			}
+		})
+	}
+}
+
+// fixtureProblems returns the sorted fields listed on the "# problems:"
+// line of a fixture.
+func fixtureProblems(t *testing.T, data []byte) []string {
+	line, _, _ := strings.Cut(string(data), "\n")
+	list, ok := strings.CutPrefix(line, "# problems:")
+	if !ok {
+		t.Fatal("fixture does not start with # problems:")
+	}
+	var problems []string
+	for _, field := range strings.Split(list, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			problems = append(problems, field)
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
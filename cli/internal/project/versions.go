@@ -0,0 +1,245 @@
+package project
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultVersions is the built-in runtime version matrix used when a project
+// does not pin a version. It can be overridden per runtime with the
+// runtime_versions key in ~/.plate.yaml.
+var DefaultVersions = map[string]string{
+	"nodejs": "20",
+	"python": "3.12",
+	"go":     "1.22",
+	"rust":   "1.77",
+	"java":   "21",
+	"php":    "8.3",
+	"ruby":   "3.3",
+}
+
+// versionSource is a single place a runtime version may be declared.
+type versionSource struct {
+	file    string
+	extract func(content string) string
+}
+
+var (
+	versionPattern     = regexp.MustCompile(`\d+(?:\.\d+){0,2}`)
+	goDirectivePattern = regexp.MustCompile(`(?m)^go\s+(\d+\.\d+(?:\.\d+)?)\s*$`)
+	goToolchainPattern = regexp.MustCompile(`(?m)^toolchain\s+go(\d+\.\d+(?:\.\d+)?)\s*$`)
+	rustChannelPattern = regexp.MustCompile(`(?m)^\s*channel\s*=\s*"([^"]+)"`)
+	rustVersionPattern = regexp.MustCompile(`(?m)^\s*rust-version\s*=\s*"([^"]+)"`)
+	pomJavaPattern     = regexp.MustCompile(`<(?:java\.version|maven\.compiler\.release|maven\.compiler\.target)>\s*([\d.]+)\s*<`)
+	gradleJavaPattern  = regexp.MustCompile(`(?:JavaLanguageVersion\.of\(|sourceCompatibility\s*=\s*['"]?(?:JavaVersion\.VERSION_)?)(\d+(?:[._]\d+)?)`)
+	gemfileRubyPattern = regexp.MustCompile(`(?m)^\s*ruby\s+['"]([^'"]+)['"]`)
+	pythonReqPattern   = regexp.MustCompile(`(?m)^\s*requires-python\s*=\s*"([^"]+)"`)
+	runtimeTxtPattern  = regexp.MustCompile(`python-(\d+\.\d+(?:\.\d+)?)`)
+)
+
+// asdfNames maps runtimes to their plugin names in .tool-versions.
+var asdfNames = map[string][]string{
+	"nodejs": {"nodejs", "node"},
+	"python": {"python"},
+	"go":     {"golang", "go"},
+	"rust":   {"rust"},
+	"java":   {"java"},
+	"php":    {"php"},
+	"ruby":   {"ruby"},
+}
+
+func plainVersion(content string) string {
+	return strings.TrimSpace(strings.SplitN(content, "\n", 2)[0])
+}
+
+func submatch(pattern *regexp.Regexp) func(string) string {
+	return func(content string) string {
+		if match := pattern.FindStringSubmatch(content); match != nil {
+			return match[1]
+		}
+		return ""
+	}
+}
+
+// versionSources lists, in precedence order, where each runtime's version
+// may be pinned.
+var versionSources = map[string][]versionSource{
+	"nodejs": {
+		{".nvmrc", plainVersion},
+		{".node-version", plainVersion},
+		{"package.json", func(content string) string {
+			var pkg packageJSON
+			if jsonUnmarshal(content, &pkg) {
+				return pkg.Engines["node"]
+			}
+			return ""
+		}},
+	},
+	"python": {
+		{".python-version", plainVersion},
+		{"runtime.txt", submatch(runtimeTxtPattern)},
+		{"pyproject.toml", submatch(pythonReqPattern)},
+	},
+	"go": {
+		{"go.mod", func(content string) string {
+			if toolchain := submatch(goToolchainPattern)(content); toolchain != "" {
+				return toolchain
+			}
+			return submatch(goDirectivePattern)(content)
+		}},
+	},
+	"rust": {
+		{"rust-toolchain.toml", submatch(rustChannelPattern)},
+		{"rust-toolchain", plainVersion},
+		{"Cargo.toml", submatch(rustVersionPattern)},
+	},
+	"java": {
+		{".java-version", plainVersion},
+		{"pom.xml", submatch(pomJavaPattern)},
+		{"build.gradle.kts", submatch(gradleJavaPattern)},
+		{"build.gradle", submatch(gradleJavaPattern)},
+	},
+	"php": {
+		{".php-version", plainVersion},
+		{"composer.json", func(content string) string {
+			var composer composerJSON
+			if jsonUnmarshal(content, &composer) {
+				return composer.Require["php"]
+			}
+			return ""
+		}},
+	},
+	"ruby": {
+		{".ruby-version", func(content string) string {
+			return strings.TrimPrefix(plainVersion(content), "ruby-")
+		}},
+		{"Gemfile", submatch(gemfileRubyPattern)},
+	},
+}
+
+// normalizeVersion turns a declared version or version range into an image
+// tag. Exact versions are kept; ranges are reduced to the lowest major (or
+// major.minor for Python, whose minors are not compatible).
+func normalizeVersion(runtime, declared string) string {
+	declared = strings.TrimPrefix(strings.TrimSpace(declared), "v")
+	if runtime == "java" {
+		// Gradle spells 1.8 as JavaVersion.VERSION_1_8
+		declared = strings.ReplaceAll(declared, "_", ".")
+	}
+	version := versionPattern.FindString(declared)
+	if version == "" {
+		return ""
+	}
+
+	parts := strings.Split(version, ".")
+	exact := declared == version
+	switch runtime {
+	case "java":
+		// Temurin images are tagged by feature release only, which
+		// releases before 9 spell as 1.<feature>
+		if parts[0] == "1" && len(parts) > 1 {
+			return parts[1]
+		}
+		return parts[0]
+	case "python", "ruby", "php":
+		if !exact && len(parts) > 2 {
+			parts = parts[:2]
+		}
+		if len(parts) < 2 {
+			return ""
+		}
+	case "go", "rust":
+		if len(parts) < 2 {
+			return ""
+		}
+	default:
+		if !exact {
+			parts = parts[:1]
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// resolveVersion finds the version pinned by the project, falling back to
+// the defaults matrix.
+func resolveVersion(dir, runtime string, defaults map[string]string, d *Detection) string {
+	for _, source := range versionSources[runtime] {
+		content, ok := readFile(dir, source.file)
+		if !ok {
+			continue
+		}
+		declared := source.extract(content)
+		if declared == "" {
+			continue
+		}
+		if version := normalizeVersion(runtime, declared); version != "" {
+			d.explain("version", source.file, "%s pins %s", source.file, declared)
+			return version
+		}
+		d.explain("version", source.file, "ignoring unsupported version %q", declared)
+	}
+
+	if content, ok := readFile(dir, ".tool-versions"); ok {
+		for _, line := range strings.Split(content, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) < 2 {
+				continue
+			}
+			for _, name := range asdfNames[runtime] {
+				if fields[0] != name {
+					continue
+				}
+				if version := normalizeVersion(runtime, fields[1]); version != "" {
+					d.explain("version", ".tool-versions", "%s %s pinned", name, fields[1])
+					return version
+				}
+			}
+		}
+	}
+
+	if version, ok := defaults[runtime]; ok && version != "" {
+		d.explain("version", "", "no version pinned, using default %s", version)
+		return version
+	}
+	if version, ok := DefaultVersions[runtime]; ok {
+		d.explain("version", "", "no version pinned, using built-in default %s", version)
+		return version
+	}
+	return ""
+}
+
+// baseImages returns the build and runtime images for a runtime version.
+// The build command decides the build image where the toolchain is not part
+// of the runtime image, e.g. Maven or Gradle without a wrapper script.
+func baseImages(runtime, version, buildCmd string) (string, string) {
+	switch runtime {
+	case "nodejs":
+		image := fmt.Sprintf("node:%s-alpine", version)
+		return image, image
+	case "python":
+		image := fmt.Sprintf("python:%s-slim", version)
+		return image, image
+	case "go":
+		return fmt.Sprintf("golang:%s-alpine", version), "alpine:3.19"
+	case "rust":
+		return fmt.Sprintf("rust:%s", version), "debian:bookworm-slim"
+	case "java":
+		runImage := fmt.Sprintf("eclipse-temurin:%s-jre", version)
+		switch {
+		case strings.HasPrefix(buildCmd, "mvn "):
+			return fmt.Sprintf("maven:3.9-eclipse-temurin-%s", version), runImage
+		case strings.HasPrefix(buildCmd, "gradle "):
+			return fmt.Sprintf("gradle:8-jdk%s", version), runImage
+		}
+		return fmt.Sprintf("eclipse-temurin:%s-jdk", version), runImage
+	case "php":
+		image := fmt.Sprintf("php:%s-cli", version)
+		return image, image
+	case "ruby":
+		image := fmt.Sprintf("ruby:%s-slim", version)
+		return image, image
+	default:
+		return "alpine:3.19", "alpine:3.19"
+	}
+}
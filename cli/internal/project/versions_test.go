@@ -0,0 +1,62 @@
+package project
+
+import "testing"
+
+func TestNormalizeVersion(t *testing.T) {
+	tests := []struct {
+		runtime, declared, want string
+	}{
+		{"nodejs", "20", "20"},
+		{"nodejs", "v20.11.1", "20.11.1"},
+		{"nodejs", ">=18.0.0", "18"},
+		{"nodejs", "^18", "18"},
+		{"nodejs", "lts/*", ""},
+		{"python", "3.12", "3.12"},
+		{"python", "3.12.1", "3.12.1"},
+		{"python", ">=3.11.4", "3.11"},
+		{"python", "3", ""},
+		{"ruby", "3.3.0", "3.3.0"},
+		{"ruby", "~> 3.2", "3.2"},
+		{"php", "^8.2", "8.2"},
+		{"go", "1.22", "1.22"},
+		{"go", "1.22.1", "1.22.1"},
+		{"go", "1", ""},
+		{"rust", "1.75.0", "1.75.0"},
+		{"rust", "stable", ""},
+		{"java", "17", "17"},
+		{"java", "21.0.2", "21"},
+		{"java", "1.8", "8"},
+		{"java", "1.11", "11"},
+		{"java", "1_8", "8"},
+	}
+	for _, tt := range tests {
+		if got := normalizeVersion(tt.runtime, tt.declared); got != tt.want {
+			t.Errorf("normalizeVersion(%q, %q) = %q, want %q", tt.runtime, tt.declared, got, tt.want)
+		}
+	}
+}
+
+func TestJavaVersionSources(t *testing.T) {
+	tests := []struct {
+		file, content, want string
+	}{
+		{".java-version", "1.8\n", "8"},
+		{"pom.xml", "<properties><java.version>1.8</java.version></properties>", "8"},
+		{"pom.xml", "<maven.compiler.release>17</maven.compiler.release>", "17"},
+		{"build.gradle.kts", "java { toolchain { languageVersion = JavaLanguageVersion.of(21) } }", "21"},
+		{"build.gradle", "sourceCompatibility = '1.8'", "8"},
+		{"build.gradle", "sourceCompatibility = JavaVersion.VERSION_1_8", "8"},
+		{"build.gradle", "sourceCompatibility = JavaVersion.VERSION_17", "17"},
+	}
+	for _, tt := range tests {
+		var got string
+		for _, source := range versionSources["java"] {
+			if source.file == tt.file {
+				got = normalizeVersion("java", source.extract(tt.content))
+			}
+		}
+		if got != tt.want {
+			t.Errorf("%s %q: version %q, want %q", tt.file, tt.content, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package project
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// conventionalServiceDirs are scanned even when no workspace file declares
+// them.
+var conventionalServiceDirs = []string{"apps/*", "services/*"}
+
+var (
+	goWorkUsePattern   = regexp.MustCompile(`(?m)^\s*use\s+(\S+)\s*$`)
+	goWorkBlockPattern = regexp.MustCompile(`(?s)use\s*\((.*?)\)`)
+)
+
+// ServiceCandidate is a deployable directory found inside a repository.
+type ServiceCandidate struct {
+	Name      string
+	Path      string // relative to the repository root, slash separated
+	Detection *Detection
+}
+
+// DiscoverServices scans workspace definitions and conventional directories
+// for deployable services. Directories holding libraries rather than
+// applications are skipped. The evidence explains where each pattern came
+// from.
+func DiscoverServices(root string) ([]ServiceCandidate, []Evidence) {
+	var evidence []Evidence
+	patterns := map[string]string{}
+	addPatterns := func(source string, globs []string) {
+		for _, glob := range globs {
+			glob = strings.TrimPrefix(strings.TrimSpace(glob), "./")
+			if glob == "" || glob == "." || strings.HasPrefix(glob, "!") {
+				continue
+			}
+			// Recursive globs are treated as a single level
+			glob = strings.ReplaceAll(glob, "**", "*")
+			if _, seen := patterns[glob]; !seen {
+				patterns[glob] = source
+			}
+		}
+	}
+
+	if globs := packageJSONWorkspaces(root); len(globs) > 0 {
+		evidence = append(evidence, Evidence{Field: "services", Source: "package.json", Reason: "npm/yarn workspaces declared"})
+		addPatterns("package.json", globs)
+	}
+
+	var pnpm struct {
+		Packages []string `yaml:"packages"`
+	}
+	if content, ok := readFile(root, "pnpm-workspace.yaml"); ok && yaml.Unmarshal([]byte(content), &pnpm) == nil && len(pnpm.Packages) > 0 {
+		evidence = append(evidence, Evidence{Field: "services", Source: "pnpm-workspace.yaml", Reason: "pnpm workspace declared"})
+		addPatterns("pnpm-workspace.yaml", pnpm.Packages)
+	}
+
+	if content, ok := readFile(root, "go.work"); ok {
+		var uses []string
+		for _, match := range goWorkUsePattern.FindAllStringSubmatch(content, -1) {
+			uses = append(uses, match[1])
+		}
+		for _, block := range goWorkBlockPattern.FindAllStringSubmatch(content, -1) {
+			for _, line := range strings.Split(block[1], "\n") {
+				if line = strings.TrimSpace(strings.SplitN(line, "//", 2)[0]); line != "" {
+					uses = append(uses, line)
+				}
+			}
+		}
+		if len(uses) > 0 {
+			evidence = append(evidence, Evidence{Field: "services", Source: "go.work", Reason: "Go workspace modules declared"})
+			addPatterns("go.work", uses)
+		}
+	}
+
+	var cargo struct {
+		Workspace struct {
+			Members []string `toml:"members"`
+		} `toml:"workspace"`
+	}
+	if readTOML(root, "Cargo.toml", &cargo) && len(cargo.Workspace.Members) > 0 {
+		evidence = append(evidence, Evidence{Field: "services", Source: "Cargo.toml", Reason: "Cargo workspace members declared"})
+		addPatterns("Cargo.toml", cargo.Workspace.Members)
+	}
+
+	addPatterns("", conventionalServiceDirs)
+
+	globs := make([]string, 0, len(patterns))
+	for glob := range patterns {
+		globs = append(globs, glob)
+	}
+	sort.Strings(globs)
+
+	seen := map[string]bool{}
+	var candidates []ServiceCandidate
+	for _, glob := range globs {
+		matches, err := filepath.Glob(filepath.Join(root, filepath.FromSlash(glob)))
+		if err != nil {
+			continue
+		}
+		sort.Strings(matches)
+
+		for _, dir := range matches {
+			info, err := os.Stat(dir)
+			if err != nil || !info.IsDir() {
+				continue
+			}
+			rel, err := filepath.Rel(root, dir)
+			if err != nil || rel == "." || seen[rel] {
+				continue
+			}
+			seen[rel] = true
+
+			detection := Detect(dir, "")
+			if !isDeployable(dir, detection) {
+				continue
+			}
+
+			source := patterns[glob]
+			if source == "" {
+				source = "conventional layout"
+			}
+			detection.explain("runtime", "", "service directory %s matched %q from %s", filepath.ToSlash(rel), glob, source)
+			candidates = append(candidates, ServiceCandidate{
+				Path:      filepath.ToSlash(rel),
+				Detection: detection,
+			})
+		}
+	}
+
+	assignServiceNames(candidates)
+	return candidates, evidence
+}
+
+func packageJSONWorkspaces(root string) []string {
+	var pkg struct {
+		Workspaces json.RawMessage `json:"workspaces"`
+	}
+	if !readJSON(root, "package.json", &pkg) || len(pkg.Workspaces) == 0 {
+		return nil
+	}
+
+	var globs []string
+	if json.Unmarshal(pkg.Workspaces, &globs) == nil {
+		return globs
+	}
+
+	var object struct {
+		Packages []string `json:"packages"`
+	}
+	if json.Unmarshal(pkg.Workspaces, &object) == nil {
+		return object.Packages
+	}
+	return nil
+}
+
+// isDeployable filters out directories that hold libraries rather than
+// services: workspace packages without a start script, Rust library crates
+// and Go modules without a main package.
+func isDeployable(dir string, detection *Detection) bool {
+	switch detection.Runtime {
+	case "generic":
+		return fileExists(dir, "Dockerfile")
+	case "nodejs":
+		var pkg packageJSON
+		readJSON(dir, "package.json", &pkg)
+		_, hasStart := pkg.Scripts["start"]
+		return hasStart || detection.Framework != "" || fileExists(dir, "Dockerfile")
+	case "rust":
+		return fileExists(dir, "src/main.rs") || fileExists(dir, "src/bin")
+	case "go":
+		return fileExists(dir, "main.go") || fileExists(dir, "cmd")
+	}
+	return true
+}
+
+// assignServiceNames names each service after its directory, falling back
+// to the full path when two directories share a name.
+func assignServiceNames(candidates []ServiceCandidate) {
+	counts := map[string]int{}
+	for _, candidate := range candidates {
+		counts[filepath.Base(candidate.Path)]++
+	}
+	for i := range candidates {
+		name := filepath.Base(candidates[i].Path)
+		if counts[name] > 1 {
+			name = strings.ReplaceAll(candidates[i].Path, "/", "-")
+		}
+		candidates[i].Name = sanitizeName(name)
+	}
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// sanitizeName turns a directory or package name into a DNS-1123 label
+// usable as a project name.
+func sanitizeName(name string) string {
+	name = invalidNameChars.ReplaceAllString(strings.ToLower(name), "-")
+	return strings.Trim(name, "-")
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/plate/service/internal/api"
 	"github.com/plate/service/internal/config"
+	"github.com/plate/service/internal/database"
 	"github.com/plate/service/internal/services"
 	"github.com/spf13/cobra"
 )
@@ -24,8 +25,17 @@ This will start the HTTP server and initialize all required services.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		cfg := config.Load()
 		
+		// Connect to the database. Without it the API still serves cluster
+		// state, but project registration and deployments are unavailable.
+		db, err := database.Initialize(cfg.Database)
+		if err != nil {
+			fmt.Printf("Warning: %v\n", err)
+			fmt.Println("Continuing without database...")
+			db = nil
+		}
+
 		// Initialize services
-		serviceManager := services.NewManager(cfg, nil)
+		serviceManager := services.NewManager(cfg, db)
 		if err := serviceManager.Initialize(); err != nil {
 			fmt.Fprintf(os.Stderr, "Failed to initialize services: %v\n", err)
 			os.Exit(1)
@@ -39,6 +49,8 @@ This will start the HTTP server and initialize all required services.`,
 			Addr:    ":" + cfg.Port,
 			Handler: server.Router(),
 		}
+		// Event streams would otherwise hold up the shutdown
+		srv.RegisterOnShutdown(serviceManager.Events.Close)
 
 		// Graceful shutdown
 		go func() {
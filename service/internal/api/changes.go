@@ -0,0 +1,75 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/plate/service/internal/services"
+	"gorm.io/gorm"
+)
+
+// handleGetDeploymentChanges lists the commits, authors and merged pull
+// requests a deployment shipped since the last successful deployment
+// before it.
+func (s *Server) handleGetDeploymentChanges(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deployment ID"})
+		return
+	}
+
+	s.respondChanges(c, uint(id))
+}
+
+// handleGetProjectChanges lists the changes of the last successful
+// deployment of a project in the environment given by ?env=.
+func (s *Server) handleGetProjectChanges(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+	env := c.Query("env")
+	if env == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The env query parameter is required"})
+		return
+	}
+
+	deployment, err := s.services.Deployment.LatestDeployment(uint(id), env)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project has no successful deployment in " + env})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.respondChanges(c, deployment.ID)
+}
+
+// respondChanges writes the changelog of a deployment as JSON or, with
+// ?format=markdown, as Markdown release notes.
+func (s *Server) respondChanges(c *gin.Context, deploymentID uint) {
+	changelog, err := s.services.Deployment.Changes(deploymentID)
+	var unavailable *services.ChangesUnavailableError
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deployment not found"})
+		return
+	case errors.As(err, &unavailable):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	if c.Query("format") == "markdown" {
+		c.Data(http.StatusOK, "text/markdown; charset=utf-8", []byte(changelog.Markdown()))
+		return
+	}
+	c.JSON(http.StatusOK, changelog)
+}
@@ -1,30 +1,50 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
-	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/plate/service/internal/models"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"github.com/plate/service/internal/services"
 )
 
 func (s *Server) handleListDeployments(c *gin.Context) {
-	// Get deployments from all Plate-managed namespaces
-	namespaces := []string{"dev", "staging", "production"}
+	// Get deployments from the namespaces of all environments
+	environments, err := s.environmentNamespaces("")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get environments"})
+		return
+	}
+	// The records of the latest deployments give the listed ones their IDs
+	var latest []models.Deployment
+	if s.services.Deployment != nil {
+		latest, err = s.services.Deployment.ListLatest()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get deployments"})
+			return
+		}
+	}
+	// Workloads are told by the project ID label they were deployed with,
+	// or by their name, which is that of the project, if they predate it
+	records := make(map[string]*models.Deployment, len(latest))
+	byName := make(map[string]*models.Deployment, len(latest))
+	for i := range latest {
+		records[deploymentKey(latest[i].ProjectID, latest[i].Environment.Name)] = &latest[i]
+		byName[latest[i].Project.Name+"/"+latest[i].Environment.Name] = &latest[i]
+	}
+
 	var allDeployments []DeploymentResponse
 
-	for _, namespace := range namespaces {
-		deployments, err := s.services.Kubernetes.GetClientset().AppsV1().Deployments(namespace).List(c, metav1.ListOptions{
-			LabelSelector: "managed-by=plate",
-		})
+	for _, environment := range environments {
+		deployments, err := s.services.Kubernetes.ListDeployments(environment.Namespace, "managed-by=plate")
 		if err != nil {
 			continue
 		}
 
-		for i, deployment := range deployments.Items {
+		for _, deployment := range deployments {
 			appName := deployment.Labels["app"]
 			if appName == "" {
 				appName = deployment.Name
@@ -47,33 +67,80 @@ func (s *Server) handleListDeployments(c *gin.Context) {
 				deployedAt = deployment.CreationTimestamp.Format("2006-01-02 15:04")
 			}
 
-			// Generate URL for web services
-			url := ""
-			if strings.Contains(appName, "web") || strings.Contains(appName, "frontend") {
-				url = fmt.Sprintf("https://%s.%s.yourapp.com", appName, namespace)
-			} else if strings.Contains(appName, "api") {
-				url = fmt.Sprintf("https://api.%s.yourapp.com", namespace)
-			}
-
-			allDeployments = append(allDeployments, DeploymentResponse{
-				ID:                uint(len(allDeployments) + i + 1),
+			response := DeploymentResponse{
 				Application:       appName,
-				Environment:       namespace,
+				Environment:       environment.Name,
 				Version:           version,
 				Status:            status,
-				URL:               url,
+				URL:               appURL(appName, environment),
 				DeployedAt:        deployedAt,
 				Duration:          "N/A",
 				DesiredReplicas:   *deployment.Spec.Replicas,
 				ReadyReplicas:     deployment.Status.ReadyReplicas,
 				AvailableReplicas: deployment.Status.AvailableReplicas,
-			})
+			}
+			record := byName[deployment.Name+"/"+environment.Name]
+			if id, err := strconv.ParseUint(deployment.Labels[services.ProjectIDLabel], 10, 0); err == nil {
+				record = records[deploymentKey(uint(id), environment.Name)]
+			}
+			if record != nil {
+				delete(records, deploymentKey(record.ProjectID, environment.Name))
+				response.ID = record.ID
+				response.ProjectID = record.ProjectID
+				response.EnvironmentID = record.EnvironmentID
+				response.Version = record.Version
+				response.DeployedAt = record.CreatedAt.Format("2006-01-02 15:04")
+				response.Health = record.Health
+				response.Drift = record.Drift
+			}
+			allDeployments = append(allDeployments, response)
 		}
 	}
 
+	// Deployments that have not reached the cluster, or whose workload is
+	// gone, are listed as recorded
+	for i := range latest {
+		record := &latest[i]
+		if _, ok := records[deploymentKey(record.ProjectID, record.Environment.Name)]; !ok {
+			continue
+		}
+		allDeployments = append(allDeployments, DeploymentResponse{
+			ID:            record.ID,
+			ProjectID:     record.ProjectID,
+			EnvironmentID: record.EnvironmentID,
+			Application:   record.Project.Name,
+			Environment:   record.Environment.Name,
+			Version:       record.Version,
+			Status:        recordedStatus(record.Status),
+			URL:           record.URL,
+			DeployedAt:    record.CreatedAt.Format("2006-01-02 15:04"),
+			Duration:      "N/A",
+			Health:        record.Health,
+			Drift:         record.Drift,
+		})
+	}
+
 	c.JSON(http.StatusOK, allDeployments)
 }
 
+// deploymentKey identifies the deployments of a project in an environment.
+func deploymentKey(projectID uint, environment string) string {
+	return fmt.Sprintf("%d/%s", projectID, environment)
+}
+
+// recordedStatus is the listed status of a deployment whose workload is not
+// in the cluster, from the status of its record.
+func recordedStatus(status string) string {
+	switch status {
+	case "pending", "running":
+		return "building"
+	case "success":
+		return "live"
+	default:
+		return status
+	}
+}
+
 func (s *Server) handleCreateDeployment(c *gin.Context) {
 	var deployment models.Deployment
 	if err := c.ShouldBindJSON(&deployment); err != nil {
@@ -136,10 +203,27 @@ func (s *Server) handleGetDeploymentLogs(c *gin.Context) {
 	c.JSON(http.StatusOK, logs)
 }
 
+// handleReconcileDeployments compares the latest deployments with the
+// cluster now, instead of waiting for the reconciler, and corrects what was
+// recorded. With ?dry_run=true it only reports the drift.
+func (s *Server) handleReconcileDeployments(c *gin.Context) {
+	dryRun := c.Query("dry_run") == "true"
+	report, err := s.services.Deployment.Reconcile(dryRun)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"dry_run": dryRun, "report": report})
+}
+
 func (s *Server) handleDeploy(c *gin.Context) {
+	// Projects and environments may be referenced by ID or by name
 	var req struct {
-		ProjectID     uint   `json:"project_id" binding:"required"`
-		EnvironmentID uint   `json:"environment_id" binding:"required"`
+		ProjectID     uint   `json:"project_id"`
+		EnvironmentID uint   `json:"environment_id"`
+		Project       string `json:"project"`
+		Environment   string `json:"environment"`
 		Version       string `json:"version"`
 	}
 
@@ -148,8 +232,39 @@ func (s *Server) handleDeploy(c *gin.Context) {
 		return
 	}
 
+	if req.ProjectID == 0 {
+		if req.Project == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "project_id or project is required"})
+			return
+		}
+		project, err := s.services.Project.GetByName(req.Project)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Project %s not found", req.Project)})
+			return
+		}
+		req.ProjectID = project.ID
+	}
+
+	if req.EnvironmentID == 0 {
+		if req.Environment == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "environment_id or environment is required"})
+			return
+		}
+		environment, err := s.services.Environment.GetByName(req.Environment)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Environment %s not found", req.Environment)})
+			return
+		}
+		req.EnvironmentID = environment.ID
+	}
+
 	deployment, err := s.services.Deployment.Deploy(req.ProjectID, req.EnvironmentID, req.Version)
 	if err != nil {
+		var quotaErr *services.QuotaExceededError
+		if errors.As(err, &quotaErr) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "violations": quotaErr.Violations})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -161,18 +276,17 @@ func (s *Server) handleGetStatus(c *gin.Context) {
 	env := c.Query("env")
 	detailed := c.Query("detailed") == "true"
 
+	environments, err := s.environmentNamespaces(env)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get environments"})
+		return
+	}
+
 	if detailed {
 		// Return detailed status from Kubernetes
-		namespaces := []string{"dev", "staging", "production"}
-		if env != "" {
-			namespaces = []string{env}
-		}
-
 		var statuses []DetailedStatusResponse
-		for _, namespace := range namespaces {
-			deployments, err := s.services.Kubernetes.GetClientset().AppsV1().Deployments(namespace).List(c, metav1.ListOptions{
-				LabelSelector: "managed-by=plate",
-			})
+		for _, environment := range environments {
+			deployments, err := s.services.Kubernetes.ListDeployments(environment.Namespace, "managed-by=plate")
 			if err != nil {
 				continue
 			}
@@ -180,7 +294,7 @@ func (s *Server) handleGetStatus(c *gin.Context) {
 			var applications []ApplicationStatusResponse
 			overallStatus := "healthy"
 
-			for _, deployment := range deployments.Items {
+			for _, deployment := range deployments {
 				appName := deployment.Labels["app"]
 				if appName == "" {
 					appName = deployment.Name
@@ -208,13 +322,6 @@ func (s *Server) handleGetStatus(c *gin.Context) {
 					}
 				}
 
-				url := ""
-				if strings.Contains(appName, "web") || strings.Contains(appName, "frontend") {
-					url = fmt.Sprintf("https://%s.%s.yourapp.com", appName, namespace)
-				} else if strings.Contains(appName, "api") {
-					url = fmt.Sprintf("https://api.%s.yourapp.com", namespace)
-				}
-
 				lastUpdate := "unknown"
 				if !deployment.CreationTimestamp.Time.IsZero() {
 					lastUpdate = deployment.CreationTimestamp.Format("2006-01-02 15:04")
@@ -222,10 +329,10 @@ func (s *Server) handleGetStatus(c *gin.Context) {
 
 				applications = append(applications, ApplicationStatusResponse{
 					Application: appName,
-					Environment: namespace,
+					Environment: environment.Name,
 					Status:      status,
 					Health:      health,
-					URL:         url,
+					URL:         appURL(appName, environment),
 					Version:     version,
 					Uptime:      uptime,
 					LastUpdate:  lastUpdate,
@@ -234,7 +341,7 @@ func (s *Server) handleGetStatus(c *gin.Context) {
 
 			if len(applications) > 0 {
 				statuses = append(statuses, DetailedStatusResponse{
-					Environment:  namespace,
+					Environment:  environment.Name,
 					Region:       "local",
 					Status:       overallStatus,
 					Applications: applications,
@@ -248,24 +355,21 @@ func (s *Server) handleGetStatus(c *gin.Context) {
 	}
 
 	// Return simple status from Kubernetes
-	namespaces := []string{"dev", "staging", "production"}
 	status := gin.H{}
 
-	for _, namespace := range namespaces {
-		deployments, err := s.services.Kubernetes.GetClientset().AppsV1().Deployments(namespace).List(c, metav1.ListOptions{
-			LabelSelector: "managed-by=plate",
-		})
+	for _, environment := range environments {
+		deployments, err := s.services.Kubernetes.ListDeployments(environment.Namespace, "managed-by=plate")
 		if err != nil {
 			continue
 		}
 
-		for _, deployment := range deployments.Items {
+		for _, deployment := range deployments {
 			appName := deployment.Labels["app"]
 			if appName == "" {
 				appName = deployment.Name
 			}
 
-			key := fmt.Sprintf("%s-%s", appName, namespace)
+			key := fmt.Sprintf("%s-%s", appName, environment.Name)
 			deploymentStatus := "live"
 			
 			if deployment.Status.ReadyReplicas == 0 {
@@ -278,17 +382,5 @@ func (s *Server) handleGetStatus(c *gin.Context) {
 		}
 	}
 
-	// Filter by environment if specified
-	if env != "" {
-		filtered := gin.H{}
-		for key, value := range status {
-			if strings.Contains(key, "-"+env) {
-				filtered[key] = value
-			}
-		}
-		c.JSON(http.StatusOK, filtered)
-		return
-	}
-
 	c.JSON(http.StatusOK, status)
 }
\ No newline at end of file
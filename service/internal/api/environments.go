@@ -1,58 +1,141 @@
 package api
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/plate/service/internal/models"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"github.com/plate/service/internal/services"
+	"gorm.io/gorm"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 )
 
+// handleListEnvironments lists the stored environments with the state of
+// their namespaces. Without a database, the namespaces labeled as managed
+// by Plate are listed instead.
 func (s *Server) handleListEnvironments(c *gin.Context) {
-	// Get namespaces managed by Plate
-	namespaces, err := s.services.Kubernetes.GetClientset().CoreV1().Namespaces().List(c, metav1.ListOptions{
-		LabelSelector: "managed-by=plate",
-	})
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get namespaces"})
+	clientset := s.services.Kubernetes.GetClientset()
+
+	if s.services.Environment == nil {
+		environments := []EnvironmentResponse{}
+		if clientset == nil {
+			c.JSON(http.StatusOK, environments)
+			return
+		}
+
+		namespaces, err := s.services.Kubernetes.ManagedNamespaces()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get namespaces"})
+			return
+		}
+		for i, ns := range namespaces {
+			envType := ns.Labels[services.EnvironmentLabel]
+			if envType == "" {
+				envType = ns.Name
+			}
+			environments = append(environments, EnvironmentResponse{
+				ID:     uint(i + 1),
+				Name:   ns.Name,
+				Type:   envType,
+				Region: "local",
+				Status: namespaceStatus(&ns),
+			})
+		}
+		c.JSON(http.StatusOK, environments)
 		return
 	}
 
-	var environments []EnvironmentResponse
-	for i, ns := range namespaces.Items {
-		envType := ns.Labels["environment"]
-		if envType == "" {
-			envType = ns.Name
-		}
+	stored, err := s.services.Environment.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
-		// Determine status based on namespace phase
-		status := "active"
-		if ns.Status.Phase != "Active" {
-			status = "inactive"
+	environments := make([]EnvironmentResponse, 0, len(stored))
+	for _, environment := range stored {
+		status := "unknown"
+		if clientset != nil {
+			ns, err := s.services.Kubernetes.GetNamespace(environment.Namespace)
+			switch {
+			case k8serrors.IsNotFound(err):
+				status = "missing"
+			case err == nil:
+				status = namespaceStatus(ns)
+			}
 		}
 
 		environments = append(environments, EnvironmentResponse{
-			ID:     uint(i + 1),
-			Name:   ns.Name,
-			Type:   envType,
-			Region: "local",
-			Status: status,
+			ID:        environment.ID,
+			Name:      environment.Name,
+			Namespace: environment.Namespace,
+			Domain:    environment.Domain,
+			Type:      environment.Name,
+			Region:    "local",
+			Status:    status,
 		})
 	}
 
 	c.JSON(http.StatusOK, environments)
 }
 
+// namespaceStatus is active for a namespace that is not being deleted.
+func namespaceStatus(ns *corev1.Namespace) string {
+	if ns.Status.Phase != corev1.NamespaceActive {
+		return "inactive"
+	}
+	return "active"
+}
+
+// bindEnvironment decodes and validates an environment from the request
+// body. It writes the error response and returns false if it is invalid.
+func bindEnvironment(c *gin.Context, environment *models.Environment) bool {
+	if err := c.ShouldBindJSON(environment); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+
+	if err := environment.Validate(); err != nil {
+		var validationErr *models.ValidationError
+		if errors.As(err, &validationErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "fields": validationErr.Fields})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return false
+	}
+	return true
+}
+
+// environmentError writes the response for an error creating or updating
+// an environment.
+func environmentError(c *gin.Context, err error) {
+	var notManaged *services.NamespaceNotManagedError
+	if errors.As(err, &notManaged) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// handleCreateEnvironment stores an environment and provisions its
+// namespace with the default quota, limit range and network policy.
 func (s *Server) handleCreateEnvironment(c *gin.Context) {
 	var environment models.Environment
-	if err := c.ShouldBindJSON(&environment); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindEnvironment(c, &environment) {
+		return
+	}
+
+	if _, err := s.services.Environment.GetByName(environment.Name); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Environment %s already exists", environment.Name)})
 		return
 	}
 
 	if err := s.services.Environment.Create(&environment); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		environmentError(c, err)
 		return
 	}
 
@@ -82,17 +165,161 @@ func (s *Server) handleUpdateEnvironment(c *gin.Context) {
 		return
 	}
 
+	existing, err := s.services.Environment.GetByID(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Environment not found"})
+		return
+	}
+
 	var environment models.Environment
-	if err := c.ShouldBindJSON(&environment); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindEnvironment(c, &environment) {
 		return
 	}
 
-	environment.ID = uint(id)
+	// Deployments and secrets refer to an environment by name
+	if environment.Name != existing.Name {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Environment name cannot be changed"})
+		return
+	}
+
+	environment.ID = existing.ID
 	if err := s.services.Environment.Update(&environment); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		environmentError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, environment)
-}
\ No newline at end of file
+}
+
+// handleDeleteEnvironment removes an environment and deletes its
+// namespace. An environment with deployments is only removed with
+// ?force=true, which deletes the applications running in it.
+func (s *Server) handleDeleteEnvironment(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid environment ID"})
+		return
+	}
+
+	force := c.Query("force") == "true"
+	namespaceDeleted, err := s.services.Environment.Delete(uint(id), force)
+	if err != nil {
+		var inUse *services.EnvironmentInUseError
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Environment not found"})
+		case errors.As(err, &inUse):
+			c.JSON(http.StatusConflict, gin.H{
+				"error":    err.Error(),
+				"projects": inUse.Projects,
+				"hint":     "Delete with ?force=true to remove the environment and everything deployed to it",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":           "Environment deleted successfully",
+		"namespace_deleted": namespaceDeleted,
+	})
+}
+
+// handleReconcileEnvironments provisions the namespace of every
+// environment and, with ?adopt=true, adopts managed namespaces without
+// one. With ?dry_run=true it only reports the drift.
+func (s *Server) handleReconcileEnvironments(c *gin.Context) {
+	if s.services.Kubernetes.GetClientset() == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Kubernetes is not available"})
+		return
+	}
+
+	dryRun := c.Query("dry_run") == "true"
+	adopt := c.Query("adopt") == "true"
+	report, err := s.services.Environment.Reconcile(dryRun, adopt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"dry_run": dryRun, "report": report})
+}
+
+// environmentNamespace is an environment and the namespace its
+// applications run in.
+type environmentNamespace struct {
+	Name      string
+	Namespace string
+	Domain    string
+}
+
+// environmentNamespaces returns the environments to look for applications
+// in: the stored environments and, with namespace discovery enabled, the
+// namespaces labeled as managed by Plate that no environment uses. A
+// non-empty ref selects the environment with that name or namespace.
+func (s *Server) environmentNamespaces(ref string) ([]environmentNamespace, error) {
+	var environments []environmentNamespace
+	used := map[string]bool{}
+
+	if s.services.Environment != nil {
+		stored, err := s.services.Environment.List()
+		if err != nil {
+			return nil, err
+		}
+		for _, environment := range stored {
+			used[environment.Namespace] = true
+			environments = append(environments, environmentNamespace{
+				Name:      environment.Name,
+				Namespace: environment.Namespace,
+				Domain:    environment.Domain,
+			})
+		}
+	}
+
+	if s.config.Environments.DiscoverNamespaces && s.services.Kubernetes.GetClientset() != nil {
+		namespaces, err := s.services.Kubernetes.ManagedNamespaces()
+		if err != nil {
+			return nil, err
+		}
+		for _, ns := range namespaces {
+			if used[ns.Name] {
+				continue
+			}
+			name := ns.Labels[services.EnvironmentLabel]
+			if name == "" {
+				name = ns.Name
+			}
+			environments = append(environments, environmentNamespace{Name: name, Namespace: ns.Name})
+		}
+	}
+
+	if ref == "" {
+		return environments, nil
+	}
+	for _, environment := range environments {
+		if environment.Name == ref || environment.Namespace == ref {
+			return []environmentNamespace{environment}, nil
+		}
+	}
+	return nil, nil
+}
+
+// appNamespace returns the namespace of the environment ref names, or ref
+// itself when it names no known environment.
+func (s *Server) appNamespace(ref string) string {
+	environments, err := s.environmentNamespaces(ref)
+	if err != nil || len(environments) == 0 {
+		return ref
+	}
+	return environments[0].Namespace
+}
+
+// appURL is where an application is served in an environment with a
+// domain.
+func appURL(appName string, environment environmentNamespace) string {
+	if environment.Domain == "" {
+		return ""
+	}
+	return fmt.Sprintf("https://%s.%s", appName, environment.Domain)
+}
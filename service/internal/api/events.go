@@ -0,0 +1,155 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/plate/service/internal/services"
+	"golang.org/x/net/websocket"
+)
+
+// eventKeepAlive is how often an idle event stream sends a comment, so that
+// proxies do not close it.
+const eventKeepAlive = 15 * time.Second
+
+// handleEvents streams the events of deployments and apps as Server-Sent
+// Events, or as JSON messages over a WebSocket when the request upgrades
+// to one. Events can be narrowed down with comma-separated ?types=,
+// ?project=, ?environment= and ?deployment= lists. SSE clients resume after
+// the Last-Event-ID header or ?last_event_id=, as far as the events are
+// still kept.
+func (s *Server) handleEvents(c *gin.Context) {
+	filter := services.EventFilter{
+		Types:        splitList(c.Query("types")),
+		Projects:     splitList(c.Query("project")),
+		Environments: splitList(c.Query("environment")),
+	}
+	for _, value := range splitList(c.Query("deployment")) {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deployment ID " + value})
+			return
+		}
+		filter.Deployments = append(filter.Deployments, uint(id))
+	}
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	var after uint64
+	if lastEventID != "" {
+		var err error
+		if after, err = strconv.ParseUint(lastEventID, 10, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid last event ID"})
+			return
+		}
+	}
+
+	subscription := s.services.Events.Subscribe(filter, after)
+	defer subscription.Close()
+
+	if c.IsWebsocket() {
+		streamWebSocketEvents(c, subscription)
+		return
+	}
+	streamServerSentEvents(c, subscription)
+}
+
+func streamServerSentEvents(c *gin.Context, subscription *services.Subscription) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	fmt.Fprint(c.Writer, "retry: 3000\n\n")
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(c.Writer, ": keep-alive\n\n")
+		case event, ok := <-subscription.Events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+		}
+		c.Writer.Flush()
+	}
+}
+
+func streamWebSocketEvents(c *gin.Context, subscription *services.Subscription) {
+	server := websocket.Server{
+		// Any origin may connect, as with the CORS policy of the API
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(conn *websocket.Conn) {
+			defer conn.Close()
+
+			// Messages from the client are ignored; reading tells when it
+			// closed the connection
+			closed := make(chan struct{})
+			go func() {
+				io.Copy(io.Discard, conn)
+				close(closed)
+			}()
+
+			for {
+				select {
+				case <-closed:
+					return
+				case event, ok := <-subscription.Events:
+					if !ok {
+						return
+					}
+					if err := websocket.JSON.Send(conn, event); err != nil {
+						return
+					}
+				}
+			}
+		},
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// publishScaled announces an app scaled, started or stopped through the API
+// on the event stream.
+func (s *Server) publishScaled(app, environment string, event services.AppScaledEvent) {
+	if s.services.Environment != nil {
+		if found, err := s.services.Environment.Find(environment); err == nil {
+			environment = found.Name
+		}
+	}
+	s.services.Events.Publish(services.Event{
+		Type:        services.EventAppScaled,
+		Project:     app,
+		Environment: environment,
+		Data:        event,
+	})
+}
+
+// splitList splits a comma-separated query parameter.
+func splitList(value string) []string {
+	var values []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	return values
+}
@@ -0,0 +1,491 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/plate/service/internal/models"
+	"github.com/plate/service/internal/services"
+)
+
+// maxHookPayload is the largest webhook payload accepted.
+const maxHookPayload = 5 << 20
+
+// hookRepository is the repository a webhook is sent for, with the URLs a
+// project may have recorded it by.
+type hookRepository struct {
+	FullName string
+	URLs     []string
+}
+
+// pushHook is a push to a repository.
+type pushHook struct {
+	Ref        string
+	After      string
+	Repository hookRepository
+}
+
+// pullRequestHook is an event of a pull request, or merge request on
+// GitLab, in the terms of Gitea.
+type pullRequestHook struct {
+	// Action is opened, reopened, synchronized when commits were pushed, or
+	// closed; other actions are ignored
+	Action      string
+	Merged      bool
+	PullRequest services.PullRequest
+	Repository  hookRepository
+}
+
+// newHookRepository returns the repository a webhook is sent for, leaving
+// out the URLs the payload did not fill in.
+func newHookRepository(fullName string, urls ...string) hookRepository {
+	repo := hookRepository{FullName: fullName}
+	for _, url := range urls {
+		if url != "" {
+			repo.URLs = append(repo.URLs, url)
+		}
+	}
+	return repo
+}
+
+// githubRepositoryPayload is the repository a GitHub or Gitea webhook is
+// sent for; Gitea's webhooks follow GitHub's.
+type githubRepositoryPayload struct {
+	FullName string `json:"full_name"`
+	HTMLURL  string `json:"html_url"`
+	CloneURL string `json:"clone_url"`
+	SSHURL   string `json:"ssh_url"`
+}
+
+func (r githubRepositoryPayload) hookRepository() hookRepository {
+	return newHookRepository(r.FullName, r.CloneURL, r.HTMLURL, r.SSHURL)
+}
+
+// githubPullRequestPayload is the payload of GitHub and Gitea pull_request
+// webhooks.
+type githubPullRequestPayload struct {
+	Action      string `json:"action"`
+	Number      int    `json:"number"`
+	PullRequest struct {
+		Merged bool `json:"merged"`
+		Head   struct {
+			Ref string `json:"ref"`
+			SHA string `json:"sha"`
+		} `json:"head"`
+	} `json:"pull_request"`
+	Repository githubRepositoryPayload `json:"repository"`
+}
+
+// githubPushPayload is the payload of GitHub and Gitea push webhooks.
+type githubPushPayload struct {
+	Ref        string                  `json:"ref"`
+	After      string                  `json:"after"`
+	Repository githubRepositoryPayload `json:"repository"`
+}
+
+// gitlabProjectPayload is the project a GitLab webhook is sent for.
+type gitlabProjectPayload struct {
+	PathWithNamespace string `json:"path_with_namespace"`
+	WebURL            string `json:"web_url"`
+	GitHTTPURL        string `json:"git_http_url"`
+	GitSSHURL         string `json:"git_ssh_url"`
+}
+
+func (p gitlabProjectPayload) hookRepository() hookRepository {
+	return newHookRepository(p.PathWithNamespace, p.GitHTTPURL, p.WebURL, p.GitSSHURL)
+}
+
+// gitlabPushPayload is the payload of GitLab push hooks.
+type gitlabPushPayload struct {
+	Ref     string               `json:"ref"`
+	After   string               `json:"after"`
+	Project gitlabProjectPayload `json:"project"`
+}
+
+// gitlabMergeRequestPayload is the payload of GitLab merge request hooks.
+type gitlabMergeRequestPayload struct {
+	ObjectAttributes struct {
+		IID          int    `json:"iid"`
+		Action       string `json:"action"`
+		SourceBranch string `json:"source_branch"`
+		// OldRev is set on updates that pushed commits
+		OldRev     string `json:"oldrev"`
+		LastCommit struct {
+			ID string `json:"id"`
+		} `json:"last_commit"`
+	} `json:"object_attributes"`
+	Project gitlabProjectPayload `json:"project"`
+}
+
+func parseGitHubPush(body []byte) (*pushHook, error) {
+	var payload githubPushPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	return &pushHook{Ref: payload.Ref, After: payload.After, Repository: payload.Repository.hookRepository()}, nil
+}
+
+// parseGitHubPullRequest reads a pull_request webhook of GitHub or, with
+// provider gitea, of Gitea.
+func parseGitHubPullRequest(provider string, body []byte) (*pullRequestHook, error) {
+	var payload githubPullRequestPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+
+	action := payload.Action
+	if action == "synchronize" {
+		action = "synchronized"
+	}
+	return &pullRequestHook{
+		Action: action,
+		Merged: payload.PullRequest.Merged,
+		PullRequest: services.PullRequest{
+			Provider:   provider,
+			Repository: payload.Repository.FullName,
+			Number:     payload.Number,
+			Branch:     payload.PullRequest.Head.Ref,
+			CommitSHA:  payload.PullRequest.Head.SHA,
+		},
+		Repository: payload.Repository.hookRepository(),
+	}, nil
+}
+
+func parseGitLabPush(body []byte) (*pushHook, error) {
+	var payload gitlabPushPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	return &pushHook{Ref: payload.Ref, After: payload.After, Repository: payload.Project.hookRepository()}, nil
+}
+
+func parseGitLabMergeRequest(body []byte) (*pullRequestHook, error) {
+	var payload gitlabMergeRequestPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+
+	attributes := payload.ObjectAttributes
+	hook := &pullRequestHook{
+		Action: attributes.Action,
+		PullRequest: services.PullRequest{
+			Provider:   "gitlab",
+			Repository: payload.Project.PathWithNamespace,
+			Number:     attributes.IID,
+			Branch:     attributes.SourceBranch,
+			CommitSHA:  attributes.LastCommit.ID,
+		},
+		Repository: payload.Project.hookRepository(),
+	}
+	switch attributes.Action {
+	case "open":
+		hook.Action = "opened"
+	case "reopen":
+		hook.Action = "reopened"
+	case "update":
+		// Updates of the title or labels push no commits
+		if attributes.OldRev != "" {
+			hook.Action = "synchronized"
+		}
+	case "close":
+		hook.Action = "closed"
+	case "merge":
+		hook.Action = "closed"
+		hook.Merged = true
+	}
+	return hook, nil
+}
+
+// handleGiteaHook receives the webhooks of Gitea repositories, signed in
+// X-Gitea-Signature.
+func (s *Server) handleGiteaHook(c *gin.Context) {
+	secret := s.config.Gitea.WebhookSecret
+	verify := func(body []byte) bool {
+		return validSignature(secret, body, c.GetHeader("X-Gitea-Signature"))
+	}
+
+	s.receiveHook(c, "gitea", secret, verify, c.GetHeader("X-Gitea-Event"), c.GetHeader("X-Gitea-Delivery"), func(event string, body []byte) {
+		switch event {
+		case "push":
+			s.handlePushHook(c, body, parseGitHubPush)
+		case "pull_request":
+			s.handlePullRequestHook(c, body, func(body []byte) (*pullRequestHook, error) {
+				return parseGitHubPullRequest("gitea", body)
+			})
+		default:
+			c.JSON(http.StatusOK, gin.H{"message": "Ignored " + event + " event"})
+		}
+	})
+}
+
+// handleGitHubHook receives the webhooks of GitHub repositories, signed in
+// X-Hub-Signature-256.
+func (s *Server) handleGitHubHook(c *gin.Context) {
+	secret := s.config.GitHub.WebhookSecret
+	verify := func(body []byte) bool {
+		signature, ok := strings.CutPrefix(c.GetHeader("X-Hub-Signature-256"), "sha256=")
+		return ok && validSignature(secret, body, signature)
+	}
+
+	s.receiveHook(c, "github", secret, verify, c.GetHeader("X-GitHub-Event"), c.GetHeader("X-GitHub-Delivery"), func(event string, body []byte) {
+		switch event {
+		case "push":
+			s.handlePushHook(c, body, parseGitHubPush)
+		case "pull_request":
+			s.handlePullRequestHook(c, body, func(body []byte) (*pullRequestHook, error) {
+				return parseGitHubPullRequest("github", body)
+			})
+		default:
+			c.JSON(http.StatusOK, gin.H{"message": "Ignored " + event + " event"})
+		}
+	})
+}
+
+// handleGitLabHook receives the webhooks of GitLab projects, which carry
+// the secret in X-Gitlab-Token.
+func (s *Server) handleGitLabHook(c *gin.Context) {
+	secret := s.config.GitLab.WebhookSecret
+	verify := func(body []byte) bool {
+		return subtle.ConstantTimeCompare([]byte(c.GetHeader("X-Gitlab-Token")), []byte(secret)) == 1
+	}
+
+	// Idempotency-Key stays the same when GitLab retries a delivery
+	s.receiveHook(c, "gitlab", secret, verify, c.GetHeader("X-Gitlab-Event"), c.GetHeader("Idempotency-Key"), func(event string, body []byte) {
+		switch event {
+		case "Push Hook":
+			s.handlePushHook(c, body, parseGitLabPush)
+		case "Merge Request Hook":
+			s.handlePullRequestHook(c, body, parseGitLabMergeRequest)
+		default:
+			c.JSON(http.StatusOK, gin.H{"message": "Ignored " + event + " event"})
+		}
+	})
+}
+
+// receiveHook reads a webhook delivery from a Git provider, checks it with
+// verify and hands it to handle. Deliveries are refused when no secret is
+// configured, and handled once: redeliveries are ignored unless handling
+// failed.
+func (s *Server) receiveHook(c *gin.Context, provider, secret string, verify func(body []byte) bool, event, delivery string, handle func(event string, body []byte)) {
+	if secret == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Webhooks of " + provider + " are not configured"})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxHookPayload))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !verify(body) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid webhook signature"})
+		return
+	}
+
+	if delivery != "" {
+		duplicate, err := s.services.Webhooks.Record(provider, delivery, event)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if duplicate {
+			c.JSON(http.StatusOK, gin.H{"message": "Delivery " + delivery + " was already handled"})
+			return
+		}
+	}
+
+	handle(event, body)
+
+	if delivery != "" && c.Writer.Status() >= http.StatusInternalServerError {
+		if err := s.services.Webhooks.Forget(provider, delivery); err != nil {
+			fmt.Printf("Warning: Failed to forget webhook delivery %s: %v\n", delivery, err)
+		}
+	}
+}
+
+// handlePushHook deploys a pushed commit to the environments the projects
+// built from the repository map the branch to.
+func (s *Server) handlePushHook(c *gin.Context, body []byte, parse func([]byte) (*pushHook, error)) {
+	payload, err := parse(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid push payload: " + err.Error()})
+		return
+	}
+
+	branch, ok := strings.CutPrefix(payload.Ref, "refs/heads/")
+	if !ok {
+		c.JSON(http.StatusOK, gin.H{"message": "Ignored push to " + payload.Ref})
+		return
+	}
+	if strings.Trim(payload.After, "0") == "" {
+		c.JSON(http.StatusOK, gin.H{"message": "Ignored deletion of branch " + branch})
+		return
+	}
+
+	projects, ok := s.hookProjects(c, payload.Repository)
+	if !ok {
+		return
+	}
+
+	result := newHookResult()
+	for i := range projects {
+		project := &projects[i]
+		for _, name := range services.BranchEnvironments(project, branch) {
+			key := fmt.Sprintf("%s in %s", project.Name, name)
+			environment, err := s.services.Environment.GetByName(name)
+			if err != nil {
+				result.fail(key, fmt.Errorf("environment %s not found", name))
+				continue
+			}
+			deployment, err := s.services.Deployment.Deploy(project.ID, environment.ID, payload.After)
+			if err != nil {
+				result.fail(key, err)
+				continue
+			}
+			result.deployed = append(result.deployed, deployment)
+		}
+	}
+
+	result.respond(c, "deployments", "No environment is deployed from branch "+branch)
+}
+
+// handlePullRequestHook deploys the head of an opened or updated pull
+// request to the preview environments of the projects built from the
+// repository, and tears the previews down when the pull request is closed.
+func (s *Server) handlePullRequestHook(c *gin.Context, body []byte, parse func([]byte) (*pullRequestHook, error)) {
+	payload, err := parse(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pull request payload: " + err.Error()})
+		return
+	}
+
+	if !s.services.Previews.Enabled() {
+		c.JSON(http.StatusOK, gin.H{"message": "Preview environments are disabled"})
+		return
+	}
+
+	projects, ok := s.hookProjects(c, payload.Repository)
+	if !ok {
+		return
+	}
+
+	result := newHookResult()
+	switch payload.Action {
+	case "opened", "reopened", "synchronized":
+		for i := range projects {
+			preview, _, err := s.services.Previews.Deploy(&projects[i], payload.PullRequest)
+			if err != nil {
+				result.fail(projects[i].Name, err)
+				continue
+			}
+			result.deployed = append(result.deployed, preview)
+		}
+		result.respond(c, "previews", "")
+
+	case "closed":
+		for i := range projects {
+			preview, err := s.services.Previews.Close(&projects[i], payload.PullRequest.Number, payload.Merged)
+			if err != nil {
+				result.fail(projects[i].Name, err)
+				continue
+			}
+			if preview != nil {
+				result.deployed = append(result.deployed, preview)
+			}
+		}
+		if result.err == nil {
+			c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Removed %d preview environments", len(result.deployed))})
+			return
+		}
+		result.respond(c, "previews", "")
+
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": "Ignored pull request action " + payload.Action})
+	}
+}
+
+// hookProjects returns the projects built from the repository of a
+// webhook. If there are none, or they cannot be looked up, the response is
+// written and ok is false.
+func (s *Server) hookProjects(c *gin.Context, repo hookRepository) (projects []models.Project, ok bool) {
+	projects, err := s.services.Project.ListByRepository(repo.URLs...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if len(projects) == 0 {
+		c.JSON(http.StatusOK, gin.H{"message": "No project is built from " + repo.FullName})
+		return nil, false
+	}
+	return projects, true
+}
+
+// hookResult collects what a webhook delivery deployed and what failed,
+// keyed by project or by project and environment.
+type hookResult struct {
+	deployed []interface{}
+	errors   map[string]string
+	err      error // the first failure
+}
+
+func newHookResult() *hookResult {
+	return &hookResult{deployed: []interface{}{}, errors: map[string]string{}}
+}
+
+func (r *hookResult) fail(key string, err error) {
+	if r.err == nil {
+		r.err = err
+	}
+	r.errors[key] = err.Error()
+}
+
+// respond reports the deployments under key. Partial failures are listed
+// with them; if everything failed, the first failure sets the status.
+func (r *hookResult) respond(c *gin.Context, key, nothing string) {
+	switch {
+	case len(r.deployed) > 0:
+		response := gin.H{key: r.deployed}
+		if len(r.errors) > 0 {
+			response["errors"] = r.errors
+		}
+		c.JSON(http.StatusAccepted, response)
+	case r.err != nil:
+		c.JSON(hookErrorStatus(r.err), gin.H{"error": r.err.Error(), "errors": r.errors})
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": nothing})
+	}
+}
+
+// hookErrorStatus is the status of a delivery that failed with err.
+func hookErrorStatus(err error) int {
+	var previewQuota *services.PreviewQuotaError
+	var quotaErr *services.QuotaExceededError
+	switch {
+	case errors.As(err, &previewQuota):
+		return http.StatusConflict
+	case errors.As(err, &quotaErr):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// validSignature checks the hex-encoded HMAC-SHA256 of a webhook payload.
+func validSignature(secret string, body []byte, signature string) bool {
+	expected, err := hex.DecodeString(signature)
+	if err != nil || len(expected) == 0 {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
@@ -0,0 +1,236 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/plate/service/internal/config"
+	"github.com/plate/service/internal/services"
+)
+
+func TestParseGitHubPullRequest(t *testing.T) {
+	body := `{
+		"action": "synchronize",
+		"number": 34,
+		"pull_request": {"merged": false, "head": {"ref": "fix/checkout", "sha": "def456"}},
+		"repository": {
+			"full_name": "plate/shop",
+			"html_url": "https://github.com/plate/shop",
+			"clone_url": "https://github.com/plate/shop.git",
+			"ssh_url": "git@github.com:plate/shop.git"
+		}
+	}`
+
+	hook, err := parseGitHubPullRequest("github", []byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := services.PullRequest{Provider: "github", Repository: "plate/shop", Number: 34, Branch: "fix/checkout", CommitSHA: "def456"}
+	if hook.Action != "synchronized" || hook.Merged || hook.PullRequest != want {
+		t.Errorf("hook = %+v", hook)
+	}
+	if len(hook.Repository.URLs) != 3 || hook.Repository.URLs[0] != "https://github.com/plate/shop.git" {
+		t.Errorf("repository = %+v", hook.Repository)
+	}
+}
+
+func TestParseGitLabMergeRequest(t *testing.T) {
+	tests := []struct {
+		action, oldRev string
+		want           string
+		merged         bool
+	}{
+		{action: "open", want: "opened"},
+		{action: "reopen", want: "reopened"},
+		{action: "update", oldRev: "abc123", want: "synchronized"},
+		{action: "update", want: "update"},
+		{action: "close", want: "closed"},
+		{action: "merge", want: "closed", merged: true},
+		{action: "approved", want: "approved"},
+	}
+
+	for _, tt := range tests {
+		body := `{
+			"object_kind": "merge_request",
+			"object_attributes": {
+				"iid": 5, "action": "` + tt.action + `", "oldrev": "` + tt.oldRev + `",
+				"source_branch": "feature/login", "last_commit": {"id": "def456"}
+			},
+			"project": {
+				"path_with_namespace": "plate/shop",
+				"web_url": "https://gitlab.com/plate/shop",
+				"git_http_url": "https://gitlab.com/plate/shop.git",
+				"git_ssh_url": "git@gitlab.com:plate/shop.git"
+			}
+		}`
+
+		hook, err := parseGitLabMergeRequest([]byte(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hook.Action != tt.want || hook.Merged != tt.merged {
+			t.Errorf("%s (oldrev %q): action = %s, merged = %v, want %s, %v", tt.action, tt.oldRev, hook.Action, hook.Merged, tt.want, tt.merged)
+		}
+		want := services.PullRequest{Provider: "gitlab", Repository: "plate/shop", Number: 5, Branch: "feature/login", CommitSHA: "def456"}
+		if hook.PullRequest != want {
+			t.Errorf("pull request = %+v, want %+v", hook.PullRequest, want)
+		}
+	}
+}
+
+func TestParseGitLabPush(t *testing.T) {
+	body := `{
+		"object_kind": "push",
+		"ref": "refs/heads/main",
+		"after": "def456",
+		"project": {"path_with_namespace": "plate/shop", "git_http_url": "https://gitlab.com/plate/shop.git"}
+	}`
+
+	hook, err := parseGitLabPush([]byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hook.Ref != "refs/heads/main" || hook.After != "def456" || hook.Repository.FullName != "plate/shop" || hook.Repository.URLs[0] != "https://gitlab.com/plate/shop.git" {
+		t.Errorf("hook = %+v", hook)
+	}
+}
+
+func TestHookRepositoryWithoutURLs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	body := `{
+		"ref": "refs/heads/main",
+		"after": "def456",
+		"repository": {"full_name": "plate/shop", "clone_url": "https://github.com/plate/shop.git", "html_url": "", "ssh_url": ""}
+	}`
+	hook, err := parseGitHubPush([]byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hook.Repository.URLs) != 1 || hook.Repository.URLs[0] != "https://github.com/plate/shop.git" {
+		t.Errorf("URLs = %q", hook.Repository.URLs)
+	}
+
+	// Projects registered without a repository have an empty one, which
+	// a payload without URLs must not match. The project service has no
+	// database, so it must not be queried either.
+	server := &Server{services: &services.Manager{Project: &services.ProjectService{}}}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	projects, ok := server.hookProjects(c, newHookRepository("plate/shop", "", ""))
+	if ok || len(projects) != 0 || !strings.Contains(w.Body.String(), "No project is built from plate/shop") {
+		t.Errorf("projects = %v, response %d %s", projects, w.Code, w.Body)
+	}
+}
+
+func testHookSignature(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// TestHookVerification delivers events no handler acts on, so that only
+// the checks of the deliveries run.
+func TestHookVerification(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const body = `{"zen": "Keep it logically awesome."}`
+	server := &Server{
+		config: &config.Config{
+			Gitea:  config.Gitea{WebhookSecret: "gitea-secret"},
+			GitHub: config.GitHub{WebhookSecret: "github-secret"},
+			GitLab: config.GitLab{WebhookSecret: "gitlab-secret"},
+		},
+		services: &services.Manager{},
+	}
+	unconfigured := &Server{config: &config.Config{}, services: &services.Manager{}}
+
+	tests := []struct {
+		name    string
+		server  *Server
+		handler func(*Server) gin.HandlerFunc
+		headers map[string]string
+		status  int
+	}{
+		{
+			name:    "gitea",
+			handler: func(s *Server) gin.HandlerFunc { return s.handleGiteaHook },
+			headers: map[string]string{"X-Gitea-Event": "repository", "X-Gitea-Signature": testHookSignature("gitea-secret", body)},
+			status:  http.StatusOK,
+		},
+		{
+			name:    "gitea with wrong secret",
+			handler: func(s *Server) gin.HandlerFunc { return s.handleGiteaHook },
+			headers: map[string]string{"X-Gitea-Event": "repository", "X-Gitea-Signature": testHookSignature("other", body)},
+			status:  http.StatusUnauthorized,
+		},
+		{
+			name:    "github",
+			handler: func(s *Server) gin.HandlerFunc { return s.handleGitHubHook },
+			headers: map[string]string{"X-GitHub-Event": "ping", "X-Hub-Signature-256": "sha256=" + testHookSignature("github-secret", body)},
+			status:  http.StatusOK,
+		},
+		{
+			name:    "github without prefix",
+			handler: func(s *Server) gin.HandlerFunc { return s.handleGitHubHook },
+			headers: map[string]string{"X-GitHub-Event": "ping", "X-Hub-Signature-256": testHookSignature("github-secret", body)},
+			status:  http.StatusUnauthorized,
+		},
+		{
+			name:    "github signed with the gitea secret",
+			handler: func(s *Server) gin.HandlerFunc { return s.handleGitHubHook },
+			headers: map[string]string{"X-GitHub-Event": "ping", "X-Hub-Signature-256": "sha256=" + testHookSignature("gitea-secret", body)},
+			status:  http.StatusUnauthorized,
+		},
+		{
+			name:    "gitlab",
+			handler: func(s *Server) gin.HandlerFunc { return s.handleGitLabHook },
+			headers: map[string]string{"X-Gitlab-Event": "Issue Hook", "X-Gitlab-Token": "gitlab-secret"},
+			status:  http.StatusOK,
+		},
+		{
+			name:    "gitlab with wrong token",
+			handler: func(s *Server) gin.HandlerFunc { return s.handleGitLabHook },
+			headers: map[string]string{"X-Gitlab-Event": "Issue Hook", "X-Gitlab-Token": "github-secret"},
+			status:  http.StatusUnauthorized,
+		},
+		{
+			name:    "github without secret",
+			server:  unconfigured,
+			handler: func(s *Server) gin.HandlerFunc { return s.handleGitHubHook },
+			headers: map[string]string{"X-GitHub-Event": "ping", "X-Hub-Signature-256": "sha256=" + testHookSignature("", body)},
+			status:  http.StatusServiceUnavailable,
+		},
+		{
+			name:    "gitlab without secret",
+			server:  unconfigured,
+			handler: func(s *Server) gin.HandlerFunc { return s.handleGitLabHook },
+			headers: map[string]string{"X-Gitlab-Event": "Issue Hook", "X-Gitlab-Token": ""},
+			status:  http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.server
+			if s == nil {
+				s = server
+			}
+			recorder := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(recorder)
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/hooks", strings.NewReader(body))
+			for name, value := range tt.headers {
+				c.Request.Header.Set(name, value)
+			}
+
+			tt.handler(s)(c)
+			if recorder.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", recorder.Code, tt.status, recorder.Body)
+			}
+		})
+	}
+}
@@ -1,9 +1,13 @@
 package api
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/plate/service/internal/models"
+	"github.com/plate/service/internal/services"
 )
 
 // Deployment management handlers
@@ -24,6 +28,7 @@ func (s *Server) handleScaleDeployment(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	s.publishScaled(name, namespace, services.AppScaledEvent{Action: "scale", Replicas: &req.Replicas})
 
 	c.JSON(http.StatusOK, gin.H{"message": "Deployment scaled successfully"})
 }
@@ -36,6 +41,7 @@ func (s *Server) handleStopDeployment(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	s.publishScaled(name, namespace, services.AppScaledEvent{Action: "stop"})
 
 	c.JSON(http.StatusOK, gin.H{"message": "Deployment stopped successfully"})
 }
@@ -58,6 +64,7 @@ func (s *Server) handleStartDeployment(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	s.publishScaled(name, namespace, services.AppScaledEvent{Action: "start", Replicas: &req.Replicas})
 
 	c.JSON(http.StatusOK, gin.H{"message": "Deployment started successfully"})
 }
@@ -91,17 +98,21 @@ func (s *Server) handleGetDeploymentStatus(c *gin.Context) {
 func (s *Server) handleGetAppStatus(c *gin.Context) {
 	appName := c.Param("name")
 	
-	// Check all plate-managed namespaces
-	namespaces := []string{"dev", "staging", "production"}
+	// Check the namespaces of all environments
+	environments, err := s.environmentNamespaces("")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get environments"})
+		return
+	}
 	appStatus := make(map[string]interface{})
 
-	for _, namespace := range namespaces {
-		status, err := s.services.Kubernetes.GetDeploymentStatus(namespace, appName)
+	for _, environment := range environments {
+		status, err := s.services.Kubernetes.GetDeploymentStatus(environment.Namespace, appName)
 		if err != nil {
 			// Skip if deployment doesn't exist in this namespace
 			continue
 		}
-		appStatus[namespace] = status
+		appStatus[environment.Name] = status
 	}
 
 	if len(appStatus) == 0 {
@@ -124,21 +135,121 @@ func (s *Server) handleScaleApp(c *gin.Context) {
 		return
 	}
 
+	// Replicas, a sizing preset, or both. A manual scale conflicts with an
+	// autoscaler unless its bounds may be adjusted to the new replicas.
 	var req struct {
-		Replicas int32 `json:"replicas" binding:"required,min=0"`
+		Replicas         *int32 `json:"replicas" binding:"omitempty,min=0"`
+		Size             string `json:"size"`
+		AdjustAutoscaler bool   `json:"adjust_autoscaler"`
 	}
 	
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-
-	if err := s.services.Kubernetes.ScaleDeployment(environment, appName, req.Replicas); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if req.Replicas == nil && req.Size == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "replicas or size is required"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Application scaled successfully"})
+	// The env parameter may name an environment or its namespace; stored
+	// projects are updated where the database is available
+	namespace := environment
+	var project *models.Project
+	var env *models.Environment
+	if s.services.Project != nil {
+		if found, err := s.services.Environment.Find(environment); err == nil {
+			env = found
+			namespace = found.Namespace
+		}
+		if found, err := s.services.Project.GetByName(appName); err == nil {
+			project = found
+		}
+	}
+
+	response := gin.H{"message": "Application scaled successfully"}
+
+	// Resizing saves the project and rolls out new resources, so a scale
+	// an autoscaler would refuse is rejected before anything is changed
+	if req.Replicas != nil && req.Size != "" {
+		if err := s.services.Kubernetes.CheckScale(namespace, appName, *req.Replicas, req.AdjustAutoscaler); err != nil {
+			scaleError(c, err)
+			return
+		}
+	}
+
+	if req.Size != "" {
+		switch {
+		case s.services.Project == nil:
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Database is not available"})
+			return
+		case project == nil:
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Project %s not found", appName)})
+			return
+		case env == nil:
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Environment %s not found", environment)})
+			return
+		}
+
+		settings, err := s.services.Deployment.Resize(project, env, req.Size)
+		if err != nil {
+			var validationErr *models.ValidationError
+			var quotaErr *services.QuotaExceededError
+			switch {
+			case errors.As(err, &validationErr):
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "fields": validationErr.Fields})
+			case errors.As(err, &quotaErr):
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "violations": quotaErr.Violations})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
+			return
+		}
+
+		response["size"] = settings.Size
+		response["resources"] = settings.Resources
+	}
+
+	if req.Replicas != nil {
+		autoscaler, err := s.services.Kubernetes.ScaleDeploymentChecked(namespace, appName, *req.Replicas, req.AdjustAutoscaler)
+		if err != nil {
+			scaleError(c, err)
+			return
+		}
+
+		if autoscaler != nil {
+			if project != nil && env != nil {
+				err := s.services.Deployment.SetAutoscalingBounds(project, env, int(autoscaler.MinReplicas), int(autoscaler.MaxReplicas))
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+			}
+			response["autoscaler"] = autoscaler
+		}
+		response["replicas"] = *req.Replicas
+	}
+
+	s.publishScaled(appName, environment, services.AppScaledEvent{Action: "scale", Replicas: req.Replicas, Size: req.Size})
+	c.JSON(http.StatusOK, response)
+}
+
+// scaleError writes the response for an error scaling a deployment.
+func scaleError(c *gin.Context, err error) {
+	var conflict *services.AutoscalerConflictError
+	if errors.As(err, &conflict) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+			"autoscaler": gin.H{
+				"name":         conflict.Autoscaler,
+				"min_replicas": conflict.MinReplicas,
+				"max_replicas": conflict.MaxReplicas,
+			},
+			"hint": "set adjust_autoscaler to raise the autoscaler's minimum to the requested replicas",
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 }
 
 func (s *Server) handleStopApp(c *gin.Context) {
@@ -150,10 +261,11 @@ func (s *Server) handleStopApp(c *gin.Context) {
 		return
 	}
 
-	if err := s.services.Kubernetes.StopDeployment(environment, appName); err != nil {
+	if err := s.services.Kubernetes.StopDeployment(s.appNamespace(environment), appName); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	s.publishScaled(appName, environment, services.AppScaledEvent{Action: "stop"})
 
 	c.JSON(http.StatusOK, gin.H{"message": "Application stopped successfully"})
 }
@@ -177,10 +289,11 @@ func (s *Server) handleStartApp(c *gin.Context) {
 		req.Replicas = 1
 	}
 
-	if err := s.services.Kubernetes.StartDeployment(environment, appName, req.Replicas); err != nil {
+	if err := s.services.Kubernetes.StartDeployment(s.appNamespace(environment), appName, req.Replicas); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	s.publishScaled(appName, environment, services.AppScaledEvent{Action: "start", Replicas: &req.Replicas})
 
 	c.JSON(http.StatusOK, gin.H{"message": "Application started successfully"})
 }
@@ -194,7 +307,7 @@ func (s *Server) handleRestartApp(c *gin.Context) {
 		return
 	}
 
-	if err := s.services.Kubernetes.RestartDeployment(environment, appName); err != nil {
+	if err := s.services.Kubernetes.RestartDeployment(s.appNamespace(environment), appName); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
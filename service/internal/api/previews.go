@@ -0,0 +1,53 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// handleListPreviews lists the preview environments of pull requests, of
+// the project given by ?project_id= or of all projects.
+func (s *Server) handleListPreviews(c *gin.Context) {
+	var projectID uint64
+	if value := c.Query("project_id"); value != "" {
+		var err error
+		projectID, err = strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+			return
+		}
+	}
+
+	previews, err := s.services.Previews.List(uint(projectID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, previews)
+}
+
+// handleDeletePreview tears down a preview before its pull request is
+// closed. It is not redeployed until the next commit.
+func (s *Server) handleDeletePreview(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid preview ID"})
+		return
+	}
+
+	if err := s.services.Previews.Delete(uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Preview not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Preview deleted successfully"})
+}
@@ -1,45 +1,49 @@
 package api
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/plate/service/internal/models"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"github.com/plate/service/internal/services"
 )
 
 // Project handlers
 func (s *Server) handleListProjects(c *gin.Context) {
-	// Get all namespaces managed by Plate
-	namespaces, err := s.services.Kubernetes.GetNamespaces()
+	// Get the namespaces of all environments
+	environments, err := s.environmentNamespaces("")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get namespaces"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get environments"})
 		return
 	}
 
-	// Filter namespaces managed by Plate
-	plateNamespaces := []string{}
-	for _, ns := range namespaces {
-		if ns == "dev" || ns == "staging" || ns == "production" {
-			plateNamespaces = append(plateNamespaces, ns)
+	// Registered projects give the listed applications their IDs
+	var registered []models.Project
+	if s.services.Project != nil {
+		registered, err = s.services.Project.List()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get projects"})
+			return
 		}
 	}
 
 	// Collect unique applications across all environments
 	applicationMap := make(map[string]*ProjectResponse)
 
-	for _, namespace := range plateNamespaces {
-		deployments, err := s.services.Kubernetes.GetClientset().AppsV1().Deployments(namespace).List(c, metav1.ListOptions{
-			LabelSelector: "managed-by=plate",
-		})
+	for _, environment := range environments {
+		deployments, err := s.services.Kubernetes.ListDeployments(environment.Namespace, "managed-by=plate")
 		if err != nil {
 			continue
 		}
 
-		for i, deployment := range deployments.Items {
+		for _, deployment := range deployments {
 			appName := deployment.Labels["app"]
 			runtime := deployment.Labels["runtime"]
 			if appName == "" {
@@ -64,7 +68,7 @@ func (s *Server) handleListProjects(c *gin.Context) {
 
 			if existing, exists := applicationMap[appName]; exists {
 				// Add environment to existing app
-				existing.Environments = append(existing.Environments, namespace)
+				existing.Environments = append(existing.Environments, environment.Name)
 				// Update status if this environment has issues
 				if status != "active" {
 					existing.Status = status
@@ -72,32 +76,79 @@ func (s *Server) handleListProjects(c *gin.Context) {
 			} else {
 				// Create new application entry
 				applicationMap[appName] = &ProjectResponse{
-					ID:          uint(i + 1),
 					Name:        appName,
 					Description: fmt.Sprintf("%s application running on Kubernetes", strings.Title(runtime)),
 					Repository:  fmt.Sprintf("https://github.com/yourorg/%s.git", appName),
 					Runtime:     runtime,
 					Status:      status,
 					LastDeploy:  lastDeploy,
-					Environments: []string{namespace},
+					Environments: []string{environment.Name},
 				}
 			}
 		}
 	}
 
-	// Convert map to slice
+	// Registered projects take their details from the record, and are
+	// listed even when they run nowhere
 	var projects []ProjectResponse
-	for _, project := range applicationMap {
+	for i := range registered {
+		record := &registered[i]
+		project, running := applicationMap[record.Name]
+		if !running {
+			project = &ProjectResponse{
+				Name:         record.Name,
+				Status:       "inactive",
+				LastDeploy:   "never",
+				Environments: []string{},
+			}
+			var last time.Time
+			for _, deployment := range record.Deployments {
+				if deployment.CreatedAt.After(last) {
+					last = deployment.CreatedAt
+				}
+			}
+			if !last.IsZero() {
+				project.LastDeploy = last.Format("2006-01-02 15:04")
+			}
+		}
+		delete(applicationMap, record.Name)
+
+		project.ID = record.ID
+		project.Description = record.Description
+		project.Repository = record.Repository
+		if record.Runtime != "" {
+			project.Runtime = record.Runtime
+		}
+		project.CreatedAt = record.CreatedAt
 		projects = append(projects, *project)
 	}
+	sort.Slice(projects, func(i, j int) bool { return projects[i].ID < projects[j].ID })
+
+	// Then the applications that were not registered, by name
+	names := make([]string, 0, len(applicationMap))
+	for name := range applicationMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		projects = append(projects, *applicationMap[name])
+	}
 
 	c.JSON(http.StatusOK, projects)
 }
 
 func (s *Server) handleCreateProject(c *gin.Context) {
 	var project models.Project
-	if err := c.ShouldBindJSON(&project); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindProject(c, &project) {
+		return
+	}
+
+	// Return the existing project so clients can update it instead
+	if existing, err := s.services.Project.GetByName(project.Name); err == nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   fmt.Sprintf("Project %s already exists", project.Name),
+			"project": existing,
+		})
 		return
 	}
 
@@ -109,6 +160,30 @@ func (s *Server) handleCreateProject(c *gin.Context) {
 	c.JSON(http.StatusCreated, project)
 }
 
+// bindProject decodes a project from the request body, rejecting unknown
+// fields, and validates it with the same rules the CLI applies to
+// .plate/config.yaml. It writes the error response and returns false if the
+// project is invalid.
+func bindProject(c *gin.Context, project *models.Project) bool {
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(project); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+
+	if err := project.Validate(); err != nil {
+		var validationErr *models.ValidationError
+		if errors.As(err, &validationErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "fields": validationErr.Fields})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return false
+	}
+	return true
+}
+
 func (s *Server) handleGetProject(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
@@ -133,8 +208,7 @@ func (s *Server) handleUpdateProject(c *gin.Context) {
 	}
 
 	var project models.Project
-	if err := c.ShouldBindJSON(&project); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindProject(c, &project) {
 		return
 	}
 
@@ -160,4 +234,80 @@ func (s *Server) handleDeleteProject(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Project deleted successfully"})
-}
\ No newline at end of file
+}
+
+// handleSetProjectRepository records the Git repository a project is built
+// from and the provider hosting it, the configured git_provider unless one
+// is given. Without a clone URL, a repository is created at the provider
+// and its details are returned so the client can push to it.
+func (s *Server) handleSetProjectRepository(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	var req struct {
+		Provider string `json:"provider"`
+		Name     string `json:"name"` // the project's name by default
+		CloneURL string `json:"clone_url"`
+		Branch   string `json:"branch"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	provider, err := s.services.Git.Get(req.Provider)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	project, err := s.services.Project.GetByID(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+
+	if req.Name == "" {
+		req.Name = project.Name
+	}
+
+	repo := &models.Repository{
+		Name:     req.Name,
+		URL:      req.CloneURL,
+		CloneURL: req.CloneURL,
+		Branch:   req.Branch,
+		Provider: provider.Name(),
+		FullName: services.RepositoryFullName(req.CloneURL),
+	}
+
+	if req.CloneURL == "" {
+		created, err := provider.CreateRepository(req.Name, project.Description)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
+
+		repo.Name = created.Name
+		repo.URL = created.HTMLURL
+		repo.CloneURL = created.CloneURL
+		repo.FullName = created.FullName
+		repo.ProviderRepoID = created.ID
+		if repo.Branch == "" {
+			repo.Branch = created.DefaultBranch
+		}
+	}
+
+	if repo.Branch == "" {
+		repo.Branch = "main"
+	}
+
+	if err := s.services.Project.SetRepository(project, repo); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, repo)
+}
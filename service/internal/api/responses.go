@@ -4,8 +4,11 @@ import "time"
 
 // Developer-friendly response structures that hide infrastructure complexity
 
+// ProjectResponse is an application running in the cluster or registered
+// as a project. ID is the project's, and is omitted for applications that
+// were not registered.
 type ProjectResponse struct {
-	ID          uint   `json:"id"`
+	ID          uint   `json:"id,omitempty"`
 	Name        string `json:"name"`
 	Description string `json:"description"`
 	Repository  string `json:"repository"`
@@ -16,8 +19,14 @@ type ProjectResponse struct {
 	CreatedAt   time.Time `json:"created_at"`
 }
 
+// DeploymentResponse is the latest deployment of an application in an
+// environment. ID is that of the deployment record, which the other
+// deployment endpoints take, and is omitted for applications that were not
+// deployed by Plate.
 type DeploymentResponse struct {
-	ID                uint   `json:"id"`
+	ID                uint   `json:"id,omitempty"`
+	ProjectID         uint   `json:"project_id,omitempty"`
+	EnvironmentID     uint   `json:"environment_id,omitempty"`
 	Application       string `json:"application"`
 	Environment       string `json:"environment"`
 	Version           string `json:"version"`
@@ -28,14 +37,18 @@ type DeploymentResponse struct {
 	DesiredReplicas   int32  `json:"desired_replicas"`
 	ReadyReplicas     int32  `json:"ready_replicas"`
 	AvailableReplicas int32  `json:"available_replicas"`
+	Health            string `json:"health,omitempty"` // as recorded by the reconciler
+	Drift             string `json:"drift,omitempty"`
 }
 
 type EnvironmentResponse struct {
-	ID     uint   `json:"id"`
-	Name   string `json:"name"`
-	Type   string `json:"type"` // development, staging, production
-	Region string `json:"region"`
-	Status string `json:"status"`
+	ID        uint   `json:"id"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	Domain    string `json:"domain,omitempty"`
+	Type      string `json:"type"` // development, staging, production
+	Region    string `json:"region"`
+	Status    string `json:"status"` // active, inactive, missing or unknown
 }
 
 type ApplicationStatusResponse struct {
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/plate/service/internal/models"
+	"github.com/plate/service/internal/services"
+	"gorm.io/gorm"
+)
+
+// SecretResponse describes a secret without its value, which the API never
+// returns.
+type SecretResponse struct {
+	Name        string `json:"name"`
+	Environment string `json:"environment"` // empty for every environment
+	UpdatedAt   string `json:"updated_at"`
+}
+
+func secretResponse(secret *models.Secret) SecretResponse {
+	return SecretResponse{
+		Name:        secret.Name,
+		Environment: secret.Environment,
+		UpdatedAt:   secret.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+}
+
+// secretProject resolves the project of a secrets request, writing the
+// error response if it does not exist.
+func (s *Server) secretProject(c *gin.Context) (*models.Project, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return nil, false
+	}
+
+	project, err := s.services.Project.GetByID(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return nil, false
+	}
+
+	// Secrets for an unknown environment would never be deployed
+	if env := c.Query("env"); env != "" {
+		if _, err := s.services.Environment.GetByName(env); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Environment %s not found", env)})
+			return nil, false
+		}
+	}
+
+	return project, true
+}
+
+func (s *Server) handleListSecrets(c *gin.Context) {
+	project, ok := s.secretProject(c)
+	if !ok {
+		return
+	}
+
+	secrets, err := s.services.Secrets.List(project.ID, c.Query("env"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := make([]SecretResponse, 0, len(secrets))
+	for i := range secrets {
+		response = append(response, secretResponse(&secrets[i]))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (s *Server) handleSetSecret(c *gin.Context) {
+	project, ok := s.secretProject(c)
+	if !ok {
+		return
+	}
+
+	name := c.Param("name")
+	if !models.ValidEnvVarName(name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid secret name %q: must be a valid environment variable name", name)})
+		return
+	}
+
+	var req struct {
+		Value *string `json:"value" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	secret, err := s.services.Secrets.Set(project.ID, c.Query("env"), name, *req.Value)
+	if err != nil {
+		if errors.Is(err, services.ErrSecretsDisabled) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, secretResponse(secret))
+}
+
+func (s *Server) handleDeleteSecret(c *gin.Context) {
+	project, ok := s.secretProject(c)
+	if !ok {
+		return
+	}
+
+	name := c.Param("name")
+	if err := s.services.Secrets.Delete(project.ID, c.Query("env"), name); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Secret %s not found", name)})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Secret deleted successfully"})
+}
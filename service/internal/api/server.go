@@ -49,6 +49,17 @@ func (s *Server) setupRoutes() {
 		c.JSON(http.StatusOK, gin.H{"status": "healthy"})
 	})
 
+	// Readiness: requests are served from the informer cache once it has
+	// synced. Without a Kubernetes connection there is nothing to wait for.
+	s.router.GET("/ready", func(c *gin.Context) {
+		cache := s.services.Kubernetes.CacheStatus()
+		if cache.Enabled && !cache.Synced {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "syncing", "cache": cache})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ready", "cache": cache})
+	})
+
 	// API v1 routes
 	v1 := s.router.Group("/api/v1")
 	{
@@ -56,10 +67,17 @@ func (s *Server) setupRoutes() {
 		projects := v1.Group("/projects")
 		{
 			projects.GET("", s.handleListProjects)
-			projects.POST("", s.handleCreateProject)
-			projects.GET("/:id", s.handleGetProject)
-			projects.PUT("/:id", s.handleUpdateProject)
-			projects.DELETE("/:id", s.handleDeleteProject)
+			projects.POST("", s.requireDatabase, s.handleCreateProject)
+			projects.GET("/:id", s.requireDatabase, s.handleGetProject)
+			projects.PUT("/:id", s.requireDatabase, s.handleUpdateProject)
+			projects.DELETE("/:id", s.requireDatabase, s.handleDeleteProject)
+			projects.POST("/:id/repository", s.requireDatabase, s.handleSetProjectRepository)
+			projects.GET("/:id/changes", s.requireDatabase, s.handleGetProjectChanges) // ?env=production&format=markdown
+
+			// Secrets, for every environment or the one named by ?env=
+			projects.GET("/:id/secrets", s.requireDatabase, s.handleListSecrets)
+			projects.PUT("/:id/secrets/:name", s.requireDatabase, s.handleSetSecret)
+			projects.DELETE("/:id/secrets/:name", s.requireDatabase, s.handleDeleteSecret)
 		}
 
 		// Deployments
@@ -70,23 +88,45 @@ func (s *Server) setupRoutes() {
 			deployments.GET("/:id", s.handleGetDeployment)
 			deployments.DELETE("/:id", s.handleDeleteDeployment)
 			deployments.GET("/:id/logs", s.handleGetDeploymentLogs)
+			deployments.GET("/:id/changes", s.requireDatabase, s.handleGetDeploymentChanges) // ?format=markdown
+			deployments.POST("/reconcile", s.requireDatabase, s.handleReconcileDeployments) // ?dry_run=true
 		}
 
 		// Environments
 		environments := v1.Group("/environments")
 		{
 			environments.GET("", s.handleListEnvironments)
-			environments.POST("", s.handleCreateEnvironment)
-			environments.GET("/:id", s.handleGetEnvironment)
-			environments.PUT("/:id", s.handleUpdateEnvironment)
+			environments.POST("", s.requireDatabase, s.handleCreateEnvironment)
+			environments.POST("/reconcile", s.requireDatabase, s.handleReconcileEnvironments) // ?dry_run=true
+			environments.GET("/:id", s.requireDatabase, s.handleGetEnvironment)
+			environments.PUT("/:id", s.requireDatabase, s.handleUpdateEnvironment)
+			environments.DELETE("/:id", s.requireDatabase, s.handleDeleteEnvironment) // ?force=true
+		}
+
+		// Preview environments of pull requests
+		previews := v1.Group("/previews")
+		{
+			previews.GET("", s.requireDatabase, s.handleListPreviews) // ?project_id=1
+			previews.DELETE("/:id", s.requireDatabase, s.handleDeletePreview)
+		}
+
+		// Webhooks of Git servers
+		hooks := v1.Group("/hooks")
+		{
+			hooks.POST("/gitea", s.requireDatabase, s.handleGiteaHook)
+			hooks.POST("/github", s.requireDatabase, s.handleGitHubHook)
+			hooks.POST("/gitlab", s.requireDatabase, s.handleGitLabHook)
 		}
 
 		// Deploy action
-		v1.POST("/deploy", s.handleDeploy)
+		v1.POST("/deploy", s.requireDatabase, s.handleDeploy)
 		
 		// Status
 		v1.GET("/status", s.handleGetStatus)
 
+		// Events of deployments and apps, as SSE or over a WebSocket
+		v1.GET("/events", s.handleEvents) // ?types=&project=&environment=&deployment=
+
 		// Application management
 		apps := v1.Group("/apps")
 		{
@@ -107,4 +147,14 @@ func (s *Server) setupRoutes() {
 			manage.POST("/:namespace/:name/restart", s.handleRestartDeployment)
 		}
 	}
-}
\ No newline at end of file
+}
+
+// requireDatabase rejects requests that need stored projects when the
+// service runs without a database.
+func (s *Server) requireDatabase(c *gin.Context) {
+	if s.services.Project == nil {
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Database is not available"})
+		return
+	}
+	c.Next()
+}
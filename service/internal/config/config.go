@@ -1,16 +1,30 @@
 package config
 
 import (
+	"os"
+	"strings"
+	"time"
+
 	"github.com/spf13/viper"
 )
 
 type Config struct {
 	Port      string     `mapstructure:"port"`
+	DashboardURL string  `mapstructure:"dashboard_url"` // linked from commit statuses
 	Database  Database   `mapstructure:"db"`
 	Kubernetes Kubernetes `mapstructure:"kubernetes"`
 	ArgoCD    ArgoCD     `mapstructure:"argocd"`
 	Gitea     Gitea      `mapstructure:"gitea"`
+	GitHub    GitHub     `mapstructure:"github"`
+	GitLab    GitLab     `mapstructure:"gitlab"`
+	GitProvider string   `mapstructure:"git_provider"` // for new repositories: gitea, github or gitlab
 	Helm      Helm       `mapstructure:"helm"`
+	Secrets   Secrets    `mapstructure:"secrets"`
+	Vault     Vault      `mapstructure:"vault"`
+	Environments Environments `mapstructure:"environments"`
+	// ReconcileInterval is how often deployments are compared with the
+	// cluster, Argo CD and Helm; zero disables it
+	ReconcileInterval time.Duration `mapstructure:"reconcile_interval"`
 }
 
 type Database struct {
@@ -35,10 +49,33 @@ type ArgoCD struct {
 	Password string `mapstructure:"password"`
 }
 
+// Gitea is the Git server projects are hosted on. WebhookSecret is the
+// secret its webhooks are signed with; webhooks are refused without it.
 type Gitea struct {
-	URL    string `mapstructure:"url"`
-	Token  string `mapstructure:"token"`
-	OrgName string `mapstructure:"org_name"`
+	URL           string `mapstructure:"url"`
+	Token         string `mapstructure:"token"`
+	OrgName       string `mapstructure:"org_name"`
+	WebhookSecret string `mapstructure:"webhook_secret"`
+}
+
+// GitHub is a GitHub or GitHub Enterprise account projects are hosted on.
+// URL is the API root, https://api.github.com by default; new repositories
+// are created in the organization Owner, or for the token's user.
+type GitHub struct {
+	URL           string `mapstructure:"url"`
+	Token         string `mapstructure:"token"`
+	Owner         string `mapstructure:"owner"`
+	WebhookSecret string `mapstructure:"webhook_secret"`
+}
+
+// GitLab is a GitLab instance projects are hosted on, https://gitlab.com by
+// default. New repositories are created in the group with the path Group,
+// or for the token's user.
+type GitLab struct {
+	URL           string `mapstructure:"url"`
+	Token         string `mapstructure:"token"`
+	Group         string `mapstructure:"group"`
+	WebhookSecret string `mapstructure:"webhook_secret"`
 }
 
 type Helm struct {
@@ -46,9 +83,75 @@ type Helm struct {
 	ChartPath string `mapstructure:"chart_path"`
 }
 
+// Secrets configures the encryption of project secrets at rest. Key is a
+// base64-encoded 32-byte AES key; without it secrets cannot be stored.
+//
+// With ExternalStore set, references to external secrets are rendered as
+// ExternalSecret resources synced by the External Secrets Operator from
+// that store, instead of being read by Plate at deploy time.
+type Secrets struct {
+	Key               string `mapstructure:"key"`
+	ExternalStore     string `mapstructure:"external_store"`
+	ExternalStoreKind string `mapstructure:"external_store_kind"`
+}
+
+// Vault is the HashiCorp Vault server vault:// secret references are read
+// from, using its KV version 2 engine.
+type Vault struct {
+	Address   string `mapstructure:"address"`
+	Token     string `mapstructure:"token"`
+	Namespace string `mapstructure:"namespace"`
+}
+
+// Environments configures the namespace of every environment. Empty
+// quantities leave the corresponding limit unset.
+type Environments struct {
+	Quota          Quota   `mapstructure:"quota"`
+	DefaultRequest Compute `mapstructure:"default_request"` // for containers without requests
+	DefaultLimit   Compute `mapstructure:"default_limit"`   // for containers without limits
+	// NetworkPolicy only admits traffic from inside the namespace and from
+	// IngressNamespace.
+	NetworkPolicy    bool   `mapstructure:"network_policy"`
+	IngressNamespace string `mapstructure:"ingress_namespace"`
+	// DiscoverNamespaces also looks for applications in namespaces labeled
+	// managed-by=plate that belong to no stored environment, and in all of
+	// them when the service runs without a database.
+	DiscoverNamespaces bool `mapstructure:"discover_namespaces"`
+	// Previews configures the environments deployed for pull requests
+	Previews Previews `mapstructure:"previews"`
+}
+
+// Previews configures the environments deployed for pull requests. A
+// preview is served at <app>.<environment>.<Domain> and torn down when its
+// pull request is closed, or TTL after its last deployment. Zero TTL and
+// MaxPerProject disable the corresponding limit; a Quota with any value
+// set replaces the quota of other environments.
+type Previews struct {
+	Enabled       bool          `mapstructure:"enabled"`
+	Domain        string        `mapstructure:"domain"`
+	TTL           time.Duration `mapstructure:"ttl"`
+	MaxPerProject int           `mapstructure:"max_per_project"`
+	Quota         Quota         `mapstructure:"quota"`
+}
+
+// Quota is the ResourceQuota of an environment's namespace.
+type Quota struct {
+	RequestsCPU    string `mapstructure:"requests_cpu"`
+	RequestsMemory string `mapstructure:"requests_memory"`
+	LimitsCPU      string `mapstructure:"limits_cpu"`
+	LimitsMemory   string `mapstructure:"limits_memory"`
+	Pods           string `mapstructure:"pods"`
+}
+
+type Compute struct {
+	CPU    string `mapstructure:"cpu"`
+	Memory string `mapstructure:"memory"`
+}
+
 func Load() *Config {
 	cfg := &Config{
 		Port: viper.GetString("port"),
+		DashboardURL: strings.TrimSuffix(viper.GetString("dashboard_url"), "/"),
 		Database: Database{
 			Host:     viper.GetString("db.host"),
 			Port:     viper.GetString("db.port"),
@@ -69,14 +172,72 @@ func Load() *Config {
 			Password: viper.GetString("argocd.password"),
 		},
 		Gitea: Gitea{
-			URL:     viper.GetString("gitea.url"),
-			Token:   viper.GetString("gitea.token"),
-			OrgName: viper.GetString("gitea.org_name"),
+			URL:           viper.GetString("gitea.url"),
+			Token:         viper.GetString("gitea.token"),
+			OrgName:       viper.GetString("gitea.org_name"),
+			WebhookSecret: viper.GetString("gitea.webhook_secret"),
+		},
+		GitHub: GitHub{
+			URL:           viper.GetString("github.url"),
+			Token:         viper.GetString("github.token"),
+			Owner:         viper.GetString("github.owner"),
+			WebhookSecret: viper.GetString("github.webhook_secret"),
 		},
+		GitLab: GitLab{
+			URL:           viper.GetString("gitlab.url"),
+			Token:         viper.GetString("gitlab.token"),
+			Group:         viper.GetString("gitlab.group"),
+			WebhookSecret: viper.GetString("gitlab.webhook_secret"),
+		},
+		GitProvider: viper.GetString("git_provider"),
+		ReconcileInterval: viper.GetDuration("reconcile_interval"),
 		Helm: Helm{
 			RepoURL:   viper.GetString("helm.repo_url"),
 			ChartPath: viper.GetString("helm.chart_path"),
 		},
+		Secrets: Secrets{
+			Key:               viper.GetString("secrets.key"),
+			ExternalStore:     viper.GetString("secrets.external_store"),
+			ExternalStoreKind: viper.GetString("secrets.external_store_kind"),
+		},
+		Vault: Vault{
+			Address:   viper.GetString("vault.address"),
+			Token:     viper.GetString("vault.token"),
+			Namespace: viper.GetString("vault.namespace"),
+		},
+		Environments: Environments{
+			Quota: Quota{
+				RequestsCPU:    viper.GetString("environments.quota.requests_cpu"),
+				RequestsMemory: viper.GetString("environments.quota.requests_memory"),
+				LimitsCPU:      viper.GetString("environments.quota.limits_cpu"),
+				LimitsMemory:   viper.GetString("environments.quota.limits_memory"),
+				Pods:           viper.GetString("environments.quota.pods"),
+			},
+			DefaultRequest: Compute{
+				CPU:    viper.GetString("environments.default_request.cpu"),
+				Memory: viper.GetString("environments.default_request.memory"),
+			},
+			DefaultLimit: Compute{
+				CPU:    viper.GetString("environments.default_limit.cpu"),
+				Memory: viper.GetString("environments.default_limit.memory"),
+			},
+			NetworkPolicy:      viper.GetBool("environments.network_policy"),
+			IngressNamespace:   viper.GetString("environments.ingress_namespace"),
+			DiscoverNamespaces: viper.GetBool("environments.discover_namespaces"),
+			Previews: Previews{
+				Enabled:       viper.GetBool("environments.previews.enabled"),
+				Domain:        viper.GetString("environments.previews.domain"),
+				TTL:           viper.GetDuration("environments.previews.ttl"),
+				MaxPerProject: viper.GetInt("environments.previews.max_per_project"),
+				Quota: Quota{
+					RequestsCPU:    viper.GetString("environments.previews.quota.requests_cpu"),
+					RequestsMemory: viper.GetString("environments.previews.quota.requests_memory"),
+					LimitsCPU:      viper.GetString("environments.previews.quota.limits_cpu"),
+					LimitsMemory:   viper.GetString("environments.previews.quota.limits_memory"),
+					Pods:           viper.GetString("environments.previews.quota.pods"),
+				},
+			},
+		},
 	}
 
 	// Set defaults
@@ -89,6 +250,50 @@ func Load() *Config {
 	if cfg.Kubernetes.Namespace == "" {
 		cfg.Kubernetes.Namespace = "plate-system"
 	}
+	// The key is better kept out of config files
+	if cfg.Secrets.Key == "" {
+		cfg.Secrets.Key = os.Getenv("PLATE_SECRETS_KEY")
+	}
+	if cfg.Gitea.WebhookSecret == "" {
+		cfg.Gitea.WebhookSecret = os.Getenv("GITEA_WEBHOOK_SECRET")
+	}
+	if cfg.GitHub.URL == "" {
+		cfg.GitHub.URL = "https://api.github.com"
+	}
+	if cfg.GitHub.Token == "" {
+		cfg.GitHub.Token = os.Getenv("GITHUB_TOKEN")
+	}
+	if cfg.GitHub.WebhookSecret == "" {
+		cfg.GitHub.WebhookSecret = os.Getenv("GITHUB_WEBHOOK_SECRET")
+	}
+	if cfg.GitLab.URL == "" {
+		cfg.GitLab.URL = "https://gitlab.com"
+	}
+	if cfg.GitLab.Token == "" {
+		cfg.GitLab.Token = os.Getenv("GITLAB_TOKEN")
+	}
+	if cfg.GitLab.WebhookSecret == "" {
+		cfg.GitLab.WebhookSecret = os.Getenv("GITLAB_WEBHOOK_SECRET")
+	}
+	if cfg.GitProvider == "" {
+		cfg.GitProvider = "gitea"
+	}
+	if !viper.IsSet("reconcile_interval") {
+		cfg.ReconcileInterval = time.Minute
+	}
+	if cfg.Secrets.ExternalStoreKind == "" {
+		cfg.Secrets.ExternalStoreKind = "ClusterSecretStore"
+	}
+	// Same variables as the Vault CLI
+	if cfg.Vault.Address == "" {
+		cfg.Vault.Address = os.Getenv("VAULT_ADDR")
+	}
+	if cfg.Vault.Token == "" {
+		cfg.Vault.Token = os.Getenv("VAULT_TOKEN")
+	}
+	if cfg.Vault.Namespace == "" {
+		cfg.Vault.Namespace = os.Getenv("VAULT_NAMESPACE")
+	}
 
 	return cfg
 }
\ No newline at end of file
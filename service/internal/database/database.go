@@ -28,10 +28,16 @@ func Initialize(cfg config.Database) (*gorm.DB, error) {
 		&models.Deployment{},
 		&models.DeploymentLog{},
 		&models.Repository{},
+		&models.Secret{},
+		&models.Preview{},
+		&models.WebhookDelivery{},
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
+	if err := migrateGitProviders(db); err != nil {
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
 
 	// Create default environments if they don't exist
 	environments := []models.Environment{
@@ -50,4 +56,25 @@ func Initialize(cfg config.Database) (*gorm.DB, error) {
 	}
 
 	return db, nil
-}
\ No newline at end of file
+}
+
+// migrateGitProviders moves rows stored before Git providers other than
+// Gitea were supported: repositories and previews without a provider are on
+// Gitea, and the Gitea ID of a repository becomes its provider ID. The old
+// gitea_repo_id column is left in place.
+func migrateGitProviders(db *gorm.DB) error {
+	if db.Migrator().HasColumn(&models.Repository{}, "gitea_repo_id") {
+		err := db.Exec("UPDATE repositories SET provider_repo_id = gitea_repo_id WHERE COALESCE(provider_repo_id, 0) = 0 AND gitea_repo_id IS NOT NULL").Error
+		if err != nil {
+			return fmt.Errorf("failed to copy gitea_repo_id: %w", err)
+		}
+	}
+
+	for _, model := range []interface{}{&models.Repository{}, &models.Preview{}} {
+		err := db.Model(model).Where("provider = '' OR provider IS NULL").UpdateColumn("provider", "gitea").Error
+		if err != nil {
+			return fmt.Errorf("failed to set the provider of existing rows: %w", err)
+		}
+	}
+	return nil
+}
@@ -12,10 +12,21 @@ type Project struct {
 	Description string    `json:"description"`
 	Repository  string    `json:"repository"`
 	Runtime     string    `json:"runtime"`
+	Framework   string    `json:"framework"`
+	RuntimeVersion string `json:"runtime_version"`
+	BuildContext string   `json:"build_context"` // path of the build context inside the repository
 	BuildCmd    string    `json:"build_cmd"`
 	StartCmd    string    `json:"start_cmd"`
 	Port        int       `json:"port"`
 	EnvVars     string    `json:"env_vars" gorm:"type:text"` // JSON string
+	Replicas    int          `json:"replicas"`
+	Size        string       `json:"size"` // sizing preset, see SizePresets
+	Resources   Resources    `json:"resources" gorm:"serializer:json"` // explicit values override the preset
+	Domain      string       `json:"domain"` // host name replacing <name>.<environment domain>
+	HealthCheck HealthCheck  `json:"health_check" gorm:"serializer:json"`
+	Autoscaling Autoscaling  `json:"autoscaling" gorm:"serializer:json"`
+	// Environments overrides the settings above per environment name
+	Environments map[string]EnvironmentOverride `json:"environments,omitempty" gorm:"serializer:json"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 	
@@ -27,12 +38,40 @@ type Environment struct {
 	Name      string    `json:"name" gorm:"uniqueIndex;not null"`
 	Namespace string    `json:"namespace"`
 	Domain    string    `json:"domain"`
+	Preview   bool      `json:"preview"` // deployed for a pull request, see Preview
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	// AdoptNamespace lets Namespace be provisioned although it existed
+	// before and Plate did not create it. It is never deleted with the
+	// environment.
+	AdoptNamespace bool `json:"adopt_namespace"`
 	
 	Deployments []Deployment `json:"deployments,omitempty" gorm:"foreignKey:EnvironmentID"`
 }
 
+// Preview is an environment a pull request of a project is deployed to. It
+// is torn down when the pull request is closed, or when ExpiresAt passes
+// without new commits.
+type Preview struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	ProjectID     uint       `json:"project_id" gorm:"uniqueIndex:idx_previews_pull_request;not null"`
+	PullRequest   int        `json:"pull_request" gorm:"uniqueIndex:idx_previews_pull_request"`
+	EnvironmentID uint       `json:"environment_id"`
+	Provider      string     `json:"provider"`   // Git provider the pull request was opened at
+	Repository    string     `json:"repository"` // full name of the repository at the provider
+	Branch        string     `json:"branch"`
+	CommitSHA     string     `json:"commit_sha"`
+	URL           string     `json:"url"`
+	CommentID     int64      `json:"-"` // pull request comment announcing the preview
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+
+	Project     Project     `json:"project,omitempty" gorm:"foreignKey:ProjectID"`
+	Environment Environment `json:"environment,omitempty" gorm:"foreignKey:EnvironmentID"`
+}
+
 type Deployment struct {
 	ID            uint        `json:"id" gorm:"primaryKey"`
 	ProjectID     uint        `json:"project_id"`
@@ -43,6 +82,11 @@ type Deployment struct {
 	LogURL        string      `json:"log_url"`
 	ArgoAppName   string      `json:"argo_app_name"`
 	HelmRelease   string      `json:"helm_release"`
+	// Recorded by the reconciler from the cluster, Argo CD and Helm
+	Health        string      `json:"health,omitempty"` // healthy, progressing, degraded, stopped or missing
+	SyncStatus    string      `json:"sync_status,omitempty"` // of the Argo CD application
+	Drift         string      `json:"drift,omitempty" gorm:"type:text"` // how the cluster differs
+	ReconciledAt  *time.Time  `json:"reconciled_at,omitempty"`
 	CreatedAt     time.Time   `json:"created_at"`
 	UpdatedAt     time.Time   `json:"updated_at"`
 	DeletedAt     gorm.DeletedAt `json:"-" gorm:"index"`
@@ -62,15 +106,41 @@ type DeploymentLog struct {
 }
 
 type Repository struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	ProjectID      uint      `json:"project_id"`
+	Name           string    `json:"name"`
+	URL            string    `json:"url"`
+	CloneURL       string    `json:"clone_url"`
+	Branch         string    `json:"branch"`
+	Provider       string    `json:"provider"`  // Git provider hosting the repository: gitea, github or gitlab
+	FullName       string    `json:"full_name"` // owner/name at the provider
+	ProviderRepoID int64     `json:"provider_repo_id"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+
+	Project Project `json:"project,omitempty" gorm:"foreignKey:ProjectID"`
+}
+
+// Secret is a value a project is deployed with that is stored encrypted and
+// only handed to Kubernetes as a Secret, never rendered into the chart. An
+// empty Environment applies to every environment; a secret for a named
+// environment takes precedence.
+type Secret struct {
 	ID          uint      `json:"id" gorm:"primaryKey"`
-	ProjectID   uint      `json:"project_id"`
-	Name        string    `json:"name"`
-	URL         string    `json:"url"`
-	CloneURL    string    `json:"clone_url"`
-	Branch      string    `json:"branch"`
-	GiteaRepoID int       `json:"gitea_repo_id"`
+	ProjectID   uint      `json:"project_id" gorm:"uniqueIndex:idx_secrets_name;not null"`
+	Environment string    `json:"environment" gorm:"uniqueIndex:idx_secrets_name"`
+	Name        string    `json:"name" gorm:"uniqueIndex:idx_secrets_name;not null"`
+	Value       []byte    `json:"-"` // nonce and AES-GCM ciphertext
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
-	
-	Project Project `json:"project,omitempty" gorm:"foreignKey:ProjectID"`
-}
\ No newline at end of file
+}
+
+// WebhookDelivery records a webhook delivery that was handled, so that
+// redeliveries of it are ignored.
+type WebhookDelivery struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	Provider   string    `json:"provider" gorm:"uniqueIndex:idx_webhook_deliveries_delivery;not null"`
+	DeliveryID string    `json:"delivery_id" gorm:"uniqueIndex:idx_webhook_deliveries_delivery;not null"`
+	Event      string    `json:"event"`
+	CreatedAt  time.Time `json:"created_at" gorm:"index"`
+}
@@ -0,0 +1,195 @@
+package models
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+//go:generate cp ../../../cli/internal/project/schema/config.v1.json schema/config.v1.json
+
+// configSchema is the JSON Schema of the CLI's configuration file, copied
+// from cli/internal/project/schema by go generate. Projects are validated
+// against it, so a project is accepted exactly when plate config validate
+// accepts it as a configuration file.
+//
+//go:embed schema/config.v1.json
+var configSchema string
+
+var (
+	projectSchema = compileSchema("")
+	nameSchema    = compileSchema("/$defs/name")
+	domainSchema  = compileSchema("/$defs/domain")
+)
+
+// compileSchema compiles the subschema of configSchema at pointer.
+func compileSchema(pointer string) *jsonschema.Schema {
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource("config.v1.json", strings.NewReader(configSchema)); err != nil {
+		panic(err)
+	}
+	return compiler.MustCompile("config.v1.json#" + pointer)
+}
+
+// configService is a project as the configuration file describes a
+// service, with unset fields left out.
+type configService struct {
+	APIVersion     string                         `json:"apiVersion"`
+	Name           string                         `json:"name,omitempty"`
+	Path           string                         `json:"path,omitempty"`
+	Runtime        string                         `json:"runtime,omitempty"`
+	Framework      string                         `json:"framework,omitempty"`
+	RuntimeVersion string                         `json:"runtime_version,omitempty"`
+	Port           int                            `json:"port,omitempty"`
+	BuildCmd       string                         `json:"build_cmd,omitempty"`
+	StartCmd       string                         `json:"start_cmd,omitempty"`
+	EnvVars        interface{}                    `json:"env_vars,omitempty"`
+	Replicas       int                            `json:"replicas,omitempty"`
+	Size           string                         `json:"size,omitempty"`
+	Resources      Resources                      `json:"resources"`
+	Domain         string                         `json:"domain,omitempty"`
+	HealthCheck    HealthCheck                    `json:"health_check"`
+	Autoscaling    Autoscaling                    `json:"autoscaling"`
+	Environments   map[string]EnvironmentOverride `json:"environments,omitempty"`
+}
+
+// configFields maps the fields of the configuration file to the project
+// fields they are stored in, where the names differ.
+var configFields = map[string]string{"path": "build_context"}
+
+// validateSchema checks a value against schema and records every problem
+// in fields, keyed by the dotted path of the offending field with prefix
+// in front.
+func validateSchema(fields map[string]string, prefix string, schema *jsonschema.Schema, value interface{}) error {
+	// The validator expects values as produced by encoding/json
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	var instance interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&instance); err != nil {
+		return err
+	}
+
+	var validationErr *jsonschema.ValidationError
+	if err := schema.Validate(instance); !errors.As(err, &validationErr) {
+		return err
+	}
+	for _, leaf := range leafErrors(validationErr) {
+		field, problem := schemaProblem(leaf)
+		if prefix != "" {
+			field = strings.TrimSuffix(prefix+"."+field, ".")
+		}
+		if _, ok := fields[field]; !ok {
+			fields[field] = problem
+		}
+	}
+	return nil
+}
+
+// leafErrors returns the problems a validation error is made of. The
+// alternatives of an anyOf are kept together, as only one of them has to
+// be fixed.
+func leafErrors(err *jsonschema.ValidationError) []*jsonschema.ValidationError {
+	if len(err.Causes) == 0 || strings.HasSuffix(err.KeywordLocation, "/anyOf") {
+		return []*jsonschema.ValidationError{err}
+	}
+	var leaves []*jsonschema.ValidationError
+	for _, cause := range err.Causes {
+		// Values are decoded from Go structs and cannot hold unknown
+		// fields. Fields are only reported as unevaluated because a
+		// value nested in them is invalid, which is reported itself.
+		if strings.HasSuffix(cause.KeywordLocation, "/unevaluatedProperties") {
+			continue
+		}
+		leaves = append(leaves, leafErrors(cause)...)
+	}
+	return leaves
+}
+
+// schemaProblem returns the field a schema violation is about and a
+// description of it, worded as the CLI reports it for configuration files.
+func schemaProblem(err *jsonschema.ValidationError) (string, string) {
+	var path []string
+	for _, token := range strings.Split(strings.TrimPrefix(err.InstanceLocation, "/"), "/") {
+		if token == "" {
+			continue
+		}
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		if field, ok := configFields[token]; ok && len(path) == 0 {
+			token = field
+		}
+		path = append(path, token)
+	}
+
+	keyword := err.KeywordLocation[strings.LastIndex(err.KeywordLocation, "/")+1:]
+	message := err.Message
+	switch keyword {
+	case "propertyNames":
+		message = "invalid name: " + message
+	case "not":
+		message = "must be a path inside the repository"
+	case "required":
+		missing := missingProperties(err)
+		if len(missing) == 1 {
+			return strings.Join(append(path, missing[0]), "."), "is required"
+		}
+		message = "missing required fields " + strings.Join(missing, ", ")
+	case "anyOf":
+		var missing []string
+		for _, cause := range err.Causes {
+			missing = append(missing, missingProperties(cause)...)
+		}
+		message = "needs one of " + strings.Join(missing, ", ")
+	}
+	return strings.Join(path, "."), message
+}
+
+// missingProperties returns the properties a required violation lists.
+func missingProperties(err *jsonschema.ValidationError) []string {
+	_, list, ok := strings.Cut(err.Message, "missing properties:")
+	if !ok {
+		return nil
+	}
+	var properties []string
+	for _, property := range strings.Split(list, ",") {
+		properties = append(properties, strings.Trim(strings.TrimSpace(property), "'"))
+	}
+	return properties
+}
+
+// configDocument returns the project in the format of the configuration
+// file. Env vars that are not JSON are reported in fields and left out.
+func (p *Project) configDocument(fields map[string]string) configService {
+	service := configService{
+		APIVersion:     "plate/v1",
+		Name:           p.Name,
+		Path:           p.BuildContext,
+		Runtime:        p.Runtime,
+		Framework:      p.Framework,
+		RuntimeVersion: p.RuntimeVersion,
+		Port:           p.Port,
+		BuildCmd:       p.BuildCmd,
+		StartCmd:       p.StartCmd,
+		Replicas:       p.Replicas,
+		Size:           p.Size,
+		Resources:      p.Resources,
+		Domain:         p.Domain,
+		HealthCheck:    p.HealthCheck,
+		Autoscaling:    p.Autoscaling,
+		Environments:   p.Environments,
+	}
+	if p.EnvVars != "" {
+		if err := json.Unmarshal([]byte(p.EnvVars), &service.EnvVars); err != nil {
+			fields["env_vars"] = fmt.Sprintf("must be a JSON object: %v", err)
+		}
+	}
+	return service
+}
@@ -0,0 +1,174 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+)
+
+// cliProject is the CLI package that owns the configuration schema and the
+// fixtures validated on both sides.
+const cliProject = "../../../cli/internal/project"
+
+func TestSchemaMatchesCLI(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join(cliProject, "schema", "config.v1.json"))
+	if os.IsNotExist(err) {
+		t.Skip("CLI sources are not available")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != configSchema {
+		t.Error("schema/config.v1.json differs from the CLI's schema, run go generate ./internal/models")
+	}
+}
+
+// The fixtures are configuration files the CLI test validates too. Each is
+// turned into the project the CLI would register for it, and must be
+// rejected for the fields listed on its first line.
+func TestValidateFixtures(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join(cliProject, "testdata", "config", "*.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Skip("CLI sources are not available")
+	}
+
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := fixtureProblems(t, data)
+			project := fixtureProject(t, data)
+
+			var got []string
+			err = project.Validate()
+			var validationErr *ValidationError
+			if errors.As(err, &validationErr) {
+				for field := range validationErr.Fields {
+					if strings.HasPrefix(field, "build_context") {
+						field = "path" + strings.TrimPrefix(field, "build_context")
+					}
+					got = append(got, field)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(got)
+			if strings.Join(got, ", ") != strings.Join(want, ", ") {
+				t.Errorf("problems with %s, want %s\n%v", strings.Join(got, ", "), strings.Join(want, ", "), err)
+			}
+		})
+	}
+}
+
+// fixtureProblems returns the sorted fields listed on the "# problems:"
+// line of a fixture.
+func fixtureProblems(t *testing.T, data []byte) []string {
+	line, _, _ := strings.Cut(string(data), "\n")
+	list, ok := strings.CutPrefix(line, "# problems:")
+	if !ok {
+		t.Fatal("fixture does not start with # problems:")
+	}
+	var problems []string
+	for _, field := range strings.Split(list, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			problems = append(problems, field)
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+// fixtureProject decodes a configuration file into a project, storing the
+// build context and env vars the way the API receives them.
+func fixtureProject(t *testing.T, data []byte) *Project {
+	var config map[string]interface{}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		t.Fatal(err)
+	}
+	delete(config, "apiVersion")
+	if path, ok := config["path"]; ok {
+		config["build_context"] = path
+		delete(config, "path")
+	}
+	if envVars, ok := config["env_vars"]; ok {
+		encoded, err := json.Marshal(envVars)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config["env_vars"] = string(encoded)
+	}
+
+	encoded, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var project Project
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&project); err != nil {
+		t.Fatalf("fixture is not a project: %v", err)
+	}
+	return &project
+}
+
+func TestValidateServiceRules(t *testing.T) {
+	project := &Project{
+		Name:    "shop",
+		EnvVars: `{"DB_PASSWORD": "vault://secret/apps/shop"}`,
+		HealthCheck: HealthCheck{
+			Liveness:  &Probe{SuccessThreshold: 2},
+			Readiness: &Probe{Type: ProbeExec, SuccessThreshold: 2},
+		},
+		Autoscaling: Autoscaling{
+			MinReplicas: 5,
+			MaxReplicas: 2,
+			Metrics: []CustomMetric{
+				{Type: MetricPods, Name: "rps", AverageValue: "100", Value: "1k"},
+				{Type: MetricExternal, Name: "queue", AverageValue: "10", Value: "30", Object: &DescribedObject{Kind: "Queue", Name: "orders"}},
+			},
+		},
+		Environments: map[string]EnvironmentOverride{
+			"staging": {EnvVars: map[string]string{"API_KEY": "vault://#key"}},
+		},
+	}
+
+	err := project.Validate()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("error = %v, want a ValidationError", err)
+	}
+	want := []string{
+		"autoscaling",
+		"autoscaling.metrics.0.value",
+		"autoscaling.metrics.1",
+		"autoscaling.metrics.1.object",
+		"env_vars.DB_PASSWORD",
+		"environments.staging.env_vars.API_KEY",
+		"health_check.liveness.success_threshold",
+		"health_check.readiness.command",
+	}
+	var got []string
+	for field := range validationErr.Fields {
+		got = append(got, field)
+	}
+	sort.Strings(got)
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("problems with %s, want %s\n%v", strings.Join(got, ", "), strings.Join(want, ", "), err)
+	}
+
+	if err := (&Project{Name: "shop", EnvVars: "PORT=8080"}).Validate(); err == nil || !strings.Contains(err.Error(), "env_vars: must be a JSON object") {
+		t.Errorf("env vars that are not JSON: error = %v", err)
+	}
+}
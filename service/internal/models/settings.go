@@ -0,0 +1,165 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultSize is the sizing preset of projects that do not choose one.
+const DefaultSize = "medium"
+
+// SizePresets are the named resource sizes a project can use instead of
+// spelling out requests and limits.
+var SizePresets = map[string]Resources{
+	"small": {
+		Requests: ResourceList{CPU: "100m", Memory: "128Mi"},
+		Limits:   ResourceList{CPU: "250m", Memory: "256Mi"},
+	},
+	"medium": {
+		Requests: ResourceList{CPU: "250m", Memory: "256Mi"},
+		Limits:   ResourceList{CPU: "500m", Memory: "512Mi"},
+	},
+	"large": {
+		Requests: ResourceList{CPU: "500m", Memory: "512Mi"},
+		Limits:   ResourceList{CPU: "1", Memory: "1Gi"},
+	},
+	"xlarge": {
+		Requests: ResourceList{CPU: "1", Memory: "1Gi"},
+		Limits:   ResourceList{CPU: "2", Memory: "2Gi"},
+	},
+}
+
+// Resources are the CPU and memory requests and limits of a container,
+// as Kubernetes quantities.
+type Resources struct {
+	Requests ResourceList `json:"requests,omitempty"`
+	Limits   ResourceList `json:"limits,omitempty"`
+}
+
+type ResourceList struct {
+	CPU    string `json:"cpu,omitempty"`
+	Memory string `json:"memory,omitempty"`
+}
+
+// HealthCheck configures the liveness, readiness and startup probes of a
+// container. Path is the default HTTP path for probes that do not set
+// their own.
+type HealthCheck struct {
+	Path      string `json:"path,omitempty"`
+	Liveness  *Probe `json:"liveness,omitempty"`
+	Readiness *Probe `json:"readiness,omitempty"`
+	Startup   *Probe `json:"startup,omitempty"`
+}
+
+// Probe types
+const (
+	ProbeHTTP = "http"
+	ProbeTCP  = "tcp"
+	ProbeExec = "exec"
+	ProbeNone = "none" // disables the probe
+)
+
+// Probe configures a single Kubernetes probe. Zero values leave the
+// Kubernetes default in place.
+type Probe struct {
+	Type                string   `json:"type,omitempty"`
+	Path                string   `json:"path,omitempty"`
+	Port                int      `json:"port,omitempty"` // defaults to the project port
+	Command             []string `json:"command,omitempty"`
+	InitialDelaySeconds int      `json:"initial_delay_seconds,omitempty"`
+	PeriodSeconds       int      `json:"period_seconds,omitempty"`
+	TimeoutSeconds      int      `json:"timeout_seconds,omitempty"`
+	FailureThreshold    int      `json:"failure_threshold,omitempty"`
+	SuccessThreshold    int      `json:"success_threshold,omitempty"`
+}
+
+// Autoscaling configures a HorizontalPodAutoscaler for a project. Without
+// any target, it scales on 80% CPU utilization.
+type Autoscaling struct {
+	Enabled                 *bool          `json:"enabled,omitempty"`
+	MinReplicas             int            `json:"min_replicas,omitempty"`
+	MaxReplicas             int            `json:"max_replicas,omitempty"`
+	TargetCPUUtilization    int            `json:"target_cpu_utilization,omitempty"`
+	TargetMemoryUtilization int            `json:"target_memory_utilization,omitempty"`
+	Metrics                 []CustomMetric `json:"metrics,omitempty"`
+}
+
+// Custom metric types
+const (
+	MetricPods     = "pods"     // averaged over the project's pods
+	MetricObject   = "object"   // describing another object, such as an ingress
+	MetricExternal = "external" // from outside the cluster, such as a queue
+)
+
+// CustomMetric is an autoscaling target on a metric served by a custom or
+// external metrics API. Pods metrics target an AverageValue per pod; object
+// and external metrics target either a Value or an AverageValue.
+type CustomMetric struct {
+	Type         string            `json:"type"`
+	Name         string            `json:"name"`
+	Selector     map[string]string `json:"selector,omitempty"`
+	AverageValue string            `json:"average_value,omitempty"`
+	Value        string            `json:"value,omitempty"`
+	Object       *DescribedObject  `json:"object,omitempty"`
+}
+
+// DescribedObject is the object an object metric describes.
+type DescribedObject struct {
+	APIVersion string `json:"api_version,omitempty"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+}
+
+// IsEnabled reports whether autoscaling is switched on.
+func (a Autoscaling) IsEnabled() bool {
+	return a.Enabled != nil && *a.Enabled
+}
+
+// EnvironmentOverride holds the settings of a project that differ in one
+// environment. Unset fields keep the project's value; env vars are merged
+// key by key. Setting Size starts from that preset instead of the
+// project's resources. Pushes to Branch are deployed to the environment.
+type EnvironmentOverride struct {
+	Replicas    *int              `json:"replicas,omitempty"`
+	Size        string            `json:"size,omitempty"`
+	Resources   *Resources        `json:"resources,omitempty"`
+	EnvVars     map[string]string `json:"env_vars,omitempty"`
+	Domain      string            `json:"domain,omitempty"`
+	HealthCheck *HealthCheck      `json:"health_check,omitempty"`
+	Autoscaling *Autoscaling      `json:"autoscaling,omitempty"`
+	Branch      string            `json:"branch,omitempty"`
+}
+
+// SecretReferenceSchemes are the external secret stores env var values can
+// reference.
+var SecretReferenceSchemes = []string{"vault"}
+
+// SecretReference is an env var value pointing at a secret kept outside of
+// Plate, written as <scheme>://<path>#<key>, such as
+// vault://secret/apps/shop#db_password. Only the reference is stored; the
+// value is read when the project is deployed.
+type SecretReference struct {
+	Scheme string
+	Path   string
+	Key    string
+}
+
+func (r SecretReference) String() string {
+	return fmt.Sprintf("%s://%s#%s", r.Scheme, r.Path, r.Key)
+}
+
+// ParseSecretReference reports whether an env var value references an
+// external secret, returning an error for a malformed reference.
+func ParseSecretReference(value string) (SecretReference, bool, error) {
+	scheme, rest, ok := strings.Cut(value, "://")
+	if !ok || !contains(SecretReferenceSchemes, scheme) {
+		return SecretReference{}, false, nil
+	}
+
+	path, key, ok := strings.Cut(rest, "#")
+	path = strings.Trim(path, "/")
+	if !ok || path == "" || key == "" {
+		return SecretReference{}, true, fmt.Errorf("invalid secret reference %q: must be %s://<path>#<key>", value, scheme)
+	}
+	return SecretReference{Scheme: scheme, Path: path, Key: key}, true, nil
+}
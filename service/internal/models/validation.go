@@ -0,0 +1,172 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// envVarNamePattern matches the env var names the configuration schema
+// allows, for secrets that are checked without it.
+var envVarNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidationError lists the problems found in a project or environment.
+type ValidationError struct {
+	Object string            `json:"-"` // what was validated, project by default
+	Fields map[string]string `json:"fields"`
+}
+
+func (e *ValidationError) Error() string {
+	problems := make([]string, 0, len(e.Fields))
+	for field, problem := range e.Fields {
+		problems = append(problems, fmt.Sprintf("%s: %s", field, problem))
+	}
+	sort.Strings(problems)
+	object := e.Object
+	if object == "" {
+		object = "project"
+	}
+	return "invalid " + object + ": " + strings.Join(problems, "; ")
+}
+
+// Validate checks an environment received from a client.
+func (e *Environment) Validate() error {
+	fields := map[string]string{}
+
+	if err := validateSchema(fields, "name", nameSchema, e.Name); err != nil {
+		return err
+	}
+	if e.Namespace != "" && len(validation.IsDNS1123Label(e.Namespace)) > 0 {
+		fields["namespace"] = "must be a valid namespace name"
+	}
+	if e.Domain != "" {
+		if err := validateSchema(fields, "domain", domainSchema, e.Domain); err != nil {
+			return err
+		}
+	}
+
+	if len(fields) > 0 {
+		return &ValidationError{Object: "environment", Fields: fields}
+	}
+	return nil
+}
+
+// Validate checks a project received from a client against the
+// configuration schema, followed by the rules the schema cannot express.
+func (p *Project) Validate() error {
+	fields := map[string]string{}
+
+	service := p.configDocument(fields)
+	if err := validateSchema(fields, "", projectSchema, service); err != nil {
+		return err
+	}
+
+	if envVars, ok := service.EnvVars.(map[string]interface{}); ok {
+		values := map[string]string{}
+		for name, value := range envVars {
+			if value, ok := value.(string); ok {
+				values[name] = value
+			}
+		}
+		validateSecretReferences(fields, "env_vars", values)
+	}
+	validateSettings(fields, "", &p.HealthCheck, &p.Autoscaling)
+
+	for name, override := range p.Environments {
+		prefix := "environments." + name + "."
+		validateSecretReferences(fields, prefix+"env_vars", override.EnvVars)
+		validateSettings(fields, prefix, override.HealthCheck, override.Autoscaling)
+	}
+
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// validateSecretReferences checks the secret references among the values
+// of env vars.
+func validateSecretReferences(fields map[string]string, field string, envVars map[string]string) {
+	for name, value := range envVars {
+		if _, _, err := ParseSecretReference(value); err != nil {
+			fields[field+"."+name] = err.Error()
+		}
+	}
+}
+
+// validateSettings checks the settings that can be overridden per
+// environment for what the schema cannot express. Nil settings are not set
+// and always valid.
+func validateSettings(fields map[string]string, prefix string, healthCheck *HealthCheck, autoscaling *Autoscaling) {
+	if healthCheck != nil {
+		validateProbe(fields, prefix+"health_check.liveness", healthCheck.Liveness, true)
+		validateProbe(fields, prefix+"health_check.readiness", healthCheck.Readiness, false)
+		validateProbe(fields, prefix+"health_check.startup", healthCheck.Startup, true)
+	}
+
+	if autoscaling != nil {
+		if autoscaling.MaxReplicas > 0 && autoscaling.MinReplicas > autoscaling.MaxReplicas {
+			fields[prefix+"autoscaling"] = "min_replicas must not exceed max_replicas"
+		}
+		for i, metric := range autoscaling.Metrics {
+			validateMetric(fields, fmt.Sprintf("%sautoscaling.metrics.%d", prefix, i), metric)
+		}
+	}
+}
+
+// validateMetric checks the targets of a custom autoscaling metric, of
+// which the schema only requires that one is set.
+func validateMetric(fields map[string]string, field string, metric CustomMetric) {
+	switch {
+	case metric.Type == MetricPods && metric.Value != "":
+		fields[field+".value"] = "pods metrics only take an average_value target"
+	case metric.Type != MetricPods && metric.AverageValue != "" && metric.Value != "":
+		fields[field] = "needs either a value or an average_value target, not both"
+	}
+
+	if metric.Type == MetricObject {
+		if metric.Object != nil && (metric.Object.Kind == "" || metric.Object.Name == "") {
+			fields[field+".object"] = "object metrics need the kind and name of the object"
+		}
+	} else if metric.Object != nil {
+		fields[field+".object"] = "is only allowed for object metrics"
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// validateProbe checks a probe. Kubernetes requires a success threshold of 1
+// for liveness and startup probes.
+func validateProbe(fields map[string]string, field string, probe *Probe, singleSuccess bool) {
+	if probe == nil {
+		return
+	}
+	if probe.Type == ProbeExec && len(probe.Command) == 0 {
+		fields[field+".command"] = "is required for exec probes"
+	}
+	if singleSuccess && probe.SuccessThreshold > 1 {
+		fields[field+".success_threshold"] = "must be 1"
+	}
+}
+
+// ValidSize reports whether size names a sizing preset.
+func ValidSize(size string) bool {
+	_, ok := SizePresets[size]
+	return ok
+}
+
+// ValidEnvVarName reports whether name can be used as an environment
+// variable, which secrets are exposed as.
+func ValidEnvVarName(name string) bool {
+	return envVarNamePattern.MatchString(name)
+}
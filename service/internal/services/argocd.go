@@ -1,11 +1,16 @@
 package services
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/plate/service/internal/config"
 )
 
+// ErrArgoCDUnavailable is returned by lookups of Argo CD applications, which
+// are not implemented yet.
+var ErrArgoCDUnavailable = errors.New("looking up Argo CD applications is not implemented")
+
 type ArgoCDService struct {
 	config config.ArgoCD
 	// Add ArgoCD client when implementing
@@ -52,11 +57,7 @@ func (s *ArgoCDService) DeleteApplication(name string) error {
 
 func (s *ArgoCDService) GetApplication(name string) (*ArgoApplication, error) {
 	// TODO: Implement ArgoCD application retrieval
-	return &ArgoApplication{
-		Name:   name,
-		Status: "Healthy",
-		SyncStatus: "Synced",
-	}, nil
+	return nil, ErrArgoCDUnavailable
 }
 
 func (s *ArgoCDService) SyncApplication(name string) error {
@@ -0,0 +1,318 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/plate/service/internal/models"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// defaultTargetCPUUtilization applies to autoscalers without any target.
+const defaultTargetCPUUtilization = 80
+
+// AutoscalerStatus is the state of the HorizontalPodAutoscaler of a
+// deployment.
+type AutoscalerStatus struct {
+	Name            string                                           `json:"name"`
+	MinReplicas     int32                                            `json:"min_replicas"`
+	MaxReplicas     int32                                            `json:"max_replicas"`
+	CurrentReplicas int32                                            `json:"current_replicas"`
+	DesiredReplicas int32                                            `json:"desired_replicas"`
+	Metrics         []AutoscalerMetric                               `json:"metrics"`
+	Conditions      []autoscalingv2.HorizontalPodAutoscalerCondition `json:"conditions"`
+	LastScaleTime   *metav1.Time                                     `json:"last_scale_time,omitempty"`
+}
+
+// AutoscalerMetric is the current value of a metric an autoscaler scales
+// on, next to its target. Current is empty until the metric is observed.
+type AutoscalerMetric struct {
+	Name    string `json:"name"`
+	Current string `json:"current,omitempty"`
+	Target  string `json:"target"`
+}
+
+// AutoscalerConflictError reports a manual scale of a deployment whose
+// replicas are managed by an autoscaler, which would undo it.
+type AutoscalerConflictError struct {
+	Autoscaler  string
+	MinReplicas int32
+	MaxReplicas int32
+	Replicas    int32
+}
+
+func (e *AutoscalerConflictError) Error() string {
+	return fmt.Sprintf("replicas are managed by autoscaler %s between %d and %d; scaling to %d manually would be undone",
+		e.Autoscaler, e.MinReplicas, e.MaxReplicas, e.Replicas)
+}
+
+// AutoscalerMetrics returns the metrics the autoscaler of a project scales
+// on, in autoscaling/v2 form.
+func (s *DeploymentSettings) AutoscalerMetrics() ([]autoscalingv2.MetricSpec, error) {
+	autoscaling := s.Autoscaling
+	var metrics []autoscalingv2.MetricSpec
+
+	cpu := autoscaling.TargetCPUUtilization
+	if cpu == 0 && autoscaling.TargetMemoryUtilization == 0 && len(autoscaling.Metrics) == 0 {
+		cpu = defaultTargetCPUUtilization
+	}
+	if cpu != 0 {
+		metrics = append(metrics, utilizationMetric(corev1.ResourceCPU, cpu))
+	}
+	if autoscaling.TargetMemoryUtilization != 0 {
+		metrics = append(metrics, utilizationMetric(corev1.ResourceMemory, autoscaling.TargetMemoryUtilization))
+	}
+
+	for _, custom := range autoscaling.Metrics {
+		target, err := metricTarget(custom)
+		if err != nil {
+			return nil, fmt.Errorf("metric %s: %w", custom.Name, err)
+		}
+
+		identifier := autoscalingv2.MetricIdentifier{Name: custom.Name}
+		if len(custom.Selector) > 0 {
+			identifier.Selector = &metav1.LabelSelector{MatchLabels: custom.Selector}
+		}
+
+		switch custom.Type {
+		case models.MetricPods:
+			metrics = append(metrics, autoscalingv2.MetricSpec{
+				Type: autoscalingv2.PodsMetricSourceType,
+				Pods: &autoscalingv2.PodsMetricSource{Metric: identifier, Target: target},
+			})
+		case models.MetricObject:
+			if custom.Object == nil {
+				return nil, fmt.Errorf("metric %s: object metrics need the described object", custom.Name)
+			}
+			metrics = append(metrics, autoscalingv2.MetricSpec{
+				Type: autoscalingv2.ObjectMetricSourceType,
+				Object: &autoscalingv2.ObjectMetricSource{
+					DescribedObject: autoscalingv2.CrossVersionObjectReference{
+						APIVersion: custom.Object.APIVersion,
+						Kind:       custom.Object.Kind,
+						Name:       custom.Object.Name,
+					},
+					Metric: identifier,
+					Target: target,
+				},
+			})
+		case models.MetricExternal:
+			metrics = append(metrics, autoscalingv2.MetricSpec{
+				Type:     autoscalingv2.ExternalMetricSourceType,
+				External: &autoscalingv2.ExternalMetricSource{Metric: identifier, Target: target},
+			})
+		default:
+			return nil, fmt.Errorf("metric %s: unknown type %q", custom.Name, custom.Type)
+		}
+	}
+
+	return metrics, nil
+}
+
+func utilizationMetric(name corev1.ResourceName, percent int) autoscalingv2.MetricSpec {
+	utilization := int32(percent)
+	return autoscalingv2.MetricSpec{
+		Type: autoscalingv2.ResourceMetricSourceType,
+		Resource: &autoscalingv2.ResourceMetricSource{
+			Name: name,
+			Target: autoscalingv2.MetricTarget{
+				Type:               autoscalingv2.UtilizationMetricType,
+				AverageUtilization: &utilization,
+			},
+		},
+	}
+}
+
+func metricTarget(metric models.CustomMetric) (autoscalingv2.MetricTarget, error) {
+	if metric.AverageValue != "" {
+		quantity, err := resource.ParseQuantity(metric.AverageValue)
+		if err != nil {
+			return autoscalingv2.MetricTarget{}, err
+		}
+		return autoscalingv2.MetricTarget{Type: autoscalingv2.AverageValueMetricType, AverageValue: &quantity}, nil
+	}
+	if metric.Value != "" {
+		quantity, err := resource.ParseQuantity(metric.Value)
+		if err != nil {
+			return autoscalingv2.MetricTarget{}, err
+		}
+		return autoscalingv2.MetricTarget{Type: autoscalingv2.ValueMetricType, Value: &quantity}, nil
+	}
+	return autoscalingv2.MetricTarget{}, fmt.Errorf("no target value")
+}
+
+// FindAutoscaler returns the HorizontalPodAutoscaler that scales a
+// deployment, or nil if there is none.
+func (s *KubernetesService) FindAutoscaler(namespace, deploymentName string) (*autoscalingv2.HorizontalPodAutoscaler, error) {
+	if s.clientset == nil {
+		return nil, nil
+	}
+
+	autoscalers, err := s.clientset.AutoscalingV2().HorizontalPodAutoscalers(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list autoscalers in namespace %s: %w", namespace, err)
+	}
+
+	for i := range autoscalers.Items {
+		target := autoscalers.Items[i].Spec.ScaleTargetRef
+		if target.Kind == "Deployment" && target.Name == deploymentName {
+			return &autoscalers.Items[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// CheckScale reports the conflict ScaleDeploymentChecked would refuse to
+// scale a deployment over, without changing anything. It lets callers
+// find out before making other changes along with the scale.
+func (s *KubernetesService) CheckScale(namespace, name string, replicas int32, adjustAutoscaler bool) error {
+	autoscaler, err := s.FindAutoscaler(namespace, name)
+	if err != nil {
+		return err
+	}
+	if conflict := autoscalerConflict(autoscaler, replicas, adjustAutoscaler); conflict != nil {
+		return conflict
+	}
+	return nil
+}
+
+// autoscalerConflict returns the conflict of scaling to replicas while
+// autoscaler manages them, or nil if the scale can go ahead.
+func autoscalerConflict(autoscaler *autoscalingv2.HorizontalPodAutoscaler, replicas int32, adjustAutoscaler bool) *AutoscalerConflictError {
+	// Autoscalers cannot scale to zero
+	if autoscaler == nil || (adjustAutoscaler && replicas >= 1) {
+		return nil
+	}
+
+	minReplicas := int32(1)
+	if autoscaler.Spec.MinReplicas != nil {
+		minReplicas = *autoscaler.Spec.MinReplicas
+	}
+	return &AutoscalerConflictError{
+		Autoscaler:  autoscaler.Name,
+		MinReplicas: minReplicas,
+		MaxReplicas: autoscaler.Spec.MaxReplicas,
+		Replicas:    replicas,
+	}
+}
+
+// ScaleDeploymentChecked scales a deployment like ScaleDeployment, but
+// refuses to when an autoscaler manages its replicas. With
+// adjustAutoscaler, the autoscaler's minimum is moved to replicas instead,
+// raising its maximum if needed, and the new bounds are returned.
+func (s *KubernetesService) ScaleDeploymentChecked(namespace, name string, replicas int32, adjustAutoscaler bool) (*AutoscalerStatus, error) {
+	autoscaler, err := s.FindAutoscaler(namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if autoscaler == nil {
+		return nil, s.ScaleDeployment(namespace, name, replicas)
+	}
+	if conflict := autoscalerConflict(autoscaler, replicas, adjustAutoscaler); conflict != nil {
+		return nil, conflict
+	}
+
+	autoscaler.Spec.MinReplicas = &replicas
+	if autoscaler.Spec.MaxReplicas < replicas {
+		autoscaler.Spec.MaxReplicas = replicas
+	}
+	updated, err := s.clientset.AutoscalingV2().HorizontalPodAutoscalers(namespace).Update(context.TODO(), autoscaler, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update autoscaler %s/%s: %w", namespace, autoscaler.Name, err)
+	}
+
+	if err := s.ScaleDeployment(namespace, name, replicas); err != nil {
+		return nil, err
+	}
+
+	return autoscalerStatus(updated), nil
+}
+
+func autoscalerStatus(autoscaler *autoscalingv2.HorizontalPodAutoscaler) *AutoscalerStatus {
+	status := &AutoscalerStatus{
+		Name:            autoscaler.Name,
+		MinReplicas:     1,
+		MaxReplicas:     autoscaler.Spec.MaxReplicas,
+		CurrentReplicas: autoscaler.Status.CurrentReplicas,
+		DesiredReplicas: autoscaler.Status.DesiredReplicas,
+		Metrics:         make([]AutoscalerMetric, 0, len(autoscaler.Spec.Metrics)),
+		Conditions:      autoscaler.Status.Conditions,
+		LastScaleTime:   autoscaler.Status.LastScaleTime,
+	}
+	if autoscaler.Spec.MinReplicas != nil {
+		status.MinReplicas = *autoscaler.Spec.MinReplicas
+	}
+
+	current := map[string]string{}
+	for _, metric := range autoscaler.Status.CurrentMetrics {
+		name, value := metricStatus(metric)
+		current[name] = value
+	}
+	for _, metric := range autoscaler.Spec.Metrics {
+		name, target := metricSpec(metric)
+		status.Metrics = append(status.Metrics, AutoscalerMetric{
+			Name:    name,
+			Current: current[name],
+			Target:  target,
+		})
+	}
+
+	return status
+}
+
+// metricSpec returns the name and target of a metric.
+func metricSpec(metric autoscalingv2.MetricSpec) (string, string) {
+	switch {
+	case metric.Resource != nil:
+		return string(metric.Resource.Name), formatTarget(metric.Resource.Target)
+	case metric.ContainerResource != nil:
+		return string(metric.ContainerResource.Name), formatTarget(metric.ContainerResource.Target)
+	case metric.Pods != nil:
+		return metric.Pods.Metric.Name, formatTarget(metric.Pods.Target)
+	case metric.Object != nil:
+		return metric.Object.Metric.Name, formatTarget(metric.Object.Target)
+	case metric.External != nil:
+		return metric.External.Metric.Name, formatTarget(metric.External.Target)
+	}
+	return string(metric.Type), ""
+}
+
+// metricStatus returns the name and current value of a metric.
+func metricStatus(metric autoscalingv2.MetricStatus) (string, string) {
+	switch {
+	case metric.Resource != nil:
+		return string(metric.Resource.Name), formatValue(metric.Resource.Current)
+	case metric.ContainerResource != nil:
+		return string(metric.ContainerResource.Name), formatValue(metric.ContainerResource.Current)
+	case metric.Pods != nil:
+		return metric.Pods.Metric.Name, formatValue(metric.Pods.Current)
+	case metric.Object != nil:
+		return metric.Object.Metric.Name, formatValue(metric.Object.Current)
+	case metric.External != nil:
+		return metric.External.Metric.Name, formatValue(metric.External.Current)
+	}
+	return string(metric.Type), ""
+}
+
+func formatTarget(target autoscalingv2.MetricTarget) string {
+	return formatValue(autoscalingv2.MetricValueStatus{
+		Value:              target.Value,
+		AverageValue:       target.AverageValue,
+		AverageUtilization: target.AverageUtilization,
+	})
+}
+
+func formatValue(value autoscalingv2.MetricValueStatus) string {
+	switch {
+	case value.AverageUtilization != nil:
+		return fmt.Sprintf("%d%%", *value.AverageUtilization)
+	case value.AverageValue != nil:
+		return value.AverageValue.String() + " per pod"
+	case value.Value != nil:
+		return value.Value.String()
+	}
+	return ""
+}
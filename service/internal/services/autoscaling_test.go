@@ -0,0 +1,56 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testAutoscaler(namespace, deployment string, minReplicas, maxReplicas int32) *autoscalingv2.HorizontalPodAutoscaler {
+	return &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: deployment},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{Kind: "Deployment", Name: deployment},
+			MinReplicas:    replicas(minReplicas),
+			MaxReplicas:    maxReplicas,
+		},
+	}
+}
+
+func TestCheckScale(t *testing.T) {
+	s := newTestKubernetesService(
+		testDeployment("production", "shop"),
+		testDeployment("production", "blog"),
+		testAutoscaler("production", "shop", 2, 5),
+	)
+
+	tests := []struct {
+		name     string
+		replicas int32
+		adjust   bool
+		conflict bool
+	}{
+		{"blog", 3, false, false},
+		{"shop", 3, false, true},
+		{"shop", 3, true, false},
+		{"shop", 0, true, true},
+	}
+	for _, tt := range tests {
+		err := s.CheckScale("production", tt.name, tt.replicas, tt.adjust)
+		var conflict *AutoscalerConflictError
+		if errors.As(err, &conflict) != tt.conflict {
+			t.Errorf("CheckScale(%s, %d, %v) = %v, want conflict %v", tt.name, tt.replicas, tt.adjust, err, tt.conflict)
+		}
+		if conflict != nil && (conflict.Autoscaler != "shop" || conflict.MinReplicas != 2 || conflict.MaxReplicas != 5) {
+			t.Errorf("conflict = %+v", conflict)
+		}
+	}
+
+	// Checking changes nothing
+	autoscaler, err := s.FindAutoscaler("production", "shop")
+	if err != nil || *autoscaler.Spec.MinReplicas != 2 || autoscaler.Spec.MaxReplicas != 5 {
+		t.Errorf("autoscaler = %+v, %v", autoscaler, err)
+	}
+}
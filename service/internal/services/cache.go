@@ -0,0 +1,246 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	networkinglisters "k8s.io/client-go/listers/networking/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// kubernetesCache keeps the namespaces managed by plate, and the
+// Deployments, Pods, Ingresses and Events in them, in shared informers, so
+// that requests read them from memory instead of listing them from the API
+// server. Every managed namespace gets its own informers, started when the
+// namespace appears and stopped when it goes away or loses its label.
+type kubernetesCache struct {
+	clientset kubernetes.Interface
+	events    *EventBus
+
+	namespaces       corelisters.NamespaceLister
+	namespacesSynced cache.InformerSynced
+
+	mu     sync.RWMutex
+	scopes map[string]*namespaceCache
+}
+
+// namespaceCache holds the informers of one managed namespace.
+type namespaceCache struct {
+	deployments appslisters.DeploymentNamespaceLister
+	pods        corelisters.PodNamespaceLister
+	ingresses   networkinglisters.IngressNamespaceLister
+	synced      []cache.InformerSynced
+	stop        chan struct{}
+}
+
+func (n *namespaceCache) hasSynced() bool {
+	for _, synced := range n.synced {
+		if !synced() {
+			return false
+		}
+	}
+	return true
+}
+
+func newKubernetesCache(clientset kubernetes.Interface, events *EventBus) *kubernetesCache {
+	return &kubernetesCache{
+		clientset: clientset,
+		events:    events,
+		scopes:    map[string]*namespaceCache{},
+	}
+}
+
+// start runs the namespace informer until stop is closed. The informers of
+// the namespaces follow it.
+func (c *kubernetesCache) start(stop <-chan struct{}) {
+	factory := informers.NewSharedInformerFactoryWithOptions(c.clientset, 0,
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = ManagedByLabel + "=plate"
+		}),
+	)
+	informer := factory.Core().V1().Namespaces()
+	c.namespaces = informer.Lister()
+	c.namespacesSynced = informer.Informer().HasSynced
+
+	informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if ns, ok := obj.(*corev1.Namespace); ok {
+				c.watchNamespace(ns.Name)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if ns, ok := obj.(*corev1.Namespace); ok {
+				c.forgetNamespace(ns.Name)
+			}
+		},
+	})
+
+	factory.Start(stop)
+	go func() {
+		<-stop
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for name, scope := range c.scopes {
+			close(scope.stop)
+			delete(c.scopes, name)
+		}
+	}()
+}
+
+func (c *kubernetesCache) watchNamespace(namespace string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.scopes[namespace]; ok {
+		return
+	}
+
+	factory := informers.NewSharedInformerFactoryWithOptions(c.clientset, 0, informers.WithNamespace(namespace))
+	deployments := factory.Apps().V1().Deployments()
+	pods := factory.Core().V1().Pods()
+	ingresses := factory.Networking().V1().Ingresses()
+	events := factory.Core().V1().Events()
+
+	scope := &namespaceCache{
+		deployments: deployments.Lister().Deployments(namespace),
+		pods:        pods.Lister().Pods(namespace),
+		ingresses:   ingresses.Lister().Ingresses(namespace),
+		synced: []cache.InformerSynced{
+			deployments.Informer().HasSynced,
+			pods.Informer().HasSynced,
+			ingresses.Informer().HasSynced,
+			events.Informer().HasSynced,
+		},
+		stop: make(chan struct{}),
+	}
+	pods.Informer().AddEventHandler(c.podHandler())
+	events.Informer().AddEventHandler(c.warningHandler(scope))
+
+	c.scopes[namespace] = scope
+	factory.Start(scope.stop)
+}
+
+func (c *kubernetesCache) forgetNamespace(namespace string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if scope, ok := c.scopes[namespace]; ok {
+		close(scope.stop)
+		delete(c.scopes, namespace)
+	}
+}
+
+// scope returns the informers of a namespace, or nil if the namespace is
+// not managed by plate or its informers have not synced yet.
+func (c *kubernetesCache) scope(namespace string) *namespaceCache {
+	if c == nil {
+		return nil
+	}
+	c.mu.RLock()
+	scope := c.scopes[namespace]
+	c.mu.RUnlock()
+	if scope == nil || !scope.hasSynced() {
+		return nil
+	}
+	return scope
+}
+
+// synced tells whether the namespaces and the objects in them have been
+// listed, listing the namespaces that are still syncing otherwise.
+func (c *kubernetesCache) synced() (bool, []string) {
+	if !c.namespacesSynced() {
+		return false, nil
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var pending []string
+	for name, scope := range c.scopes {
+		if !scope.hasSynced() {
+			pending = append(pending, name)
+		}
+	}
+	sort.Strings(pending)
+	return len(pending) == 0, pending
+}
+
+// managedNamespaces returns the cached namespaces, or false before they
+// have been listed.
+func (c *kubernetesCache) managedNamespaces() ([]corev1.Namespace, bool) {
+	if c == nil || !c.namespacesSynced() {
+		return nil, false
+	}
+	cached, err := c.namespaces.List(labels.Everything())
+	if err != nil {
+		return nil, false
+	}
+	sort.Slice(cached, func(i, j int) bool { return cached[i].Name < cached[j].Name })
+
+	namespaces := make([]corev1.Namespace, 0, len(cached))
+	for _, ns := range cached {
+		namespaces = append(namespaces, *ns)
+	}
+	return namespaces, true
+}
+
+// environment returns the environment of a managed namespace, as told by
+// its labels, or "" for other namespaces.
+func (c *kubernetesCache) environment(namespace string) string {
+	ns, err := c.namespaces.Get(namespace)
+	if err != nil {
+		return ""
+	}
+	if environment := ns.Labels[EnvironmentLabel]; environment != "" {
+		return environment
+	}
+	return namespace
+}
+
+// CacheStatus tells whether the cache of cluster objects is ready to serve
+// requests.
+type CacheStatus struct {
+	// Enabled is false without a Kubernetes connection, when requests have
+	// nothing to wait for
+	Enabled bool     `json:"enabled"`
+	Synced  bool     `json:"synced"`
+	Pending []string `json:"pending,omitempty"` // namespaces still syncing
+}
+
+// StartCache starts the informers that back the reads of deployments, pods,
+// ingresses and namespaces, and publishes the pod phase changes and warning
+// events they see. Until the informers have synced, reads go to the API
+// server.
+func (s *KubernetesService) StartCache(events *EventBus, stop <-chan struct{}) {
+	if s.clientset == nil {
+		return
+	}
+	c := newKubernetesCache(s.clientset, events)
+	c.start(stop)
+	s.cache = c
+}
+
+// CacheStatus reports whether the informers have synced.
+func (s *KubernetesService) CacheStatus() CacheStatus {
+	if s.cache == nil {
+		return CacheStatus{}
+	}
+	synced, pending := s.cache.synced()
+	return CacheStatus{Enabled: true, Synced: synced, Pending: pending}
+}
+
+func parseSelector(selector string) (labels.Selector, error) {
+	parsed, err := labels.Parse(selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid label selector %q: %w", selector, err)
+	}
+	return parsed, nil
+}
@@ -0,0 +1,371 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+)
+
+func testManagedNamespace(name, environment string) *corev1.Namespace {
+	return testNamespace(name, map[string]string{ManagedByLabel: "plate", EnvironmentLabel: environment}, nil)
+}
+
+func testPod(namespace, name, app string, phase corev1.PodPhase) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: map[string]string{"app": app}},
+		Status:     corev1.PodStatus{Phase: phase},
+	}
+}
+
+func testDeployment(namespace, name string) *appsv1.Deployment {
+	return &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: map[string]string{"app": name}}}
+}
+
+func testEvent(namespace, name, eventType string, count int32, object corev1.ObjectReference) *corev1.Event {
+	return &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Namespace: namespace, Name: name},
+		InvolvedObject: object,
+		Type:           eventType,
+		Reason:         "Unhealthy",
+		Message:        "Readiness probe failed",
+		Count:          count,
+	}
+}
+
+// newTestCache returns a cache that knows the given namespaces, without
+// informers, for calling its handlers directly.
+func newTestCache(t *testing.T, namespaces ...*corev1.Namespace) (*kubernetesCache, *Subscription) {
+	t.Helper()
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, ns := range namespaces {
+		if err := indexer.Add(ns); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	events := NewEventBus()
+	t.Cleanup(events.Close)
+	c := newKubernetesCache(fake.NewSimpleClientset(), events)
+	c.namespaces = corelisters.NewNamespaceLister(indexer)
+	return c, events.Subscribe(EventFilter{}, 0)
+}
+
+// receive returns the next event of a subscription, failing the test if
+// none arrives in time.
+func receive(t *testing.T, sub *Subscription) Event {
+	t.Helper()
+	select {
+	case event := <-sub.Events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event was published")
+		return Event{}
+	}
+}
+
+// expectNoEvent fails the test if the subscription has an event.
+func expectNoEvent(t *testing.T, sub *Subscription) {
+	t.Helper()
+	select {
+	case event := <-sub.Events:
+		t.Errorf("unexpected event %s %+v", event.Type, event.Data)
+	default:
+	}
+}
+
+func TestParseSelector(t *testing.T) {
+	tests := []struct {
+		selector string
+		labels   labels.Set
+		matches  bool
+	}{
+		{selector: "", labels: map[string]string{"app": "shop"}, matches: true},
+		{selector: "app=shop", labels: map[string]string{"app": "shop"}, matches: true},
+		{selector: "app=shop", labels: map[string]string{"app": "cart"}, matches: false},
+		{selector: "app=shop,tier in (web, worker)", labels: map[string]string{"app": "shop", "tier": "worker"}, matches: true},
+		{selector: "app!=shop", labels: map[string]string{}, matches: true},
+	}
+	for _, tt := range tests {
+		selector, err := parseSelector(tt.selector)
+		if err != nil {
+			t.Errorf("parseSelector(%q): %v", tt.selector, err)
+			continue
+		}
+		if got := selector.Matches(tt.labels); got != tt.matches {
+			t.Errorf("%q matches %v = %v, want %v", tt.selector, tt.labels, got, tt.matches)
+		}
+	}
+
+	for _, selector := range []string{"=shop", "app in shop", "app=shop)"} {
+		if _, err := parseSelector(selector); err == nil || !strings.Contains(err.Error(), "invalid label selector") {
+			t.Errorf("parseSelector(%q) error = %v", selector, err)
+		}
+	}
+}
+
+func TestPodHandler(t *testing.T) {
+	c, sub := newTestCache(t, testManagedNamespace("plate-staging", "staging"))
+	handler := c.podHandler()
+
+	// Pods listed when the informers start were there before
+	handler.OnAdd(testPod("plate-staging", "shop-1", "shop", corev1.PodRunning), true)
+	expectNoEvent(t, sub)
+
+	handler.OnAdd(testPod("plate-staging", "shop-2", "shop", corev1.PodPending), false)
+	event := receive(t, sub)
+	want := PodPhaseEvent{Pod: "shop-2", Phase: "Pending"}
+	if event.Type != EventPodPhase || event.Project != "shop" || event.Environment != "staging" || event.Data != want {
+		t.Errorf("event = %+v", event)
+	}
+
+	// Only changes of the phase or reason are published
+	pending := testPod("plate-staging", "shop-2", "shop", corev1.PodPending)
+	relabelled := pending.DeepCopy()
+	relabelled.Labels["tier"] = "web"
+	handler.OnUpdate(pending, relabelled)
+	expectNoEvent(t, sub)
+
+	crashing := testPod("plate-staging", "shop-2", "shop", corev1.PodRunning)
+	crashing.Status.ContainerStatuses = []corev1.ContainerStatus{{
+		State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+	}}
+	handler.OnUpdate(pending, crashing)
+	want = PodPhaseEvent{Pod: "shop-2", Phase: "Running", PreviousPhase: "Pending", Reason: "CrashLoopBackOff"}
+	if event := receive(t, sub); event.Data != want {
+		t.Errorf("event data = %+v, want %+v", event.Data, want)
+	}
+
+	handler.OnDelete(cache.DeletedFinalStateUnknown{Key: "plate-staging/shop-2", Obj: crashing})
+	want = PodPhaseEvent{Pod: "shop-2", Phase: "Deleted", PreviousPhase: "Running"}
+	if event := receive(t, sub); event.Data != want {
+		t.Errorf("event data = %+v, want %+v", event.Data, want)
+	}
+
+	// Pods of namespaces plate does not manage are not announced
+	handler.OnAdd(testPod("kube-system", "coredns-1", "coredns", corev1.PodRunning), false)
+	expectNoEvent(t, sub)
+}
+
+func TestWarningHandler(t *testing.T) {
+	c, sub := newTestCache(t, testManagedNamespace("plate-staging", "staging"))
+	pods := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	if err := pods.Add(testPod("plate-staging", "shop-1", "shop", corev1.PodRunning)); err != nil {
+		t.Fatal(err)
+	}
+	scope := &namespaceCache{pods: corelisters.NewPodLister(pods).Pods("plate-staging")}
+	handler := c.warningHandler(scope)
+	pod := corev1.ObjectReference{Kind: "Pod", Name: "shop-1"}
+
+	handler.OnAdd(testEvent("plate-staging", "shop-1.1", corev1.EventTypeWarning, 1, pod), true)
+	handler.OnAdd(testEvent("plate-staging", "shop-1.2", corev1.EventTypeNormal, 1, pod), false)
+	expectNoEvent(t, sub)
+
+	warning := testEvent("plate-staging", "shop-1.3", corev1.EventTypeWarning, 1, pod)
+	handler.OnAdd(warning, false)
+	event := receive(t, sub)
+	want := AppWarningEvent{Kind: "Pod", Name: "shop-1", Reason: "Unhealthy", Message: "Readiness probe failed", Count: 1}
+	if event.Type != EventAppWarning || event.Project != "shop" || event.Environment != "staging" || event.Data != want {
+		t.Errorf("event = %+v", event)
+	}
+
+	// A warning is published again when it repeats
+	handler.OnUpdate(warning, warning.DeepCopy())
+	expectNoEvent(t, sub)
+	repeated := warning.DeepCopy()
+	repeated.Count = 2
+	handler.OnUpdate(warning, repeated)
+	if event := receive(t, sub); event.Data.(AppWarningEvent).Count != 2 {
+		t.Errorf("event data = %+v", event.Data)
+	}
+}
+
+// startTestCache starts the informers of a KubernetesService on a fake
+// clientset and waits until the namespace has synced.
+func startTestCache(t *testing.T, namespace string, objects ...runtime.Object) (*KubernetesService, *fake.Clientset, *EventBus) {
+	t.Helper()
+	clientset := fake.NewSimpleClientset(objects...)
+	s := &KubernetesService{clientset: clientset}
+	events := NewEventBus()
+	stop := make(chan struct{})
+	t.Cleanup(func() {
+		close(stop)
+		events.Close()
+	})
+
+	s.StartCache(events, stop)
+	deadline := time.Now().Add(5 * time.Second)
+	for s.cache.scope(namespace) == nil || !s.CacheStatus().Synced {
+		if time.Now().After(deadline) {
+			t.Fatalf("cache did not sync: %+v", s.CacheStatus())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return s, clientset, events
+}
+
+// listActions returns the resources listed through the clientset.
+func listActions(clientset *fake.Clientset) []string {
+	var listed []string
+	for _, action := range clientset.Actions() {
+		if action, ok := action.(k8stesting.ListAction); ok {
+			listed = append(listed, action.GetNamespace()+"/"+action.GetResource().Resource)
+		}
+	}
+	return listed
+}
+
+func TestListFromCache(t *testing.T) {
+	s, clientset, _ := startTestCache(t, "plate-staging",
+		testManagedNamespace("plate-staging", "staging"),
+		testNamespace("other", nil, nil),
+		testPod("plate-staging", "shop-2", "shop", corev1.PodRunning),
+		testPod("plate-staging", "shop-1", "shop", corev1.PodRunning),
+		testPod("plate-staging", "cart-1", "cart", corev1.PodRunning),
+		testPod("other", "shop-1", "shop", corev1.PodRunning),
+		testDeployment("plate-staging", "shop"),
+		testDeployment("plate-staging", "cart"),
+		testDeployment("other", "shop"),
+	)
+	clientset.ClearActions()
+
+	pods, err := s.ListPods("plate-staging", "app=shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pods) != 2 || pods[0].Name != "shop-1" || pods[1].Name != "shop-2" {
+		t.Errorf("pods = %v", podNames(pods))
+	}
+	deployments, err := s.ListDeployments("plate-staging", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deployments) != 2 || deployments[0].Name != "cart" || deployments[1].Name != "shop" {
+		t.Errorf("deployments = %+v", deployments)
+	}
+	if listed := listActions(clientset); len(listed) != 0 {
+		t.Errorf("synced namespace was listed from the API server: %v", listed)
+	}
+
+	if _, err := s.ListPods("plate-staging", "=shop"); err == nil {
+		t.Error("listing pods with an invalid selector succeeded")
+	}
+	if _, err := s.ListDeployments("plate-staging", "app in shop"); err == nil {
+		t.Error("listing deployments with an invalid selector succeeded")
+	}
+
+	// Namespaces plate does not manage are not cached
+	pods, err = s.ListPods("other", "app=shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pods) != 1 || pods[0].Namespace != "other" {
+		t.Errorf("pods = %v", podNames(pods))
+	}
+	if deployments, err := s.ListDeployments("other", ""); err != nil || len(deployments) != 1 {
+		t.Errorf("deployments = %+v, %v", deployments, err)
+	}
+	if listed := listActions(clientset); strings.Join(listed, ",") != "other/pods,other/deployments" {
+		t.Errorf("listed = %v, want other/pods and other/deployments", listed)
+	}
+}
+
+func TestListWithoutCache(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		testPod("plate-staging", "shop-1", "shop", corev1.PodRunning),
+		testPod("plate-staging", "cart-1", "cart", corev1.PodRunning),
+		testDeployment("plate-staging", "shop"),
+	)
+	s := &KubernetesService{clientset: clientset}
+
+	pods, err := s.ListPods("plate-staging", "app=cart")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pods) != 1 || pods[0].Name != "cart-1" {
+		t.Errorf("pods = %v", podNames(pods))
+	}
+	if deployments, err := s.ListDeployments("plate-staging", "app=shop"); err != nil || len(deployments) != 1 {
+		t.Errorf("deployments = %+v, %v", deployments, err)
+	}
+	if listed := listActions(clientset); strings.Join(listed, ",") != "plate-staging/pods,plate-staging/deployments" {
+		t.Errorf("listed = %v", listed)
+	}
+	if status := s.CacheStatus(); status.Enabled {
+		t.Errorf("cache status = %+v", status)
+	}
+}
+
+func TestCachePublishesEvents(t *testing.T) {
+	s, clientset, events := startTestCache(t, "plate-staging",
+		testManagedNamespace("plate-staging", "staging"),
+		testPod("plate-staging", "shop-1", "shop", corev1.PodRunning),
+		testEvent("plate-staging", "shop-1.1", corev1.EventTypeWarning, 3, corev1.ObjectReference{Kind: "Pod", Name: "shop-1"}),
+	)
+	pods := events.Subscribe(EventFilter{Types: []string{EventPodPhase}}, 0)
+	warnings := events.Subscribe(EventFilter{Types: []string{EventAppWarning}}, 0)
+	ctx := context.TODO()
+
+	// What existed before the informers started is not announced, so the
+	// first events are those of the new pod and warning
+	pod := testPod("plate-staging", "shop-2", "shop", corev1.PodPending)
+	if _, err := clientset.CoreV1().Pods("plate-staging").Create(ctx, pod, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	event := receive(t, pods)
+	if data := event.Data.(PodPhaseEvent); data.Pod != "shop-2" || data.Phase != "Pending" || event.Environment != "staging" {
+		t.Errorf("event = %+v", event)
+	}
+
+	pod.Status.Phase = corev1.PodRunning
+	if _, err := clientset.CoreV1().Pods("plate-staging").UpdateStatus(ctx, pod, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if data := receive(t, pods).Data.(PodPhaseEvent); data.Phase != "Running" || data.PreviousPhase != "Pending" {
+		t.Errorf("event data = %+v", data)
+	}
+
+	object := corev1.ObjectReference{Kind: "Deployment", Name: "shop"}
+	for _, event := range []*corev1.Event{
+		testEvent("plate-staging", "shop.1", corev1.EventTypeNormal, 1, object),
+		testEvent("plate-staging", "shop.2", corev1.EventTypeWarning, 1, object),
+	} {
+		if _, err := clientset.CoreV1().Events("plate-staging").Create(ctx, event, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	event = receive(t, warnings)
+	if data := event.Data.(AppWarningEvent); data.Kind != "Deployment" || data.Name != "shop" || event.Project != "shop" {
+		t.Errorf("event = %+v", event)
+	}
+
+	// Namespaces that lose their label are no longer watched
+	if err := clientset.CoreV1().Namespaces().Delete(ctx, "plate-staging", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for s.cache.scope("plate-staging") != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("deleted namespace is still cached")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func podNames(pods []corev1.Pod) []string {
+	names := make([]string, 0, len(pods))
+	for _, pod := range pods {
+		names = append(names, pod.Namespace+"/"+pod.Name)
+	}
+	return names
+}
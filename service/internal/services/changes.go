@@ -0,0 +1,223 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/plate/service/internal/models"
+)
+
+// Changelog lists what a deployment ships since the commit deployed before
+// it in the same environment.
+type Changelog struct {
+	DeploymentID uint                `json:"deployment_id"`
+	Project      string              `json:"project"`
+	Environment  string              `json:"environment"`
+	From         string              `json:"from,omitempty"` // empty if no commit was deployed before
+	To           string              `json:"to"`
+	Commits      []GitCommit         `json:"commits"`
+	Authors      []string            `json:"authors"`
+	PullRequests []MergedPullRequest `json:"pull_requests"`
+	// Truncated is set when From is not among the latest commits the
+	// provider lists, so older changes are missing.
+	Truncated bool `json:"truncated"`
+}
+
+// MergedPullRequest is a pull request merged by one of the commits of a
+// changelog, as told by the commit message.
+type MergedPullRequest struct {
+	Number    int    `json:"number"`
+	Reference string `json:"reference"` // #12, or !12 for GitLab merge requests
+	Title     string `json:"title"`
+}
+
+// ChangesUnavailableError is returned for deployments whose changes cannot
+// be listed, such as those of a version that is not a commit.
+type ChangesUnavailableError struct {
+	Reason string
+}
+
+func (e *ChangesUnavailableError) Error() string {
+	return "changes are unavailable: " + e.Reason
+}
+
+// Changes returns the changelog of a deployment, from the commit of the
+// last successful deployment before it to its own.
+func (s *DeploymentService) Changes(id uint) (*Changelog, error) {
+	deployment, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if !commitSHAPattern.MatchString(deployment.Version) {
+		return nil, &ChangesUnavailableError{Reason: fmt.Sprintf("version %s of deployment %d is not a commit SHA", deployment.Version, id)}
+	}
+
+	provider, fullName, err := s.repository(&deployment.Project)
+	if err != nil {
+		return nil, err
+	}
+	if fullName == "" {
+		return nil, &ChangesUnavailableError{Reason: fmt.Sprintf("project %s has no repository", deployment.Project.Name)}
+	}
+
+	changelog := &Changelog{
+		DeploymentID: deployment.ID,
+		Project:      deployment.Project.Name,
+		Environment:  deployment.Environment.Name,
+		To:           deployment.Version,
+		Commits:      []GitCommit{},
+		Authors:      []string{},
+		PullRequests: []MergedPullRequest{},
+	}
+
+	var previous []models.Deployment
+	err = s.db.Where("project_id = ? AND environment_id = ? AND status = ? AND id < ?", deployment.ProjectID, deployment.EnvironmentID, "success", deployment.ID).
+		Order("id desc").Limit(20).Find(&previous).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, candidate := range previous {
+		if commitSHAPattern.MatchString(candidate.Version) {
+			changelog.From = candidate.Version
+			break
+		}
+	}
+	if changelog.From == changelog.To {
+		return changelog, nil
+	}
+
+	commits, err := provider.GetCommits(fullName, deployment.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	found := false
+	authors := map[string]bool{}
+	for _, commit := range commits {
+		if commit.SHA == changelog.From {
+			found = true
+			break
+		}
+		changelog.Commits = append(changelog.Commits, commit)
+		if commit.Author != "" && !authors[commit.Author] {
+			authors[commit.Author] = true
+			changelog.Authors = append(changelog.Authors, commit.Author)
+		}
+		if pr, ok := mergedPullRequest(commit.Message); ok {
+			changelog.PullRequests = append(changelog.PullRequests, pr)
+		}
+	}
+	sort.Strings(changelog.Authors)
+	changelog.Truncated = changelog.From != "" && !found
+
+	return changelog, nil
+}
+
+// LatestDeployment returns the last successful deployment of a project in
+// an environment.
+func (s *DeploymentService) LatestDeployment(projectID uint, environment string) (*models.Deployment, error) {
+	var deployment models.Deployment
+	err := s.db.Joins("JOIN environments ON deployments.environment_id = environments.id").
+		Where("deployments.project_id = ? AND environments.name = ? AND deployments.status = ?", projectID, environment, "success").
+		Order("deployments.id desc").First(&deployment).Error
+	if err != nil {
+		return nil, err
+	}
+	return &deployment, nil
+}
+
+var (
+	// Merge pull request 'Add checkout' (#12) from feature into main
+	giteaMergePattern = regexp.MustCompile(`^Merge pull request '(.+)' \(#(\d+)\)`)
+	// Merge pull request #12 from plate/feature, titled on the next line
+	githubMergePattern = regexp.MustCompile(`^Merge pull request #(\d+) from `)
+	// See merge request plate/shop!12, titled below the subject
+	gitlabMergePattern = regexp.MustCompile(`(?m)^See merge request \S+!(\d+)\s*$`)
+	// Add checkout (#12), as squash merges are titled
+	squashMergePattern = regexp.MustCompile(`^(.+) \(#(\d+)\)$`)
+)
+
+// mergedPullRequest tells the pull request a commit merged from the merge
+// or squash commit message the Git providers write by default.
+func mergedPullRequest(message string) (MergedPullRequest, bool) {
+	lines := strings.Split(strings.TrimSpace(message), "\n")
+	subject := strings.TrimSpace(lines[0])
+	// body is the first non-empty line after the subject
+	body := ""
+	for _, line := range lines[1:] {
+		if line = strings.TrimSpace(line); line != "" {
+			body = line
+			break
+		}
+	}
+
+	var pr MergedPullRequest
+	switch {
+	case giteaMergePattern.MatchString(subject):
+		match := giteaMergePattern.FindStringSubmatch(subject)
+		pr.Title = match[1]
+		fmt.Sscan(match[2], &pr.Number)
+	case githubMergePattern.MatchString(subject):
+		fmt.Sscan(githubMergePattern.FindStringSubmatch(subject)[1], &pr.Number)
+		pr.Title = body
+	case gitlabMergePattern.MatchString(message):
+		fmt.Sscan(gitlabMergePattern.FindStringSubmatch(message)[1], &pr.Number)
+		pr.Title = body
+		if strings.HasPrefix(body, "See merge request ") {
+			pr.Title = subject
+		}
+	case squashMergePattern.MatchString(subject):
+		match := squashMergePattern.FindStringSubmatch(subject)
+		pr.Title = match[1]
+		fmt.Sscan(match[2], &pr.Number)
+	default:
+		return pr, false
+	}
+
+	pr.Reference = fmt.Sprintf("#%d", pr.Number)
+	if gitlabMergePattern.MatchString(message) {
+		pr.Reference = fmt.Sprintf("!%d", pr.Number)
+	}
+	return pr, true
+}
+
+// Markdown renders the changelog for release notes and notifications.
+func (c *Changelog) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## %s in %s\n\n", c.Project, c.Environment)
+
+	switch {
+	case c.From == "":
+		fmt.Fprintf(&b, "Deployed %s, the first commit deployed here.\n\n", shortSHA(c.To))
+	case len(c.Commits) == 0:
+		fmt.Fprintf(&b, "Redeployed %s with no new commits.\n\n", shortSHA(c.To))
+		return b.String()
+	default:
+		fmt.Fprintf(&b, "Changes from %s to %s.\n\n", shortSHA(c.From), shortSHA(c.To))
+	}
+
+	if len(c.PullRequests) > 0 {
+		b.WriteString("### Pull requests\n\n")
+		for _, pr := range c.PullRequests {
+			fmt.Fprintf(&b, "- %s (%s)\n", pr.Title, pr.Reference)
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("### Commits\n\n")
+	for _, commit := range c.Commits {
+		subject, _, _ := strings.Cut(strings.TrimSpace(commit.Message), "\n")
+		fmt.Fprintf(&b, "- `%s` %s (%s)\n", shortSHA(commit.SHA), subject, commit.Author)
+	}
+	b.WriteString("\n")
+
+	if len(c.Authors) > 0 {
+		fmt.Fprintf(&b, "**Authors:** %s\n", strings.Join(c.Authors, ", "))
+	}
+	if c.Truncated {
+		fmt.Fprintf(&b, "\n_Only the latest %d commits are listed; earlier changes since %s are missing._\n", len(c.Commits), shortSHA(c.From))
+	}
+	return b.String()
+}
@@ -1,11 +1,16 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/url"
+	"regexp"
 	"time"
 
 	"github.com/plate/service/internal/models"
 	"gorm.io/gorm"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 )
 
 type DeploymentService struct {
@@ -13,16 +18,28 @@ type DeploymentService struct {
 	kubernetes *KubernetesService
 	argocd     *ArgoCDService
 	helm       *HelmService
-	gitea      *GiteaService
+	git        *GitProviders
+	secrets    *SecretService
+	events     *EventBus
+
+	dashboardURL string
+	// started is when the service started; deployments still in progress
+	// from before were interrupted
+	started time.Time
 }
 
-func NewDeploymentService(db *gorm.DB, k8s *KubernetesService, argo *ArgoCDService, helm *HelmService, gitea *GiteaService) *DeploymentService {
+func NewDeploymentService(db *gorm.DB, k8s *KubernetesService, argo *ArgoCDService, helm *HelmService, git *GitProviders, secrets *SecretService, events *EventBus, dashboardURL string) *DeploymentService {
 	return &DeploymentService{
 		db:         db,
 		kubernetes: k8s,
 		argocd:     argo,
 		helm:       helm,
-		gitea:      gitea,
+		git:        git,
+		secrets:    secrets,
+		events:     events,
+
+		dashboardURL: dashboardURL,
+		started:      time.Now(),
 	}
 }
 
@@ -32,6 +49,15 @@ func (s *DeploymentService) List() ([]models.Deployment, error) {
 	return deployments, err
 }
 
+// ListLatest returns the latest deployment of every project in every
+// environment, whatever its status.
+func (s *DeploymentService) ListLatest() ([]models.Deployment, error) {
+	latest := s.db.Model(&models.Deployment{}).Select("MAX(id)").Group("project_id, environment_id")
+	var deployments []models.Deployment
+	err := s.db.Preload("Project").Preload("Environment").Where("id IN (?)", latest).Order("id").Find(&deployments).Error
+	return deployments, err
+}
+
 func (s *DeploymentService) GetByID(id uint) (*models.Deployment, error) {
 	var deployment models.Deployment
 	err := s.db.Preload("Project").Preload("Environment").First(&deployment, id).Error
@@ -88,6 +114,15 @@ func (s *DeploymentService) Deploy(projectID, environmentID uint, version string
 		return nil, fmt.Errorf("environment not found: %w", err)
 	}
 
+	// Refuse deployments that cannot be scheduled within the namespace's quota
+	settings, err := ResolveSettings(&project, &environment)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.kubernetes.CheckQuota(environment.Namespace, project.Name, settings.MinReplicas(), settings.Resources); err != nil {
+		return nil, err
+	}
+
 	// Set default version if not provided
 	if version == "" {
 		version = fmt.Sprintf("v%d", time.Now().Unix())
@@ -107,57 +142,326 @@ func (s *DeploymentService) Deploy(projectID, environmentID uint, version string
 		return nil, fmt.Errorf("failed to create deployment record: %w", err)
 	}
 
+	s.publishStatus(deployment, &project, &environment, nil)
+
 	// Start deployment process asynchronously
 	go s.performDeployment(deployment, &project, &environment)
 
 	return deployment, nil
 }
 
+// Resize switches a project to a sizing preset in one environment. The size
+// is stored as an environment override, checked against the namespace's
+// quota and applied to the running deployment, if there is one.
+func (s *DeploymentService) Resize(project *models.Project, environment *models.Environment, size string) (*DeploymentSettings, error) {
+	if !models.ValidSize(size) {
+		return nil, &models.ValidationError{Fields: map[string]string{"size": fmt.Sprintf("unknown size %q", size)}}
+	}
+
+	if project.Environments == nil {
+		project.Environments = map[string]models.EnvironmentOverride{}
+	}
+	override := project.Environments[environment.Name]
+	override.Size = size
+	override.Resources = nil
+	project.Environments[environment.Name] = override
+
+	settings, err := ResolveSettings(project, environment)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.kubernetes.CheckQuota(environment.Namespace, project.Name, settings.MinReplicas(), settings.Resources); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Omit("created_at").Save(project).Error; err != nil {
+		return nil, fmt.Errorf("failed to save project: %w", err)
+	}
+
+	if s.kubernetes.GetClientset() != nil {
+		err := s.kubernetes.SetDeploymentResources(environment.Namespace, project.Name, settings.Resources)
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return nil, err
+		}
+	}
+
+	return settings, nil
+}
+
+// SetAutoscalingBounds stores new autoscaler bounds of a project in one
+// environment, so the next deployment keeps them.
+func (s *DeploymentService) SetAutoscalingBounds(project *models.Project, environment *models.Environment, minReplicas, maxReplicas int) error {
+	if project.Environments == nil {
+		project.Environments = map[string]models.EnvironmentOverride{}
+	}
+	override := project.Environments[environment.Name]
+	autoscaling := models.Autoscaling{}
+	if override.Autoscaling != nil {
+		autoscaling = *override.Autoscaling
+	}
+	autoscaling.MinReplicas = minReplicas
+	autoscaling.MaxReplicas = maxReplicas
+	override.Autoscaling = &autoscaling
+	project.Environments[environment.Name] = override
+
+	if err := s.db.Omit("created_at").Save(project).Error; err != nil {
+		return fmt.Errorf("failed to save project: %w", err)
+	}
+	return nil
+}
+
 func (s *DeploymentService) performDeployment(deployment *models.Deployment, project *models.Project, environment *models.Environment) {
+	s.reportStatus(deployment, project, environment)
+
 	// Update status to running
-	deployment.Status = "running"
-	s.db.Save(deployment)
+	s.setStatus(deployment, project, environment, "running", nil)
+
+	// Create repository unless the project was registered with one
+	if project.Repository == "" {
+		s.logStage(deployment, project, environment, "info", "Creating repository")
+		provider, err := s.git.Get("")
+		if err == nil {
+			_, err = provider.CreateRepository(project.Name, project.Description)
+		}
+		if err != nil {
+			s.handleDeploymentError(deployment, project, environment, fmt.Errorf("failed to create repository: %w", err))
+			return
+		}
+	}
 
-	// Create repository if it doesn't exist
-	if err := s.gitea.CreateRepository(project.Name, project.Description); err != nil {
-		s.handleDeploymentError(deployment, fmt.Errorf("failed to create repository: %w", err))
+	// Secrets go to the cluster directly; the chart only references them
+	s.logStage(deployment, project, environment, "info", "Applying secrets")
+	chartSecret, err := s.applySecrets(project, environment)
+	if err != nil {
+		s.handleDeploymentError(deployment, project, environment, fmt.Errorf("failed to apply secrets: %w", err))
 		return
 	}
 
 	// Generate Helm chart
-	chartPath, err := s.helm.GenerateChart(project, environment)
+	s.logStage(deployment, project, environment, "info", "Generating Helm chart")
+	chartPath, err := s.helm.GenerateChart(project, environment, deployment, chartSecret)
 	if err != nil {
-		s.handleDeploymentError(deployment, fmt.Errorf("failed to generate Helm chart: %w", err))
+		s.handleDeploymentError(deployment, project, environment, fmt.Errorf("failed to generate Helm chart: %w", err))
 		return
 	}
 
 	// Create ArgoCD application
+	s.logStage(deployment, project, environment, "info", "Creating ArgoCD application")
 	if err := s.argocd.CreateApplication(deployment.ArgoAppName, project.Name, environment.Namespace, chartPath); err != nil {
-		s.handleDeploymentError(deployment, fmt.Errorf("failed to create ArgoCD application: %w", err))
+		s.handleDeploymentError(deployment, project, environment, fmt.Errorf("failed to create ArgoCD application: %w", err))
 		return
 	}
 
 	// Deploy with Helm
+	s.logStage(deployment, project, environment, "info", "Installing Helm release")
 	if err := s.helm.InstallRelease(deployment.HelmRelease, chartPath, environment.Namespace); err != nil {
-		s.handleDeploymentError(deployment, fmt.Errorf("failed to install Helm release: %w", err))
+		s.handleDeploymentError(deployment, project, environment, fmt.Errorf("failed to install Helm release: %w", err))
 		return
 	}
 
-	// Update deployment status and URL
-	deployment.Status = "success"
+	// Log successful deployment, then update deployment status and URL
+	s.logStage(deployment, project, environment, "info", "Deployment completed successfully")
 	if environment.Domain != "" {
 		deployment.URL = fmt.Sprintf("https://%s.%s", project.Name, environment.Domain)
 	}
-	s.db.Save(deployment)
+	s.setStatus(deployment, project, environment, "success", nil)
+}
+
+// applySecrets writes the project's secrets for environment to a
+// Kubernetes Secret, or removes a stale one when there are none left. Env
+// vars referencing external secrets are read into the same Secret, unless
+// an external store is configured to sync them with an ExternalSecret.
+func (s *DeploymentService) applySecrets(project *models.Project, environment *models.Environment) (*ChartSecret, error) {
+	settings, err := ResolveSettings(project, environment)
+	if err != nil {
+		return nil, err
+	}
+	references, err := settings.SecretReferences()
+	if err != nil {
+		return nil, err
+	}
+
+	resolved, err := s.secrets.Resolve(project.ID, environment.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	// A stored secret takes precedence over a reference of the same name
+	for name := range resolved.Data {
+		delete(references, name)
+	}
+
+	chartSecret := &ChartSecret{}
+	if len(references) > 0 {
+		if store, kind := s.secrets.ExternalStore(); store != "" {
+			chartSecret.External = NewChartExternalSecret(project.Name, store, kind, references)
+		} else {
+			values, err := s.secrets.ReadReferences(context.Background(), references)
+			if err != nil {
+				return nil, err
+			}
+			resolved.addExternal(values)
+		}
+	}
+
+	name := fmt.Sprintf("%s-secrets", project.Name)
+	switch {
+	case s.kubernetes.GetClientset() == nil:
+		if len(resolved.Data) > 0 {
+			return nil, fmt.Errorf("cannot create secret %s without a Kubernetes connection", name)
+		}
+	case len(resolved.Data) == 0:
+		if err := s.kubernetes.DeleteSecret(environment.Namespace, name); err != nil {
+			return nil, err
+		}
+	default:
+		labels := map[string]string{"app": project.Name, "managed-by": "plate"}
+		if err := s.kubernetes.ApplySecret(environment.Namespace, name, labels, resolved.Data); err != nil {
+			return nil, err
+		}
+		chartSecret.Name = name
+		chartSecret.Keys = resolved.Names()
+		chartSecret.Checksum = resolved.Checksum
+	}
 
-	// Log successful deployment
-	s.logDeployment(deployment.ID, "info", "Deployment completed successfully")
+	if chartSecret.Name == "" && chartSecret.External == nil {
+		return nil, nil
+	}
+	return chartSecret, nil
+}
+
+func (s *DeploymentService) handleDeploymentError(deployment *models.Deployment, project *models.Project, environment *models.Environment, err error) {
+	s.logStage(deployment, project, environment, "error", err.Error())
+	s.setStatus(deployment, project, environment, "failed", err)
 }
 
-func (s *DeploymentService) handleDeploymentError(deployment *models.Deployment, err error) {
-	deployment.Status = "failed"
+// setStatus saves the status of a deployment and announces it on the event
+// stream and as a commit status. A failed deployment carries its cause.
+func (s *DeploymentService) setStatus(deployment *models.Deployment, project *models.Project, environment *models.Environment, status string, cause error) {
+	deployment.Status = status
 	s.db.Save(deployment)
-	s.logDeployment(deployment.ID, "error", err.Error())
+	s.publishStatus(deployment, project, environment, cause)
+	s.reportStatus(deployment, project, environment)
+}
+
+func (s *DeploymentService) publishStatus(deployment *models.Deployment, project *models.Project, environment *models.Environment, cause error) {
+	data := DeploymentStatusEvent{Status: deployment.Status, Version: deployment.Version, URL: deployment.URL}
+	if cause != nil {
+		data.Error = cause.Error()
+	}
+	s.events.Publish(Event{
+		Type:         EventDeploymentStatus,
+		Project:      project.Name,
+		Environment:  environment.Name,
+		DeploymentID: deployment.ID,
+		Data:         data,
+	})
+}
+
+// logStage logs a stage of a deployment and publishes it on the event
+// stream.
+func (s *DeploymentService) logStage(deployment *models.Deployment, project *models.Project, environment *models.Environment, level, message string) {
+	s.logDeployment(deployment.ID, level, message)
+	s.events.Publish(Event{
+		Type:         EventDeploymentLog,
+		Project:      project.Name,
+		Environment:  environment.Name,
+		DeploymentID: deployment.ID,
+		Data:         DeploymentLogEvent{Level: level, Message: message},
+	})
+}
+
+// commitSHAPattern matches full commit SHAs, which deployments of a commit,
+// such as those triggered by webhooks, have as their version.
+var commitSHAPattern = regexp.MustCompile(`^[0-9a-f]{40}([0-9a-f]{24})?$`)
+
+// commitStates maps the status of a deployment to the state of its commit.
+var commitStates = map[string]CommitState{
+	"pending": CommitStatePending,
+	"running": CommitStateRunning,
+	"success": CommitStateSuccess,
+	"failed":  CommitStateFailure,
+}
+
+// reportStatus reports the status of a deployment on the commit it deploys
+// at the Git provider of the project's repository, with the environment as
+// context. Deployments of versions other than commit SHAs are not
+// reported. Failures are only logged, since deployments work without it.
+func (s *DeploymentService) reportStatus(deployment *models.Deployment, project *models.Project, environment *models.Environment) {
+	state, ok := commitStates[deployment.Status]
+	if !ok || !commitSHAPattern.MatchString(deployment.Version) {
+		return
+	}
+
+	provider, fullName, err := s.repository(project)
+	if err != nil {
+		s.logDeployment(deployment.ID, "warning", fmt.Sprintf("Failed to report commit status: %v", err))
+		return
+	}
+	if fullName == "" {
+		return
+	}
+
+	var description string
+	switch state {
+	case CommitStatePending:
+		description = "Waiting to deploy to " + environment.Name
+	case CommitStateRunning:
+		description = "Deploying to " + environment.Name
+	case CommitStateSuccess:
+		description = "Deployed to " + environment.Name
+	default:
+		description = "Failed to deploy to " + environment.Name
+	}
+
+	status := CommitStatus{
+		State:       state,
+		Context:     "plate/" + environment.Name,
+		Description: description,
+		TargetURL:   s.deploymentPage(deployment, project, environment),
+	}
+	if err := provider.SetCommitStatus(fullName, deployment.Version, status); err != nil {
+		s.logDeployment(deployment.ID, "warning", fmt.Sprintf("Failed to report commit status: %v", err))
+	}
+}
+
+// repository returns the Git provider and full name of the repository a
+// project is built from, or an empty name if it has none.
+func (s *DeploymentService) repository(project *models.Project) (GitProvider, string, error) {
+	var repo models.Repository
+	err := s.db.Where("project_id = ?", project.ID).First(&repo).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		// Registered with a repository URL only, at the default provider
+		repo.CloneURL = project.Repository
+	case err != nil:
+		return nil, "", err
+	}
+
+	fullName := repo.FullName
+	if fullName == "" {
+		fullName = RepositoryFullName(repo.CloneURL)
+	}
+	if fullName == "" {
+		return nil, "", nil
+	}
+
+	provider, err := s.git.Get(repo.Provider)
+	if err != nil {
+		return nil, "", err
+	}
+	return provider, fullName, nil
+}
+
+// deploymentPage links to the deployments of the project in the
+// environment on the dashboard, or to the deployed application without a
+// dashboard URL.
+func (s *DeploymentService) deploymentPage(deployment *models.Deployment, project *models.Project, environment *models.Environment) string {
+	if s.dashboardURL == "" {
+		return deployment.URL
+	}
+	query := url.Values{"project": {project.Name}, "environment": {environment.Name}}
+	return s.dashboardURL + "/deployments?" + query.Encode()
 }
 
 func (s *DeploymentService) logDeployment(deploymentID uint, level, message string) {
@@ -0,0 +1,273 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/plate/service/internal/config"
+	"github.com/plate/service/internal/models"
+	"gorm.io/gorm"
+)
+
+// EnvironmentService stores environments and keeps the namespace of each
+// provisioned. Without a cluster connection only the rows are changed; the
+// next Reconcile provisions the namespaces.
+type EnvironmentService struct {
+	db         *gorm.DB
+	kubernetes *KubernetesService
+	defaults   config.Environments
+}
+
+func NewEnvironmentService(db *gorm.DB, kubernetes *KubernetesService, defaults config.Environments) *EnvironmentService {
+	return &EnvironmentService{db: db, kubernetes: kubernetes, defaults: defaults}
+}
+
+// EnvironmentInUseError is returned when deleting an environment that
+// projects are deployed to.
+type EnvironmentInUseError struct {
+	Environment string
+	Projects    []string
+}
+
+func (e *EnvironmentInUseError) Error() string {
+	return fmt.Sprintf("environment %s has deployments of %d projects", e.Environment, len(e.Projects))
+}
+
+func (s *EnvironmentService) List() ([]models.Environment, error) {
+	var environments []models.Environment
+	err := s.db.Order("id").Find(&environments).Error
+	return environments, err
+}
+
+func (s *EnvironmentService) GetByID(id uint) (*models.Environment, error) {
+	var environment models.Environment
+	err := s.db.First(&environment, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &environment, nil
+}
+
+func (s *EnvironmentService) GetByName(name string) (*models.Environment, error) {
+	var environment models.Environment
+	err := s.db.Where("name = ?", name).First(&environment).Error
+	if err != nil {
+		return nil, err
+	}
+	return &environment, nil
+}
+
+// Find returns the environment named ref, or else the one using namespace ref.
+func (s *EnvironmentService) Find(ref string) (*models.Environment, error) {
+	if environment, err := s.GetByName(ref); err == nil {
+		return environment, nil
+	}
+
+	var environment models.Environment
+	err := s.db.Where("namespace = ?", ref).First(&environment).Error
+	if err != nil {
+		return nil, err
+	}
+	return &environment, nil
+}
+
+// Create stores an environment and provisions its namespace, which
+// defaults to plate-<name>. Nothing is stored if provisioning fails.
+func (s *EnvironmentService) Create(environment *models.Environment) error {
+	if environment.Namespace == "" {
+		environment.Namespace = "plate-" + environment.Name
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(environment).Error; err != nil {
+			return err
+		}
+		return s.provision(environment)
+	})
+}
+
+// Update changes an environment and provisions its namespace. A namespace
+// the environment no longer uses is left in place.
+func (s *EnvironmentService) Update(environment *models.Environment) error {
+	if environment.Namespace == "" {
+		environment.Namespace = "plate-" + environment.Name
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("created_at").Save(environment).Error; err != nil {
+			return err
+		}
+		return s.provision(environment)
+	})
+}
+
+// Delete removes an environment with its deployment records and deletes
+// its namespace. Without force, an environment with deployments is
+// refused. A namespace Plate did not create for the environment, such as
+// an adopted one, is left in place, which is reported by namespaceDeleted.
+func (s *EnvironmentService) Delete(id uint, force bool) (namespaceDeleted bool, err error) {
+	environment, err := s.GetByID(id)
+	if err != nil {
+		return false, err
+	}
+
+	var deployments []models.Deployment
+	if err := s.db.Preload("Project").Where("environment_id = ?", id).Find(&deployments).Error; err != nil {
+		return false, err
+	}
+	if len(deployments) > 0 && !force {
+		seen := map[string]bool{}
+		inUse := &EnvironmentInUseError{Environment: environment.Name}
+		for _, deployment := range deployments {
+			if !seen[deployment.Project.Name] {
+				seen[deployment.Project.Name] = true
+				inUse.Projects = append(inUse.Projects, deployment.Project.Name)
+			}
+		}
+		sort.Strings(inUse.Projects)
+		return false, inUse
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		deploymentIDs := tx.Model(&models.Deployment{}).Select("id").Where("environment_id = ?", id)
+		if err := tx.Where("deployment_id IN (?)", deploymentIDs).Delete(&models.DeploymentLog{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("environment_id = ?", id).Delete(&models.Deployment{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("environment = ?", environment.Name).Delete(&models.Secret{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("environment_id = ?", id).Delete(&models.Preview{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.Environment{}, id).Error; err != nil {
+			return err
+		}
+
+		if s.kubernetes.GetClientset() == nil {
+			return nil
+		}
+		err := s.kubernetes.DeleteNamespace(environment.Namespace, environment.Name)
+		var notManaged *NamespaceNotManagedError
+		if errors.As(err, &notManaged) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		namespaceDeleted = true
+		return nil
+	})
+	return namespaceDeleted, err
+}
+
+// ReconcileReport describes the drift Reconcile found between the
+// environments and the namespaces managed by Plate.
+type ReconcileReport struct {
+	// Missing are environments whose namespace did not exist
+	Missing []string `json:"missing"`
+	// Provisioned are environments whose namespace was created or brought
+	// up to date
+	Provisioned []string `json:"provisioned"`
+	// Adopted are managed namespaces without an environment, for which one
+	// was created
+	Adopted []string `json:"adopted"`
+	// Orphaned are managed namespaces without an environment that were not
+	// adopted: adoption was not asked for, the namespace is reserved, or
+	// their environment uses another namespace
+	Orphaned []string          `json:"orphaned"`
+	Errors   map[string]string `json:"errors,omitempty"`
+}
+
+// Reconcile provisions the namespace of every environment and, with adopt,
+// creates environments for managed namespaces that have none. A dry run
+// only reports what would change.
+func (s *EnvironmentService) Reconcile(dryRun, adopt bool) (*ReconcileReport, error) {
+	if s.kubernetes.GetClientset() == nil {
+		return nil, fmt.Errorf("kubernetes is not connected")
+	}
+
+	environments, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	namespaces, err := s.kubernetes.ManagedNamespaces()
+	if err != nil {
+		return nil, err
+	}
+	existing, err := s.kubernetes.GetNamespaces()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ReconcileReport{Missing: []string{}, Provisioned: []string{}, Adopted: []string{}, Orphaned: []string{}, Errors: map[string]string{}}
+	exists := map[string]bool{}
+	for _, name := range existing {
+		exists[name] = true
+	}
+	byName := map[string]bool{}
+	used := map[string]bool{}
+	for i := range environments {
+		environment := &environments[i]
+		byName[environment.Name] = true
+		used[environment.Namespace] = true
+
+		if !exists[environment.Namespace] {
+			report.Missing = append(report.Missing, environment.Name)
+		}
+		if dryRun {
+			continue
+		}
+		if err := s.provision(environment); err != nil {
+			report.Errors[environment.Name] = err.Error()
+			continue
+		}
+		report.Provisioned = append(report.Provisioned, environment.Name)
+	}
+
+	for _, namespace := range namespaces {
+		if used[namespace.Name] {
+			continue
+		}
+		name := namespace.Labels[EnvironmentLabel]
+		if name == "" {
+			name = namespace.Name
+		}
+
+		environment := &models.Environment{Name: name, Namespace: namespace.Name, AdoptNamespace: true}
+		if !adopt || byName[name] || reservedNamespace(namespace.Name) || environment.Validate() != nil {
+			report.Orphaned = append(report.Orphaned, namespace.Name)
+			continue
+		}
+		byName[name] = true
+		if !dryRun {
+			if err := s.db.Create(environment).Error; err != nil {
+				report.Errors[name] = err.Error()
+				continue
+			}
+			if err := s.provision(environment); err != nil {
+				report.Errors[name] = err.Error()
+			}
+		}
+		report.Adopted = append(report.Adopted, name)
+	}
+
+	return report, nil
+}
+
+// provision creates or updates the namespace of an environment when a
+// cluster is connected. Previews get their own quota, if one is set.
+func (s *EnvironmentService) provision(environment *models.Environment) error {
+	if s.kubernetes.GetClientset() == nil {
+		return nil
+	}
+
+	defaults := s.defaults
+	if environment.Preview && defaults.Previews.Quota != (config.Quota{}) {
+		defaults.Quota = defaults.Previews.Quota
+	}
+	return s.kubernetes.ProvisionNamespace(environment.Namespace, environment.Name, environment.AdoptNamespace, defaults)
+}
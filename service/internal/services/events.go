@@ -0,0 +1,251 @@
+package services
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event types published on the EventBus
+const (
+	// EventDeploymentStatus is a deployment moving to pending, running,
+	// success or failed; its data is a DeploymentStatusEvent.
+	EventDeploymentStatus = "deployment.status"
+	// EventDeploymentLog is a line logged by a deployment, such as the
+	// stage it reached; its data is a DeploymentLogEvent.
+	EventDeploymentLog = "deployment.log"
+	// EventDeploymentHealth is a change in the health, Argo CD sync status
+	// or drift of a deployment found by the reconciler; its data is a
+	// DeploymentHealthEvent.
+	EventDeploymentHealth = "deployment.health"
+	// EventPodPhase is a pod of an app changing phase; its data is a
+	// PodPhaseEvent.
+	EventPodPhase = "pod.phase"
+	// EventAppScaled is an app scaled, started or stopped; its data is an
+	// AppScaledEvent.
+	EventAppScaled = "app.scaled"
+	// EventAppWarning is a Warning event Kubernetes recorded about an
+	// object of an app, such as a failed probe; its data is an
+	// AppWarningEvent.
+	EventAppWarning = "app.warning"
+)
+
+// Event is published on the EventBus. IDs increase with every event, so
+// subscribers can resume after the last one they received.
+type Event struct {
+	ID           uint64      `json:"id"`
+	Type         string      `json:"type"`
+	Time         time.Time   `json:"time"`
+	Project      string      `json:"project,omitempty"`
+	Environment  string      `json:"environment,omitempty"`
+	DeploymentID uint        `json:"deployment_id,omitempty"`
+	Data         interface{} `json:"data,omitempty"`
+}
+
+// DeploymentStatusEvent is the data of EventDeploymentStatus.
+type DeploymentStatusEvent struct {
+	Status  string `json:"status"`
+	Version string `json:"version"`
+	URL     string `json:"url,omitempty"`
+	Error   string `json:"error,omitempty"` // why a deployment failed
+}
+
+// DeploymentLogEvent is the data of EventDeploymentLog.
+type DeploymentLogEvent struct {
+	Level   string `json:"level"`
+	Message string `json:"message"`
+}
+
+// DeploymentHealthEvent is the data of EventDeploymentHealth. Drift is
+// empty once the cluster matches the deployment again.
+type DeploymentHealthEvent struct {
+	Health         string `json:"health"`
+	PreviousHealth string `json:"previous_health,omitempty"`
+	SyncStatus     string `json:"sync_status,omitempty"`
+	Drift          string `json:"drift,omitempty"`
+}
+
+// PodPhaseEvent is the data of EventPodPhase. Phase is deleted for pods
+// that are gone.
+type PodPhaseEvent struct {
+	Pod           string `json:"pod"`
+	Phase         string `json:"phase"`
+	PreviousPhase string `json:"previous_phase,omitempty"`
+	Reason        string `json:"reason,omitempty"`
+}
+
+// AppScaledEvent is the data of EventAppScaled.
+type AppScaledEvent struct {
+	Replicas *int32 `json:"replicas,omitempty"`
+	Size     string `json:"size,omitempty"`
+	Action   string `json:"action"` // scale, start or stop
+}
+
+// AppWarningEvent is the data of EventAppWarning.
+type AppWarningEvent struct {
+	Kind    string `json:"kind"` // of the object, such as Pod
+	Name    string `json:"name"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+	Count   int32  `json:"count,omitempty"` // times it occurred
+}
+
+// EventFilter selects the events a subscriber receives. Empty fields match
+// every event.
+type EventFilter struct {
+	Types        []string // event types, or prefixes such as deployment.
+	Projects     []string
+	Environments []string
+	Deployments  []uint
+}
+
+func (f EventFilter) matches(event Event) bool {
+	if len(f.Types) > 0 && !matchesAny(f.Types, func(t string) bool {
+		return event.Type == t || strings.HasSuffix(t, ".") && strings.HasPrefix(event.Type, t)
+	}) {
+		return false
+	}
+	if len(f.Projects) > 0 && !matchesAny(f.Projects, func(p string) bool { return event.Project == p }) {
+		return false
+	}
+	if len(f.Environments) > 0 && !matchesAny(f.Environments, func(e string) bool { return event.Environment == e }) {
+		return false
+	}
+	if len(f.Deployments) > 0 {
+		for _, id := range f.Deployments {
+			if event.DeploymentID == id {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func matchesAny(values []string, match func(string) bool) bool {
+	for _, value := range values {
+		if match(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// eventHistory is how many past events are kept for subscribers resuming
+// after a reconnect.
+const eventHistory = 512
+
+// subscriberBuffer is how many events may queue for a subscriber before it
+// is dropped as too slow.
+const subscriberBuffer = 256
+
+// EventBus fans events such as deployment status changes out to the
+// subscribers of the event stream. Publishing never blocks: a subscriber
+// that falls behind is dropped and has to reconnect.
+type EventBus struct {
+	mu          sync.Mutex
+	lastID      uint64
+	history     []Event
+	subscribers map[*Subscription]bool
+	closed      bool
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{subscribers: map[*Subscription]bool{}}
+}
+
+// Subscription receives the events matching its filter until it is closed.
+// Events is closed when the subscription ends, by Close, by the bus
+// shutting down, or because the subscriber fell behind.
+type Subscription struct {
+	Events <-chan Event
+
+	events chan Event
+	filter EventFilter
+	bus    *EventBus
+}
+
+// Subscribe starts a subscription. With a non-zero after, the past events
+// after that ID that are still kept are delivered first.
+func (b *EventBus) Subscribe(filter EventFilter, after uint64) *Subscription {
+	events := make(chan Event, subscriberBuffer+eventHistory)
+	sub := &Subscription{Events: events, events: events, filter: filter, bus: b}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		close(events)
+		return sub
+	}
+	if after > 0 {
+		for _, event := range b.history {
+			if event.ID > after && filter.matches(event) {
+				events <- event
+			}
+		}
+	}
+	b.subscribers[sub] = true
+	return sub
+}
+
+// Close ends the subscription.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	if s.bus.subscribers[s] {
+		delete(s.bus.subscribers, s)
+		close(s.events)
+	}
+}
+
+// Publish assigns the event an ID and time and delivers it to the
+// matching subscribers.
+func (b *EventBus) Publish(event Event) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+	b.lastID++
+	event.ID = b.lastID
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	b.history = append(b.history, event)
+	if len(b.history) > eventHistory {
+		b.history = b.history[len(b.history)-eventHistory:]
+	}
+
+	for sub := range b.subscribers {
+		if !sub.filter.matches(event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			delete(b.subscribers, sub)
+			close(sub.events)
+		}
+	}
+}
+
+// Close ends every subscription, so that event streams finish and the
+// server can shut down.
+func (b *EventBus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for sub := range b.subscribers {
+		delete(b.subscribers, sub)
+		close(sub.events)
+	}
+}
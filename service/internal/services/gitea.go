@@ -2,125 +2,200 @@ package services
 
 import (
 	"fmt"
+	"net/http"
+	"net/url"
 
 	"github.com/plate/service/internal/config"
 )
 
+// GiteaService is the GitProvider for a Gitea server. Its API follows
+// GitHub's, whose response types it shares.
 type GiteaService struct {
 	config config.Gitea
-	// Add Gitea client when implementing
+	api    *gitAPI
 }
 
 func NewGiteaService(cfg config.Gitea) *GiteaService {
+	baseURL := ""
+	if cfg.URL != "" {
+		baseURL = cfg.URL + "/api/v1"
+	}
+	headers := map[string]string{}
+	if cfg.Token != "" {
+		headers["Authorization"] = "token " + cfg.Token
+	}
+
 	return &GiteaService{
 		config: cfg,
+		api:    newGitAPI("gitea", baseURL, headers),
 	}
 }
 
+func (s *GiteaService) Name() string {
+	return "gitea"
+}
+
 func (s *GiteaService) Initialize() error {
 	// TODO: Initialize Gitea client
 	// This would typically involve:
 	// 1. Creating Gitea API client
 	// 2. Authenticating with token
 	// 3. Testing connection
-	
+
 	fmt.Printf("Initializing Gitea client for: %s\n", s.config.URL)
 	return nil
 }
 
-func (s *GiteaService) CreateRepository(name, description string) error {
-	// TODO: Implement Gitea repository creation
-	// This would create a new repository in the configured organization
-	
-	fmt.Printf("Creating Gitea repository: %s\n", name)
-	fmt.Printf("  Description: %s\n", description)
-	fmt.Printf("  Organization: %s\n", s.config.OrgName)
-	
-	// Placeholder implementation
-	return nil
+// CreateRepository creates a repository in the configured organization, or
+// for the token's user without one.
+func (s *GiteaService) CreateRepository(name, description string) (*GitRepository, error) {
+	body := map[string]interface{}{
+		"name":           name,
+		"description":    description,
+		"private":        true,
+		"default_branch": "main",
+	}
+
+	path, owner := "/user/repos", ""
+	if s.config.OrgName != "" {
+		path, owner = fmt.Sprintf("/orgs/%s/repos", s.config.OrgName), s.config.OrgName
+	}
+
+	var repo GitRepository
+	err := s.api.request(http.MethodPost, path, body, &repo)
+	if isStatus(err, http.StatusConflict) && owner != "" {
+		return s.GetRepository(owner + "/" + name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create repository %s: %w", name, err)
+	}
+	return &repo, nil
 }
 
-func (s *GiteaService) DeleteRepository(name string) error {
-	// TODO: Implement Gitea repository deletion
-	fmt.Printf("Deleting Gitea repository: %s\n", name)
+func (s *GiteaService) DeleteRepository(fullName string) error {
+	if err := s.api.request(http.MethodDelete, "/repos/"+fullName, nil, nil); err != nil {
+		return fmt.Errorf("failed to delete repository %s: %w", fullName, err)
+	}
 	return nil
 }
 
-func (s *GiteaService) GetRepository(name string) (*GiteaRepository, error) {
-	// TODO: Implement Gitea repository retrieval
-	return &GiteaRepository{
-		Name:        name,
-		FullName:    fmt.Sprintf("%s/%s", s.config.OrgName, name),
-		CloneURL:    fmt.Sprintf("%s/%s/%s.git", s.config.URL, s.config.OrgName, name),
-		DefaultBranch: "main",
-	}, nil
+func (s *GiteaService) GetRepository(fullName string) (*GitRepository, error) {
+	var repo GitRepository
+	if err := s.api.request(http.MethodGet, "/repos/"+fullName, nil, &repo); err != nil {
+		return nil, fmt.Errorf("failed to get repository %s: %w", fullName, err)
+	}
+	return &repo, nil
 }
 
-func (s *GiteaService) CreateWebhook(repoName, webhookURL string) error {
-	// TODO: Implement Gitea webhook creation
-	// This would create a webhook for the repository to notify on push events
-	
-	fmt.Printf("Creating webhook for repository: %s\n", repoName)
-	fmt.Printf("  Webhook URL: %s\n", webhookURL)
-	
-	return nil
+// CreateWebhook adds a webhook sending push and pull request events to
+// webhookURL, signed with the configured webhook secret.
+func (s *GiteaService) CreateWebhook(fullName, webhookURL string) (int64, error) {
+	if s.config.WebhookSecret == "" {
+		return 0, fmt.Errorf("failed to create webhook for %s: no webhook secret is configured", fullName)
+	}
+
+	body := map[string]interface{}{
+		"type":   "gitea",
+		"active": true,
+		"events": []string{"push", "pull_request"},
+		"config": map[string]string{
+			"url":          webhookURL,
+			"content_type": "json",
+			"secret":       s.config.WebhookSecret,
+		},
+	}
+	var hook struct {
+		ID int64 `json:"id"`
+	}
+	if err := s.api.request(http.MethodPost, fmt.Sprintf("/repos/%s/hooks", fullName), body, &hook); err != nil {
+		return 0, fmt.Errorf("failed to create webhook for %s: %w", fullName, err)
+	}
+	return hook.ID, nil
 }
 
-func (s *GiteaService) DeleteWebhook(repoName string, webhookID int) error {
-	// TODO: Implement Gitea webhook deletion
-	fmt.Printf("Deleting webhook %d for repository: %s\n", webhookID, repoName)
+func (s *GiteaService) DeleteWebhook(fullName string, id int64) error {
+	if err := s.api.request(http.MethodDelete, fmt.Sprintf("/repos/%s/hooks/%d", fullName, id), nil, nil); err != nil {
+		return fmt.Errorf("failed to delete webhook %d of %s: %w", id, fullName, err)
+	}
 	return nil
 }
 
-func (s *GiteaService) GetBranches(repoName string) ([]string, error) {
-	// TODO: Implement Gitea branch listing
-	return []string{"main", "develop"}, nil
+func (s *GiteaService) GetBranches(fullName string) ([]string, error) {
+	var branches []struct {
+		Name string `json:"name"`
+	}
+	if err := s.api.request(http.MethodGet, fmt.Sprintf("/repos/%s/branches", fullName), nil, &branches); err != nil {
+		return nil, fmt.Errorf("failed to list branches of %s: %w", fullName, err)
+	}
+
+	names := make([]string, len(branches))
+	for i, branch := range branches {
+		names[i] = branch.Name
+	}
+	return names, nil
 }
 
-func (s *GiteaService) GetCommits(repoName, branch string) ([]GiteaCommit, error) {
-	// TODO: Implement Gitea commit listing
-	return []GiteaCommit{
-		{
-			SHA:     "abc123",
-			Message: "Initial commit",
-			Author:  "developer",
-		},
-	}, nil
+func (s *GiteaService) GetCommits(fullName, branch string) ([]GitCommit, error) {
+	var commits []githubCommit
+	path := fmt.Sprintf("/repos/%s/commits?limit=50&sha=%s", fullName, url.QueryEscape(branch))
+	if err := s.api.request(http.MethodGet, path, nil, &commits); err != nil {
+		return nil, fmt.Errorf("failed to list commits of %s: %w", fullName, err)
+	}
+
+	result := make([]GitCommit, len(commits))
+	for i, commit := range commits {
+		result[i] = commit.gitCommit()
+	}
+	return result, nil
 }
 
-func (s *GiteaService) CreatePullRequest(repoName, title, description, sourceBranch, targetBranch string) (*GiteaPullRequest, error) {
-	// TODO: Implement Gitea pull request creation
-	return &GiteaPullRequest{
-		ID:     1,
-		Title:  title,
-		State:  "open",
-		Author: "developer",
-	}, nil
+func (s *GiteaService) CreatePullRequest(fullName, title, description, sourceBranch, targetBranch string) (*GitPullRequest, error) {
+	body := map[string]string{
+		"title": title,
+		"body":  description,
+		"head":  sourceBranch,
+		"base":  targetBranch,
+	}
+	var pr githubPullRequest
+	if err := s.api.request(http.MethodPost, fmt.Sprintf("/repos/%s/pulls", fullName), body, &pr); err != nil {
+		return nil, fmt.Errorf("failed to create pull request in %s: %w", fullName, err)
+	}
+	return pr.gitPullRequest(), nil
 }
 
-// GiteaRepository represents a Gitea repository
-type GiteaRepository struct {
-	ID            int    `json:"id"`
-	Name          string `json:"name"`
-	FullName      string `json:"full_name"`
-	Description   string `json:"description"`
-	CloneURL      string `json:"clone_url"`
-	DefaultBranch string `json:"default_branch"`
-	Private       bool   `json:"private"`
+func (s *GiteaService) CreateComment(fullName string, number int, body string) (int64, error) {
+	var comment struct {
+		ID int64 `json:"id"`
+	}
+	err := s.api.request(http.MethodPost, fmt.Sprintf("/repos/%s/issues/%d/comments", fullName, number), map[string]string{"body": body}, &comment)
+	if err != nil {
+		return 0, fmt.Errorf("failed to comment on %s#%d: %w", fullName, number, err)
+	}
+	return comment.ID, nil
 }
 
-// GiteaCommit represents a Gitea commit
-type GiteaCommit struct {
-	SHA       string `json:"sha"`
-	Message   string `json:"message"`
-	Author    string `json:"author"`
-	Timestamp string `json:"timestamp"`
+func (s *GiteaService) EditComment(fullName string, number int, id int64, body string) error {
+	err := s.api.request(http.MethodPatch, fmt.Sprintf("/repos/%s/issues/comments/%d", fullName, id), map[string]string{"body": body}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to edit comment %d on %s#%d: %w", id, fullName, number, err)
+	}
+	return nil
 }
 
-// GiteaPullRequest represents a Gitea pull request
-type GiteaPullRequest struct {
-	ID     int    `json:"id"`
-	Title  string `json:"title"`
-	State  string `json:"state"`
-	Author string `json:"author"`
-}
\ No newline at end of file
+func (s *GiteaService) SetCommitStatus(fullName, sha string, status CommitStatus) error {
+	state := string(status.State)
+	if status.State == CommitStateRunning {
+		state = string(CommitStatePending)
+	}
+
+	body := map[string]string{
+		"state":       state,
+		"context":     status.Context,
+		"description": status.Description,
+		"target_url":  status.TargetURL,
+	}
+	if err := s.api.request(http.MethodPost, fmt.Sprintf("/repos/%s/statuses/%s", fullName, sha), body, nil); err != nil {
+		return fmt.Errorf("failed to set status of %s@%s: %w", fullName, shortSHA(sha), err)
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/plate/service/internal/config"
+)
+
+func giteaTestConfig(url string) config.Gitea {
+	return config.Gitea{URL: url, Token: "gitea-token", OrgName: "plate", WebhookSecret: "hook-secret"}
+}
+
+const giteaTestRepository = `{
+	"id": 42,
+	"name": "shop",
+	"full_name": "plate/shop",
+	"html_url": "https://git.example.com/plate/shop",
+	"clone_url": "https://git.example.com/plate/shop.git",
+	"default_branch": "main",
+	"private": true
+}`
+
+func TestGiteaCreateRepository(t *testing.T) {
+	server := newGitTestServer(t, map[string]gitTestResponse{
+		"POST /api/v1/orgs/plate/repos": {status: http.StatusCreated, body: giteaTestRepository},
+	})
+	gitea := NewGiteaService(giteaTestConfig(server.server.URL))
+
+	repo, err := gitea.CreateRepository("shop", "The shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.ID != 42 || repo.FullName != "plate/shop" || repo.CloneURL != "https://git.example.com/plate/shop.git" || !repo.Private {
+		t.Errorf("repository = %+v", repo)
+	}
+
+	request := server.request("POST /api/v1/orgs/plate/repos")
+	if got := request.header.Get("Authorization"); got != "token gitea-token" {
+		t.Errorf("Authorization = %q", got)
+	}
+	if request.body["name"] != "shop" || request.body["description"] != "The shop" || request.body["private"] != true {
+		t.Errorf("body = %v", request.body)
+	}
+}
+
+func TestGiteaCreateExistingRepository(t *testing.T) {
+	server := newGitTestServer(t, map[string]gitTestResponse{
+		"POST /api/v1/orgs/plate/repos": {status: http.StatusConflict, body: `{"message":"repository already exists"}`},
+		"GET /api/v1/repos/plate/shop":  {body: giteaTestRepository},
+	})
+	gitea := NewGiteaService(giteaTestConfig(server.server.URL))
+
+	repo, err := gitea.CreateRepository("shop", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.ID != 42 {
+		t.Errorf("repository = %+v", repo)
+	}
+}
+
+func TestGiteaGetRepository(t *testing.T) {
+	server := newGitTestServer(t, map[string]gitTestResponse{
+		"GET /api/v1/repos/plate/shop":    {body: giteaTestRepository},
+		"GET /api/v1/repos/plate/missing": {status: http.StatusNotFound, body: `{"message":"not found"}`},
+	})
+	gitea := NewGiteaService(giteaTestConfig(server.server.URL))
+
+	repo, err := gitea.GetRepository("plate/shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.Name != "shop" || repo.DefaultBranch != "main" {
+		t.Errorf("repository = %+v", repo)
+	}
+
+	if _, err := gitea.GetRepository("plate/missing"); err == nil {
+		t.Error("getting a missing repository succeeded")
+	}
+}
+
+func TestGiteaWebhooks(t *testing.T) {
+	server := newGitTestServer(t, map[string]gitTestResponse{
+		"POST /api/v1/repos/plate/shop/hooks":     {status: http.StatusCreated, body: `{"id": 7}`},
+		"DELETE /api/v1/repos/plate/shop/hooks/7": {status: http.StatusNoContent},
+	})
+	gitea := NewGiteaService(giteaTestConfig(server.server.URL))
+
+	id, err := gitea.CreateWebhook("plate/shop", "https://plate.example.com/hooks/gitea")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 7 {
+		t.Errorf("webhook ID = %d, want 7", id)
+	}
+
+	body := server.request("POST /api/v1/repos/plate/shop/hooks").body
+	hookConfig, _ := body["config"].(map[string]interface{})
+	if body["type"] != "gitea" || hookConfig["url"] != "https://plate.example.com/hooks/gitea" || hookConfig["secret"] != "hook-secret" {
+		t.Errorf("body = %v", body)
+	}
+	if events, _ := body["events"].([]interface{}); len(events) != 2 || events[0] != "push" || events[1] != "pull_request" {
+		t.Errorf("events = %v", body["events"])
+	}
+
+	if err := gitea.DeleteWebhook("plate/shop", 7); err != nil {
+		t.Fatal(err)
+	}
+	server.request("DELETE /api/v1/repos/plate/shop/hooks/7")
+
+	unsigned := giteaTestConfig(server.server.URL)
+	unsigned.WebhookSecret = ""
+	if _, err := NewGiteaService(unsigned).CreateWebhook("plate/shop", "https://plate.example.com/hooks/gitea"); err == nil {
+		t.Error("creating a webhook without a secret succeeded")
+	}
+}
+
+func TestGiteaBranchesAndCommits(t *testing.T) {
+	server := newGitTestServer(t, map[string]gitTestResponse{
+		"GET /api/v1/repos/plate/shop/branches": {body: `[{"name": "main"}, {"name": "feature/login"}]`},
+		"GET /api/v1/repos/plate/shop/commits": {body: `[{
+			"sha": "abc123",
+			"commit": {"message": "Add login", "author": {"name": "Ada", "date": "2024-03-01T10:00:00Z"}}
+		}]`},
+	})
+	gitea := NewGiteaService(giteaTestConfig(server.server.URL))
+
+	branches, err := gitea.GetBranches("plate/shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(branches) != 2 || branches[0] != "main" || branches[1] != "feature/login" {
+		t.Errorf("branches = %v", branches)
+	}
+
+	commits, err := gitea.GetCommits("plate/shop", "feature/login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := GitCommit{SHA: "abc123", Message: "Add login", Author: "Ada", Timestamp: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)}
+	if len(commits) != 1 || commits[0] != want {
+		t.Errorf("commits = %+v, want %+v", commits, want)
+	}
+	if query := server.request("GET /api/v1/repos/plate/shop/commits").query; query.Get("sha") != "feature/login" || query.Get("limit") != "50" {
+		t.Errorf("query = %v", query)
+	}
+}
+
+func TestGiteaPullRequestsAndComments(t *testing.T) {
+	server := newGitTestServer(t, map[string]gitTestResponse{
+		"POST /api/v1/repos/plate/shop/pulls": {status: http.StatusCreated, body: `{
+			"number": 12, "title": "Add login", "state": "open",
+			"html_url": "https://git.example.com/plate/shop/pulls/12", "user": {"login": "ada"}
+		}`},
+		"POST /api/v1/repos/plate/shop/issues/12/comments":  {status: http.StatusCreated, body: `{"id": 99}`},
+		"PATCH /api/v1/repos/plate/shop/issues/comments/99": {body: `{"id": 99}`},
+	})
+	gitea := NewGiteaService(giteaTestConfig(server.server.URL))
+
+	pr, err := gitea.CreatePullRequest("plate/shop", "Add login", "Adds a login page", "feature/login", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := GitPullRequest{Number: 12, Title: "Add login", State: "open", Author: "ada", HTMLURL: "https://git.example.com/plate/shop/pulls/12"}
+	if *pr != want {
+		t.Errorf("pull request = %+v, want %+v", pr, want)
+	}
+	if body := server.request("POST /api/v1/repos/plate/shop/pulls").body; body["head"] != "feature/login" || body["base"] != "main" || body["body"] != "Adds a login page" {
+		t.Errorf("body = %v", body)
+	}
+
+	id, err := gitea.CreateComment("plate/shop", 12, "Deployed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 99 {
+		t.Errorf("comment ID = %d, want 99", id)
+	}
+	if err := gitea.EditComment("plate/shop", 12, 99, "Redeployed"); err != nil {
+		t.Fatal(err)
+	}
+	if body := server.request("PATCH /api/v1/repos/plate/shop/issues/comments/99").body; body["body"] != "Redeployed" {
+		t.Errorf("body = %v", body)
+	}
+}
+
+func TestGiteaSetCommitStatus(t *testing.T) {
+	server := newGitTestServer(t, map[string]gitTestResponse{
+		"POST /api/v1/repos/plate/shop/statuses/abc123": {status: http.StatusCreated, body: `{}`},
+	})
+	gitea := NewGiteaService(giteaTestConfig(server.server.URL))
+
+	for state, want := range testCommitStates {
+		status := CommitStatus{State: state, Context: "plate/staging", Description: "Deploying", TargetURL: "https://plate.example.com/deployments/3"}
+		if err := gitea.SetCommitStatus("plate/shop", "abc123", status); err != nil {
+			t.Fatal(err)
+		}
+
+		body := server.request("POST /api/v1/repos/plate/shop/statuses/abc123").body
+		if body["state"] != want {
+			t.Errorf("state %s was sent as %v, want %s", state, body["state"], want)
+		}
+		if body["context"] != "plate/staging" || body["target_url"] != "https://plate.example.com/deployments/3" {
+			t.Errorf("body = %v", body)
+		}
+	}
+}
+
+func TestGiteaAPIError(t *testing.T) {
+	server := newGitTestServer(t, map[string]gitTestResponse{
+		"GET /api/v1/repos/plate/shop/branches": {status: http.StatusInternalServerError, body: "boom"},
+	})
+	gitea := NewGiteaService(giteaTestConfig(server.server.URL))
+
+	_, err := gitea.GetBranches("plate/shop")
+	if err == nil {
+		t.Fatal("listing branches succeeded")
+	}
+	if got := err.Error(); got != "failed to list branches of plate/shop: gitea API returned 500: boom" {
+		t.Errorf("error = %q", got)
+	}
+
+	if _, err := NewGiteaService(config.Gitea{}).GetBranches("plate/shop"); err == nil {
+		t.Error("listing branches without a URL succeeded")
+	}
+}
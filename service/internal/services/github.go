@@ -0,0 +1,222 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/plate/service/internal/config"
+)
+
+// GitHubService is the GitProvider for GitHub and GitHub Enterprise.
+type GitHubService struct {
+	config config.GitHub
+	api    *gitAPI
+}
+
+func NewGitHubService(cfg config.GitHub) *GitHubService {
+	headers := map[string]string{
+		"Accept":               "application/vnd.github+json",
+		"X-GitHub-Api-Version": "2022-11-28",
+	}
+	if cfg.Token != "" {
+		headers["Authorization"] = "Bearer " + cfg.Token
+	}
+
+	return &GitHubService{
+		config: cfg,
+		api:    newGitAPI("github", cfg.URL, headers),
+	}
+}
+
+func (s *GitHubService) Name() string {
+	return "github"
+}
+
+// githubCommit is a commit as listed by GitHub, and by Gitea, whose API
+// follows GitHub's.
+type githubCommit struct {
+	SHA    string `json:"sha"`
+	Commit struct {
+		Message string `json:"message"`
+		Author  struct {
+			Name string    `json:"name"`
+			Date time.Time `json:"date"`
+		} `json:"author"`
+	} `json:"commit"`
+}
+
+func (c githubCommit) gitCommit() GitCommit {
+	return GitCommit{SHA: c.SHA, Message: c.Commit.Message, Author: c.Commit.Author.Name, Timestamp: c.Commit.Author.Date}
+}
+
+// githubPullRequest is a pull request as returned by GitHub and Gitea.
+type githubPullRequest struct {
+	Number  int    `json:"number"`
+	Title   string `json:"title"`
+	State   string `json:"state"`
+	HTMLURL string `json:"html_url"`
+	User    struct {
+		Login string `json:"login"`
+	} `json:"user"`
+}
+
+func (pr githubPullRequest) gitPullRequest() *GitPullRequest {
+	return &GitPullRequest{Number: pr.Number, Title: pr.Title, State: pr.State, Author: pr.User.Login, HTMLURL: pr.HTMLURL}
+}
+
+// CreateRepository creates a repository in the configured organization, or
+// for the token's user without one.
+func (s *GitHubService) CreateRepository(name, description string) (*GitRepository, error) {
+	body := map[string]interface{}{
+		"name":        name,
+		"description": description,
+		"private":     true,
+	}
+
+	path := "/user/repos"
+	if s.config.Owner != "" {
+		path = fmt.Sprintf("/orgs/%s/repos", s.config.Owner)
+	}
+
+	var repo GitRepository
+	err := s.api.request(http.MethodPost, path, body, &repo)
+	if isStatus(err, http.StatusUnprocessableEntity) {
+		// The name is taken, most likely by the repository itself
+		owner := s.config.Owner
+		if owner == "" {
+			var user struct {
+				Login string `json:"login"`
+			}
+			if err := s.api.request(http.MethodGet, "/user", nil, &user); err != nil {
+				return nil, fmt.Errorf("failed to create repository %s: %w", name, err)
+			}
+			owner = user.Login
+		}
+		return s.GetRepository(owner + "/" + name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create repository %s: %w", name, err)
+	}
+	return &repo, nil
+}
+
+func (s *GitHubService) GetRepository(fullName string) (*GitRepository, error) {
+	var repo GitRepository
+	if err := s.api.request(http.MethodGet, "/repos/"+fullName, nil, &repo); err != nil {
+		return nil, fmt.Errorf("failed to get repository %s: %w", fullName, err)
+	}
+	return &repo, nil
+}
+
+func (s *GitHubService) CreateWebhook(fullName, webhookURL string) (int64, error) {
+	if s.config.WebhookSecret == "" {
+		return 0, fmt.Errorf("failed to create webhook for %s: no webhook secret is configured", fullName)
+	}
+
+	body := map[string]interface{}{
+		"name":   "web",
+		"active": true,
+		"events": []string{"push", "pull_request"},
+		"config": map[string]string{
+			"url":          webhookURL,
+			"content_type": "json",
+			"secret":       s.config.WebhookSecret,
+		},
+	}
+	var hook struct {
+		ID int64 `json:"id"`
+	}
+	if err := s.api.request(http.MethodPost, fmt.Sprintf("/repos/%s/hooks", fullName), body, &hook); err != nil {
+		return 0, fmt.Errorf("failed to create webhook for %s: %w", fullName, err)
+	}
+	return hook.ID, nil
+}
+
+func (s *GitHubService) DeleteWebhook(fullName string, id int64) error {
+	if err := s.api.request(http.MethodDelete, fmt.Sprintf("/repos/%s/hooks/%d", fullName, id), nil, nil); err != nil {
+		return fmt.Errorf("failed to delete webhook %d of %s: %w", id, fullName, err)
+	}
+	return nil
+}
+
+func (s *GitHubService) GetBranches(fullName string) ([]string, error) {
+	var branches []struct {
+		Name string `json:"name"`
+	}
+	if err := s.api.request(http.MethodGet, fmt.Sprintf("/repos/%s/branches?per_page=100", fullName), nil, &branches); err != nil {
+		return nil, fmt.Errorf("failed to list branches of %s: %w", fullName, err)
+	}
+
+	names := make([]string, len(branches))
+	for i, branch := range branches {
+		names[i] = branch.Name
+	}
+	return names, nil
+}
+
+func (s *GitHubService) GetCommits(fullName, branch string) ([]GitCommit, error) {
+	var commits []githubCommit
+	path := fmt.Sprintf("/repos/%s/commits?per_page=50&sha=%s", fullName, url.QueryEscape(branch))
+	if err := s.api.request(http.MethodGet, path, nil, &commits); err != nil {
+		return nil, fmt.Errorf("failed to list commits of %s: %w", fullName, err)
+	}
+
+	result := make([]GitCommit, len(commits))
+	for i, commit := range commits {
+		result[i] = commit.gitCommit()
+	}
+	return result, nil
+}
+
+func (s *GitHubService) CreatePullRequest(fullName, title, description, sourceBranch, targetBranch string) (*GitPullRequest, error) {
+	body := map[string]string{
+		"title": title,
+		"body":  description,
+		"head":  sourceBranch,
+		"base":  targetBranch,
+	}
+	var pr githubPullRequest
+	if err := s.api.request(http.MethodPost, fmt.Sprintf("/repos/%s/pulls", fullName), body, &pr); err != nil {
+		return nil, fmt.Errorf("failed to create pull request in %s: %w", fullName, err)
+	}
+	return pr.gitPullRequest(), nil
+}
+
+func (s *GitHubService) CreateComment(fullName string, number int, body string) (int64, error) {
+	var comment struct {
+		ID int64 `json:"id"`
+	}
+	err := s.api.request(http.MethodPost, fmt.Sprintf("/repos/%s/issues/%d/comments", fullName, number), map[string]string{"body": body}, &comment)
+	if err != nil {
+		return 0, fmt.Errorf("failed to comment on %s#%d: %w", fullName, number, err)
+	}
+	return comment.ID, nil
+}
+
+func (s *GitHubService) EditComment(fullName string, number int, id int64, body string) error {
+	err := s.api.request(http.MethodPatch, fmt.Sprintf("/repos/%s/issues/comments/%d", fullName, id), map[string]string{"body": body}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to edit comment %d on %s#%d: %w", id, fullName, number, err)
+	}
+	return nil
+}
+
+func (s *GitHubService) SetCommitStatus(fullName, sha string, status CommitStatus) error {
+	state := string(status.State)
+	if status.State == CommitStateRunning {
+		state = string(CommitStatePending)
+	}
+
+	body := map[string]string{
+		"state":       state,
+		"context":     status.Context,
+		"description": status.Description,
+		"target_url":  status.TargetURL,
+	}
+	if err := s.api.request(http.MethodPost, fmt.Sprintf("/repos/%s/statuses/%s", fullName, sha), body, nil); err != nil {
+		return fmt.Errorf("failed to set status of %s@%s: %w", fullName, shortSHA(sha), err)
+	}
+	return nil
+}
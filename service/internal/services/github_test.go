@@ -0,0 +1,227 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/plate/service/internal/config"
+)
+
+func githubTestConfig(url string) config.GitHub {
+	return config.GitHub{URL: url, Token: "github-token", Owner: "plate", WebhookSecret: "hook-secret"}
+}
+
+const githubTestRepository = `{
+	"id": 1296269,
+	"name": "shop",
+	"full_name": "plate/shop",
+	"html_url": "https://github.com/plate/shop",
+	"clone_url": "https://github.com/plate/shop.git",
+	"default_branch": "main",
+	"private": true
+}`
+
+func TestGitHubCreateRepository(t *testing.T) {
+	server := newGitTestServer(t, map[string]gitTestResponse{
+		"POST /orgs/plate/repos": {status: http.StatusCreated, body: githubTestRepository},
+	})
+	github := NewGitHubService(githubTestConfig(server.server.URL))
+
+	repo, err := github.CreateRepository("shop", "The shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.ID != 1296269 || repo.FullName != "plate/shop" || repo.HTMLURL != "https://github.com/plate/shop" || !repo.Private {
+		t.Errorf("repository = %+v", repo)
+	}
+
+	request := server.request("POST /orgs/plate/repos")
+	if got := request.header.Get("Authorization"); got != "Bearer github-token" {
+		t.Errorf("Authorization = %q", got)
+	}
+	if got := request.header.Get("Accept"); got != "application/vnd.github+json" {
+		t.Errorf("Accept = %q", got)
+	}
+	if request.body["name"] != "shop" || request.body["private"] != true {
+		t.Errorf("body = %v", request.body)
+	}
+}
+
+func TestGitHubCreateExistingUserRepository(t *testing.T) {
+	server := newGitTestServer(t, map[string]gitTestResponse{
+		"POST /user/repos":    {status: http.StatusUnprocessableEntity, body: `{"message":"Repository creation failed."}`},
+		"GET /user":           {body: `{"login": "ada"}`},
+		"GET /repos/ada/shop": {body: `{"id": 5, "name": "shop", "full_name": "ada/shop"}`},
+	})
+	cfg := githubTestConfig(server.server.URL)
+	cfg.Owner = ""
+	github := NewGitHubService(cfg)
+
+	repo, err := github.CreateRepository("shop", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.ID != 5 || repo.FullName != "ada/shop" {
+		t.Errorf("repository = %+v", repo)
+	}
+}
+
+func TestGitHubGetRepository(t *testing.T) {
+	server := newGitTestServer(t, map[string]gitTestResponse{
+		"GET /repos/plate/shop":    {body: githubTestRepository},
+		"GET /repos/plate/missing": {status: http.StatusNotFound, body: `{"message":"Not Found"}`},
+	})
+	github := NewGitHubService(githubTestConfig(server.server.URL))
+
+	repo, err := github.GetRepository("plate/shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.Name != "shop" || repo.CloneURL != "https://github.com/plate/shop.git" {
+		t.Errorf("repository = %+v", repo)
+	}
+
+	_, err = github.GetRepository("plate/missing")
+	var apiErr *gitAPIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("err = %v, want a 404 from the API", err)
+	}
+}
+
+func TestGitHubWebhooks(t *testing.T) {
+	server := newGitTestServer(t, map[string]gitTestResponse{
+		"POST /repos/plate/shop/hooks":            {status: http.StatusCreated, body: `{"id": 12345678}`},
+		"DELETE /repos/plate/shop/hooks/12345678": {status: http.StatusNoContent},
+	})
+	github := NewGitHubService(githubTestConfig(server.server.URL))
+
+	id, err := github.CreateWebhook("plate/shop", "https://plate.example.com/hooks/github")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 12345678 {
+		t.Errorf("webhook ID = %d", id)
+	}
+
+	body := server.request("POST /repos/plate/shop/hooks").body
+	hookConfig, _ := body["config"].(map[string]interface{})
+	if body["name"] != "web" || hookConfig["url"] != "https://plate.example.com/hooks/github" || hookConfig["secret"] != "hook-secret" || hookConfig["content_type"] != "json" {
+		t.Errorf("body = %v", body)
+	}
+	if events, _ := body["events"].([]interface{}); len(events) != 2 || events[0] != "push" || events[1] != "pull_request" {
+		t.Errorf("events = %v", body["events"])
+	}
+
+	if err := github.DeleteWebhook("plate/shop", 12345678); err != nil {
+		t.Fatal(err)
+	}
+	server.request("DELETE /repos/plate/shop/hooks/12345678")
+
+	unsigned := githubTestConfig(server.server.URL)
+	unsigned.WebhookSecret = ""
+	if _, err := NewGitHubService(unsigned).CreateWebhook("plate/shop", "https://plate.example.com/hooks/github"); err == nil {
+		t.Error("creating a webhook without a secret succeeded")
+	}
+}
+
+func TestGitHubBranchesAndCommits(t *testing.T) {
+	server := newGitTestServer(t, map[string]gitTestResponse{
+		"GET /repos/plate/shop/branches": {body: `[{"name": "main"}, {"name": "release"}]`},
+		"GET /repos/plate/shop/commits": {body: `[
+			{"sha": "def456", "commit": {"message": "Fix checkout", "author": {"name": "Grace", "date": "2024-03-02T09:30:00Z"}}},
+			{"sha": "abc123", "commit": {"message": "Add login", "author": {"name": "Ada", "date": "2024-03-01T10:00:00Z"}}}
+		]`},
+	})
+	github := NewGitHubService(githubTestConfig(server.server.URL))
+
+	branches, err := github.GetBranches("plate/shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(branches) != 2 || branches[0] != "main" || branches[1] != "release" {
+		t.Errorf("branches = %v", branches)
+	}
+	if query := server.request("GET /repos/plate/shop/branches").query; query.Get("per_page") != "100" {
+		t.Errorf("query = %v", query)
+	}
+
+	commits, err := github.GetCommits("plate/shop", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []GitCommit{
+		{SHA: "def456", Message: "Fix checkout", Author: "Grace", Timestamp: time.Date(2024, 3, 2, 9, 30, 0, 0, time.UTC)},
+		{SHA: "abc123", Message: "Add login", Author: "Ada", Timestamp: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)},
+	}
+	if len(commits) != len(want) || commits[0] != want[0] || commits[1] != want[1] {
+		t.Errorf("commits = %+v, want %+v", commits, want)
+	}
+	if query := server.request("GET /repos/plate/shop/commits").query; query.Get("sha") != "main" || query.Get("per_page") != "50" {
+		t.Errorf("query = %v", query)
+	}
+}
+
+func TestGitHubPullRequestsAndComments(t *testing.T) {
+	server := newGitTestServer(t, map[string]gitTestResponse{
+		"POST /repos/plate/shop/pulls": {status: http.StatusCreated, body: `{
+			"number": 34, "title": "Fix checkout", "state": "open",
+			"html_url": "https://github.com/plate/shop/pull/34", "user": {"login": "grace"}
+		}`},
+		"POST /repos/plate/shop/issues/34/comments":    {status: http.StatusCreated, body: `{"id": 1001}`},
+		"PATCH /repos/plate/shop/issues/comments/1001": {body: `{"id": 1001}`},
+	})
+	github := NewGitHubService(githubTestConfig(server.server.URL))
+
+	pr, err := github.CreatePullRequest("plate/shop", "Fix checkout", "", "fix/checkout", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := GitPullRequest{Number: 34, Title: "Fix checkout", State: "open", Author: "grace", HTMLURL: "https://github.com/plate/shop/pull/34"}
+	if *pr != want {
+		t.Errorf("pull request = %+v, want %+v", pr, want)
+	}
+	if body := server.request("POST /repos/plate/shop/pulls").body; body["head"] != "fix/checkout" || body["base"] != "main" {
+		t.Errorf("body = %v", body)
+	}
+
+	id, err := github.CreateComment("plate/shop", 34, "Deployed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 1001 {
+		t.Errorf("comment ID = %d, want 1001", id)
+	}
+	if body := server.request("POST /repos/plate/shop/issues/34/comments").body; body["body"] != "Deployed" {
+		t.Errorf("body = %v", body)
+	}
+	if err := github.EditComment("plate/shop", 34, 1001, "Redeployed"); err != nil {
+		t.Fatal(err)
+	}
+	if body := server.request("PATCH /repos/plate/shop/issues/comments/1001").body; body["body"] != "Redeployed" {
+		t.Errorf("body = %v", body)
+	}
+}
+
+func TestGitHubSetCommitStatus(t *testing.T) {
+	server := newGitTestServer(t, map[string]gitTestResponse{
+		"POST /repos/plate/shop/statuses/abc123": {status: http.StatusCreated, body: `{}`},
+	})
+	github := NewGitHubService(githubTestConfig(server.server.URL))
+
+	for state, want := range testCommitStates {
+		status := CommitStatus{State: state, Context: "plate/production", Description: "Deployed", TargetURL: "https://shop.example.com"}
+		if err := github.SetCommitStatus("plate/shop", "abc123", status); err != nil {
+			t.Fatal(err)
+		}
+
+		body := server.request("POST /repos/plate/shop/statuses/abc123").body
+		if body["state"] != want {
+			t.Errorf("state %s was sent as %v, want %s", state, body["state"], want)
+		}
+		if body["context"] != "plate/production" || body["description"] != "Deployed" || body["target_url"] != "https://shop.example.com" {
+			t.Errorf("body = %v", body)
+		}
+	}
+}
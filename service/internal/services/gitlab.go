@@ -0,0 +1,241 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/plate/service/internal/config"
+)
+
+// GitLabService is the GitProvider for GitLab. Repositories are GitLab
+// projects, addressed by their path with namespace; pull requests are
+// merge requests, numbered by their IID.
+type GitLabService struct {
+	config config.GitLab
+	api    *gitAPI
+}
+
+func NewGitLabService(cfg config.GitLab) *GitLabService {
+	baseURL := ""
+	if cfg.URL != "" {
+		baseURL = cfg.URL + "/api/v4"
+	}
+
+	return &GitLabService{
+		config: cfg,
+		api:    newGitAPI("gitlab", baseURL, map[string]string{"PRIVATE-TOKEN": cfg.Token}),
+	}
+}
+
+func (s *GitLabService) Name() string {
+	return "gitlab"
+}
+
+// gitlabProject is a repository as returned by GitLab.
+type gitlabProject struct {
+	ID                int64  `json:"id"`
+	Name              string `json:"name"`
+	PathWithNamespace string `json:"path_with_namespace"`
+	Description       string `json:"description"`
+	WebURL            string `json:"web_url"`
+	HTTPURLToRepo     string `json:"http_url_to_repo"`
+	DefaultBranch     string `json:"default_branch"`
+	Visibility        string `json:"visibility"`
+}
+
+func (p gitlabProject) gitRepository() *GitRepository {
+	return &GitRepository{
+		ID:            p.ID,
+		Name:          p.Name,
+		FullName:      p.PathWithNamespace,
+		Description:   p.Description,
+		HTMLURL:       p.WebURL,
+		CloneURL:      p.HTTPURLToRepo,
+		DefaultBranch: p.DefaultBranch,
+		Private:       p.Visibility == "private",
+	}
+}
+
+// projectPath is the API path of the project with the given full name.
+func projectPath(fullName string) string {
+	return "/projects/" + url.PathEscape(fullName)
+}
+
+// CreateRepository creates a project in the configured group, or for the
+// token's user without one.
+func (s *GitLabService) CreateRepository(name, description string) (*GitRepository, error) {
+	body := map[string]interface{}{
+		"name":        name,
+		"path":        name,
+		"description": description,
+		"visibility":  "private",
+	}
+
+	owner := s.config.Group
+	if owner != "" {
+		var group struct {
+			ID int64 `json:"id"`
+		}
+		if err := s.api.request(http.MethodGet, "/groups/"+url.PathEscape(owner), nil, &group); err != nil {
+			return nil, fmt.Errorf("failed to find group %s: %w", owner, err)
+		}
+		body["namespace_id"] = group.ID
+	}
+
+	var project gitlabProject
+	err := s.api.request(http.MethodPost, "/projects", body, &project)
+	if isStatus(err, http.StatusBadRequest) {
+		// GitLab answers 400 when the path is taken, most likely by the
+		// project itself
+		if owner == "" {
+			var user struct {
+				Username string `json:"username"`
+			}
+			if userErr := s.api.request(http.MethodGet, "/user", nil, &user); userErr != nil {
+				return nil, fmt.Errorf("failed to create repository %s: %w", name, err)
+			}
+			owner = user.Username
+		}
+		if existing, getErr := s.GetRepository(owner + "/" + name); getErr == nil {
+			return existing, nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create repository %s: %w", name, err)
+	}
+	return project.gitRepository(), nil
+}
+
+func (s *GitLabService) GetRepository(fullName string) (*GitRepository, error) {
+	var project gitlabProject
+	if err := s.api.request(http.MethodGet, projectPath(fullName), nil, &project); err != nil {
+		return nil, fmt.Errorf("failed to get repository %s: %w", fullName, err)
+	}
+	return project.gitRepository(), nil
+}
+
+// CreateWebhook adds a webhook sending push and merge request events to
+// webhookURL, with the configured webhook secret as its token.
+func (s *GitLabService) CreateWebhook(fullName, webhookURL string) (int64, error) {
+	if s.config.WebhookSecret == "" {
+		return 0, fmt.Errorf("failed to create webhook for %s: no webhook secret is configured", fullName)
+	}
+
+	body := map[string]interface{}{
+		"url":                     webhookURL,
+		"push_events":             true,
+		"merge_requests_events":   true,
+		"enable_ssl_verification": true,
+		"token":                   s.config.WebhookSecret,
+	}
+	var hook struct {
+		ID int64 `json:"id"`
+	}
+	if err := s.api.request(http.MethodPost, projectPath(fullName)+"/hooks", body, &hook); err != nil {
+		return 0, fmt.Errorf("failed to create webhook for %s: %w", fullName, err)
+	}
+	return hook.ID, nil
+}
+
+func (s *GitLabService) DeleteWebhook(fullName string, id int64) error {
+	if err := s.api.request(http.MethodDelete, fmt.Sprintf("%s/hooks/%d", projectPath(fullName), id), nil, nil); err != nil {
+		return fmt.Errorf("failed to delete webhook %d of %s: %w", id, fullName, err)
+	}
+	return nil
+}
+
+func (s *GitLabService) GetBranches(fullName string) ([]string, error) {
+	var branches []struct {
+		Name string `json:"name"`
+	}
+	if err := s.api.request(http.MethodGet, projectPath(fullName)+"/repository/branches?per_page=100", nil, &branches); err != nil {
+		return nil, fmt.Errorf("failed to list branches of %s: %w", fullName, err)
+	}
+
+	names := make([]string, len(branches))
+	for i, branch := range branches {
+		names[i] = branch.Name
+	}
+	return names, nil
+}
+
+func (s *GitLabService) GetCommits(fullName, branch string) ([]GitCommit, error) {
+	var commits []struct {
+		ID            string    `json:"id"`
+		Message       string    `json:"message"`
+		AuthorName    string    `json:"author_name"`
+		CommittedDate time.Time `json:"committed_date"`
+	}
+	path := fmt.Sprintf("%s/repository/commits?per_page=50&ref_name=%s", projectPath(fullName), url.QueryEscape(branch))
+	if err := s.api.request(http.MethodGet, path, nil, &commits); err != nil {
+		return nil, fmt.Errorf("failed to list commits of %s: %w", fullName, err)
+	}
+
+	result := make([]GitCommit, len(commits))
+	for i, commit := range commits {
+		result[i] = GitCommit{SHA: commit.ID, Message: commit.Message, Author: commit.AuthorName, Timestamp: commit.CommittedDate}
+	}
+	return result, nil
+}
+
+func (s *GitLabService) CreatePullRequest(fullName, title, description, sourceBranch, targetBranch string) (*GitPullRequest, error) {
+	body := map[string]string{
+		"title":         title,
+		"description":   description,
+		"source_branch": sourceBranch,
+		"target_branch": targetBranch,
+	}
+	var mr struct {
+		IID    int    `json:"iid"`
+		Title  string `json:"title"`
+		State  string `json:"state"`
+		WebURL string `json:"web_url"`
+		Author struct {
+			Username string `json:"username"`
+		} `json:"author"`
+	}
+	if err := s.api.request(http.MethodPost, projectPath(fullName)+"/merge_requests", body, &mr); err != nil {
+		return nil, fmt.Errorf("failed to create merge request in %s: %w", fullName, err)
+	}
+
+	return &GitPullRequest{Number: mr.IID, Title: mr.Title, State: mr.State, Author: mr.Author.Username, HTMLURL: mr.WebURL}, nil
+}
+
+func (s *GitLabService) CreateComment(fullName string, number int, body string) (int64, error) {
+	var note struct {
+		ID int64 `json:"id"`
+	}
+	path := fmt.Sprintf("%s/merge_requests/%d/notes", projectPath(fullName), number)
+	if err := s.api.request(http.MethodPost, path, map[string]string{"body": body}, &note); err != nil {
+		return 0, fmt.Errorf("failed to comment on %s!%d: %w", fullName, number, err)
+	}
+	return note.ID, nil
+}
+
+func (s *GitLabService) EditComment(fullName string, number int, id int64, body string) error {
+	path := fmt.Sprintf("%s/merge_requests/%d/notes/%d", projectPath(fullName), number, id)
+	if err := s.api.request(http.MethodPut, path, map[string]string{"body": body}, nil); err != nil {
+		return fmt.Errorf("failed to edit comment %d on %s!%d: %w", id, fullName, number, err)
+	}
+	return nil
+}
+
+func (s *GitLabService) SetCommitStatus(fullName, sha string, status CommitStatus) error {
+	state := string(status.State)
+	if status.State == CommitStateFailure {
+		state = "failed"
+	}
+
+	body := map[string]string{
+		"state":       state,
+		"name":        status.Context,
+		"description": status.Description,
+		"target_url":  status.TargetURL,
+	}
+	if err := s.api.request(http.MethodPost, fmt.Sprintf("%s/statuses/%s", projectPath(fullName), sha), body, nil); err != nil {
+		return fmt.Errorf("failed to set status of %s@%s: %w", fullName, shortSHA(sha), err)
+	}
+	return nil
+}
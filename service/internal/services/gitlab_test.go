@@ -0,0 +1,221 @@
+package services
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/plate/service/internal/config"
+)
+
+func gitlabTestConfig(url string) config.GitLab {
+	return config.GitLab{URL: url, Token: "gitlab-token", Group: "plate", WebhookSecret: "hook-secret"}
+}
+
+const gitlabTestProject = `{
+	"id": 278964,
+	"name": "shop",
+	"path_with_namespace": "plate/shop",
+	"description": "The shop",
+	"web_url": "https://gitlab.com/plate/shop",
+	"http_url_to_repo": "https://gitlab.com/plate/shop.git",
+	"default_branch": "main",
+	"visibility": "private"
+}`
+
+func TestGitLabCreateRepository(t *testing.T) {
+	server := newGitTestServer(t, map[string]gitTestResponse{
+		"GET /api/v4/groups/plate": {body: `{"id": 9, "full_path": "plate"}`},
+		"POST /api/v4/projects":    {status: http.StatusCreated, body: gitlabTestProject},
+	})
+	gitlab := NewGitLabService(gitlabTestConfig(server.server.URL))
+
+	repo, err := gitlab.CreateRepository("shop", "The shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := GitRepository{
+		ID:            278964,
+		Name:          "shop",
+		FullName:      "plate/shop",
+		Description:   "The shop",
+		HTMLURL:       "https://gitlab.com/plate/shop",
+		CloneURL:      "https://gitlab.com/plate/shop.git",
+		DefaultBranch: "main",
+		Private:       true,
+	}
+	if *repo != want {
+		t.Errorf("repository = %+v, want %+v", repo, want)
+	}
+
+	request := server.request("POST /api/v4/projects")
+	if got := request.header.Get("PRIVATE-TOKEN"); got != "gitlab-token" {
+		t.Errorf("PRIVATE-TOKEN = %q", got)
+	}
+	if request.body["path"] != "shop" || request.body["visibility"] != "private" || request.body["namespace_id"] != float64(9) {
+		t.Errorf("body = %v", request.body)
+	}
+}
+
+func TestGitLabCreateExistingRepository(t *testing.T) {
+	server := newGitTestServer(t, map[string]gitTestResponse{
+		"GET /api/v4/groups/plate":          {body: `{"id": 9}`},
+		"POST /api/v4/projects":             {status: http.StatusBadRequest, body: `{"message":{"path":["has already been taken"]}}`},
+		"GET /api/v4/projects/plate%2Fshop": {body: gitlabTestProject},
+	})
+	gitlab := NewGitLabService(gitlabTestConfig(server.server.URL))
+
+	repo, err := gitlab.CreateRepository("shop", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.ID != 278964 {
+		t.Errorf("repository = %+v", repo)
+	}
+}
+
+func TestGitLabGetRepository(t *testing.T) {
+	server := newGitTestServer(t, map[string]gitTestResponse{
+		"GET /api/v4/projects/plate%2Fbackend%2Fshop": {body: `{"id": 3, "name": "shop", "path_with_namespace": "plate/backend/shop", "visibility": "internal"}`},
+	})
+	gitlab := NewGitLabService(gitlabTestConfig(server.server.URL))
+
+	repo, err := gitlab.GetRepository("plate/backend/shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.FullName != "plate/backend/shop" || repo.Private {
+		t.Errorf("repository = %+v", repo)
+	}
+}
+
+func TestGitLabWebhooks(t *testing.T) {
+	server := newGitTestServer(t, map[string]gitTestResponse{
+		"POST /api/v4/projects/plate%2Fshop/hooks":     {status: http.StatusCreated, body: `{"id": 4}`},
+		"DELETE /api/v4/projects/plate%2Fshop/hooks/4": {status: http.StatusNoContent},
+	})
+	gitlab := NewGitLabService(gitlabTestConfig(server.server.URL))
+
+	id, err := gitlab.CreateWebhook("plate/shop", "https://plate.example.com/hooks/gitlab")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 4 {
+		t.Errorf("webhook ID = %d, want 4", id)
+	}
+
+	body := server.request("POST /api/v4/projects/plate%2Fshop/hooks").body
+	if body["url"] != "https://plate.example.com/hooks/gitlab" || body["token"] != "hook-secret" ||
+		body["push_events"] != true || body["merge_requests_events"] != true {
+		t.Errorf("body = %v", body)
+	}
+
+	if err := gitlab.DeleteWebhook("plate/shop", 4); err != nil {
+		t.Fatal(err)
+	}
+	server.request("DELETE /api/v4/projects/plate%2Fshop/hooks/4")
+
+	unsigned := gitlabTestConfig(server.server.URL)
+	unsigned.WebhookSecret = ""
+	if _, err := NewGitLabService(unsigned).CreateWebhook("plate/shop", "https://plate.example.com/hooks/gitlab"); err == nil {
+		t.Error("creating a webhook without a secret succeeded")
+	}
+}
+
+func TestGitLabBranchesAndCommits(t *testing.T) {
+	server := newGitTestServer(t, map[string]gitTestResponse{
+		"GET /api/v4/projects/plate%2Fshop/repository/branches": {body: `[{"name": "main"}, {"name": "feature/login"}]`},
+		"GET /api/v4/projects/plate%2Fshop/repository/commits": {body: `[
+			{"id": "abc123", "message": "Add login", "author_name": "Ada", "committed_date": "2024-03-01T10:00:00Z"}
+		]`},
+	})
+	gitlab := NewGitLabService(gitlabTestConfig(server.server.URL))
+
+	branches, err := gitlab.GetBranches("plate/shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(branches) != 2 || branches[0] != "main" || branches[1] != "feature/login" {
+		t.Errorf("branches = %v", branches)
+	}
+
+	commits, err := gitlab.GetCommits("plate/shop", "feature/login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := GitCommit{SHA: "abc123", Message: "Add login", Author: "Ada", Timestamp: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)}
+	if len(commits) != 1 || commits[0] != want {
+		t.Errorf("commits = %+v, want %+v", commits, want)
+	}
+	if query := server.request("GET /api/v4/projects/plate%2Fshop/repository/commits").query; query.Get("ref_name") != "feature/login" {
+		t.Errorf("query = %v", query)
+	}
+}
+
+func TestGitLabMergeRequestsAndComments(t *testing.T) {
+	server := newGitTestServer(t, map[string]gitTestResponse{
+		"POST /api/v4/projects/plate%2Fshop/merge_requests": {status: http.StatusCreated, body: `{
+			"id": 7001, "iid": 5, "title": "Add login", "state": "opened",
+			"web_url": "https://gitlab.com/plate/shop/-/merge_requests/5", "author": {"username": "ada"}
+		}`},
+		"POST /api/v4/projects/plate%2Fshop/merge_requests/5/notes":    {status: http.StatusCreated, body: `{"id": 301}`},
+		"PUT /api/v4/projects/plate%2Fshop/merge_requests/5/notes/301": {body: `{"id": 301}`},
+	})
+	gitlab := NewGitLabService(gitlabTestConfig(server.server.URL))
+
+	mr, err := gitlab.CreatePullRequest("plate/shop", "Add login", "Adds a login page", "feature/login", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := GitPullRequest{Number: 5, Title: "Add login", State: "opened", Author: "ada", HTMLURL: "https://gitlab.com/plate/shop/-/merge_requests/5"}
+	if *mr != want {
+		t.Errorf("merge request = %+v, want %+v", mr, want)
+	}
+	body := server.request("POST /api/v4/projects/plate%2Fshop/merge_requests").body
+	if body["source_branch"] != "feature/login" || body["target_branch"] != "main" || body["description"] != "Adds a login page" {
+		t.Errorf("body = %v", body)
+	}
+
+	id, err := gitlab.CreateComment("plate/shop", 5, "Deployed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 301 {
+		t.Errorf("comment ID = %d, want 301", id)
+	}
+	if err := gitlab.EditComment("plate/shop", 5, 301, "Redeployed"); err != nil {
+		t.Fatal(err)
+	}
+	if body := server.request("PUT /api/v4/projects/plate%2Fshop/merge_requests/5/notes/301").body; body["body"] != "Redeployed" {
+		t.Errorf("body = %v", body)
+	}
+}
+
+func TestGitLabSetCommitStatus(t *testing.T) {
+	server := newGitTestServer(t, map[string]gitTestResponse{
+		"POST /api/v4/projects/plate%2Fshop/statuses/abc123": {status: http.StatusCreated, body: `{}`},
+	})
+	gitlab := NewGitLabService(gitlabTestConfig(server.server.URL))
+
+	// GitLab has a running state, and calls failure failed
+	states := map[CommitState]string{
+		CommitStatePending: "pending",
+		CommitStateRunning: "running",
+		CommitStateSuccess: "success",
+		CommitStateFailure: "failed",
+	}
+	for state, want := range states {
+		status := CommitStatus{State: state, Context: "plate/staging", Description: "Deploying", TargetURL: "https://plate.example.com/deployments/3"}
+		if err := gitlab.SetCommitStatus("plate/shop", "abc123", status); err != nil {
+			t.Fatal(err)
+		}
+
+		body := server.request("POST /api/v4/projects/plate%2Fshop/statuses/abc123").body
+		if body["state"] != want {
+			t.Errorf("state %s was sent as %v, want %s", state, body["state"], want)
+		}
+		if body["name"] != "plate/staging" || body["target_url"] != "https://plate.example.com/deployments/3" {
+			t.Errorf("body = %v", body)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// GitProvider is a Git hosting service projects are built from, such as
+// Gitea, GitHub or GitLab. Repositories are named by their full name,
+// owner/name, or group/subgroup/name on GitLab.
+type GitProvider interface {
+	// Name identifies the provider in models.Repository, such as gitea.
+	Name() string
+
+	// CreateRepository creates a private repository in the configured
+	// organization, or returns it if it exists.
+	CreateRepository(name, description string) (*GitRepository, error)
+	GetRepository(fullName string) (*GitRepository, error)
+
+	// CreateWebhook sends push and pull request events of a repository to
+	// webhookURL, and returns the ID of the webhook.
+	CreateWebhook(fullName, webhookURL string) (int64, error)
+	DeleteWebhook(fullName string, id int64) error
+
+	GetBranches(fullName string) ([]string, error)
+	// GetCommits returns the latest commits of a branch, newest first.
+	GetCommits(fullName, branch string) ([]GitCommit, error)
+
+	CreatePullRequest(fullName, title, description, sourceBranch, targetBranch string) (*GitPullRequest, error)
+	// CreateComment comments on a pull request and returns the ID of the
+	// comment.
+	CreateComment(fullName string, number int, body string) (int64, error)
+	EditComment(fullName string, number int, id int64, body string) error
+
+	// SetCommitStatus reports the state of a commit, such as whether it is
+	// deployed, next to it in the provider's UI.
+	SetCommitStatus(fullName, sha string, status CommitStatus) error
+}
+
+// GitRepository is a repository at a Git provider.
+type GitRepository struct {
+	ID            int64  `json:"id"`
+	Name          string `json:"name"`
+	FullName      string `json:"full_name"`
+	Description   string `json:"description"`
+	HTMLURL       string `json:"html_url"`
+	CloneURL      string `json:"clone_url"`
+	DefaultBranch string `json:"default_branch"`
+	Private       bool   `json:"private"`
+}
+
+// GitCommit is a commit of a repository.
+type GitCommit struct {
+	SHA       string    `json:"sha"`
+	Message   string    `json:"message"`
+	Author    string    `json:"author"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// GitPullRequest is a pull request, or merge request on GitLab.
+type GitPullRequest struct {
+	Number  int    `json:"number"`
+	Title   string `json:"title"`
+	State   string `json:"state"`
+	Author  string `json:"author"`
+	HTMLURL string `json:"html_url"`
+}
+
+// CommitState is the state of a commit status.
+type CommitState string
+
+const (
+	CommitStatePending CommitState = "pending"
+	CommitStateRunning CommitState = "running"
+	CommitStateSuccess CommitState = "success"
+	CommitStateFailure CommitState = "failure"
+)
+
+// CommitStatus is reported on a commit. Context tells apart the statuses
+// of a commit, such as the environments it is deployed to.
+type CommitStatus struct {
+	State       CommitState
+	Context     string
+	Description string
+	TargetURL   string
+}
+
+// GitProviders are the configured Git providers by name.
+type GitProviders struct {
+	providers   map[string]GitProvider
+	defaultName string
+}
+
+// NewGitProviders returns the providers given, of which the one named
+// defaultName is used when none is chosen.
+func NewGitProviders(defaultName string, providers ...GitProvider) *GitProviders {
+	p := &GitProviders{providers: map[string]GitProvider{}, defaultName: defaultName}
+	for _, provider := range providers {
+		p.providers[provider.Name()] = provider
+	}
+	return p
+}
+
+// Get returns the provider with the given name, or the default one for an
+// empty name.
+func (p *GitProviders) Get(name string) (GitProvider, error) {
+	if name == "" {
+		name = p.defaultName
+	}
+	provider, ok := p.providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown git provider %q, expected one of %s", name, strings.Join(p.Names(), ", "))
+	}
+	return provider, nil
+}
+
+// Names returns the names of the providers in order.
+func (p *GitProviders) Names() []string {
+	names := make([]string, 0, len(p.providers))
+	for name := range p.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RepositoryFullName derives the full name of a repository from its web or
+// clone URL, such as plate/shop from https://git.example.com/plate/shop.git
+// or git@git.example.com:plate/shop.git.
+func RepositoryFullName(repoURL string) string {
+	path := repoURL
+	if parsed, err := url.Parse(repoURL); err == nil && parsed.Host != "" {
+		path = parsed.Path
+	} else if _, rest, ok := strings.Cut(repoURL, ":"); ok {
+		path = rest
+	}
+	return strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+}
+
+// gitAPIError is returned for non-2xx responses from a Git provider's API.
+type gitAPIError struct {
+	Provider   string
+	StatusCode int
+	Message    string
+}
+
+func (e *gitAPIError) Error() string {
+	return fmt.Sprintf("%s API returned %d: %s", e.Provider, e.StatusCode, e.Message)
+}
+
+// isStatus reports whether err is a response from a Git provider's API
+// with the given status.
+func isStatus(err error, status int) bool {
+	apiErr, ok := err.(*gitAPIError)
+	return ok && apiErr.StatusCode == status
+}
+
+// gitAPI calls the JSON API of a Git provider.
+type gitAPI struct {
+	provider string
+	baseURL  string
+	headers  map[string]string
+	client   *http.Client
+}
+
+func newGitAPI(provider, baseURL string, headers map[string]string) *gitAPI {
+	return &gitAPI{
+		provider: provider,
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		headers:  headers,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// request performs an API call, decoding the JSON response into out when
+// it is non-nil.
+func (a *gitAPI) request(method, path string, body, out interface{}) error {
+	if a.baseURL == "" {
+		return fmt.Errorf("%s is not configured", a.provider)
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, a.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range a.headers {
+		if value != "" {
+			req.Header.Set(name, value)
+		}
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s request %s %s failed: %w", a.provider, method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &gitAPIError{Provider: a.provider, StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(message))}
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+// gitTestServer stands in for the API of a Git provider. It answers
+// requests with canned responses by method and escaped path, such as
+// "GET /repos/plate/shop", and records them.
+type gitTestServer struct {
+	t         *testing.T
+	server    *httptest.Server
+	responses map[string]gitTestResponse
+
+	mu       sync.Mutex
+	requests map[string]*gitTestRequest
+}
+
+type gitTestResponse struct {
+	status int // 200 if unset
+	body   string
+}
+
+type gitTestRequest struct {
+	query  url.Values
+	header http.Header
+	body   map[string]interface{}
+}
+
+func newGitTestServer(t *testing.T, responses map[string]gitTestResponse) *gitTestServer {
+	s := &gitTestServer{t: t, responses: responses, requests: map[string]*gitTestRequest{}}
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Method + " " + r.URL.EscapedPath()
+
+		request := &gitTestRequest{query: r.URL.Query(), header: r.Header}
+		if data, _ := io.ReadAll(r.Body); len(data) > 0 {
+			if err := json.Unmarshal(data, &request.body); err != nil {
+				t.Errorf("%s: invalid request body: %v", key, err)
+			}
+		}
+		s.mu.Lock()
+		s.requests[key] = request
+		s.mu.Unlock()
+
+		response, ok := s.responses[key]
+		if !ok {
+			t.Errorf("unexpected request %s", key)
+			http.NotFound(w, r)
+			return
+		}
+		if response.status == 0 {
+			response.status = http.StatusOK
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(response.status)
+		io.WriteString(w, response.body)
+	}))
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+// request returns the last request received with the given method and
+// path.
+func (s *gitTestServer) request(key string) *gitTestRequest {
+	s.t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	request, ok := s.requests[key]
+	if !ok {
+		s.t.Fatalf("no request %s", key)
+	}
+	return request
+}
+
+// testCommitStates are the states of CommitStatus, with the state GitHub
+// and Gitea expect for each.
+var testCommitStates = map[CommitState]string{
+	CommitStatePending: "pending",
+	CommitStateRunning: "pending",
+	CommitStateSuccess: "success",
+	CommitStateFailure: "failure",
+}
+
+func TestRepositoryFullName(t *testing.T) {
+	tests := map[string]string{
+		"https://git.example.com/plate/shop.git":        "plate/shop",
+		"https://github.com/plate/shop":                 "plate/shop",
+		"git@git.example.com:plate/shop.git":            "plate/shop",
+		"https://gitlab.com/plate/backend/shop.git":     "plate/backend/shop",
+		"ssh://git@git.example.com:2222/plate/shop.git": "plate/shop",
+	}
+	for repoURL, want := range tests {
+		if got := RepositoryFullName(repoURL); got != want {
+			t.Errorf("RepositoryFullName(%q) = %q, want %q", repoURL, got, want)
+		}
+	}
+}
+
+func TestGitProviders(t *testing.T) {
+	providers := NewGitProviders("gitea", NewGiteaService(giteaTestConfig("")), NewGitHubService(githubTestConfig("")))
+
+	provider, err := providers.Get("")
+	if err != nil || provider.Name() != "gitea" {
+		t.Errorf("Get(\"\") = %v, %v, want gitea", provider, err)
+	}
+	if provider, err := providers.Get("github"); err != nil || provider.Name() != "github" {
+		t.Errorf("Get(github) = %v, %v", provider, err)
+	}
+	if _, err := providers.Get("bitbucket"); err == nil {
+		t.Error("Get(bitbucket) succeeded")
+	}
+}
@@ -1,15 +1,34 @@
 package services
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"text/template"
 
 	"github.com/plate/service/internal/config"
 	"github.com/plate/service/internal/models"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	"sigs.k8s.io/yaml"
 )
 
+const (
+	// DeploymentIDAnnotation on the pod template of a workload holds the
+	// ID of the deployment that rolled it out.
+	DeploymentIDAnnotation = "plate/deployment-id"
+	// ProjectIDLabel on a workload holds the ID of the project it runs.
+	ProjectIDLabel = "plate/project-id"
+)
+
+// ErrHelmUnavailable is returned by lookups of Helm releases, which are not
+// implemented yet.
+var ErrHelmUnavailable = errors.New("looking up Helm releases is not implemented")
+
 type HelmService struct {
 	config config.Helm
 }
@@ -38,7 +57,55 @@ func (s *HelmService) Initialize() error {
 	return nil
 }
 
-func (s *HelmService) GenerateChart(project *models.Project, environment *models.Environment) (string, error) {
+// ChartSecret is the Kubernetes Secret holding a project's secrets, which
+// the chart references instead of containing the values. Name is empty
+// when all secrets come from External.
+type ChartSecret struct {
+	Name     string
+	Keys     []string
+	Checksum string
+	External *ChartExternalSecret
+}
+
+// ChartExternalSecret is an ExternalSecret the chart renders for the
+// External Secrets Operator, which creates a Secret of the same name from
+// an external store.
+type ChartExternalSecret struct {
+	Name     string
+	Store    helmSecretStoreRef
+	Data     []helmExternalSecretData
+	Checksum string
+}
+
+// NewChartExternalSecret syncs the referenced secrets of a project from an
+// External Secrets Operator store.
+func NewChartExternalSecret(project, store, kind string, references map[string]models.SecretReference) *ChartExternalSecret {
+	names := make([]string, 0, len(references))
+	for name := range references {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	external := &ChartExternalSecret{
+		Name:  fmt.Sprintf("%s-external-secrets", project),
+		Store: helmSecretStoreRef{Name: store, Kind: kind},
+	}
+	checksum := sha256.New()
+	for _, name := range names {
+		reference := references[name]
+		external.Data = append(external.Data, helmExternalSecretData{
+			SecretKey: name,
+			RemoteRef: helmRemoteRef{Key: reference.Path, Property: reference.Key},
+		})
+		fmt.Fprintf(checksum, "%s=%s\n", name, reference)
+	}
+	external.Checksum = hex.EncodeToString(checksum.Sum(nil))
+	return external
+}
+
+// GenerateChart writes the Helm chart of a deployment of a project to
+// environment. secret is nil for projects without secrets.
+func (s *HelmService) GenerateChart(project *models.Project, environment *models.Environment, deployment *models.Deployment, secret *ChartSecret) (string, error) {
 	chartName := fmt.Sprintf("%s-%s", project.Name, environment.Name)
 	chartDir := filepath.Join(s.config.ChartPath, chartName)
 	
@@ -59,8 +126,12 @@ func (s *HelmService) GenerateChart(project *models.Project, environment *models
 		return "", err
 	}
 	
-	// Generate values.yaml
-	if err := s.generateValues(chartDir, project, environment); err != nil {
+	// Generate values.yaml from the settings for this environment
+	settings, err := ResolveSettings(project, environment)
+	if err != nil {
+		return "", err
+	}
+	if err := s.generateValues(chartDir, project, deployment, settings, secret); err != nil {
 		return "", err
 	}
 	
@@ -74,8 +145,22 @@ func (s *HelmService) GenerateChart(project *models.Project, environment *models
 		return "", err
 	}
 	
+	// Generate autoscaler template if needed
+	if settings.Autoscaling.IsEnabled() {
+		if err := s.generateAutoscalerTemplate(chartDir); err != nil {
+			return "", err
+		}
+	}
+	
+	// Generate external secret template if needed
+	if secret != nil && secret.External != nil {
+		if err := s.generateExternalSecretTemplate(chartDir); err != nil {
+			return "", err
+		}
+	}
+	
 	// Generate ingress template if needed
-	if environment.Domain != "" {
+	if settings.Host != "" {
 		if err := s.generateIngressTemplate(chartDir, project, environment); err != nil {
 			return "", err
 		}
@@ -102,12 +187,7 @@ func (s *HelmService) UninstallRelease(name string) error {
 
 func (s *HelmService) GetRelease(name string) (*HelmRelease, error) {
 	// TODO: Implement Helm release retrieval
-	return &HelmRelease{
-		Name:      name,
-		Status:    "deployed",
-		Revision:  1,
-		Namespace: "default",
-	}, nil
+	return nil, ErrHelmUnavailable
 }
 
 func (s *HelmService) generateChartYaml(chartDir string, project *models.Project) error {
@@ -133,64 +213,178 @@ appVersion: "1.0.0"
 	return tmpl.Execute(file, project)
 }
 
-func (s *HelmService) generateValues(chartDir string, project *models.Project, environment *models.Environment) error {
-	valuesYaml := `replicaCount: 1
-
-image:
-  repository: {{.Name}}
-  pullPolicy: IfNotPresent
-  tag: "latest"
-
-service:
-  type: ClusterIP
-  port: {{.Port}}
-
-ingress:
-  enabled: {{.IngressEnabled}}
-  className: "nginx"
-  annotations: {}
-  hosts:
-    - host: {{.Host}}
-      paths:
-        - path: /
-          pathType: Prefix
-  tls: []
-
-resources:
-  limits:
-    cpu: 500m
-    memory: 512Mi
-  requests:
-    cpu: 250m
-    memory: 256Mi
-
-env: {{.EnvVars}}
-`
-	
-	data := struct {
-		*models.Project
-		IngressEnabled bool
-		Host          string
-		EnvVars       string
-	}{
-		Project:        project,
-		IngressEnabled: environment.Domain != "",
-		Host:          fmt.Sprintf("%s.%s", project.Name, environment.Domain),
-		EnvVars:       project.EnvVars,
+// helmValues is the values.yaml of a generated chart.
+type helmValues struct {
+	ReplicaCount int              `json:"replicaCount"`
+	Image        helmImage        `json:"image"`
+	Service      helmService      `json:"service"`
+	Ingress      helmIngress      `json:"ingress"`
+	Resources    models.Resources `json:"resources"`
+	Env          []helmEnvVar     `json:"env"`
+	EnvFrom      []helmEnvFrom    `json:"envFrom,omitempty"`
+	// Labels are added to the Deployment
+	Labels map[string]string `json:"labels,omitempty"`
+	// PodAnnotations record the deployment, and roll the pods when a
+	// referenced secret changes
+	PodAnnotations map[string]string `json:"podAnnotations,omitempty"`
+	ExternalSecret *helmExternalSecret `json:"externalSecret,omitempty"`
+	Probes       Probes           `json:"probes"`
+	Autoscaling  helmAutoscaling  `json:"autoscaling"`
+}
+
+type helmImage struct {
+	Repository string `json:"repository"`
+	PullPolicy string `json:"pullPolicy"`
+	Tag        string `json:"tag"`
+}
+
+type helmService struct {
+	Type string `json:"type"`
+	Port int    `json:"port"`
+}
+
+type helmIngress struct {
+	Enabled     bool              `json:"enabled"`
+	ClassName   string            `json:"className"`
+	Annotations map[string]string `json:"annotations"`
+	Hosts       []helmIngressHost `json:"hosts"`
+	TLS         []interface{}     `json:"tls"`
+}
+
+type helmIngressHost struct {
+	Host  string            `json:"host"`
+	Paths []helmIngressPath `json:"paths"`
+}
+
+type helmIngressPath struct {
+	Path     string `json:"path"`
+	PathType string `json:"pathType"`
+}
+
+type helmEnvVar struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type helmEnvFrom struct {
+	SecretRef helmSecretRef `json:"secretRef"`
+}
+
+type helmSecretRef struct {
+	Name string `json:"name"`
+}
+
+type helmExternalSecret struct {
+	Name           string                   `json:"name"`
+	SecretStoreRef helmSecretStoreRef       `json:"secretStoreRef"`
+	Data           []helmExternalSecretData `json:"data"`
+}
+
+type helmSecretStoreRef struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+}
+
+type helmExternalSecretData struct {
+	SecretKey string        `json:"secretKey"`
+	RemoteRef helmRemoteRef `json:"remoteRef"`
+}
+
+type helmRemoteRef struct {
+	Key      string `json:"key"`
+	Property string `json:"property"`
+}
+
+type helmAutoscaling struct {
+	Enabled     bool                       `json:"enabled"`
+	MinReplicas int                        `json:"minReplicas,omitempty"`
+	MaxReplicas int                        `json:"maxReplicas,omitempty"`
+	Metrics     []autoscalingv2.MetricSpec `json:"metrics,omitempty"`
+}
+
+func (s *HelmService) generateValues(chartDir string, project *models.Project, deployment *models.Deployment, settings *DeploymentSettings, secret *ChartSecret) error {
+	values := helmValues{
+		ReplicaCount: settings.Replicas,
+		Image: helmImage{
+			Repository: project.Name,
+			PullPolicy: "IfNotPresent",
+			Tag:        "latest",
+		},
+		Service: helmService{
+			Type: "ClusterIP",
+			Port: project.Port,
+		},
+		Ingress: helmIngress{
+			Enabled:     settings.Host != "",
+			ClassName:   "nginx",
+			Annotations: map[string]string{},
+			Hosts: []helmIngressHost{{
+				Host:  settings.Host,
+				Paths: []helmIngressPath{{Path: "/", PathType: "Prefix"}},
+			}},
+			TLS: []interface{}{},
+		},
+		Resources:   settings.Resources,
+		Env:         []helmEnvVar{},
+		Labels: map[string]string{
+			ProjectIDLabel: strconv.FormatUint(uint64(project.ID), 10),
+		},
+		PodAnnotations: map[string]string{
+			DeploymentIDAnnotation: strconv.FormatUint(uint64(deployment.ID), 10),
+		},
+		Probes:      settings.Probes(),
+		Autoscaling: helmAutoscaling{
+			Enabled: settings.Autoscaling.IsEnabled(),
+		},
 	}
-	
-	tmpl, err := template.New("values").Parse(valuesYaml)
-	if err != nil {
-		return err
+
+	if values.Autoscaling.Enabled {
+		metrics, err := settings.AutoscalerMetrics()
+		if err != nil {
+			return fmt.Errorf("invalid autoscaling: %w", err)
+		}
+		values.Autoscaling.MinReplicas = settings.Autoscaling.MinReplicas
+		values.Autoscaling.MaxReplicas = settings.Autoscaling.MaxReplicas
+		values.Autoscaling.Metrics = metrics
 	}
-	
-	file, err := os.Create(filepath.Join(chartDir, "values.yaml"))
+
+	// A secret replaces a plain env var of the same name
+	secretKeys := map[string]bool{}
+	if secret != nil {
+		if secret.Name != "" {
+			for _, key := range secret.Keys {
+				secretKeys[key] = true
+			}
+			values.EnvFrom = append(values.EnvFrom, helmEnvFrom{SecretRef: helmSecretRef{Name: secret.Name}})
+			values.PodAnnotations["checksum/secrets"] = secret.Checksum
+		}
+		if external := secret.External; external != nil {
+			for _, data := range external.Data {
+				secretKeys[data.SecretKey] = true
+			}
+			values.EnvFrom = append(values.EnvFrom, helmEnvFrom{SecretRef: helmSecretRef{Name: external.Name}})
+			values.PodAnnotations["checksum/external-secrets"] = external.Checksum
+			values.ExternalSecret = &helmExternalSecret{
+				Name:           external.Name,
+				SecretStoreRef: external.Store,
+				Data:           external.Data,
+			}
+		}
+	}
+
+	for _, name := range settings.SortedEnvVars() {
+		if secretKeys[name] {
+			continue
+		}
+		values.Env = append(values.Env, helmEnvVar{Name: name, Value: settings.EnvVars[name]})
+	}
+
+	data, err := yaml.Marshal(values)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to render values: %w", err)
 	}
-	defer file.Close()
-	
-	return tmpl.Execute(file, data)
+
+	return os.WriteFile(filepath.Join(chartDir, "values.yaml"), data, 0644)
 }
 
 func (s *HelmService) generateDeploymentTemplate(chartDir string, project *models.Project) error {
@@ -200,13 +394,22 @@ metadata:
   name: {{ include "chart.fullname" . }}
   labels:
     {{- include "chart.labels" . | nindent 4 }}
+    {{- with .Values.labels }}
+    {{- toYaml . | nindent 4 }}
+    {{- end }}
 spec:
+  {{- if not .Values.autoscaling.enabled }}
   replicas: {{ .Values.replicaCount }}
+  {{- end }}
   selector:
     matchLabels:
       {{- include "chart.selectorLabels" . | nindent 6 }}
   template:
     metadata:
+      {{- with .Values.podAnnotations }}
+      annotations:
+        {{- toYaml . | nindent 8 }}
+      {{- end }}
       labels:
         {{- include "chart.selectorLabels" . | nindent 8 }}
     spec:
@@ -218,14 +421,26 @@ spec:
             - name: http
               containerPort: {{ .Values.service.port }}
               protocol: TCP
+          {{- with .Values.env }}
+          env:
+            {{- toYaml . | nindent 12 }}
+          {{- end }}
+          {{- with .Values.envFrom }}
+          envFrom:
+            {{- toYaml . | nindent 12 }}
+          {{- end }}
+          {{- with .Values.probes.liveness }}
           livenessProbe:
-            httpGet:
-              path: /
-              port: http
+            {{- toYaml . | nindent 12 }}
+          {{- end }}
+          {{- with .Values.probes.readiness }}
           readinessProbe:
-            httpGet:
-              path: /
-              port: http
+            {{- toYaml . | nindent 12 }}
+          {{- end }}
+          {{- with .Values.probes.startup }}
+          startupProbe:
+            {{- toYaml . | nindent 12 }}
+          {{- end }}
           resources:
             {{- toYaml .Values.resources | nindent 12 }}
 `
@@ -254,6 +469,51 @@ spec:
 	return os.WriteFile(filepath.Join(chartDir, "templates", "service.yaml"), []byte(serviceTemplate), 0644)
 }
 
+func (s *HelmService) generateAutoscalerTemplate(chartDir string) error {
+	autoscalerTemplate := `{{- if .Values.autoscaling.enabled }}
+apiVersion: autoscaling/v2
+kind: HorizontalPodAutoscaler
+metadata:
+  name: {{ include "chart.fullname" . }}
+  labels:
+    {{- include "chart.labels" . | nindent 4 }}
+spec:
+  scaleTargetRef:
+    apiVersion: apps/v1
+    kind: Deployment
+    name: {{ include "chart.fullname" . }}
+  minReplicas: {{ .Values.autoscaling.minReplicas }}
+  maxReplicas: {{ .Values.autoscaling.maxReplicas }}
+  metrics:
+    {{- toYaml .Values.autoscaling.metrics | nindent 4 }}
+{{- end }}
+`
+	
+	return os.WriteFile(filepath.Join(chartDir, "templates", "hpa.yaml"), []byte(autoscalerTemplate), 0644)
+}
+
+func (s *HelmService) generateExternalSecretTemplate(chartDir string) error {
+	externalSecretTemplate := `{{- with .Values.externalSecret }}
+apiVersion: external-secrets.io/v1beta1
+kind: ExternalSecret
+metadata:
+  name: {{ .name }}
+  labels:
+    {{- include "chart.labels" $ | nindent 4 }}
+spec:
+  refreshInterval: 1h
+  secretStoreRef:
+    {{- toYaml .secretStoreRef | nindent 4 }}
+  target:
+    name: {{ .name }}
+  data:
+    {{- toYaml .data | nindent 4 }}
+{{- end }}
+`
+	
+	return os.WriteFile(filepath.Join(chartDir, "templates", "externalsecret.yaml"), []byte(externalSecretTemplate), 0644)
+}
+
 func (s *HelmService) generateIngressTemplate(chartDir string, project *models.Project, environment *models.Environment) error {
 	ingressTemplate := `{{- if .Values.ingress.enabled -}}
 apiVersion: networking.k8s.io/v1
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/plate/service/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// deliveryRetention is how long webhook deliveries are remembered.
+// Providers redeliver within hours, if at all.
+const deliveryRetention = 7 * 24 * time.Hour
+
+// WebhookService keeps track of the webhook deliveries that were handled.
+type WebhookService struct {
+	db *gorm.DB
+}
+
+func NewWebhookService(db *gorm.DB) *WebhookService {
+	return &WebhookService{db: db}
+}
+
+// Record remembers a delivery, and reports whether it was recorded before,
+// in which case it must not be handled again.
+func (s *WebhookService) Record(provider, deliveryID, event string) (duplicate bool, err error) {
+	delivery := &models.WebhookDelivery{Provider: provider, DeliveryID: deliveryID, Event: event}
+	result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(delivery)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to record webhook delivery %s: %w", deliveryID, result.Error)
+	}
+
+	if err := s.db.Where("created_at < ?", time.Now().Add(-deliveryRetention)).Delete(&models.WebhookDelivery{}).Error; err != nil {
+		fmt.Printf("Warning: Failed to remove old webhook deliveries: %v\n", err)
+	}
+	return result.RowsAffected == 0, nil
+}
+
+// Forget removes a delivery that could not be handled, so that the
+// provider can redeliver it.
+func (s *WebhookService) Forget(provider, deliveryID string) error {
+	return s.db.Where("provider = ? AND delivery_id = ?", provider, deliveryID).Delete(&models.WebhookDelivery{}).Error
+}
+
+// BranchEnvironments returns the names of the environments pushes to
+// branch are deployed to, as set by the branch of their overrides.
+func BranchEnvironments(project *models.Project, branch string) []string {
+	if branch == "" {
+		return nil
+	}
+
+	var names []string
+	for name, override := range project.Environments {
+		if override.Branch == branch {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
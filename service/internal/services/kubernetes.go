@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/plate/service/internal/config"
@@ -12,6 +13,7 @@ import (
 	networkingv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -20,8 +22,10 @@ import (
 
 type KubernetesService struct {
 	config    config.Kubernetes
-	clientset *kubernetes.Clientset
+	clientset kubernetes.Interface
 	restConfig *rest.Config
+	// cache serves reads once its informers have synced; see StartCache
+	cache *kubernetesCache
 }
 
 func NewKubernetesService(cfg config.Kubernetes) *KubernetesService {
@@ -69,7 +73,7 @@ func (s *KubernetesService) Initialize() error {
 	return nil
 }
 
-func (s *KubernetesService) GetClientset() *kubernetes.Clientset {
+func (s *KubernetesService) GetClientset() kubernetes.Interface {
 	return s.clientset
 }
 
@@ -102,14 +106,84 @@ func (s *KubernetesService) CreateNamespace(name string) error {
 }
 
 func (s *KubernetesService) GetPods(namespace string) ([]corev1.Pod, error) {
-	pods, err := s.clientset.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{})
+	return s.ListPods(namespace, "")
+}
+
+// ListPods returns the pods of a namespace matching a label selector, from
+// the cache when it has synced.
+func (s *KubernetesService) ListPods(namespace, selector string) ([]corev1.Pod, error) {
+	if scope := s.cache.scope(namespace); scope != nil {
+		parsed, err := parseSelector(selector)
+		if err != nil {
+			return nil, err
+		}
+		cached, err := scope.pods.List(parsed)
+		if err != nil {
+			return nil, err
+		}
+		pods := make([]corev1.Pod, 0, len(cached))
+		for _, pod := range cached {
+			pods = append(pods, *pod)
+		}
+		sort.Slice(pods, func(i, j int) bool { return pods[i].Name < pods[j].Name })
+		return pods, nil
+	}
+
+	pods, err := s.clientset.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: selector})
 	if err != nil {
 		return nil, err
 	}
-
 	return pods.Items, nil
 }
 
+// ListDeployments returns the deployments of a namespace matching a label
+// selector, from the cache when it has synced.
+func (s *KubernetesService) ListDeployments(namespace, selector string) ([]appsv1.Deployment, error) {
+	if scope := s.cache.scope(namespace); scope != nil {
+		parsed, err := parseSelector(selector)
+		if err != nil {
+			return nil, err
+		}
+		cached, err := scope.deployments.List(parsed)
+		if err != nil {
+			return nil, err
+		}
+		deployments := make([]appsv1.Deployment, 0, len(cached))
+		for _, deployment := range cached {
+			deployments = append(deployments, *deployment)
+		}
+		sort.Slice(deployments, func(i, j int) bool { return deployments[i].Name < deployments[j].Name })
+		return deployments, nil
+	}
+
+	deployments, err := s.clientset.AppsV1().Deployments(namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	return deployments.Items, nil
+}
+
+// GetDeployment returns a deployment, from the cache when it has synced.
+// The result must not be modified; updates get the deployment from the API
+// server instead.
+func (s *KubernetesService) GetDeployment(namespace, name string) (*appsv1.Deployment, error) {
+	if scope := s.cache.scope(namespace); scope != nil {
+		return scope.deployments.Get(name)
+	}
+	return s.clientset.AppsV1().Deployments(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+}
+
+// GetNamespace returns a namespace, from the cache if it is managed by
+// plate and the cache has synced.
+func (s *KubernetesService) GetNamespace(name string) (*corev1.Namespace, error) {
+	if s.cache != nil && s.cache.namespacesSynced() {
+		if ns, err := s.cache.namespaces.Get(name); err == nil {
+			return ns, nil
+		}
+	}
+	return s.clientset.CoreV1().Namespaces().Get(context.TODO(), name, metav1.GetOptions{})
+}
+
 func (s *KubernetesService) GetServices(namespace string) ([]corev1.Service, error) {
 	services, err := s.clientset.CoreV1().Services(namespace).List(context.TODO(), metav1.ListOptions{})
 	if err != nil {
@@ -119,6 +193,43 @@ func (s *KubernetesService) GetServices(namespace string) ([]corev1.Service, err
 	return services.Items, nil
 }
 
+// ApplySecret creates or replaces an Opaque secret.
+func (s *KubernetesService) ApplySecret(namespace, name string, labels map[string]string, data map[string][]byte) error {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: data,
+	}
+
+	secrets := s.clientset.CoreV1().Secrets(namespace)
+	existing, err := secrets.Get(context.TODO(), name, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		_, err = secrets.Create(context.TODO(), secret, metav1.CreateOptions{})
+	case err == nil:
+		secret.ResourceVersion = existing.ResourceVersion
+		_, err = secrets.Update(context.TODO(), secret, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to apply secret %s/%s: %w", namespace, name, err)
+	}
+
+	return nil
+}
+
+// DeleteSecret deletes a secret if it exists.
+func (s *KubernetesService) DeleteSecret(namespace, name string) error {
+	err := s.clientset.CoreV1().Secrets(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete secret %s/%s: %w", namespace, name, err)
+	}
+	return nil
+}
+
 // Deployment management methods
 func (s *KubernetesService) ScaleDeployment(namespace, name string, replicas int32) error {
 	deployment, err := s.clientset.AppsV1().Deployments(namespace).Get(context.TODO(), name, metav1.GetOptions{})
@@ -167,14 +278,12 @@ func (s *KubernetesService) RestartDeployment(namespace, name string) error {
 }
 
 func (s *KubernetesService) GetDeploymentStatus(namespace, name string) (*DeploymentStatus, error) {
-	deployment, err := s.clientset.AppsV1().Deployments(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	deployment, err := s.GetDeployment(namespace, name)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get deployment %s/%s: %w", namespace, name, err)
 	}
 
-	pods, err := s.clientset.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{
-		LabelSelector: fmt.Sprintf("app=%s", name),
-	})
+	pods, err := s.ListPods(namespace, fmt.Sprintf("app=%s", name))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get pods for deployment %s/%s: %w", namespace, name, err)
 	}
@@ -186,18 +295,29 @@ func (s *KubernetesService) GetDeploymentStatus(namespace, name string) (*Deploy
 		routes = []IngressRoute{}
 	}
 
+	// An autoscaler sets the desired replicas; its status explains why
+	autoscaler, err := s.FindAutoscaler(namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
 	status := &DeploymentStatus{
 		Name:              deployment.Name,
 		Namespace:         deployment.Namespace,
 		DesiredReplicas:   *deployment.Spec.Replicas,
+		CurrentReplicas:   deployment.Status.Replicas,
+		UpdatedReplicas:   deployment.Status.UpdatedReplicas,
 		ReadyReplicas:     deployment.Status.ReadyReplicas,
 		AvailableReplicas: deployment.Status.AvailableReplicas,
 		Conditions:        deployment.Status.Conditions,
-		Pods:              make([]PodStatus, 0, len(pods.Items)),
+		Pods:              make([]PodStatus, 0, len(pods)),
 		Routes:            routes,
 	}
+	if autoscaler != nil {
+		status.Autoscaler = autoscalerStatus(autoscaler)
+	}
 
-	for _, pod := range pods.Items {
+	for _, pod := range pods {
 		podStatus := PodStatus{
 			Name:    pod.Name,
 			Phase:   string(pod.Status.Phase),
@@ -225,7 +345,7 @@ func (s *KubernetesService) GetDeploymentStatus(namespace, name string) (*Deploy
 // GetIngressRoutes retrieves ingress routes for a specific deployment
 func (s *KubernetesService) GetIngressRoutes(namespace, deploymentName string) ([]IngressRoute, error) {
 	// Get all ingresses in the namespace
-	ingresses, err := s.clientset.NetworkingV1().Ingresses(namespace).List(context.TODO(), metav1.ListOptions{})
+	ingresses, err := s.listIngresses(namespace)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get ingresses in namespace %s: %w", namespace, err)
 	}
@@ -233,7 +353,7 @@ func (s *KubernetesService) GetIngressRoutes(namespace, deploymentName string) (
 	var routes []IngressRoute
 
 	// Look for ingresses that reference services related to this deployment
-	for _, ingress := range ingresses.Items {
+	for _, ingress := range ingresses {
 		// Check if ingress is related to this deployment by labels or service names
 		if s.isIngressRelatedToDeployment(ingress, deploymentName) {
 			for _, rule := range ingress.Spec.Rules {
@@ -262,6 +382,27 @@ func (s *KubernetesService) GetIngressRoutes(namespace, deploymentName string) (
 	return routes, nil
 }
 
+func (s *KubernetesService) listIngresses(namespace string) ([]networkingv1.Ingress, error) {
+	if scope := s.cache.scope(namespace); scope != nil {
+		cached, err := scope.ingresses.List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+		ingresses := make([]networkingv1.Ingress, 0, len(cached))
+		for _, ingress := range cached {
+			ingresses = append(ingresses, *ingress)
+		}
+		sort.Slice(ingresses, func(i, j int) bool { return ingresses[i].Name < ingresses[j].Name })
+		return ingresses, nil
+	}
+
+	ingresses, err := s.clientset.NetworkingV1().Ingresses(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return ingresses.Items, nil
+}
+
 // isIngressRelatedToDeployment checks if an ingress is related to a deployment
 func (s *KubernetesService) isIngressRelatedToDeployment(ingress networkingv1.Ingress, deploymentName string) bool {
 	// Check labels
@@ -292,11 +433,14 @@ type DeploymentStatus struct {
 	Name              string                     `json:"name"`
 	Namespace         string                     `json:"namespace"`
 	DesiredReplicas   int32                      `json:"desired_replicas"`
+	CurrentReplicas   int32                      `json:"current_replicas"`
+	UpdatedReplicas   int32                      `json:"updated_replicas"`
 	ReadyReplicas     int32                      `json:"ready_replicas"`
 	AvailableReplicas int32                      `json:"available_replicas"`
 	Conditions        []appsv1.DeploymentCondition `json:"conditions"`
 	Pods              []PodStatus                `json:"pods"`
 	Routes            []IngressRoute             `json:"routes"`
+	Autoscaler        *AutoscalerStatus          `json:"autoscaler,omitempty"`
 }
 
 type PodStatus struct {
@@ -2,8 +2,11 @@ package services
 
 import (
 	"fmt"
+	"time"
+
 	"github.com/plate/service/internal/config"
 	"gorm.io/gorm"
+	"k8s.io/apimachinery/pkg/util/wait"
 )
 
 type Manager struct {
@@ -12,10 +15,14 @@ type Manager struct {
 	Project    *ProjectService
 	Deployment *DeploymentService
 	Environment *EnvironmentService
+	Secrets    *SecretService
+	Previews   *PreviewService
+	Webhooks   *WebhookService
+	Events     *EventBus
 	Kubernetes *KubernetesService
 	ArgoCD     *ArgoCDService
 	Helm       *HelmService
-	Gitea      *GiteaService
+	Git        *GitProviders
 }
 
 func NewManager(cfg *config.Config, db *gorm.DB) *Manager {
@@ -24,17 +31,35 @@ func NewManager(cfg *config.Config, db *gorm.DB) *Manager {
 		db:     db,
 	}
 
-	// Initialize services (skip database-dependent services for development)
-	if db != nil {
-		manager.Project = NewProjectService(db)
-		manager.Environment = NewEnvironmentService(db)
-		manager.Deployment = NewDeploymentService(db, nil, nil, nil, nil)
-	}
-	
 	manager.Kubernetes = NewKubernetesService(cfg.Kubernetes)
 	manager.ArgoCD = NewArgoCDService(cfg.ArgoCD)
 	manager.Helm = NewHelmService(cfg.Helm)
-	manager.Gitea = NewGiteaService(cfg.Gitea)
+	manager.Events = NewEventBus()
+	manager.Git = NewGitProviders(cfg.GitProvider,
+		NewGiteaService(cfg.Gitea),
+		NewGitHubService(cfg.GitHub),
+		NewGitLabService(cfg.GitLab),
+	)
+
+	// Database-dependent services are only available with a database
+	if db != nil {
+		manager.Project = NewProjectService(db)
+		manager.Webhooks = NewWebhookService(db)
+		manager.Environment = NewEnvironmentService(db, manager.Kubernetes, cfg.Environments)
+
+		secrets, err := NewSecretService(db, cfg.Secrets)
+		if err != nil {
+			fmt.Printf("Warning: %v\n", err)
+			fmt.Println("Continuing without secrets...")
+		}
+		if cfg.Vault.Address != "" {
+			secrets.RegisterProvider(NewVaultProvider(cfg.Vault))
+		}
+		manager.Secrets = secrets
+
+		manager.Deployment = NewDeploymentService(db, manager.Kubernetes, manager.ArgoCD, manager.Helm, manager.Git, manager.Secrets, manager.Events, cfg.DashboardURL)
+		manager.Previews = NewPreviewService(db, manager.Environment, manager.Deployment, manager.Git, cfg.Environments.Previews)
+	}
 
 	return manager
 }
@@ -50,11 +75,84 @@ func (m *Manager) Initialize() error {
 			fmt.Println("Continuing without Kubernetes integration...")
 		} else {
 			fmt.Println("Kubernetes service initialized successfully")
+			m.reconcileEnvironments()
+			m.Kubernetes.StartCache(m.Events, wait.NeverStop)
 		}
 	}
 	
+	if m.Previews != nil && m.config.Environments.Previews.TTL > 0 {
+		go m.expirePreviews()
+	}
+
+	if m.Deployment != nil && m.config.ReconcileInterval > 0 {
+		go m.reconcileDeployments(m.config.ReconcileInterval)
+	}
+
 	// For development, skip other service initializations
 	// TODO: Enable ArgoCD, Helm, and Gitea when implementing real integrations
 	
 	return nil
-}
\ No newline at end of file
+}
+
+// reconcileEnvironments provisions the namespaces of the stored
+// environments, such as the defaults seeded into a new database.
+func (m *Manager) reconcileEnvironments() {
+	if m.Environment == nil {
+		return
+	}
+
+	fmt.Println("Reconciling environments...")
+	// Namespaces are only adopted when asked to through the API
+	report, err := m.Environment.Reconcile(false, false)
+	if err != nil {
+		fmt.Printf("Warning: Failed to reconcile environments: %v\n", err)
+		return
+	}
+	for name, problem := range report.Errors {
+		fmt.Printf("Warning: Failed to provision environment %s: %v\n", name, problem)
+	}
+	for _, namespace := range report.Orphaned {
+		fmt.Printf("Warning: Namespace %s is labeled managed-by=plate but belongs to no environment\n", namespace)
+	}
+}
+
+// expirePreviews periodically tears down the previews whose TTL has
+// passed.
+func (m *Manager) expirePreviews() {
+	ticker := time.NewTicker(5 * time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		expired, err := m.Previews.Expire()
+		for _, name := range expired {
+			fmt.Printf("Removed expired preview environment %s\n", name)
+		}
+		if err != nil {
+			fmt.Printf("Warning: Failed to remove expired previews: %v\n", err)
+		}
+	}
+}
+
+// reconcileDeployments periodically brings the recorded state of
+// deployments in line with the cluster.
+func (m *Manager) reconcileDeployments(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		report, err := m.Deployment.Reconcile(false)
+		if err != nil {
+			fmt.Printf("Warning: Failed to reconcile deployments: %v\n", err)
+			continue
+		}
+		for _, id := range report.Interrupted {
+			fmt.Printf("Failed interrupted deployment %d\n", id)
+		}
+		for _, id := range sortedIDs(report.Corrected) {
+			fmt.Printf("Corrected status of deployment %d to %s\n", id, report.Corrected[id])
+		}
+		for _, id := range sortedIDs(report.Errors) {
+			fmt.Printf("Warning: Failed to reconcile deployment %d: %s\n", id, report.Errors[id])
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/plate/service/internal/config"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Labels on the namespaces of environments
+const (
+	ManagedByLabel   = "managed-by"
+	EnvironmentLabel = "environment"
+)
+
+// CreatedForAnnotation marks a namespace Plate created, naming the
+// environment it was created for. Only namespaces with it are ever deleted;
+// labels alone are not enough, since adopted namespaces carry them too.
+const CreatedForAnnotation = "plate/created-for"
+
+// Names of the objects Plate puts in every environment's namespace
+const (
+	namespaceQuotaName         = "plate-quota"
+	namespaceLimitRangeName    = "plate-limits"
+	namespaceNetworkPolicyName = "plate-ingress"
+)
+
+// NamespaceNotManagedError is returned when Plate is asked to take over or
+// remove a namespace that belongs to something else.
+type NamespaceNotManagedError struct {
+	Namespace string
+	Reason    string
+}
+
+func (e *NamespaceNotManagedError) Error() string {
+	return fmt.Sprintf("namespace %s is not managed by plate for this environment: %s", e.Namespace, e.Reason)
+}
+
+// ProvisionNamespace creates the namespace of an environment and brings its
+// ResourceQuota, LimitRange and NetworkPolicy in line with defaults. Objects
+// for disabled defaults are removed, so it can be run again to undo drift.
+// An existing namespace Plate did not create is only taken over with adopt.
+func (s *KubernetesService) ProvisionNamespace(namespace, environment string, adopt bool, defaults config.Environments) error {
+	if err := s.applyNamespace(namespace, environment, adopt); err != nil {
+		return err
+	}
+
+	labels := map[string]string{ManagedByLabel: "plate", EnvironmentLabel: environment}
+	core := s.clientset.CoreV1()
+
+	hard, err := quantities(map[corev1.ResourceName]string{
+		corev1.ResourceRequestsCPU:    defaults.Quota.RequestsCPU,
+		corev1.ResourceRequestsMemory: defaults.Quota.RequestsMemory,
+		corev1.ResourceLimitsCPU:      defaults.Quota.LimitsCPU,
+		corev1.ResourceLimitsMemory:   defaults.Quota.LimitsMemory,
+		corev1.ResourcePods:           defaults.Quota.Pods,
+	})
+	if err != nil {
+		return fmt.Errorf("invalid environment quota: %w", err)
+	}
+	if len(hard) > 0 {
+		err = applyObject[*corev1.ResourceQuota](core.ResourceQuotas(namespace), &corev1.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: namespaceQuotaName, Namespace: namespace, Labels: labels},
+			Spec:       corev1.ResourceQuotaSpec{Hard: hard},
+		})
+	} else {
+		err = core.ResourceQuotas(namespace).Delete(context.TODO(), namespaceQuotaName, metav1.DeleteOptions{})
+	}
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to apply resource quota in namespace %s: %w", namespace, err)
+	}
+
+	defaultRequest, err := quantities(map[corev1.ResourceName]string{
+		corev1.ResourceCPU:    defaults.DefaultRequest.CPU,
+		corev1.ResourceMemory: defaults.DefaultRequest.Memory,
+	})
+	if err != nil {
+		return fmt.Errorf("invalid environment default request: %w", err)
+	}
+	defaultLimit, err := quantities(map[corev1.ResourceName]string{
+		corev1.ResourceCPU:    defaults.DefaultLimit.CPU,
+		corev1.ResourceMemory: defaults.DefaultLimit.Memory,
+	})
+	if err != nil {
+		return fmt.Errorf("invalid environment default limit: %w", err)
+	}
+	if len(defaultRequest) > 0 || len(defaultLimit) > 0 {
+		err = applyObject[*corev1.LimitRange](core.LimitRanges(namespace), &corev1.LimitRange{
+			ObjectMeta: metav1.ObjectMeta{Name: namespaceLimitRangeName, Namespace: namespace, Labels: labels},
+			Spec: corev1.LimitRangeSpec{Limits: []corev1.LimitRangeItem{{
+				Type:           corev1.LimitTypeContainer,
+				Default:        defaultLimit,
+				DefaultRequest: defaultRequest,
+			}}},
+		})
+	} else {
+		err = core.LimitRanges(namespace).Delete(context.TODO(), namespaceLimitRangeName, metav1.DeleteOptions{})
+	}
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to apply limit range in namespace %s: %w", namespace, err)
+	}
+
+	policies := s.clientset.NetworkingV1().NetworkPolicies(namespace)
+	if defaults.NetworkPolicy {
+		err = applyObject[*networkingv1.NetworkPolicy](policies, ingressPolicy(namespace, labels, defaults.IngressNamespace))
+	} else {
+		err = policies.Delete(context.TODO(), namespaceNetworkPolicyName, metav1.DeleteOptions{})
+	}
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to apply network policy in namespace %s: %w", namespace, err)
+	}
+
+	return nil
+}
+
+// applyNamespace creates a namespace labeled for environment and marked as
+// created by Plate. An existing namespace is refused unless it is labeled
+// for environment already, or adopt is set and no other environment claims
+// it; an adopted namespace is labeled but not marked, so it is never deleted.
+func (s *KubernetesService) applyNamespace(name, environment string, adopt bool) error {
+	if reservedNamespace(name) {
+		return &NamespaceNotManagedError{Namespace: name, Reason: "it is reserved for Kubernetes"}
+	}
+
+	namespaces := s.clientset.CoreV1().Namespaces()
+	existing, err := namespaces.Get(context.TODO(), name, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		_, err = namespaces.Create(context.TODO(), &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Labels:      map[string]string{ManagedByLabel: "plate", EnvironmentLabel: environment},
+				Annotations: map[string]string{CreatedForAnnotation: environment},
+			},
+		}, metav1.CreateOptions{})
+	case err == nil:
+		managed := existing.Labels[ManagedByLabel] == "plate"
+		if claimed := existing.Labels[EnvironmentLabel]; managed && claimed != "" && claimed != environment {
+			return &NamespaceNotManagedError{Namespace: name, Reason: fmt.Sprintf("it belongs to environment %s", claimed)}
+		}
+		if managed && existing.Labels[EnvironmentLabel] == environment {
+			return nil
+		}
+		if !managed && !adopt {
+			return &NamespaceNotManagedError{Namespace: name, Reason: "it already exists and was not created by plate; set adopt_namespace to provision it"}
+		}
+		if existing.Labels == nil {
+			existing.Labels = map[string]string{}
+		}
+		existing.Labels[ManagedByLabel] = "plate"
+		existing.Labels[EnvironmentLabel] = environment
+		_, err = namespaces.Update(context.TODO(), existing, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to provision namespace %s: %w", name, err)
+	}
+	return nil
+}
+
+// DeleteNamespace deletes the namespace of an environment, with everything
+// running in it. Only namespaces Plate created for the environment are
+// deleted; adopted and reserved namespaces are refused.
+func (s *KubernetesService) DeleteNamespace(name, environment string) error {
+	if reservedNamespace(name) {
+		return &NamespaceNotManagedError{Namespace: name, Reason: "it is reserved for Kubernetes"}
+	}
+
+	namespaces := s.clientset.CoreV1().Namespaces()
+	existing, err := namespaces.Get(context.TODO(), name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get namespace %s: %w", name, err)
+	}
+
+	switch createdFor := existing.Annotations[CreatedForAnnotation]; {
+	case createdFor == "":
+		return &NamespaceNotManagedError{Namespace: name, Reason: "it was not created by plate"}
+	case createdFor != environment:
+		return &NamespaceNotManagedError{Namespace: name, Reason: fmt.Sprintf("it was created for environment %s", createdFor)}
+	}
+
+	err = namespaces.Delete(context.TODO(), name, metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete namespace %s: %w", name, err)
+	}
+	return nil
+}
+
+// reservedNamespace tells whether a namespace belongs to Kubernetes itself,
+// such as default and kube-system. Plate never provisions or deletes them.
+func reservedNamespace(name string) bool {
+	return name == metav1.NamespaceDefault || strings.HasPrefix(name, "kube-")
+}
+
+// ManagedNamespaces returns the namespaces labeled as managed by Plate.
+func (s *KubernetesService) ManagedNamespaces() ([]corev1.Namespace, error) {
+	if namespaces, ok := s.cache.managedNamespaces(); ok {
+		return namespaces, nil
+	}
+
+	namespaces, err := s.clientset.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{
+		LabelSelector: ManagedByLabel + "=plate",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+	return namespaces.Items, nil
+}
+
+// ingressPolicy admits traffic to every pod of namespace from the
+// namespace itself and from the ingress controller's namespace.
+func ingressPolicy(namespace string, labels map[string]string, ingressNamespace string) *networkingv1.NetworkPolicy {
+	peers := []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{}}}
+	if ingressNamespace != "" {
+		peers = append(peers, networkingv1.NetworkPolicyPeer{
+			NamespaceSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{corev1.LabelMetadataName: ingressNamespace},
+			},
+		})
+	}
+
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: namespaceNetworkPolicyName, Namespace: namespace, Labels: labels},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress:     []networkingv1.NetworkPolicyIngressRule{{From: peers}},
+		},
+	}
+}
+
+// quantities parses the set values of a resource list.
+func quantities(values map[corev1.ResourceName]string) (corev1.ResourceList, error) {
+	list := corev1.ResourceList{}
+	for name, value := range values {
+		if value == "" {
+			continue
+		}
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		list[name] = quantity
+	}
+	return list, nil
+}
+
+// objectClient is the part of a typed client-go client applyObject needs.
+type objectClient[T metav1.Object] interface {
+	Get(ctx context.Context, name string, opts metav1.GetOptions) (T, error)
+	Create(ctx context.Context, object T, opts metav1.CreateOptions) (T, error)
+	Update(ctx context.Context, object T, opts metav1.UpdateOptions) (T, error)
+}
+
+// applyObject creates an object, or replaces it if it exists.
+func applyObject[T metav1.Object](client objectClient[T], object T) error {
+	existing, err := client.Get(context.TODO(), object.GetName(), metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		_, err = client.Create(context.TODO(), object, metav1.CreateOptions{})
+	case err == nil:
+		object.SetResourceVersion(existing.GetResourceVersion())
+		_, err = client.Update(context.TODO(), object, metav1.UpdateOptions{})
+	}
+	return err
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/plate/service/internal/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var testEnvironmentDefaults = config.Environments{
+	Quota:         config.Quota{RequestsCPU: "2", Pods: "10"},
+	DefaultLimit:  config.Compute{CPU: "500m", Memory: "512Mi"},
+	NetworkPolicy: true,
+}
+
+func newTestKubernetesService(objects ...runtime.Object) *KubernetesService {
+	return &KubernetesService{clientset: fake.NewSimpleClientset(objects...)}
+}
+
+func testNamespace(name string, labels, annotations map[string]string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels, Annotations: annotations}}
+}
+
+func getTestNamespace(t *testing.T, s *KubernetesService, name string) *corev1.Namespace {
+	t.Helper()
+	ns, err := s.clientset.CoreV1().Namespaces().Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("namespace %s: %v", name, err)
+	}
+	return ns
+}
+
+func TestProvisionNamespaceCreates(t *testing.T) {
+	s := newTestKubernetesService()
+	if err := s.ProvisionNamespace("plate-qa", "qa", false, testEnvironmentDefaults); err != nil {
+		t.Fatal(err)
+	}
+
+	ns := getTestNamespace(t, s, "plate-qa")
+	if ns.Labels[ManagedByLabel] != "plate" || ns.Labels[EnvironmentLabel] != "qa" {
+		t.Errorf("labels = %v", ns.Labels)
+	}
+	if ns.Annotations[CreatedForAnnotation] != "qa" {
+		t.Errorf("annotations = %v", ns.Annotations)
+	}
+
+	ctx := context.TODO()
+	if _, err := s.clientset.CoreV1().ResourceQuotas("plate-qa").Get(ctx, namespaceQuotaName, metav1.GetOptions{}); err != nil {
+		t.Errorf("resource quota: %v", err)
+	}
+	if _, err := s.clientset.CoreV1().LimitRanges("plate-qa").Get(ctx, namespaceLimitRangeName, metav1.GetOptions{}); err != nil {
+		t.Errorf("limit range: %v", err)
+	}
+	if _, err := s.clientset.NetworkingV1().NetworkPolicies("plate-qa").Get(ctx, namespaceNetworkPolicyName, metav1.GetOptions{}); err != nil {
+		t.Errorf("network policy: %v", err)
+	}
+}
+
+func TestProvisionNamespaceRefuses(t *testing.T) {
+	tests := []struct {
+		name      string
+		namespace string
+		adopt     bool
+		existing  *corev1.Namespace
+	}{
+		{name: "default", namespace: "default", adopt: true, existing: testNamespace("default", nil, nil)},
+		{name: "kube-system", namespace: "kube-system", adopt: true, existing: testNamespace("kube-system", nil, nil)},
+		{name: "missing kube namespace", namespace: "kube-custom", adopt: true},
+		{name: "unlabeled without adopt", namespace: "team-a", existing: testNamespace("team-a", map[string]string{"team": "a"}, nil)},
+		{
+			name:      "other environment",
+			namespace: "plate-staging",
+			adopt:     true,
+			existing:  testNamespace("plate-staging", map[string]string{ManagedByLabel: "plate", EnvironmentLabel: "staging"}, nil),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var objects []runtime.Object
+			if tt.existing != nil {
+				objects = append(objects, tt.existing)
+			}
+			s := newTestKubernetesService(objects...)
+
+			err := s.ProvisionNamespace(tt.namespace, "qa", tt.adopt, testEnvironmentDefaults)
+			var notManaged *NamespaceNotManagedError
+			if !errors.As(err, &notManaged) {
+				t.Fatalf("err = %v, want NamespaceNotManagedError", err)
+			}
+
+			quotas, err := s.clientset.CoreV1().ResourceQuotas(tt.namespace).List(context.TODO(), metav1.ListOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(quotas.Items) > 0 {
+				t.Errorf("quota was created in refused namespace %s", tt.namespace)
+			}
+			if tt.existing != nil {
+				ns := getTestNamespace(t, s, tt.namespace)
+				if ns.Labels[EnvironmentLabel] == "qa" {
+					t.Errorf("refused namespace was labeled: %v", ns.Labels)
+				}
+			}
+		})
+	}
+}
+
+func TestAdoptedNamespaceIsNotDeleted(t *testing.T) {
+	s := newTestKubernetesService(testNamespace("team-a", map[string]string{"team": "a"}, nil))
+	if err := s.ProvisionNamespace("team-a", "qa", true, testEnvironmentDefaults); err != nil {
+		t.Fatal(err)
+	}
+
+	ns := getTestNamespace(t, s, "team-a")
+	if ns.Labels[ManagedByLabel] != "plate" || ns.Labels[EnvironmentLabel] != "qa" || ns.Labels["team"] != "a" {
+		t.Errorf("labels = %v", ns.Labels)
+	}
+	if _, ok := ns.Annotations[CreatedForAnnotation]; ok {
+		t.Errorf("adopted namespace was marked as created by plate")
+	}
+
+	err := s.DeleteNamespace("team-a", "qa")
+	var notManaged *NamespaceNotManagedError
+	if !errors.As(err, &notManaged) {
+		t.Fatalf("err = %v, want NamespaceNotManagedError", err)
+	}
+	getTestNamespace(t, s, "team-a")
+}
+
+func TestDeleteNamespace(t *testing.T) {
+	managed := map[string]string{ManagedByLabel: "plate", EnvironmentLabel: "qa"}
+	tests := []struct {
+		name     string
+		existing *corev1.Namespace
+		deleted  bool
+		refused  bool
+	}{
+		{name: "created for environment", existing: testNamespace("ns", managed, map[string]string{CreatedForAnnotation: "qa"}), deleted: true},
+		{name: "created for other environment", existing: testNamespace("ns", managed, map[string]string{CreatedForAnnotation: "staging"}), refused: true},
+		{name: "labeled only", existing: testNamespace("ns", managed, nil), refused: true},
+		{name: "unlabeled", existing: testNamespace("ns", nil, nil), refused: true},
+		{name: "missing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var objects []runtime.Object
+			if tt.existing != nil {
+				objects = append(objects, tt.existing)
+			}
+			s := newTestKubernetesService(objects...)
+
+			err := s.DeleteNamespace("ns", "qa")
+			var notManaged *NamespaceNotManagedError
+			if refused := errors.As(err, &notManaged); refused != tt.refused {
+				t.Fatalf("err = %v, refused = %v, want %v", err, refused, tt.refused)
+			}
+			if !tt.refused && err != nil {
+				t.Fatal(err)
+			}
+
+			namespaces, err := s.clientset.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if exists := len(namespaces.Items) > 0; tt.existing != nil && exists == tt.deleted {
+				t.Errorf("namespace exists = %v, want deleted = %v", exists, tt.deleted)
+			}
+		})
+	}
+
+	s := newTestKubernetesService(testNamespace("kube-system", map[string]string{ManagedByLabel: "plate", EnvironmentLabel: "qa"}, map[string]string{CreatedForAnnotation: "qa"}))
+	var notManaged *NamespaceNotManagedError
+	if err := s.DeleteNamespace("kube-system", "qa"); !errors.As(err, &notManaged) {
+		t.Errorf("deleting kube-system: err = %v, want NamespaceNotManagedError", err)
+	}
+}
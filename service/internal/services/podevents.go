@@ -0,0 +1,151 @@
+package services
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// podHandler publishes the phase changes of pods seen by the informers,
+// such as a pod starting, crash looping or going away. Pods that already
+// existed when a namespace's informers started are not announced; after a
+// watch drops, the informers list the pods again and what changed
+// meanwhile is published.
+func (c *kubernetesCache) podHandler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, isInInitialList bool) {
+			if pod, ok := obj.(*corev1.Pod); ok && !isInInitialList {
+				c.publishPod(pod, string(pod.Status.Phase), podReason(pod), "")
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			previous, ok := oldObj.(*corev1.Pod)
+			if !ok {
+				return
+			}
+			pod, ok := newObj.(*corev1.Pod)
+			if !ok {
+				return
+			}
+			phase, reason := string(pod.Status.Phase), podReason(pod)
+			if phase == string(previous.Status.Phase) && reason == podReason(previous) {
+				return
+			}
+			c.publishPod(pod, phase, reason, string(previous.Status.Phase))
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if pod, ok := obj.(*corev1.Pod); ok {
+				c.publishPod(pod, "Deleted", "", string(pod.Status.Phase))
+			}
+		},
+	}
+}
+
+func (c *kubernetesCache) publishPod(pod *corev1.Pod, phase, reason, previousPhase string) {
+	environment := c.environment(pod.Namespace)
+	if environment == "" {
+		return
+	}
+	c.events.Publish(Event{
+		Type:        EventPodPhase,
+		Project:     podApp(pod),
+		Environment: environment,
+		Data: PodPhaseEvent{
+			Pod:           pod.Name,
+			Phase:         phase,
+			PreviousPhase: previousPhase,
+			Reason:        reason,
+		},
+	})
+}
+
+// warningHandler publishes the Warning events Kubernetes records in a
+// namespace, such as failed scheduling or probes, once when they first
+// occur and again whenever they repeat.
+func (c *kubernetesCache) warningHandler(scope *namespaceCache) cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, isInInitialList bool) {
+			if event, ok := obj.(*corev1.Event); ok && !isInInitialList {
+				c.publishWarning(scope, event)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			previous, ok := oldObj.(*corev1.Event)
+			if !ok {
+				return
+			}
+			if event, ok := newObj.(*corev1.Event); ok && event.Count != previous.Count {
+				c.publishWarning(scope, event)
+			}
+		},
+	}
+}
+
+func (c *kubernetesCache) publishWarning(scope *namespaceCache, event *corev1.Event) {
+	if event.Type != corev1.EventTypeWarning {
+		return
+	}
+	environment := c.environment(event.Namespace)
+	if environment == "" {
+		return
+	}
+
+	object := event.InvolvedObject
+	c.events.Publish(Event{
+		Type:        EventAppWarning,
+		Project:     scope.app(object),
+		Environment: environment,
+		Data: AppWarningEvent{
+			Kind:    object.Kind,
+			Name:    object.Name,
+			Reason:  event.Reason,
+			Message: event.Message,
+			Count:   event.Count,
+		},
+	})
+}
+
+// app returns the app an object named by an event belongs to, or "" if it
+// cannot be told.
+func (n *namespaceCache) app(object corev1.ObjectReference) string {
+	switch object.Kind {
+	case "Pod":
+		if pod, err := n.pods.Get(object.Name); err == nil {
+			return podApp(pod)
+		}
+	case "Deployment":
+		if deployment, err := n.deployments.Get(object.Name); err == nil {
+			if app := deployment.Labels["app"]; app != "" {
+				return app
+			}
+		}
+		return object.Name
+	}
+	return ""
+}
+
+// podApp returns the app a pod belongs to, as labelled by the chart.
+func podApp(pod *corev1.Pod) string {
+	for _, label := range []string{"app", "app.kubernetes.io/name", "app.kubernetes.io/instance"} {
+		if app := pod.Labels[label]; app != "" {
+			return app
+		}
+	}
+	return ""
+}
+
+// podReason tells why a pod is not running as it should, such as
+// CrashLoopBackOff or ImagePullBackOff, or "" for a healthy pod.
+func podReason(pod *corev1.Pod) string {
+	for _, status := range pod.Status.ContainerStatuses {
+		if waiting := status.State.Waiting; waiting != nil && waiting.Reason != "" {
+			return waiting.Reason
+		}
+		if terminated := status.State.Terminated; terminated != nil && terminated.Reason != "" && terminated.ExitCode != 0 {
+			return terminated.Reason
+		}
+	}
+	return pod.Status.Reason
+}
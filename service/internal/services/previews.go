@@ -0,0 +1,335 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/plate/service/internal/config"
+	"github.com/plate/service/internal/models"
+	"gorm.io/gorm"
+)
+
+// PreviewService deploys the pull requests of projects to preview
+// environments of their own, and tears those down when the pull request is
+// closed or the preview expires.
+type PreviewService struct {
+	db           *gorm.DB
+	environments *EnvironmentService
+	deployments  *DeploymentService
+	git          *GitProviders
+	config       config.Previews
+}
+
+func NewPreviewService(db *gorm.DB, environments *EnvironmentService, deployments *DeploymentService, git *GitProviders, cfg config.Previews) *PreviewService {
+	return &PreviewService{
+		db:           db,
+		environments: environments,
+		deployments:  deployments,
+		git:          git,
+		config:       cfg,
+	}
+}
+
+// PreviewQuotaError is returned when a pull request would exceed the
+// number of previews a project may have.
+type PreviewQuotaError struct {
+	Project string
+	Limit   int
+}
+
+func (e *PreviewQuotaError) Error() string {
+	return fmt.Sprintf("project %s already has %d preview environments, the most it may have", e.Project, e.Limit)
+}
+
+// PullRequest is the head of a pull request a preview is deployed from.
+type PullRequest struct {
+	Provider   string // name of the GitProvider
+	Repository string // full name of the repository
+	Number     int
+	Branch     string
+	CommitSHA  string
+}
+
+// Enabled reports whether pull requests are deployed to previews.
+func (s *PreviewService) Enabled() bool {
+	return s.config.Enabled
+}
+
+// List returns the previews of a project, or of all projects for 0.
+func (s *PreviewService) List(projectID uint) ([]models.Preview, error) {
+	var previews []models.Preview
+	query := s.db.Preload("Project").Preload("Environment").Order("id")
+	if projectID != 0 {
+		query = query.Where("project_id = ?", projectID)
+	}
+	err := query.Find(&previews).Error
+	return previews, err
+}
+
+func (s *PreviewService) GetByID(id uint) (*models.Preview, error) {
+	var preview models.Preview
+	err := s.db.Preload("Project").Preload("Environment").First(&preview, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &preview, nil
+}
+
+// Deploy deploys the head commit of a pull request to its preview, which
+// is created for the first commit, and announces the preview's URL on the
+// pull request. Every deployment restarts the preview's TTL.
+func (s *PreviewService) Deploy(project *models.Project, pr PullRequest) (*models.Preview, *models.Deployment, error) {
+	var preview models.Preview
+	err := s.db.Preload("Environment").Where("project_id = ? AND pull_request = ?", project.ID, pr.Number).First(&preview).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		created, err := s.create(project, pr)
+		if err != nil {
+			s.announce(&models.Preview{Provider: pr.Provider, Repository: pr.Repository, PullRequest: pr.Number}, failedMessage(pr, err))
+			return nil, nil, err
+		}
+		preview = *created
+	case err != nil:
+		return nil, nil, err
+	}
+
+	preview.Provider = pr.Provider
+	preview.Repository = pr.Repository
+	deployment, err := s.deployments.Deploy(project.ID, preview.EnvironmentID, pr.CommitSHA)
+	if err != nil {
+		s.announce(&preview, failedMessage(pr, err))
+		s.db.Omit("Project", "Environment").Save(&preview)
+		return nil, nil, err
+	}
+
+	preview.Branch = pr.Branch
+	preview.CommitSHA = pr.CommitSHA
+	if settings, err := ResolveSettings(project, &preview.Environment); err == nil && settings.Host != "" {
+		preview.URL = "https://" + settings.Host
+	}
+	if s.config.TTL > 0 {
+		expiresAt := time.Now().Add(s.config.TTL)
+		preview.ExpiresAt = &expiresAt
+	}
+
+	s.announce(&preview, s.deployedMessage(&preview))
+	if err := s.db.Omit("Project", "Environment").Save(&preview).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to save preview: %w", err)
+	}
+	return &preview, deployment, nil
+}
+
+// create creates the environment of a new preview, named after the
+// project and branch, and the preview itself.
+func (s *PreviewService) create(project *models.Project, pr PullRequest) (*models.Preview, error) {
+	if s.config.MaxPerProject > 0 {
+		var count int64
+		if err := s.db.Model(&models.Preview{}).Where("project_id = ?", project.ID).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if int(count) >= s.config.MaxPerProject {
+			return nil, &PreviewQuotaError{Project: project.Name, Limit: s.config.MaxPerProject}
+		}
+	}
+
+	// Another pull request may come from the same branch
+	name := previewName(project.Name, pr.Branch)
+	if _, err := s.environments.GetByName(name); err == nil {
+		name = previewName(project.Name, fmt.Sprintf("%s-%d", pr.Branch, pr.Number))
+	}
+
+	environment := &models.Environment{Name: name, Namespace: "preview-" + name, Preview: true}
+	if s.config.Domain != "" {
+		environment.Domain = name + "." + s.config.Domain
+	}
+	if err := environment.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.environments.Create(environment); err != nil {
+		return nil, fmt.Errorf("failed to create preview environment %s: %w", name, err)
+	}
+
+	preview := &models.Preview{
+		ProjectID:     project.ID,
+		PullRequest:   pr.Number,
+		EnvironmentID: environment.ID,
+		Environment:   *environment,
+	}
+	if err := s.db.Omit("Project", "Environment").Create(preview).Error; err != nil {
+		if _, cleanupErr := s.environments.Delete(environment.ID, true); cleanupErr != nil {
+			fmt.Printf("Warning: Failed to remove preview environment %s: %v\n", name, cleanupErr)
+		}
+		return nil, fmt.Errorf("failed to create preview: %w", err)
+	}
+	return preview, nil
+}
+
+// Close tears down the preview of a pull request that was closed or
+// merged. It returns nil if the pull request has no preview.
+func (s *PreviewService) Close(project *models.Project, number int, merged bool) (*models.Preview, error) {
+	var preview models.Preview
+	err := s.db.Preload("Environment").Where("project_id = ? AND pull_request = ?", project.ID, number).First(&preview).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.teardown(&preview); err != nil {
+		return nil, err
+	}
+
+	reason := "the pull request was closed"
+	if merged {
+		reason = "the pull request was merged"
+	}
+	s.announce(&preview, removedMessage(&preview, reason))
+	return &preview, nil
+}
+
+// Delete tears down a preview before its pull request is closed.
+func (s *PreviewService) Delete(id uint) error {
+	preview, err := s.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if err := s.teardown(preview); err != nil {
+		return err
+	}
+	s.announce(preview, removedMessage(preview, "it was deleted in Plate"))
+	return nil
+}
+
+// Expire tears down the previews whose TTL has passed and returns the
+// names of their environments.
+func (s *PreviewService) Expire() ([]string, error) {
+	var previews []models.Preview
+	if err := s.db.Preload("Environment").Where("expires_at < ?", time.Now()).Find(&previews).Error; err != nil {
+		return nil, err
+	}
+
+	var expired []string
+	var errs []error
+	for i := range previews {
+		preview := &previews[i]
+		if err := s.teardown(preview); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		expired = append(expired, preview.Environment.Name)
+		s.announce(preview, removedMessage(preview, fmt.Sprintf("it expired %s after its last deployment", formatTTL(s.config.TTL))))
+	}
+	return expired, errors.Join(errs...)
+}
+
+// teardown removes the deployments, environment and namespace of a
+// preview, and the preview itself.
+func (s *PreviewService) teardown(preview *models.Preview) error {
+	var deployments []models.Deployment
+	if err := s.db.Where("environment_id = ?", preview.EnvironmentID).Find(&deployments).Error; err != nil {
+		return err
+	}
+	for _, deployment := range deployments {
+		if err := s.deployments.Delete(deployment.ID); err != nil {
+			return fmt.Errorf("failed to remove deployment %d of preview %s: %w", deployment.ID, preview.Environment.Name, err)
+		}
+	}
+
+	_, err := s.environments.Delete(preview.EnvironmentID, true)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("failed to remove preview environment %s: %w", preview.Environment.Name, err)
+	}
+	return s.db.Delete(&models.Preview{}, preview.ID).Error
+}
+
+// announce posts message on the pull request of a preview, replacing the
+// comment posted before, if any. Failures are only logged, since previews
+// work without the comment.
+func (s *PreviewService) announce(preview *models.Preview, message string) {
+	if preview.Repository == "" {
+		return
+	}
+	provider, err := s.git.Get(preview.Provider)
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
+		return
+	}
+
+	if preview.CommentID != 0 {
+		err := provider.EditComment(preview.Repository, preview.PullRequest, preview.CommentID, message)
+		if err == nil {
+			return
+		}
+		fmt.Printf("Warning: %v\n", err)
+	}
+
+	id, err := provider.CreateComment(preview.Repository, preview.PullRequest, message)
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
+		return
+	}
+	preview.CommentID = id
+}
+
+func (s *PreviewService) deployedMessage(preview *models.Preview) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "**Preview environment** `%s`\n\n", preview.Environment.Name)
+	if preview.URL != "" {
+		fmt.Fprintf(&b, "Deploying %s to %s\n\n", shortSHA(preview.CommitSHA), preview.URL)
+	} else {
+		fmt.Fprintf(&b, "Deploying %s\n\n", shortSHA(preview.CommitSHA))
+	}
+	b.WriteString("The preview is removed when this pull request is closed")
+	if s.config.TTL > 0 {
+		fmt.Fprintf(&b, ", or %s after its last deployment", formatTTL(s.config.TTL))
+	}
+	b.WriteString(".")
+	return b.String()
+}
+
+func failedMessage(pr PullRequest, err error) string {
+	return fmt.Sprintf("**Preview environment**\n\nCould not deploy %s: %v", shortSHA(pr.CommitSHA), err)
+}
+
+func removedMessage(preview *models.Preview, reason string) string {
+	return fmt.Sprintf("**Preview environment** `%s`\n\nRemoved: %s.", preview.Environment.Name, reason)
+}
+
+var previewNameSeparators = regexp.MustCompile(`[^a-z0-9]+`)
+
+// previewName derives the environment name of a preview from the project
+// and branch: a DNS label that leaves room for the preview- prefix of its
+// namespace. Long names are cut and made unique with a hash.
+func previewName(project, branch string) string {
+	name := strings.Trim(previewNameSeparators.ReplaceAllString(strings.ToLower(project+"-"+branch), "-"), "-")
+	if len(name) <= 48 {
+		return name
+	}
+	sum := sha256.Sum256([]byte(project + "/" + branch))
+	return strings.TrimRight(name[:41], "-") + "-" + hex.EncodeToString(sum[:])[:6]
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+// formatTTL writes 72h rather than 72h0m0s.
+func formatTTL(ttl time.Duration) string {
+	text := ttl.String()
+	if strings.HasSuffix(text, "m0s") {
+		text = strings.TrimSuffix(text, "0s")
+	}
+	if strings.HasSuffix(text, "h0m") {
+		text = strings.TrimSuffix(text, "0m")
+	}
+	return text
+}
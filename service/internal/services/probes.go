@@ -0,0 +1,90 @@
+package services
+
+import (
+	"github.com/plate/service/internal/models"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// Probes are the Kubernetes probes of a container; nil probes are not set.
+type Probes struct {
+	Liveness  *corev1.Probe `json:"liveness,omitempty"`
+	Readiness *corev1.Probe `json:"readiness,omitempty"`
+	Startup   *corev1.Probe `json:"startup,omitempty"`
+}
+
+// Probes renders the health check settings. Liveness and readiness probes
+// that are not configured check the HTTP path of the health check when one
+// is set and otherwise only that the port accepts connections, since many
+// applications do not answer on /. A startup probe is only added when
+// configured.
+func (s *DeploymentSettings) Probes() Probes {
+	check := s.HealthCheck
+	defaultProbe := &models.Probe{}
+
+	return Probes{
+		Liveness:  buildProbe(orProbe(check.Liveness, defaultProbe), check.Path),
+		Readiness: buildProbe(orProbe(check.Readiness, defaultProbe), check.Path),
+		Startup:   buildProbe(check.Startup, check.Path),
+	}
+}
+
+func orProbe(probe, fallback *models.Probe) *models.Probe {
+	if probe != nil {
+		return probe
+	}
+	return fallback
+}
+
+// probeType infers the type of a probe that does not set one.
+func probeType(probe *models.Probe, defaultPath string) string {
+	switch {
+	case probe.Type != "":
+		return probe.Type
+	case len(probe.Command) > 0:
+		return models.ProbeExec
+	case probe.Path != "" || defaultPath != "":
+		return models.ProbeHTTP
+	default:
+		return models.ProbeTCP
+	}
+}
+
+func buildProbe(probe *models.Probe, defaultPath string) *corev1.Probe {
+	if probe == nil {
+		return nil
+	}
+
+	port := intstr.FromString("http")
+	if probe.Port != 0 {
+		port = intstr.FromInt(probe.Port)
+	}
+
+	result := &corev1.Probe{
+		InitialDelaySeconds: int32(probe.InitialDelaySeconds),
+		PeriodSeconds:       int32(probe.PeriodSeconds),
+		TimeoutSeconds:      int32(probe.TimeoutSeconds),
+		FailureThreshold:    int32(probe.FailureThreshold),
+		SuccessThreshold:    int32(probe.SuccessThreshold),
+	}
+
+	switch probeType(probe, defaultPath) {
+	case models.ProbeHTTP:
+		path := probe.Path
+		if path == "" {
+			path = defaultPath
+		}
+		if path == "" {
+			path = "/"
+		}
+		result.HTTPGet = &corev1.HTTPGetAction{Path: path, Port: port}
+	case models.ProbeTCP:
+		result.TCPSocket = &corev1.TCPSocketAction{Port: port}
+	case models.ProbeExec:
+		result.Exec = &corev1.ExecAction{Command: probe.Command}
+	default:
+		return nil
+	}
+
+	return result
+}
@@ -1,6 +1,8 @@
 package services
 
 import (
+	"slices"
+
 	"github.com/plate/service/internal/models"
 	"gorm.io/gorm"
 )
@@ -42,53 +44,58 @@ func (s *ProjectService) Create(project *models.Project) error {
 }
 
 func (s *ProjectService) Update(project *models.Project) error {
-	return s.db.Save(project).Error
-}
-
-func (s *ProjectService) Delete(id uint) error {
-	return s.db.Delete(&models.Project{}, id).Error
-}
-
-type EnvironmentService struct {
-	db *gorm.DB
-}
-
-func NewEnvironmentService(db *gorm.DB) *EnvironmentService {
-	return &EnvironmentService{db: db}
+	// created_at is not part of update requests and must be kept
+	return s.db.Omit("created_at").Save(project).Error
 }
 
-func (s *EnvironmentService) List() ([]models.Environment, error) {
-	var environments []models.Environment
-	err := s.db.Find(&environments).Error
-	return environments, err
+// SetRepository records the Git repository a project is built from,
+// replacing any previously recorded one.
+func (s *ProjectService) SetRepository(project *models.Project, repo *models.Repository) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("project_id = ?", project.ID).Delete(&models.Repository{}).Error; err != nil {
+			return err
+		}
+
+		repo.ProjectID = project.ID
+		if err := tx.Omit("Project").Create(repo).Error; err != nil {
+			return err
+		}
+
+		project.Repository = repo.CloneURL
+		return tx.Model(project).Update("repository", repo.CloneURL).Error
+	})
 }
 
-func (s *EnvironmentService) GetByID(id uint) (*models.Environment, error) {
-	var environment models.Environment
-	err := s.db.First(&environment, id).Error
-	if err != nil {
-		return nil, err
+// ListByRepository returns the projects built from the repository with
+// one of the given URLs, as recorded by SetRepository. The services of a
+// monorepo share their repository. Empty URLs are ignored, as they would
+// match every project registered without a repository.
+func (s *ProjectService) ListByRepository(urls ...string) ([]models.Project, error) {
+	urls = slices.DeleteFunc(slices.Clone(urls), func(url string) bool { return url == "" })
+	if len(urls) == 0 {
+		return nil, nil
 	}
-	return &environment, nil
+
+	var projects []models.Project
+	recorded := s.db.Model(&models.Repository{}).Select("project_id").Where("clone_url IN ? OR url IN ?", urls, urls)
+	err := s.db.Where("id IN (?) OR repository IN ?", recorded, urls).Order("id").Find(&projects).Error
+	return projects, err
 }
 
-func (s *EnvironmentService) GetByName(name string) (*models.Environment, error) {
-	var environment models.Environment
-	err := s.db.Where("name = ?", name).First(&environment).Error
+func (s *ProjectService) GetRepository(projectID uint) (*models.Repository, error) {
+	var repo models.Repository
+	err := s.db.Where("project_id = ?", projectID).First(&repo).Error
 	if err != nil {
 		return nil, err
 	}
-	return &environment, nil
+	return &repo, nil
 }
 
-func (s *EnvironmentService) Create(environment *models.Environment) error {
-	return s.db.Create(environment).Error
-}
-
-func (s *EnvironmentService) Update(environment *models.Environment) error {
-	return s.db.Save(environment).Error
+func (s *ProjectService) Delete(id uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("project_id = ?", id).Delete(&models.Secret{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Project{}, id).Error
+	})
 }
-
-func (s *EnvironmentService) Delete(id uint) error {
-	return s.db.Delete(&models.Environment{}, id).Error
-}
\ No newline at end of file
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/plate/service/internal/models"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// QuotaExceededError reports that deploying a project would exceed a
+// ResourceQuota of its environment's namespace.
+type QuotaExceededError struct {
+	Namespace  string
+	Violations []string
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("resource quota of namespace %s exceeded: %s", e.Namespace, strings.Join(e.Violations, "; "))
+}
+
+// CheckQuota checks that running replicas of an app with the given
+// resources fits the ResourceQuotas of namespace. The app's current pods
+// are not counted against it, since a rollout replaces them. Without a
+// cluster connection there is nothing to check against.
+func (s *KubernetesService) CheckQuota(namespace, name string, replicas int, resources models.Resources) error {
+	if s.clientset == nil {
+		return nil
+	}
+
+	quotas, err := s.clientset.CoreV1().ResourceQuotas(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to list resource quotas in namespace %s: %w", namespace, err)
+	}
+	if len(quotas.Items) == 0 {
+		return nil
+	}
+
+	requirements, err := resourceRequirements(resources)
+	if err != nil {
+		return err
+	}
+
+	pods, err := s.ListPods(namespace, fmt.Sprintf("app=%s", name))
+	if err != nil {
+		return fmt.Errorf("failed to get pods of %s/%s: %w", namespace, name, err)
+	}
+
+	// Pods count once each, whatever containers they run
+	needed := quotaUsage(requirements, replicas)
+	needed[corev1.ResourcePods] = *resource.NewQuantity(int64(replicas), resource.DecimalSI)
+	current := corev1.ResourceList{corev1.ResourcePods: *resource.NewQuantity(int64(len(pods)), resource.DecimalSI)}
+	for _, pod := range pods {
+		for _, container := range pod.Spec.Containers {
+			addUsage(current, quotaUsage(container.Resources, 1))
+		}
+	}
+
+	var violations []string
+	for _, quota := range quotas.Items {
+		hard := quota.Status.Hard
+		if len(hard) == 0 {
+			hard = quota.Spec.Hard
+		}
+		for _, key := range sortedResourceNames(hard) {
+			want, ok := needed[key]
+			if !ok {
+				continue
+			}
+			available := hard[key].DeepCopy()
+			used := quota.Status.Used[key]
+			available.Sub(used)
+			available.Add(current[key])
+			if want.Cmp(available) > 0 {
+				limit := hard[key]
+				violations = append(violations, fmt.Sprintf("%s: %s needs %s but only %s of %s is available",
+					quota.Name, key, want.String(), available.String(), limit.String()))
+			}
+		}
+	}
+
+	if len(violations) > 0 {
+		return &QuotaExceededError{Namespace: namespace, Violations: violations}
+	}
+	return nil
+}
+
+// SetDeploymentResources updates the requests and limits of every container
+// of a running deployment, which rolls out new pods.
+func (s *KubernetesService) SetDeploymentResources(namespace, name string, resources models.Resources) error {
+	requirements, err := resourceRequirements(resources)
+	if err != nil {
+		return err
+	}
+
+	deployment, err := s.clientset.AppsV1().Deployments(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get deployment %s/%s: %w", namespace, name, err)
+	}
+
+	for i := range deployment.Spec.Template.Spec.Containers {
+		deployment.Spec.Template.Spec.Containers[i].Resources = requirements
+	}
+
+	_, err = s.clientset.AppsV1().Deployments(namespace).Update(context.TODO(), deployment, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to update resources of deployment %s/%s: %w", namespace, name, err)
+	}
+
+	return nil
+}
+
+func resourceRequirements(resources models.Resources) (corev1.ResourceRequirements, error) {
+	requests, err := resourceList(resources.Requests)
+	if err != nil {
+		return corev1.ResourceRequirements{}, fmt.Errorf("invalid requests: %w", err)
+	}
+	limits, err := resourceList(resources.Limits)
+	if err != nil {
+		return corev1.ResourceRequirements{}, fmt.Errorf("invalid limits: %w", err)
+	}
+	return corev1.ResourceRequirements{Requests: requests, Limits: limits}, nil
+}
+
+func resourceList(list models.ResourceList) (corev1.ResourceList, error) {
+	result := corev1.ResourceList{}
+	if list.CPU != "" {
+		quantity, err := resource.ParseQuantity(list.CPU)
+		if err != nil {
+			return nil, fmt.Errorf("cpu: %w", err)
+		}
+		result[corev1.ResourceCPU] = quantity
+	}
+	if list.Memory != "" {
+		quantity, err := resource.ParseQuantity(list.Memory)
+		if err != nil {
+			return nil, fmt.Errorf("memory: %w", err)
+		}
+		result[corev1.ResourceMemory] = quantity
+	}
+	return result, nil
+}
+
+// quotaUsage returns what replicas of a container count against the quota
+// keys that limit compute resources.
+func quotaUsage(requirements corev1.ResourceRequirements, replicas int) corev1.ResourceList {
+	usage := corev1.ResourceList{}
+	add := func(key corev1.ResourceName, quantity resource.Quantity) {
+		total := quantity.DeepCopy()
+		total.Mul(int64(replicas))
+		usage[key] = total
+	}
+
+	if cpu, ok := requirements.Requests[corev1.ResourceCPU]; ok {
+		add(corev1.ResourceCPU, cpu)
+		add(corev1.ResourceRequestsCPU, cpu)
+	}
+	if memory, ok := requirements.Requests[corev1.ResourceMemory]; ok {
+		add(corev1.ResourceMemory, memory)
+		add(corev1.ResourceRequestsMemory, memory)
+	}
+	if cpu, ok := requirements.Limits[corev1.ResourceCPU]; ok {
+		add(corev1.ResourceLimitsCPU, cpu)
+	}
+	if memory, ok := requirements.Limits[corev1.ResourceMemory]; ok {
+		add(corev1.ResourceLimitsMemory, memory)
+	}
+	return usage
+}
+
+func addUsage(total, usage corev1.ResourceList) {
+	for key, quantity := range usage {
+		sum := total[key]
+		sum.Add(quantity)
+		total[key] = sum
+	}
+}
+
+func sortedResourceNames(list corev1.ResourceList) []corev1.ResourceName {
+	names := make([]corev1.ResourceName, 0, len(list))
+	for name := range list {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/plate/service/internal/models"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCheckQuotaCountsPods(t *testing.T) {
+	// 5 pods are allowed; shop runs 1 and another app 2
+	quota := &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "preview", Name: "plate-quota"},
+		Status: corev1.ResourceQuotaStatus{
+			Hard: corev1.ResourceList{corev1.ResourcePods: resource.MustParse("5")},
+			Used: corev1.ResourceList{corev1.ResourcePods: resource.MustParse("3")},
+		},
+	}
+	s := newTestKubernetesService(quota, testPod("preview", "shop-1", "shop", corev1.PodRunning))
+	resources := models.SizePresets["small"]
+
+	for replicas := 1; replicas <= 3; replicas++ {
+		if err := s.CheckQuota("preview", "shop", replicas, resources); err != nil {
+			t.Errorf("%d replicas: %v", replicas, err)
+		}
+	}
+
+	err := s.CheckQuota("preview", "shop", 4, resources)
+	var quotaErr *QuotaExceededError
+	if !errors.As(err, &quotaErr) || len(quotaErr.Violations) != 1 || !strings.Contains(quotaErr.Violations[0], "pods needs 4 but only 3 of 5 is available") {
+		t.Errorf("4 replicas: error = %v", err)
+	}
+}
@@ -0,0 +1,302 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/plate/service/internal/models"
+	"gorm.io/gorm/clause"
+	appsv1 "k8s.io/api/apps/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// Health of the workload of a deployment, as recorded by Reconcile
+const (
+	HealthHealthy     = "healthy"
+	HealthProgressing = "progressing"
+	// HealthDegraded is a rollout that exceeded its progress deadline
+	HealthDegraded = "degraded"
+	// HealthStopped is an app scaled to zero replicas
+	HealthStopped = "stopped"
+	HealthMissing = "missing"
+)
+
+// errInterrupted fails deployments that were in progress when the service
+// stopped.
+var errInterrupted = errors.New("deployment was interrupted by a restart of the service")
+
+// DeploymentReconcileReport describes what Reconcile found.
+type DeploymentReconcileReport struct {
+	// Checked is how many deployments were compared with the cluster: the
+	// latest one of every project in every environment
+	Checked int `json:"checked"`
+	// Interrupted are deployments left pending or running by a previous
+	// run of the service, which were failed
+	Interrupted []uint `json:"interrupted"`
+	// Corrected maps deployments whose status was wrong to the status
+	// they were given
+	Corrected map[uint]string `json:"corrected"`
+	// Drifted maps deployments to how the cluster differs from them
+	Drifted map[uint]string `json:"drifted"`
+	// Unavailable are the sources deployments could not be compared with,
+	// argocd and helm, whose lookups are not implemented yet
+	Unavailable []string        `json:"unavailable,omitempty"`
+	Errors      map[uint]string `json:"errors,omitempty"`
+}
+
+// deploymentState is what Reconcile found of a deployment.
+type deploymentState struct {
+	health     string
+	syncStatus string
+	drift      []string
+	// failures are reasons the deployment did not succeed
+	failures []string
+	// running is whether the workload was rolled out by the deployment
+	running bool
+	// unavailable are the sources that could not be looked up
+	unavailable []string
+}
+
+// Reconcile compares the latest deployment of every project in every
+// environment with its workload in the cluster, and with its Argo CD
+// application and Helm release where those can be looked up; the ones that
+// cannot are reported as unavailable. A deployment recorded as successful
+// whose rollout or release failed is failed, and a failed one whose
+// workload rolled out after all succeeds; health, sync status and drift are
+// recorded, and changes are published as events. Deployments left in progress by a previous run of
+// the service are failed. A dry run only reports what would change.
+func (s *DeploymentService) Reconcile(dryRun bool) (*DeploymentReconcileReport, error) {
+	report := &DeploymentReconcileReport{Interrupted: []uint{}, Corrected: map[uint]string{}, Drifted: map[uint]string{}, Errors: map[uint]string{}}
+
+	var interrupted []models.Deployment
+	err := s.db.Preload("Project").Preload("Environment").
+		Where("status IN ?", []string{"pending", "running"}).
+		Where("updated_at < ?", s.started).
+		Order("id").Find(&interrupted).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range interrupted {
+		deployment := &interrupted[i]
+		report.Interrupted = append(report.Interrupted, deployment.ID)
+		if !dryRun {
+			s.logStage(deployment, &deployment.Project, &deployment.Environment, "error", "Deployment failed: "+errInterrupted.Error())
+			s.setStatus(deployment, &deployment.Project, &deployment.Environment, "failed", errInterrupted)
+		}
+	}
+
+	// Without a cluster connection there is nothing to compare with
+	if s.kubernetes.GetClientset() == nil {
+		return report, nil
+	}
+
+	deployments, err := s.ListLatest()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range deployments {
+		deployment := &deployments[i]
+		// Deployments in progress are being worked on
+		if deployment.Status != "success" && deployment.Status != "failed" {
+			continue
+		}
+		report.Checked++
+
+		state, err := s.inspect(deployment)
+		if err != nil {
+			report.Errors[deployment.ID] = err.Error()
+			continue
+		}
+		for _, source := range state.unavailable {
+			if !slices.Contains(report.Unavailable, source) {
+				report.Unavailable = append(report.Unavailable, source)
+			}
+		}
+		if len(state.drift) > 0 {
+			report.Drifted[deployment.ID] = strings.Join(state.drift, "; ")
+		}
+		if status := correctedStatus(deployment, state); status != "" {
+			report.Corrected[deployment.ID] = status
+		}
+		if !dryRun {
+			s.record(deployment, state, report.Corrected[deployment.ID])
+		}
+	}
+
+	return report, nil
+}
+
+// inspect looks up the workload, Argo CD application and Helm release of a
+// deployment. An application or release that cannot be looked up is left
+// out rather than taken as healthy.
+func (s *DeploymentService) inspect(deployment *models.Deployment) (*deploymentState, error) {
+	project, environment := &deployment.Project, &deployment.Environment
+	state := &deploymentState{health: HealthMissing}
+
+	workload, err := s.kubernetes.GetDeployment(environment.Namespace, project.Name)
+	switch {
+	case k8serrors.IsNotFound(err):
+		state.drift = append(state.drift, fmt.Sprintf("Deployment %s/%s is missing from the cluster", environment.Namespace, project.Name))
+	case err != nil:
+		return nil, fmt.Errorf("failed to get deployment %s/%s: %w", environment.Namespace, project.Name, err)
+	default:
+		state.health = rolloutHealth(workload)
+		// Versions repeat across deployments, so the deployment is told by
+		// the ID it recorded on the pod template
+		state.running = workload.Spec.Template.Annotations[DeploymentIDAnnotation] == strconv.FormatUint(uint64(deployment.ID), 10)
+		if version := workload.Labels["version"]; version != "" && version != deployment.Version {
+			state.drift = append(state.drift, fmt.Sprintf("version %s is running instead of %s", version, deployment.Version))
+		}
+		if state.health == HealthDegraded {
+			state.failures = append(state.failures, fmt.Sprintf("rollout of %s/%s exceeded its progress deadline", environment.Namespace, project.Name))
+		}
+	}
+
+	if deployment.ArgoAppName != "" {
+		app, err := s.argocd.GetApplication(deployment.ArgoAppName)
+		switch {
+		case errors.Is(err, ErrArgoCDUnavailable):
+			state.unavailable = append(state.unavailable, "argocd")
+		case err != nil:
+			return nil, fmt.Errorf("failed to get ArgoCD application %s: %w", deployment.ArgoAppName, err)
+		default:
+			state.syncStatus = app.SyncStatus
+			if app.SyncStatus != "" && app.SyncStatus != "Synced" {
+				state.drift = append(state.drift, fmt.Sprintf("ArgoCD application %s is %s", app.Name, app.SyncStatus))
+			}
+			health := app.Health
+			if health == "" {
+				health = app.Status
+			}
+			if health == "Degraded" || health == "Missing" {
+				state.failures = append(state.failures, fmt.Sprintf("ArgoCD application %s is %s", app.Name, health))
+			}
+		}
+	}
+
+	if deployment.HelmRelease != "" {
+		release, err := s.helm.GetRelease(deployment.HelmRelease)
+		switch {
+		case errors.Is(err, ErrHelmUnavailable):
+			state.unavailable = append(state.unavailable, "helm")
+		case err != nil:
+			return nil, fmt.Errorf("failed to get Helm release %s: %w", deployment.HelmRelease, err)
+		case release.Status == "failed":
+			state.failures = append(state.failures, fmt.Sprintf("Helm release %s failed", release.Name))
+		}
+	}
+
+	return state, nil
+}
+
+// correctedStatus returns the status a deployment should have, or "" if
+// its status is right.
+func correctedStatus(deployment *models.Deployment, state *deploymentState) string {
+	switch deployment.Status {
+	case "success":
+		if len(state.failures) > 0 {
+			return "failed"
+		}
+	case "failed":
+		// Only once the workload is seen rolled out by this deployment,
+		// since it may still be that of an earlier one, even of the same
+		// version
+		if len(state.failures) == 0 && state.running && state.health == HealthHealthy &&
+			(state.syncStatus == "" || state.syncStatus == "Synced") {
+			return "success"
+		}
+	}
+	return ""
+}
+
+// record stores what Reconcile found of a deployment and publishes what
+// changed.
+func (s *DeploymentService) record(deployment *models.Deployment, state *deploymentState, status string) {
+	project, environment := &deployment.Project, &deployment.Environment
+	drift := strings.Join(state.drift, "; ")
+	changed := deployment.Health != state.health || deployment.SyncStatus != state.syncStatus || deployment.Drift != drift
+
+	switch {
+	case drift != "" && drift != deployment.Drift:
+		s.logStage(deployment, project, environment, "warning", "Drift detected: "+drift)
+	case drift == "" && deployment.Drift != "":
+		s.logStage(deployment, project, environment, "info", "Drift resolved")
+	}
+
+	previousHealth := deployment.Health
+	now := time.Now()
+	deployment.Health = state.health
+	deployment.SyncStatus = state.syncStatus
+	deployment.Drift = drift
+	deployment.ReconciledAt = &now
+
+	if status != "" {
+		var cause error
+		if status == "failed" {
+			cause = errors.New(strings.Join(state.failures, "; "))
+			s.logStage(deployment, project, environment, "error", "Deployment failed: "+cause.Error())
+		} else {
+			s.logStage(deployment, project, environment, "info", "Deployment rolled out after all")
+		}
+		s.setStatus(deployment, project, environment, status, cause)
+	} else {
+		s.db.Omit(clause.Associations).Save(deployment)
+	}
+
+	if changed {
+		s.events.Publish(Event{
+			Type:         EventDeploymentHealth,
+			Project:      project.Name,
+			Environment:  environment.Name,
+			DeploymentID: deployment.ID,
+			Data: DeploymentHealthEvent{
+				Health:         state.health,
+				PreviousHealth: previousHealth,
+				SyncStatus:     state.syncStatus,
+				Drift:          drift,
+			},
+		})
+	}
+}
+
+// rolloutHealth tells how far the rollout of a Deployment got.
+func rolloutHealth(deployment *appsv1.Deployment) string {
+	desired := int32(1)
+	if deployment.Spec.Replicas != nil {
+		desired = *deployment.Spec.Replicas
+	}
+
+	for _, condition := range deployment.Status.Conditions {
+		if condition.Type == appsv1.DeploymentProgressing && condition.Reason == "ProgressDeadlineExceeded" {
+			return HealthDegraded
+		}
+	}
+	if desired == 0 {
+		return HealthStopped
+	}
+
+	status := deployment.Status
+	if status.ObservedGeneration < deployment.Generation ||
+		status.UpdatedReplicas < desired ||
+		status.AvailableReplicas < desired ||
+		status.Replicas > status.UpdatedReplicas {
+		return HealthProgressing
+	}
+	return HealthHealthy
+}
+
+// sortedIDs returns the deployment IDs of a report map in order.
+func sortedIDs(deployments map[uint]string) []uint {
+	ids := make([]uint, 0, len(deployments))
+	for id := range deployments {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
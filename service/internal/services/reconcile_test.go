@@ -0,0 +1,187 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/plate/service/internal/models"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func replicas(n int32) *int32 {
+	return &n
+}
+
+func TestRolloutHealth(t *testing.T) {
+	deadlineExceeded := []appsv1.DeploymentCondition{{
+		Type:   appsv1.DeploymentProgressing,
+		Status: corev1.ConditionFalse,
+		Reason: "ProgressDeadlineExceeded",
+	}}
+	progressing := []appsv1.DeploymentCondition{{
+		Type:   appsv1.DeploymentProgressing,
+		Status: corev1.ConditionTrue,
+		Reason: "ReplicaSetUpdated",
+	}}
+
+	tests := []struct {
+		name       string
+		replicas   *int32
+		generation int64
+		status     appsv1.DeploymentStatus
+		want       string
+	}{
+		{
+			name:     "rolled out",
+			replicas: replicas(3),
+			status:   appsv1.DeploymentStatus{Replicas: 3, UpdatedReplicas: 3, AvailableReplicas: 3},
+			want:     HealthHealthy,
+		},
+		{
+			name:   "one replica by default",
+			status: appsv1.DeploymentStatus{Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1},
+			want:   HealthHealthy,
+		},
+		{
+			name:     "updating",
+			replicas: replicas(3),
+			status:   appsv1.DeploymentStatus{Replicas: 3, UpdatedReplicas: 1, AvailableReplicas: 3, Conditions: progressing},
+			want:     HealthProgressing,
+		},
+		{
+			name:     "not yet available",
+			replicas: replicas(2),
+			status:   appsv1.DeploymentStatus{Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 1},
+			want:     HealthProgressing,
+		},
+		{
+			name:     "old replicas still surging",
+			replicas: replicas(2),
+			status:   appsv1.DeploymentStatus{Replicas: 3, UpdatedReplicas: 2, AvailableReplicas: 2},
+			want:     HealthProgressing,
+		},
+		{
+			name:       "stale observed generation",
+			replicas:   replicas(2),
+			generation: 5,
+			status:     appsv1.DeploymentStatus{ObservedGeneration: 4, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2},
+			want:       HealthProgressing,
+		},
+		{
+			name:       "observed generation",
+			replicas:   replicas(2),
+			generation: 5,
+			status:     appsv1.DeploymentStatus{ObservedGeneration: 5, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2},
+			want:       HealthHealthy,
+		},
+		{
+			name:     "progress deadline exceeded",
+			replicas: replicas(2),
+			status:   appsv1.DeploymentStatus{Replicas: 3, UpdatedReplicas: 1, AvailableReplicas: 2, Conditions: deadlineExceeded},
+			want:     HealthDegraded,
+		},
+		{
+			name:     "scaled to zero",
+			replicas: replicas(0),
+			want:     HealthStopped,
+		},
+		{
+			name:     "scaled to zero after the deadline",
+			replicas: replicas(0),
+			status:   appsv1.DeploymentStatus{Conditions: deadlineExceeded},
+			want:     HealthDegraded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deployment := &appsv1.Deployment{Spec: appsv1.DeploymentSpec{Replicas: tt.replicas}, Status: tt.status}
+			deployment.Generation = tt.generation
+			if got := rolloutHealth(deployment); got != tt.want {
+				t.Errorf("rolloutHealth() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCorrectedStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+		state  deploymentState
+		want   string
+	}{
+		{
+			name:   "successful and healthy",
+			status: "success",
+			state:  deploymentState{health: HealthHealthy, running: true},
+		},
+		{
+			name:   "successful but still progressing",
+			status: "success",
+			state:  deploymentState{health: HealthProgressing, running: true},
+		},
+		{
+			name:   "successful past the progress deadline",
+			status: "success",
+			state:  deploymentState{health: HealthDegraded, running: true, failures: []string{"rollout of production/shop exceeded its progress deadline"}},
+			want:   "failed",
+		},
+		{
+			name:   "successful and scaled to zero",
+			status: "success",
+			state:  deploymentState{health: HealthStopped, running: true},
+		},
+		{
+			name:   "failed but rolled out",
+			status: "failed",
+			state:  deploymentState{health: HealthHealthy, running: true},
+			want:   "success",
+		},
+		{
+			name:   "failed and rolled out by another deployment",
+			status: "failed",
+			state:  deploymentState{health: HealthHealthy},
+		},
+		{
+			name:   "failed and still progressing",
+			status: "failed",
+			state:  deploymentState{health: HealthProgressing, running: true},
+		},
+		{
+			name:   "failed and scaled to zero",
+			status: "failed",
+			state:  deploymentState{health: HealthStopped, running: true},
+		},
+		{
+			name:   "failed and missing",
+			status: "failed",
+			state:  deploymentState{health: HealthMissing},
+		},
+		{
+			name:   "failed and out of sync",
+			status: "failed",
+			state:  deploymentState{health: HealthHealthy, running: true, syncStatus: "OutOfSync"},
+		},
+		{
+			name:   "failed, rolled out and synced",
+			status: "failed",
+			state:  deploymentState{health: HealthHealthy, running: true, syncStatus: "Synced"},
+			want:   "success",
+		},
+		{
+			name:   "failed with failures",
+			status: "failed",
+			state:  deploymentState{health: HealthHealthy, running: true, failures: []string{"Helm release shop-production failed"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deployment := &models.Deployment{Status: tt.status}
+			if got := correctedStatus(deployment, &tt.state); got != tt.want {
+				t.Errorf("correctedStatus() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
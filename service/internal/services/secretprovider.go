@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/plate/service/internal/config"
+	"github.com/plate/service/internal/models"
+)
+
+// SecretProvider reads secrets from an external store, for the env var
+// references with its scheme.
+type SecretProvider interface {
+	// Scheme is the scheme of the references the provider resolves, such
+	// as vault.
+	Scheme() string
+	// Read returns the key/value pairs stored at path.
+	Read(ctx context.Context, path string) (map[string]string, error)
+}
+
+// VaultProvider reads secrets from a HashiCorp Vault KV version 2 engine.
+// A reference path starts with the mount of the engine, as in the Vault
+// CLI: vault://secret/apps/shop#db_password reads the db_password key of
+// apps/shop in the engine mounted at secret.
+type VaultProvider struct {
+	config config.Vault
+	client *http.Client
+}
+
+func NewVaultProvider(cfg config.Vault) *VaultProvider {
+	return &VaultProvider{
+		config: cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *VaultProvider) Scheme() string {
+	return "vault"
+}
+
+func (p *VaultProvider) Read(ctx context.Context, path string) (map[string]string, error) {
+	mount, name, ok := strings.Cut(strings.Trim(path, "/"), "/")
+	if !ok || name == "" {
+		return nil, fmt.Errorf("vault path %q must start with the mount of a KV engine, such as secret/%s", path, path)
+	}
+
+	endpoint := fmt.Sprintf("%s/v1/%s/data/%s", strings.TrimSuffix(p.config.Address, "/"), url.PathEscape(mount), escapePath(name))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", p.config.Token)
+	if p.config.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.config.Namespace)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s from vault: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("vault has no secret at %s", path)
+	case http.StatusForbidden:
+		return nil, fmt.Errorf("vault denied access to %s", path)
+	default:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("vault returned %d for %s: %s", resp.StatusCode, path, strings.TrimSpace(string(body)))
+	}
+
+	var secret struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return nil, fmt.Errorf("invalid vault response for %s: %w", path, err)
+	}
+
+	// KV values may be any JSON; non-strings are passed on as JSON
+	values := make(map[string]string, len(secret.Data.Data))
+	for key, value := range secret.Data.Data {
+		if text, ok := value.(string); ok {
+			values[key] = text
+			continue
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		values[key] = string(data)
+	}
+	return values, nil
+}
+
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// SecretReferences returns the env vars whose values reference external
+// secrets, by name.
+func (s *DeploymentSettings) SecretReferences() (map[string]models.SecretReference, error) {
+	references := map[string]models.SecretReference{}
+	for name, value := range s.EnvVars {
+		reference, ok, err := models.ParseSecretReference(value)
+		if err != nil {
+			return nil, fmt.Errorf("env var %s: %w", name, err)
+		}
+		if ok {
+			references[name] = reference
+		}
+	}
+	return references, nil
+}
+
+// RegisterProvider makes references with the provider's scheme resolvable.
+func (s *SecretService) RegisterProvider(provider SecretProvider) {
+	if s.providers == nil {
+		s.providers = map[string]SecretProvider{}
+	}
+	s.providers[provider.Scheme()] = provider
+}
+
+// ReadReferences reads the values of external secret references, keyed
+// like references. Each path is read once.
+func (s *SecretService) ReadReferences(ctx context.Context, references map[string]models.SecretReference) (map[string][]byte, error) {
+	read := map[string]map[string]string{}
+	values := make(map[string][]byte, len(references))
+
+	for name, reference := range references {
+		provider, ok := s.providers[reference.Scheme]
+		if !ok {
+			return nil, fmt.Errorf("env var %s references %s, but no %s secret provider is configured", name, reference, reference.Scheme)
+		}
+
+		source := reference.Scheme + "://" + reference.Path
+		data, ok := read[source]
+		if !ok {
+			var err error
+			data, err = provider.Read(ctx, reference.Path)
+			if err != nil {
+				return nil, fmt.Errorf("env var %s: %w", name, err)
+			}
+			read[source] = data
+		}
+
+		value, ok := data[reference.Key]
+		if !ok {
+			return nil, fmt.Errorf("env var %s: %s has no key %s", name, source, reference.Key)
+		}
+		values[name] = []byte(value)
+	}
+
+	return values, nil
+}
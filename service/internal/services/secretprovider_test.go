@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/plate/service/internal/config"
+	"github.com/plate/service/internal/models"
+)
+
+// newVaultTestServer stands in for Vault, answering GET requests with
+// canned responses by escaped path. It counts the requests by path.
+func newVaultTestServer(t *testing.T, responses map[string]gitTestResponse) (*httptest.Server, map[string]int) {
+	var mu sync.Mutex
+	requests := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.EscapedPath()
+		mu.Lock()
+		requests[path]++
+		mu.Unlock()
+
+		if r.Method != http.MethodGet {
+			t.Errorf("%s %s: want GET", r.Method, path)
+		}
+		if got := r.Header.Get("X-Vault-Token"); got != "vault-token" {
+			t.Errorf("%s: X-Vault-Token = %q", path, got)
+		}
+		if got := r.Header.Get("X-Vault-Namespace"); got != "team" {
+			t.Errorf("%s: X-Vault-Namespace = %q", path, got)
+		}
+
+		response, ok := responses[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"errors":[]}`)
+			return
+		}
+		if response.status == 0 {
+			response.status = http.StatusOK
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(response.status)
+		io.WriteString(w, response.body)
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+func vaultTestProvider(address string) *VaultProvider {
+	return NewVaultProvider(config.Vault{Address: address + "/", Token: "vault-token", Namespace: "team"})
+}
+
+const vaultTestSecret = `{
+	"data": {
+		"data": {"db_password": "hunter2", "port": 5432, "tls": true, "hosts": ["a", "b"]},
+		"metadata": {"version": 3}
+	}
+}`
+
+func TestVaultProviderRead(t *testing.T) {
+	server, requests := newVaultTestServer(t, map[string]gitTestResponse{
+		"/v1/secret/data/apps/shop": {body: vaultTestSecret},
+	})
+	vault := vaultTestProvider(server.URL)
+
+	values, err := vault.Read(context.Background(), "/secret/apps/shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"db_password": "hunter2", "port": "5432", "tls": "true", "hosts": `["a","b"]`}
+	if len(values) != len(want) {
+		t.Errorf("values = %v, want %v", values, want)
+	}
+	for key, value := range want {
+		if values[key] != value {
+			t.Errorf("%s = %q, want %q", key, values[key], value)
+		}
+	}
+	if requests["/v1/secret/data/apps/shop"] != 1 {
+		t.Errorf("requests = %v", requests)
+	}
+}
+
+func TestVaultProviderEscapesPath(t *testing.T) {
+	server, _ := newVaultTestServer(t, map[string]gitTestResponse{
+		"/v1/kv%20team/data/apps/shop%3Fprod": {body: `{"data": {"data": {"token": "abc"}}}`},
+	})
+	vault := vaultTestProvider(server.URL)
+
+	values, err := vault.Read(context.Background(), "kv team/apps/shop?prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values["token"] != "abc" {
+		t.Errorf("values = %v", values)
+	}
+}
+
+func TestVaultProviderErrors(t *testing.T) {
+	server, _ := newVaultTestServer(t, map[string]gitTestResponse{
+		"/v1/secret/data/apps/denied": {status: http.StatusForbidden, body: `{"errors":["permission denied"]}`},
+		"/v1/secret/data/apps/sealed": {status: http.StatusServiceUnavailable, body: `{"errors":["Vault is sealed"]}`},
+		"/v1/secret/data/apps/broken": {body: `not json`},
+	})
+	vault := vaultTestProvider(server.URL)
+
+	tests := map[string]string{
+		"secret/apps/missing": "vault has no secret at secret/apps/missing",
+		"secret/apps/denied":  "vault denied access to secret/apps/denied",
+		"secret/apps/sealed":  `vault returned 503 for secret/apps/sealed: {"errors":["Vault is sealed"]}`,
+		"secret/apps/broken":  "invalid vault response for secret/apps/broken",
+		"shop":                "must start with the mount of a KV engine",
+	}
+	for path, want := range tests {
+		_, err := vault.Read(context.Background(), path)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Read(%q) error = %v, want %q", path, err, want)
+		}
+	}
+}
+
+func TestReadReferences(t *testing.T) {
+	server, requests := newVaultTestServer(t, map[string]gitTestResponse{
+		"/v1/secret/data/apps/shop":   {body: vaultTestSecret},
+		"/v1/secret/data/apps/stripe": {body: `{"data": {"data": {"key": "sk_test"}}}`},
+	})
+	secrets := &SecretService{}
+	secrets.RegisterProvider(vaultTestProvider(server.URL))
+
+	references := map[string]models.SecretReference{}
+	for name, value := range map[string]string{
+		"DB_PASSWORD": "vault://secret/apps/shop#db_password",
+		"DB_PORT":     "vault://secret/apps/shop#port",
+		"STRIPE_KEY":  "vault://secret/apps/stripe#key",
+	} {
+		reference, ok, err := models.ParseSecretReference(value)
+		if !ok || err != nil {
+			t.Fatalf("ParseSecretReference(%q) = %v, %v", value, ok, err)
+		}
+		references[name] = reference
+	}
+
+	values, err := secrets.ReadReferences(context.Background(), references)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"DB_PASSWORD": "hunter2", "DB_PORT": "5432", "STRIPE_KEY": "sk_test"}
+	for name, value := range want {
+		if string(values[name]) != value {
+			t.Errorf("%s = %q, want %q", name, values[name], value)
+		}
+	}
+	if requests["/v1/secret/data/apps/shop"] != 1 || requests["/v1/secret/data/apps/stripe"] != 1 {
+		t.Errorf("every path should be read once: %v", requests)
+	}
+
+	references["API_TOKEN"] = models.SecretReference{Scheme: "vault", Path: "secret/apps/shop", Key: "api_token"}
+	if _, err := secrets.ReadReferences(context.Background(), references); err == nil || !strings.Contains(err.Error(), "has no key api_token") {
+		t.Errorf("missing key: error = %v", err)
+	}
+
+	references = map[string]models.SecretReference{"DB_PASSWORD": {Scheme: "vault", Path: "secret/apps/gone", Key: "db_password"}}
+	if _, err := secrets.ReadReferences(context.Background(), references); err == nil || !strings.Contains(err.Error(), "env var DB_PASSWORD: vault has no secret") {
+		t.Errorf("missing secret: error = %v", err)
+	}
+
+	if _, err := (&SecretService{}).ReadReferences(context.Background(), references); err == nil || !strings.Contains(err.Error(), "no vault secret provider is configured") {
+		t.Errorf("without a provider: error = %v", err)
+	}
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/plate/service/internal/config"
+	"github.com/plate/service/internal/models"
+	"gorm.io/gorm"
+)
+
+// ErrSecretsDisabled is returned when secrets are used without an
+// encryption key configured.
+var ErrSecretsDisabled = errors.New("secrets are not available: no encryption key is configured (secrets.key or PLATE_SECRETS_KEY)")
+
+// SecretService stores project secrets encrypted with AES-256-GCM. Each
+// value is bound to its project, environment and name, so a ciphertext
+// copied to another row does not decrypt.
+type SecretService struct {
+	db   *gorm.DB
+	aead cipher.AEAD // nil without a key
+	// providers resolve references to external secrets, by scheme
+	providers map[string]SecretProvider
+	// externalStore renders references as ExternalSecrets when set
+	externalStore, externalStoreKind string
+}
+
+func NewSecretService(db *gorm.DB, cfg config.Secrets) (*SecretService, error) {
+	service := &SecretService{
+		db:                db,
+		externalStore:     cfg.ExternalStore,
+		externalStoreKind: cfg.ExternalStoreKind,
+	}
+	if cfg.Key == "" {
+		return service, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(cfg.Key)
+	if err != nil {
+		return service, fmt.Errorf("invalid secrets key: %w", err)
+	}
+	if len(key) != 32 {
+		return service, fmt.Errorf("invalid secrets key: must be 32 bytes, got %d", len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return service, fmt.Errorf("invalid secrets key: %w", err)
+	}
+	service.aead, err = cipher.NewGCM(block)
+	if err != nil {
+		return service, fmt.Errorf("invalid secrets key: %w", err)
+	}
+
+	return service, nil
+}
+
+// List returns the secrets of a project without their values. With an
+// environment, it returns the secrets deployed there: the environment's own
+// and the ones for every environment.
+func (s *SecretService) List(projectID uint, environment string) ([]models.Secret, error) {
+	query := s.db.Where("project_id = ?", projectID)
+	if environment != "" {
+		query = query.Where("environment IN ?", []string{"", environment})
+	}
+
+	var secrets []models.Secret
+	err := query.Order("name, environment").Find(&secrets).Error
+	return secrets, err
+}
+
+// Set creates or replaces a secret.
+func (s *SecretService) Set(projectID uint, environment, name, value string) (*models.Secret, error) {
+	if s.aead == nil {
+		return nil, ErrSecretsDisabled
+	}
+
+	secret := models.Secret{ProjectID: projectID, Environment: environment, Name: name}
+	err := s.db.Where("project_id = ? AND environment = ? AND name = ?", projectID, environment, name).
+		First(&secret).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	secret.Value, err = s.encrypt(&secret, []byte(value))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.Save(&secret).Error; err != nil {
+		return nil, fmt.Errorf("failed to save secret %s: %w", name, err)
+	}
+
+	return &secret, nil
+}
+
+// Delete removes a secret, returning gorm.ErrRecordNotFound if it does not
+// exist.
+func (s *SecretService) Delete(projectID uint, environment, name string) error {
+	result := s.db.Where("project_id = ? AND environment = ? AND name = ?", projectID, environment, name).
+		Delete(&models.Secret{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ResolvedSecrets are the decrypted secrets of a project in one
+// environment.
+type ResolvedSecrets struct {
+	Data map[string][]byte
+	// Checksum changes whenever a secret is set or removed, without
+	// revealing the values.
+	Checksum string
+}
+
+// Names returns the secret names in a stable order.
+func (r *ResolvedSecrets) Names() []string {
+	names := make([]string, 0, len(r.Data))
+	for name := range r.Data {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Resolve decrypts the secrets a project is deployed with in environment,
+// with the environment's own secrets taking precedence.
+func (s *SecretService) Resolve(projectID uint, environment string) (*ResolvedSecrets, error) {
+	secrets, err := s.List(projectID, environment)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := &ResolvedSecrets{Data: map[string][]byte{}}
+	if len(secrets) == 0 {
+		return resolved, nil
+	}
+	if s.aead == nil {
+		return nil, ErrSecretsDisabled
+	}
+
+	// Environment-wide secrets sort first, so specific ones overwrite them
+	sort.SliceStable(secrets, func(i, j int) bool { return secrets[i].Environment < secrets[j].Environment })
+
+	checksum := sha256.New()
+	for i := range secrets {
+		value, err := s.decrypt(&secrets[i])
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt secret %s: %w", secrets[i].Name, err)
+		}
+		resolved.Data[secrets[i].Name] = value
+		fmt.Fprintf(checksum, "%s/%s:", secrets[i].Environment, secrets[i].Name)
+		checksum.Write(secrets[i].Value)
+	}
+	resolved.Checksum = hex.EncodeToString(checksum.Sum(nil))
+
+	return resolved, nil
+}
+
+// ExternalStore returns the External Secrets Operator store references are
+// synced from, or an empty name if Plate reads them itself.
+func (s *SecretService) ExternalStore() (name, kind string) {
+	return s.externalStore, s.externalStoreKind
+}
+
+// addExternal adds the values read for external secret references. Their
+// hashes go into the checksum, so the pods restart when one is rotated.
+func (r *ResolvedSecrets) addExternal(values map[string][]byte) {
+	if len(values) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	checksum := sha256.New()
+	checksum.Write([]byte(r.Checksum))
+	for _, name := range names {
+		r.Data[name] = values[name]
+		value := sha256.Sum256(values[name])
+		fmt.Fprintf(checksum, "external/%s:%x", name, value)
+	}
+	r.Checksum = hex.EncodeToString(checksum.Sum(nil))
+}
+
+func (s *SecretService) encrypt(secret *models.Secret, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return s.aead.Seal(nonce, nonce, plaintext, associatedData(secret)), nil
+}
+
+func (s *SecretService) decrypt(secret *models.Secret) ([]byte, error) {
+	size := s.aead.NonceSize()
+	if len(secret.Value) < size {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := secret.Value[:size], secret.Value[size:]
+	return s.aead.Open(nil, nonce, ciphertext, associatedData(secret))
+}
+
+func associatedData(secret *models.Secret) []byte {
+	return []byte(fmt.Sprintf("project=%d;environment=%s;name=%s", secret.ProjectID, secret.Environment, secret.Name))
+}
@@ -0,0 +1,238 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/plate/service/internal/models"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// DeploymentSettings are the settings a project is deployed with in one
+// environment, after merging its overrides for that environment over the
+// project's base settings.
+type DeploymentSettings struct {
+	Replicas    int
+	Size        string
+	Resources   models.Resources
+	EnvVars     map[string]string
+	Host        string // empty if the project is not exposed
+	HealthCheck models.HealthCheck
+	Autoscaling models.Autoscaling
+}
+
+// ResolveSettings merges the project's overrides for environment over its
+// base settings and fills in defaults.
+func ResolveSettings(project *models.Project, environment *models.Environment) (*DeploymentSettings, error) {
+	settings := &DeploymentSettings{
+		Replicas:    project.Replicas,
+		Size:        project.Size,
+		Resources:   project.Resources,
+		EnvVars:     map[string]string{},
+		HealthCheck: project.HealthCheck,
+		Autoscaling: project.Autoscaling,
+	}
+
+	if project.EnvVars != "" {
+		if err := json.Unmarshal([]byte(project.EnvVars), &settings.EnvVars); err != nil {
+			return nil, fmt.Errorf("invalid env vars of project %s: %w", project.Name, err)
+		}
+	}
+
+	domain := project.Domain
+	if environment.Preview {
+		// Custom domains belong to the long-lived environments
+		domain = ""
+	}
+	if override, ok := project.Environments[environment.Name]; ok {
+		if override.Replicas != nil {
+			settings.Replicas = *override.Replicas
+		}
+		if override.Size != "" {
+			// A different size replaces the project's explicit resources
+			settings.Size = override.Size
+			settings.Resources = models.Resources{}
+		}
+		if override.Resources != nil {
+			settings.Resources = mergeResources(settings.Resources, *override.Resources)
+		}
+		for name, value := range override.EnvVars {
+			settings.EnvVars[name] = value
+		}
+		if override.Domain != "" {
+			domain = override.Domain
+		}
+		if override.HealthCheck != nil {
+			settings.HealthCheck = mergeHealthCheck(settings.HealthCheck, *override.HealthCheck)
+		}
+		if override.Autoscaling != nil {
+			settings.Autoscaling = mergeAutoscaling(settings.Autoscaling, *override.Autoscaling)
+		}
+	}
+
+	// Explicit resources are applied over the sizing preset
+	if settings.Size == "" {
+		settings.Size = models.DefaultSize
+	}
+	preset, ok := models.SizePresets[settings.Size]
+	if !ok {
+		return nil, fmt.Errorf("unknown size %q for project %s", settings.Size, project.Name)
+	}
+	settings.Resources = mergeResources(preset, settings.Resources)
+
+	// Raising a request above the preset limit must not produce a
+	// container Kubernetes rejects
+	settings.Resources.Limits.CPU = atLeast(settings.Resources.Limits.CPU, settings.Resources.Requests.CPU)
+	settings.Resources.Limits.Memory = atLeast(settings.Resources.Limits.Memory, settings.Resources.Requests.Memory)
+
+	if settings.Replicas <= 0 {
+		settings.Replicas = 1
+	}
+
+	// The autoscaler starts from the configured replicas
+	if settings.Autoscaling.IsEnabled() {
+		if settings.Autoscaling.MinReplicas <= 0 {
+			settings.Autoscaling.MinReplicas = settings.Replicas
+		}
+		if settings.Autoscaling.MaxReplicas == 0 {
+			return nil, fmt.Errorf("autoscaling of project %s in %s needs max_replicas", project.Name, environment.Name)
+		}
+		if settings.Autoscaling.MaxReplicas < settings.Autoscaling.MinReplicas {
+			return nil, fmt.Errorf("autoscaling of project %s in %s: min_replicas %d exceeds max_replicas %d",
+				project.Name, environment.Name, settings.Autoscaling.MinReplicas, settings.Autoscaling.MaxReplicas)
+		}
+	}
+
+	switch {
+	case domain != "":
+		settings.Host = domain
+	case environment.Domain != "":
+		settings.Host = fmt.Sprintf("%s.%s", project.Name, environment.Domain)
+	}
+
+	return settings, nil
+}
+
+// MinReplicas returns the number of replicas the project runs with at
+// least, which is the autoscaler's minimum when autoscaling is enabled.
+func (s *DeploymentSettings) MinReplicas() int {
+	if s.Autoscaling.IsEnabled() {
+		return s.Autoscaling.MinReplicas
+	}
+	return s.Replicas
+}
+
+// SortedEnvVars returns the env var names in a stable order.
+func (s *DeploymentSettings) SortedEnvVars() []string {
+	names := make([]string, 0, len(s.EnvVars))
+	for name := range s.EnvVars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func mergeResources(base, override models.Resources) models.Resources {
+	return models.Resources{
+		Requests: mergeResourceList(base.Requests, override.Requests),
+		Limits:   mergeResourceList(base.Limits, override.Limits),
+	}
+}
+
+func mergeResourceList(base, override models.ResourceList) models.ResourceList {
+	if override.CPU != "" {
+		base.CPU = override.CPU
+	}
+	if override.Memory != "" {
+		base.Memory = override.Memory
+	}
+	return base
+}
+
+func mergeHealthCheck(base, override models.HealthCheck) models.HealthCheck {
+	if override.Path != "" {
+		base.Path = override.Path
+	}
+	base.Liveness = mergeProbe(base.Liveness, override.Liveness)
+	base.Readiness = mergeProbe(base.Readiness, override.Readiness)
+	base.Startup = mergeProbe(base.Startup, override.Startup)
+	return base
+}
+
+func mergeProbe(base, override *models.Probe) *models.Probe {
+	if override == nil {
+		return base
+	}
+	if base == nil {
+		return override
+	}
+
+	merged := *base
+	if override.Type != "" {
+		merged.Type = override.Type
+	}
+	if override.Path != "" {
+		merged.Path = override.Path
+	}
+	if override.Port != 0 {
+		merged.Port = override.Port
+	}
+	if len(override.Command) > 0 {
+		merged.Command = override.Command
+	}
+	if override.InitialDelaySeconds != 0 {
+		merged.InitialDelaySeconds = override.InitialDelaySeconds
+	}
+	if override.PeriodSeconds != 0 {
+		merged.PeriodSeconds = override.PeriodSeconds
+	}
+	if override.TimeoutSeconds != 0 {
+		merged.TimeoutSeconds = override.TimeoutSeconds
+	}
+	if override.FailureThreshold != 0 {
+		merged.FailureThreshold = override.FailureThreshold
+	}
+	if override.SuccessThreshold != 0 {
+		merged.SuccessThreshold = override.SuccessThreshold
+	}
+	return &merged
+}
+
+func mergeAutoscaling(base, override models.Autoscaling) models.Autoscaling {
+	if override.Enabled != nil {
+		base.Enabled = override.Enabled
+	}
+	if override.MinReplicas != 0 {
+		base.MinReplicas = override.MinReplicas
+	}
+	if override.MaxReplicas != 0 {
+		base.MaxReplicas = override.MaxReplicas
+	}
+	if override.TargetCPUUtilization != 0 {
+		base.TargetCPUUtilization = override.TargetCPUUtilization
+	}
+	if override.TargetMemoryUtilization != 0 {
+		base.TargetMemoryUtilization = override.TargetMemoryUtilization
+	}
+	if len(override.Metrics) > 0 {
+		base.Metrics = override.Metrics
+	}
+	return base
+}
+
+// atLeast returns limit, or request if it is the larger quantity.
+func atLeast(limit, request string) string {
+	limitQuantity, err := resource.ParseQuantity(limit)
+	if err != nil {
+		return limit
+	}
+	requestQuantity, err := resource.ParseQuantity(request)
+	if err != nil {
+		return limit
+	}
+	if requestQuantity.Cmp(limitQuantity) > 0 {
+		return request
+	}
+	return limit
+}